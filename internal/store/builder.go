@@ -18,10 +18,15 @@ package store
 
 import (
 	"context"
+	"math"
+	"path"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -36,13 +41,16 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	policy "k8s.io/api/policy/v1beta1"
 	storagev1 "k8s.io/api/storage/v1"
-	vpaautoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	vpaautoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 	vpaclientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
 	ksmtypes "k8s.io/kube-state-metrics/v2/pkg/builder/types"
+	"k8s.io/kube-state-metrics/v2/pkg/constant"
 	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
 	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
 	"k8s.io/kube-state-metrics/v2/pkg/options"
@@ -54,28 +62,97 @@ import (
 // New Builder methods should be added to the public BuilderInterface.
 var _ ksmtypes.BuilderInterface = &Builder{}
 
+// metricPrefixRE matches valid prefixes of Prometheus metric names.
+var metricPrefixRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
 // Builder helps to build store. It follows the builder pattern
 // (https://en.wikipedia.org/wiki/Builder_pattern).
 type Builder struct {
-	kubeClient           clientset.Interface
-	vpaClient            vpaclientset.Interface
-	namespaces           options.NamespaceList
-	ctx                  context.Context
-	enabledResources     []string
-	allowDenyList        ksmtypes.AllowDenyLister
-	listWatchMetrics     *watch.ListWatchMetrics
-	shardingMetrics      *sharding.Metrics
-	shard                int32
-	totalShards          int
-	buildStoresFunc      ksmtypes.BuildStoresFunc
-	allowAnnotationsList map[string][]string
-	allowLabelsList      map[string][]string
-	useAPIServerCache    bool
+	kubeClient               clientset.Interface
+	vpaClient                vpaclientset.Interface
+	namespaces               options.NamespaceList
+	fieldSelectorFilter      string
+	fieldSelectors           map[string]string
+	objectNames              map[string]string
+	objectLabelSelector      string
+	metricPrefix             string
+	ctx                      context.Context
+	enabledResources         []string
+	allowDenyList            ksmtypes.AllowDenyLister
+	listWatchMetrics         *watch.ListWatchMetrics
+	shardingMetrics          *sharding.Metrics
+	shard                    int32
+	shardBy                  string
+	totalShards              int
+	buildStoresFunc          ksmtypes.BuildStoresFunc
+	allowAnnotationsList     map[string][]string
+	allowLabelsList          map[string][]string
+	defaultLabels            map[string][]string
+	enableUIDLabel           bool
+	resourceVersionLabel     bool
+	objectSeriesCount        bool
+	familyOverrides          map[string]generator.FamilyOverride
+	labelRenames             map[string]string
+	metricFilter             generator.MetricFilter
+	dropZeroStateSet         bool
+	useAPIServerCache        bool
+	vpaTargetKinds           map[string]struct{}
+	vpaZeroMissingResources  bool
+	vpaPreciseCPU            bool
+	vpaMemoryUnit            constant.ResourceUnit
+	vpaRecommenderAnnotation string
+	vpaRecommenderLabel      string
+	vpaRecommendationSums    bool
+	vpaUpdateModeCount       bool
+	vpaNamespaceCount        bool
+	vpaRecommendationBuckets []float64
+	vpaNativeHistogram       bool
+	vpaRecommendationBounds  string
+	vpaOwnerReferences       bool
+	vpaContainerDenylist     []string
+	vpaObservedContainers    string
+	legacyMetricAliases      bool
+	maxSeries                int
+	seriesBudget             *metricsstore.SeriesBudget
+	generationConcurrency    int
+	storeQueueDepth          int
+	vpaAggregates            *vpaRecommendationAggregates
+	vpaTargetReplicasEnabled bool
+	vpaOmitEmptyLabels       bool
+	vpaSkipOffModeRecs       bool
+	clusterName              string
+	vpaTargetReplicas        *vpaTargetReplicas
+	vpaHPAConflictEnabled    bool
+	vpaHPAConflicts          *vpaHPAConflicts
+	requestTimeout           time.Duration
+}
+
+// defaultLabelsOverrides tells for the resources whose default labels can be
+// overridden whether a label is known.
+var defaultLabelsOverrides = map[string]func(label string) bool{
+	"verticalpodautoscalers": isVPADefaultLabel,
+}
+
+// legacyMetricAliases maps the names of renamed metric families to their
+// former names, which are exposed as well with --enable-legacy-metric-aliases.
+// None of the VerticalPodAutoscaler families has been renamed.
+var legacyMetricAliases = map[string]string{
+	"kube_horizontalpodautoscaler_labels":                  "kube_hpa_labels",
+	"kube_horizontalpodautoscaler_metadata_generation":     "kube_hpa_metadata_generation",
+	"kube_horizontalpodautoscaler_spec_max_replicas":       "kube_hpa_spec_max_replicas",
+	"kube_horizontalpodautoscaler_spec_min_replicas":       "kube_hpa_spec_min_replicas",
+	"kube_horizontalpodautoscaler_spec_target_metric":      "kube_hpa_spec_target_metric",
+	"kube_horizontalpodautoscaler_status_condition":        "kube_hpa_status_condition",
+	"kube_horizontalpodautoscaler_status_current_replicas": "kube_hpa_status_current_replicas",
+	"kube_horizontalpodautoscaler_status_desired_replicas": "kube_hpa_status_desired_replicas",
+	"kube_pod_deletion_timestamp":                          "kube_pod_deleted",
 }
 
 // NewBuilder returns a new builder.
 func NewBuilder() *Builder {
-	b := &Builder{}
+	b := &Builder{
+		metricPrefix: generator.DefaultMetricPrefix,
+	}
 	return b
 }
 
@@ -83,6 +160,7 @@ func NewBuilder() *Builder {
 func (b *Builder) WithMetrics(r prometheus.Registerer) {
 	b.listWatchMetrics = watch.NewListWatchMetrics(r)
 	b.shardingMetrics = sharding.NewShardingMetrics(r)
+	registerStoreMetrics(r)
 }
 
 // WithEnabledResources sets the enabledResources property of a Builder.
@@ -107,6 +185,99 @@ func (b *Builder) WithNamespaces(n options.NamespaceList) {
 	b.namespaces = n
 }
 
+// WithFieldSelectorFilter sets the fieldSelector property of a Builder.
+// It is applied to the list and watch requests of all namespaced resources.
+func (b *Builder) WithFieldSelectorFilter(fieldSelectorFilter string) {
+	b.fieldSelectorFilter = fieldSelectorFilter
+}
+
+// WithObjectLabelSelector sets the label selector of the list and watch
+// requests of all resources, so that only the metrics of the matching objects
+// are exposed.
+func (b *Builder) WithObjectLabelSelector(selector string) error {
+	if _, err := labels.Parse(selector); err != nil {
+		return errors.Wrapf(err, "invalid object label selector %q", selector)
+	}
+	b.objectLabelSelector = selector
+	return nil
+}
+
+// WithMetricPrefix sets the prefix that replaces the default "kube_" prefix of
+// all metric family names.
+func (b *Builder) WithMetricPrefix(prefix string) error {
+	if prefix != "" && !metricPrefixRE.MatchString(prefix) {
+		return errors.Errorf("invalid metric prefix %q, must match %s", prefix, metricPrefixRE)
+	}
+	b.metricPrefix = prefix
+	return nil
+}
+
+// WithRequestTimeout sets the timeout of list requests against the apiserver.
+// A timeout of zero disables it.
+func (b *Builder) WithRequestTimeout(timeout time.Duration) {
+	b.requestTimeout = timeout
+}
+
+// WithUIDLabel sets whether all metrics carry a uid label holding the UID of
+// the object they were generated from.
+func (b *Builder) WithUIDLabel(enabled bool) {
+	b.enableUIDLabel = enabled
+}
+
+// WithResourceVersionLabel sets whether all metrics carry a resource_version
+// label holding the resource version of the object they were generated from.
+// It is meant for debugging only, as every update of an object creates new
+// series.
+func (b *Builder) WithResourceVersionLabel(enabled bool) {
+	b.resourceVersionLabel = enabled
+}
+
+// WithObjectSeriesCount sets whether the number of series of each object is
+// reported in kube_state_metrics_object_series_count.
+func (b *Builder) WithObjectSeriesCount(enabled bool) {
+	b.objectSeriesCount = enabled
+}
+
+// WithFamilyOverrides sets the help texts and units overriding the ones of the
+// metric families, by the name of the metric family.
+func (b *Builder) WithFamilyOverrides(overrides map[string]generator.FamilyOverride) {
+	b.familyOverrides = overrides
+}
+
+// WithLabelRenames sets the new keys of the labels of all metric families, by
+// the keys they replace. Renaming two labels to the same key is rejected.
+func (b *Builder) WithLabelRenames(renames map[string]string) error {
+	if err := generator.ValidateLabelRenames(renames); err != nil {
+		return err
+	}
+	b.labelRenames = renames
+	return nil
+}
+
+// WithMetricFilter sets the filter deciding which series of the metric
+// families are exposed. It is applied to the series once they carry all their
+// labels.
+func (b *Builder) WithMetricFilter(filter generator.MetricFilter) {
+	b.metricFilter = filter
+}
+
+// WithDropZeroStateSet sets whether only the active series of the families
+// exposing a state set are exposed, dropping the ones set to 0.
+func (b *Builder) WithDropZeroStateSet(enabled bool) {
+	b.dropZeroStateSet = enabled
+}
+
+// WithMaxLabelValueLength sets the maximum length in bytes of the values of
+// the labels converted from Kubernetes labels and annotations. Longer values
+// are truncated and end with "...". A length of 0 disables it.
+func (b *Builder) WithMaxLabelValueLength(length int) error {
+	if length != 0 && length <= len(truncatedLabelValueSuffix) {
+		return errors.Errorf("invalid maximum label value length %d, must be 0 or greater than %d", length, len(truncatedLabelValueSuffix))
+	}
+	atomic.StoreInt64(&maxLabelValueLength, int64(length))
+	return nil
+}
+
 // WithSharding sets the shard and totalShards property of a Builder.
 func (b *Builder) WithSharding(shard int32, totalShards int) {
 	b.shard = shard
@@ -115,6 +286,18 @@ func (b *Builder) WithSharding(shard int32, totalShards int) {
 	b.shardingMetrics.Ordinal.With(labels).Set(float64(shard))
 	b.totalShards = totalShards
 	b.shardingMetrics.Total.Set(float64(totalShards))
+	b.shardingMetrics.Ownership.Reset()
+}
+
+// WithShardBy sets the key objects are assigned to shards by, either
+// sharding.ByUID or sharding.ByNamespace.
+func (b *Builder) WithShardBy(by string) error {
+	switch by {
+	case sharding.ByUID, sharding.ByNamespace:
+		b.shardBy = by
+		return nil
+	}
+	return errors.Errorf("invalid shard key %q, must be one of %s or %s", by, sharding.ByUID, sharding.ByNamespace)
 }
 
 // WithContext sets the ctx property of a Builder.
@@ -132,6 +315,241 @@ func (b *Builder) WithVPAClient(c vpaclientset.Interface) {
 	b.vpaClient = c
 }
 
+// WithVPAZeroMissingResources sets whether the container recommendations of
+// VerticalPodAutoscalers always expose cpu and memory, as zero if missing.
+func (b *Builder) WithVPAZeroMissingResources(enabled bool) {
+	b.vpaZeroMissingResources = enabled
+}
+
+// WithVPAPreciseCPU sets whether the cpu resources of VerticalPodAutoscalers
+// are exposed without rounding them to millicores.
+func (b *Builder) WithVPAPreciseCPU(enabled bool) {
+	b.vpaPreciseCPU = enabled
+}
+
+// WithVPAMemoryUnit sets the unit the resources of VerticalPodAutoscalers
+// measured in bytes are exposed in, either byte or mebibyte.
+func (b *Builder) WithVPAMemoryUnit(unit string) error {
+	switch u := constant.ResourceUnit(unit); u {
+	case constant.UnitByte, constant.UnitMebibyte:
+		b.vpaMemoryUnit = u
+		return nil
+	}
+	return errors.Errorf("unknown memory unit %q, must be one of %s, %s", unit, constant.UnitByte, constant.UnitMebibyte)
+}
+
+// WithVPARecommenderAnnotation sets the annotation of VerticalPodAutoscalers
+// holding the recommendations of each recommender.
+func (b *Builder) WithVPARecommenderAnnotation(annotation string) {
+	b.vpaRecommenderAnnotation = annotation
+}
+
+// WithMaxSeries limits the number of series held by all stores to max. Once
+// exceeded, the metric families which do not fit anymore are dropped and
+// counted in kube_state_metrics_series_dropped_total. A max of 0 disables the
+// limit.
+func (b *Builder) WithMaxSeries(max int) error {
+	if max < 0 {
+		return errors.Errorf("maximum number of series must not be negative, got %d", max)
+	}
+	b.maxSeries = max
+	return nil
+}
+
+// WithStoreQueueDepth queues the updates of each store to be applied by a
+// worker of its own, coalescing the updates of objects which are still
+// queued. Once depth objects are queued, the reflector of the store blocks
+// until the worker caught up. A depth of 0 applies the updates right away.
+func (b *Builder) WithStoreQueueDepth(depth int) error {
+	if depth < 0 {
+		return errors.Errorf("store queue depth must not be negative, got %d", depth)
+	}
+	b.storeQueueDepth = depth
+	return nil
+}
+
+// WithClusterName sets the name of the cluster all metrics carry as cluster
+// label. No label is added if empty.
+func (b *Builder) WithClusterName(name string) {
+	b.clusterName = name
+}
+
+// WithVPAOmitEmptyDefaultLabels sets whether the default labels of the
+// VerticalPodAutoscaler metrics are dropped if their value is empty.
+func (b *Builder) WithVPAOmitEmptyDefaultLabels(enabled bool) {
+	b.vpaOmitEmptyLabels = enabled
+}
+
+// WithVPASkipOffModeRecommendations sets whether the container
+// recommendations of VerticalPodAutoscalers in Off mode are dropped.
+func (b *Builder) WithVPASkipOffModeRecommendations(enabled bool) {
+	b.vpaSkipOffModeRecs = enabled
+}
+
+// WithVPATargetReplicas sets whether the current number of replicas of the
+// workloads targeted by VerticalPodAutoscalers is exposed. It is resolved
+// from the stores of the workloads, so only targets whose resource is enabled
+// as well are exposed, and requires the workloads to be handled by the same
+// shard as their VerticalPodAutoscalers.
+func (b *Builder) WithVPATargetReplicas(enabled bool) {
+	b.vpaTargetReplicasEnabled = enabled
+}
+
+// newVPATargetReplicas returns the metrics writer of the replicas of the
+// workloads targeted by VerticalPodAutoscalers, nil if they are not exposed.
+func (b *Builder) newVPATargetReplicas() *vpaTargetReplicas {
+	if !b.vpaTargetReplicasEnabled || !resourceEnabled(b.enabledResources, "verticalpodautoscalers") {
+		return nil
+	}
+	r := newVPATargetReplicas(b.defaultLabels["verticalpodautoscalers"], b.vpaRecommenderLabel, b.vpaOmitEmptyLabels)
+	families := b.storeMetricFamilies(r.families)
+	if len(families) == 0 {
+		return nil
+	}
+	r.withMetricsStores(func() *metricsstore.MetricsStore {
+		return b.newMetricsStore(families, &vpaautoscaling.VerticalPodAutoscaler{})
+	})
+	return r
+}
+
+// WithVPAHPAConflict sets whether VerticalPodAutoscalers whose target is
+// scaled by a HorizontalPodAutoscaler on a resource they control are
+// exposed. It is resolved from the stores of the HorizontalPodAutoscalers, so
+// nothing is exposed unless their resource is enabled as well.
+func (b *Builder) WithVPAHPAConflict(enabled bool) {
+	b.vpaHPAConflictEnabled = enabled
+}
+
+// newVPAHPAConflicts returns the metrics writer of the conflicts between
+// VerticalPodAutoscalers and HorizontalPodAutoscalers, nil if they are not
+// exposed.
+func (b *Builder) newVPAHPAConflicts() *vpaHPAConflicts {
+	if !b.vpaHPAConflictEnabled || !resourceEnabled(b.enabledResources, "verticalpodautoscalers") ||
+		!resourceEnabled(b.enabledResources, "horizontalpodautoscalers") {
+		return nil
+	}
+	c := newVPAHPAConflicts(b.defaultLabels["verticalpodautoscalers"], b.vpaRecommenderLabel, b.vpaOmitEmptyLabels)
+	families := b.storeMetricFamilies(c.families)
+	if len(families) == 0 {
+		return nil
+	}
+	c.withMetricsStores(func() *metricsstore.MetricsStore {
+		return b.newMetricsStore(families, &vpaautoscaling.VerticalPodAutoscaler{})
+	})
+	return c
+}
+
+// WithGenerationConcurrency sets the number of workers generating the metrics
+// of the objects of a store when its reflector lists them.
+func (b *Builder) WithGenerationConcurrency(workers int) error {
+	if workers < 1 {
+		return errors.Errorf("generation concurrency must be at least 1, got %d", workers)
+	}
+	b.generationConcurrency = workers
+	return nil
+}
+
+// WithLegacyMetricAliases sets whether renamed metric families are exposed
+// under their former names as well.
+func (b *Builder) WithLegacyMetricAliases(enabled bool) {
+	b.legacyMetricAliases = enabled
+}
+
+// WithVPAObservedContainersAnnotation sets the annotation of
+// VerticalPodAutoscalers listing the containers the recommender has observed.
+func (b *Builder) WithVPAObservedContainersAnnotation(annotation string) {
+	b.vpaObservedContainers = annotation
+}
+
+// WithVPARecommenderLabel sets the annotation of VerticalPodAutoscalers whose
+// value all their metrics carry as recommender label. No label is added if
+// empty.
+func (b *Builder) WithVPARecommenderLabel(annotation string) {
+	b.vpaRecommenderLabel = annotation
+}
+
+// WithVPARecommendationSums sets whether the targets recommended across all
+// VerticalPodAutoscalers are exposed as sums.
+func (b *Builder) WithVPARecommendationSums(enabled bool) {
+	b.vpaRecommendationSums = enabled
+}
+
+// WithVPAUpdateModeCount sets whether the number of VerticalPodAutoscalers
+// in each update mode is exposed.
+func (b *Builder) WithVPAUpdateModeCount(enabled bool) {
+	b.vpaUpdateModeCount = enabled
+}
+
+// WithVPANamespaceCount sets whether the number of VerticalPodAutoscalers in
+// each namespace is exposed.
+func (b *Builder) WithVPANamespaceCount(enabled bool) {
+	b.vpaNamespaceCount = enabled
+}
+
+// WithVPARecommendationBuckets sets the upper bounds of the buckets the cpu
+// targets recommended across all VerticalPodAutoscalers are observed in. No
+// histogram is exposed if empty.
+func (b *Builder) WithVPARecommendationBuckets(cpuBuckets []float64) error {
+	buckets := append([]float64{}, cpuBuckets...)
+	sort.Float64s(buckets)
+	for i, upperBound := range buckets {
+		if math.IsNaN(upperBound) || math.IsInf(upperBound, 0) {
+			return errors.Errorf("invalid bucket %v, must be a finite number", upperBound)
+		}
+		if i > 0 && buckets[i-1] == upperBound {
+			return errors.Errorf("duplicate bucket %v", upperBound)
+		}
+	}
+	b.vpaRecommendationBuckets = buckets
+	return nil
+}
+
+// WithVPARecommendationNativeHistogram sets whether the histogram of the cpu
+// targets recommended across all VerticalPodAutoscalers is exposed as a
+// native histogram as well to clients negotiating the protobuf format.
+func (b *Builder) WithVPARecommendationNativeHistogram(enabled bool) {
+	b.vpaNativeHistogram = enabled
+}
+
+// WithVPARecommendationBounds sets the families exposing the bounds of the
+// container recommendations of VerticalPodAutoscalers, one of separate,
+// consolidated or both.
+func (b *Builder) WithVPARecommendationBounds(bounds string) error {
+	switch bounds {
+	case vpaRecommendationBoundsSeparate, vpaRecommendationBoundsConsolidated, vpaRecommendationBoundsBoth:
+		b.vpaRecommendationBounds = bounds
+		return nil
+	}
+	return errors.Errorf("unknown recommendation bounds %q, must be one of %s, %s, %s", bounds, vpaRecommendationBoundsSeparate, vpaRecommendationBoundsConsolidated, vpaRecommendationBoundsBoth)
+}
+
+// WithVPAOwnerReferences sets whether the owner references of
+// VerticalPodAutoscalers are exposed.
+func (b *Builder) WithVPAOwnerReferences(enabled bool) {
+	b.vpaOwnerReferences = enabled
+}
+
+// WithVPAContainerDenylist configures the containers no recommendations are
+// exposed for, by exact name or glob pattern as understood by path.Match.
+func (b *Builder) WithVPAContainerDenylist(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid container pattern %q", pattern)
+		}
+	}
+	b.vpaContainerDenylist = patterns
+	return nil
+}
+
+// WithVPATargetKinds configures the target kinds of the VerticalPodAutoscalers
+// to expose metrics for. All VerticalPodAutoscalers are exposed if empty.
+func (b *Builder) WithVPATargetKinds(kinds []string) {
+	b.vpaTargetKinds = make(map[string]struct{}, len(kinds))
+	for _, kind := range kinds {
+		b.vpaTargetKinds[kind] = struct{}{}
+	}
+}
+
 // WithAllowDenyList configures the allow or denylisted metric to be exposed
 // by the store build by the Builder.
 func (b *Builder) WithAllowDenyList(l ksmtypes.AllowDenyLister) {
@@ -152,6 +570,7 @@ func (b *Builder) DefaultGenerateStoresFunc() ksmtypes.BuildStoresFunc {
 // WithAllowAnnotations configures which annotations can be returned for metrics
 func (b *Builder) WithAllowAnnotations(annotations map[string][]string) {
 	if len(annotations) > 0 {
+		compileAllowListRegexps(annotations)
 		b.allowAnnotationsList = annotations
 	}
 }
@@ -159,6 +578,7 @@ func (b *Builder) WithAllowAnnotations(annotations map[string][]string) {
 // WithAllowLabels configures which labels can be returned for metrics
 func (b *Builder) WithAllowLabels(labels map[string][]string) {
 	if len(labels) > 0 {
+		compileAllowListRegexps(labels)
 		b.allowLabelsList = labels
 	}
 }
@@ -174,6 +594,18 @@ func (b *Builder) Build() []metricsstore.MetricsWriter {
 	var metricsWriters []metricsstore.MetricsWriter
 	var activeStoreNames []string
 
+	// The stores of a previous build, e.g. before resharding, are abandoned
+	// without returning their series, so each build gets a budget of its own.
+	b.seriesBudget = nil
+	if b.maxSeries > 0 {
+		b.seriesBudget = metricsstore.NewSeriesBudget(b.maxSeries, seriesDroppedTotal)
+	}
+
+	// The workload and HorizontalPodAutoscaler stores built before the
+	// VerticalPodAutoscaler ones already need to be tracked.
+	b.vpaTargetReplicas = b.newVPATargetReplicas()
+	b.vpaHPAConflicts = b.newVPAHPAConflicts()
+
 	for _, c := range b.enabledResources {
 		constructor, ok := availableStores[c]
 		if ok {
@@ -184,6 +616,15 @@ func (b *Builder) Build() []metricsstore.MetricsWriter {
 			} else {
 				metricsWriters = append(metricsWriters, metricsstore.NewMultiStoreMetricsWriter(stores))
 			}
+			if c == "verticalpodautoscalers" && b.vpaAggregates != nil {
+				metricsWriters = append(metricsWriters, b.vpaAggregates)
+			}
+			if c == "verticalpodautoscalers" && b.vpaTargetReplicas != nil {
+				metricsWriters = append(metricsWriters, b.vpaTargetReplicas)
+			}
+			if c == "verticalpodautoscalers" && b.vpaHPAConflicts != nil {
+				metricsWriters = append(metricsWriters, b.vpaHPAConflicts)
+			}
 		}
 	}
 
@@ -192,6 +633,134 @@ func (b *Builder) Build() []metricsstore.MetricsWriter {
 	return metricsWriters
 }
 
+// Catalog returns the metric families exposed for each enabled resource,
+// keyed by resource. The families are prefixed and filtered by the allow and
+// denylist like the ones of the stores returned by Build, but no stores are
+// built and no requests are made to the apiserver.
+func (b *Builder) Catalog() map[string][]generator.FamilyGenerator {
+	catalog := map[string][]generator.FamilyGenerator{}
+
+	for _, c := range b.enabledResources {
+		constructor, ok := availableStores[c]
+		if !ok {
+			continue
+		}
+		resource := c
+		catalogBuilder := *b
+		catalogBuilder.buildStoresFunc = func(
+			metricFamilies []generator.FamilyGenerator,
+			_ interface{},
+			_ func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher,
+			_ bool,
+		) []*metricsstore.MetricsStore {
+			catalog[resource] = b.effectiveMetricFamilies(metricFamilies)
+			return nil
+		}
+		constructor(&catalogBuilder)
+		if resource == "verticalpodautoscalers" && catalogBuilder.vpaAggregates != nil {
+			catalog[resource] = append(catalog[resource], catalogBuilder.vpaAggregates.families...)
+		}
+		if resource == "verticalpodautoscalers" {
+			if targetReplicas := b.newVPATargetReplicas(); targetReplicas != nil {
+				catalog[resource] = append(catalog[resource], b.effectiveMetricFamilies(targetReplicas.families)...)
+			}
+			if conflicts := b.newVPAHPAConflicts(); conflicts != nil {
+				catalog[resource] = append(catalog[resource], b.effectiveMetricFamilies(conflicts.families)...)
+			}
+		}
+	}
+
+	return catalog
+}
+
+// ValidateLabelRenames checks that no label rename collides with another
+// label of a series of the metric families of the enabled resources, i.e.
+// that no label is renamed to the key of a label the series has already. The
+// series are generated from probe objects, so the keys of the labels
+// converted from the labels and annotations of the objects are not checked.
+func (b *Builder) ValidateLabelRenames() error {
+	if len(b.labelRenames) == 0 {
+		return nil
+	}
+
+	// The families are checked before their labels are renamed, and none of
+	// their series is filtered out.
+	probe := *b
+	probe.labelRenames = nil
+	probe.metricFilter = nil
+
+	var err error
+	validate := func(families []generator.FamilyGenerator, obj interface{}) {
+		for _, f := range families {
+			if err == nil {
+				err = validateRenamedLabelKeys(b.labelRenames, f, obj)
+			}
+		}
+	}
+	for _, c := range b.enabledResources {
+		constructor, ok := availableStores[c]
+		if !ok {
+			continue
+		}
+		probeBuilder := probe
+		probeBuilder.buildStoresFunc = func(
+			metricFamilies []generator.FamilyGenerator,
+			expectedType interface{},
+			_ func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher,
+			_ bool,
+		) []*metricsstore.MetricsStore {
+			validate(probe.storeMetricFamilies(metricFamilies), probeObject(expectedType))
+			return nil
+		}
+		constructor(&probeBuilder)
+		if c != "verticalpodautoscalers" {
+			continue
+		}
+
+		a := probeObject(&vpaautoscaling.VerticalPodAutoscaler{}).(*vpaautoscaling.VerticalPodAutoscaler)
+		target, _ := vpaTargetOf(a)
+		if probeBuilder.vpaAggregates != nil {
+			validate(probeBuilder.vpaAggregates.families, vpaTargets{
+				cpuCores:    []float64{1},
+				memoryBytes: []float64{1},
+				updateModes: []string{string(vpaautoscaling.UpdateModeAuto)},
+				namespaces:  []string{a.Namespace},
+			})
+		}
+		if targetReplicas := probe.newVPATargetReplicas(); targetReplicas != nil {
+			targetReplicas.workloads = []*vpaWorkloadReplicasTracker{{replicas: map[vpaWorkload]int32{target: 1}}}
+			validate(probe.storeMetricFamilies(targetReplicas.families), a)
+		}
+		if conflicts := probe.newVPAHPAConflicts(); conflicts != nil {
+			hpas := newVPAHPAScalingTracker(conflicts)
+			hpas.keep("probe", hpaScaling{target: target, resources: map[v1.ResourceName]struct{}{v1.ResourceCPU: {}}})
+			conflicts.hpas = []*vpaHPAScalingTracker{hpas}
+			validate(probe.storeMetricFamilies(conflicts.families), a)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// validateRenamedLabelKeys checks the label keys of the series the metric
+// family generates from the object against the label renames. Families
+// failing to generate series from the object are not checked.
+func validateRenamedLabelKeys(renames map[string]string, f generator.FamilyGenerator, obj interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			klog.V(4).Infof("Skipping the validation of the label renames of metric family %s: %v", f.Name, r)
+		}
+	}()
+	for _, m := range f.Generate(obj).Metrics {
+		if err := generator.ValidateRenamedLabelKeys(renames, f.Name, m.LabelKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var availableStores = map[string]func(f *Builder) []*metricsstore.MetricsStore{
 	"certificatesigningrequests":      func(b *Builder) []*metricsstore.MetricsStore { return b.buildCsrStores() },
 	"configmaps":                      func(b *Builder) []*metricsstore.MetricsStore { return b.buildConfigMapStores() },
@@ -229,6 +798,16 @@ func resourceExists(name string) bool {
 	return ok
 }
 
+// resourceEnabled returns whether the resource is among the enabled ones.
+func resourceEnabled(enabledResources []string, name string) bool {
+	for _, r := range enabledResources {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
 func availableResources() []string {
 	c := []string{}
 	for name := range availableStores {
@@ -346,7 +925,98 @@ func (b *Builder) buildVolumeAttachmentStores() []*metricsstore.MetricsStore {
 }
 
 func (b *Builder) buildVPAStores() []*metricsstore.MetricsStore {
-	return b.buildStoresFunc(vpaMetricFamilies(b.allowAnnotationsList["verticalpodautoscalers"], b.allowLabelsList["verticalpodautoscalers"]), &vpaautoscaling.VerticalPodAutoscaler{}, createVPAListWatchFunc(b.vpaClient), b.useAPIServerCache)
+	opts := vpaOptions{
+		defaultLabels:                b.defaultLabels["verticalpodautoscalers"],
+		zeroMissingResources:         b.vpaZeroMissingResources,
+		preciseCPU:                   b.vpaPreciseCPU,
+		memoryUnit:                   b.vpaMemoryUnit,
+		recommenderAnnotation:        b.vpaRecommenderAnnotation,
+		recommenderLabelAnnotation:   b.vpaRecommenderLabel,
+		recommendationBounds:         b.vpaRecommendationBounds,
+		ownerReferences:              b.vpaOwnerReferences,
+		dropZeroStateSet:             b.dropZeroStateSet,
+		containerDenylist:            b.vpaContainerDenylist,
+		observedContainersAnnotation: b.vpaObservedContainers,
+		omitEmptyDefaultLabels:       b.vpaOmitEmptyLabels,
+		skipOffModeRecommendations:   b.vpaSkipOffModeRecs,
+	}
+	var aggregateFamilies []generator.FamilyGenerator
+	if b.vpaRecommendationSums {
+		aggregateFamilies = append(aggregateFamilies, vpaRecommendationSumFamilies()...)
+	}
+	if len(b.vpaRecommendationBuckets) > 0 {
+		aggregateFamilies = append(aggregateFamilies, vpaRecommendationHistogramFamilies(b.vpaRecommendationBuckets)...)
+	}
+	if b.vpaUpdateModeCount {
+		aggregateFamilies = append(aggregateFamilies, vpaUpdateModeCountFamilies()...)
+	}
+	if b.vpaNamespaceCount {
+		aggregateFamilies = append(aggregateFamilies, vpaNamespaceCountFamilies()...)
+	}
+	if len(aggregateFamilies) > 0 {
+		b.vpaAggregates = newVPARecommendationAggregates(generator.RenameLabels(b.labelRenames, b.effectiveMetricFamilies(aggregateFamilies)), opts)
+		if b.vpaNativeHistogram && len(b.vpaRecommendationBuckets) > 0 {
+			// The histogram may have been renamed or filtered out.
+			var names []string
+			for _, f := range b.effectiveMetricFamilies(vpaRecommendationHistogramFamilies(b.vpaRecommendationBuckets)) {
+				names = append(names, f.Name)
+			}
+			b.vpaAggregates.withNativeHistograms(names)
+		}
+	}
+	return b.buildStoresFunc(vpaMetricFamilies(b.allowAnnotationsList["verticalpodautoscalers"], b.allowLabelsList["verticalpodautoscalers"], opts), &vpaautoscaling.VerticalPodAutoscaler{}, createVPAListWatchFunc(b.vpaClient, b.vpaTargetKinds), b.useAPIServerCache)
+}
+
+// WithFieldSelectors sets the field selectors of individual resources. They
+// are combined with the fieldSelector property of the Builder.
+func (b *Builder) WithFieldSelectors(selectors map[string]string) error {
+	for resource := range selectors {
+		if !resourceExists(resource) {
+			return errors.Errorf("resource %s does not exist. Available resources: %s", resource, strings.Join(availableResources(), ","))
+		}
+	}
+	b.fieldSelectors = selectors
+	return nil
+}
+
+// WithObjectNames restricts individual resources to the object of the given
+// name. Unlike the field selectors, the name is never dropped in case the
+// apiserver rejects a selector.
+func (b *Builder) WithObjectNames(names map[string]string) error {
+	for resource := range names {
+		if !resourceExists(resource) {
+			return errors.Errorf("resource %s does not exist. Available resources: %s", resource, strings.Join(availableResources(), ","))
+		}
+	}
+	b.objectNames = names
+	return nil
+}
+
+// WithDefaultLabels overrides the labels each metric of the given resources
+// starts with. Only the resources listed in defaultLabelsOverrides support it,
+// and the labels have to be chosen among the ones known for the resource.
+func (b *Builder) WithDefaultLabels(labels map[string][]string) error {
+	for resource, resourceLabels := range labels {
+		isKnown, ok := defaultLabelsOverrides[resource]
+		if !ok {
+			return errors.Errorf("resource %s does not support overriding its default labels", resource)
+		}
+		if len(resourceLabels) == 0 {
+			return errors.Errorf("default labels of resource %s must not be empty", resource)
+		}
+		seen := map[string]struct{}{}
+		for _, label := range resourceLabels {
+			if !isKnown(label) {
+				return errors.Errorf("label %s is not a known default label of resource %s", label, resource)
+			}
+			if _, ok := seen[label]; ok {
+				return errors.Errorf("label %s is set more than once for resource %s", label, resource)
+			}
+			seen[label] = struct{}{}
+		}
+	}
+	b.defaultLabels = labels
+	return nil
 }
 
 func (b *Builder) buildLeasesStores() []*metricsstore.MetricsStore {
@@ -356,47 +1026,162 @@ func (b *Builder) buildLeasesStores() []*metricsstore.MetricsStore {
 func (b *Builder) buildStores(
 	metricFamilies []generator.FamilyGenerator,
 	expectedType interface{},
-	listWatchFunc func(kubeClient clientset.Interface, ns string) cache.ListerWatcher,
+	listWatchFunc func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher,
 	useAPIServerCache bool,
 ) []*metricsstore.MetricsStore {
-	metricFamilies = generator.FilterMetricFamilies(b.allowDenyList, metricFamilies)
-	composedMetricGenFuncs := generator.ComposeMetricGenFuncs(metricFamilies)
-	familyHeaders := generator.ExtractMetricFamilyHeaders(metricFamilies)
+	metricFamilies = b.storeMetricFamilies(metricFamilies)
+	listWatchFunc = b.withFieldSelector(resourceName(expectedType), listWatchFunc)
 
 	if isAllNamespaces(b.namespaces) {
-		store := metricsstore.NewMetricsStore(
-			familyHeaders,
-			composedMetricGenFuncs,
-		)
-		listWatcher := listWatchFunc(b.kubeClient, v1.NamespaceAll)
-		b.startReflector(expectedType, store, listWatcher, useAPIServerCache)
+		store := b.newMetricsStore(metricFamilies, expectedType)
+		listWatcher := listWatchFunc(b.kubeClient, v1.NamespaceAll, b.fieldSelectorFilter)
+		b.startReflector(expectedType, v1.NamespaceAll, store, listWatcher, useAPIServerCache)
 		return []*metricsstore.MetricsStore{store}
 	}
 
 	stores := make([]*metricsstore.MetricsStore, 0, len(b.namespaces))
 	for _, ns := range b.namespaces {
-		store := metricsstore.NewMetricsStore(
-			familyHeaders,
-			composedMetricGenFuncs,
-		)
-		listWatcher := listWatchFunc(b.kubeClient, ns)
-		b.startReflector(expectedType, store, listWatcher, useAPIServerCache)
+		store := b.newMetricsStore(metricFamilies, expectedType)
+		listWatcher := listWatchFunc(b.kubeClient, ns, b.fieldSelectorFilter)
+		b.startReflector(expectedType, ns, store, listWatcher, useAPIServerCache)
 		stores = append(stores, store)
 	}
 
 	return stores
 }
 
+// storeMetricFamilies returns the given metric families the way the stores
+// generate them, i.e. the effective ones with the uid and resource_version
+// labels if enabled, their labels renamed and their metrics filtered.
+func (b *Builder) storeMetricFamilies(metricFamilies []generator.FamilyGenerator) []generator.FamilyGenerator {
+	metricFamilies = b.effectiveMetricFamilies(metricFamilies)
+	if b.enableUIDLabel {
+		metricFamilies = withUIDLabel(metricFamilies)
+	}
+	if b.resourceVersionLabel {
+		metricFamilies = withResourceVersionLabel(metricFamilies)
+	}
+	metricFamilies = generator.RenameLabels(b.labelRenames, metricFamilies)
+	return generator.FilterMetrics(b.metricFilter, metricFamilies)
+}
+
+// newMetricsStore returns a store of the metric families, as returned by
+// storeMetricFamilies, of objects of the given type, which takes its series
+// from the series budget.
+func (b *Builder) newMetricsStore(metricFamilies []generator.FamilyGenerator, expectedType interface{}) *metricsstore.MetricsStore {
+	composedMetricGenFuncs := instrumentGenerateFunc(resourceName(expectedType), generator.ComposeMetricGenFuncs(metricFamilies))
+	if b.objectSeriesCount {
+		composedMetricGenFuncs = countSeriesFunc(resourceName(expectedType), composedMetricGenFuncs)
+	}
+	store := metricsstore.NewMetricsStore(
+		generator.ExtractMetricFamilyHeaders(metricFamilies),
+		composedMetricGenFuncs,
+	)
+	if b.seriesBudget != nil {
+		store.WithSeriesBudget(b.seriesBudget)
+	}
+	store.WithGenerationConcurrency(b.generationConcurrency)
+	return store
+}
+
+// effectiveMetricFamilies returns the given metric families with their legacy
+// aliases, prefixed, with their overrides applied and filtered by the allow
+// and denylist. Their metrics carry the cluster label, if set.
+func (b *Builder) effectiveMetricFamilies(metricFamilies []generator.FamilyGenerator) []generator.FamilyGenerator {
+	if b.legacyMetricAliases {
+		metricFamilies = generator.AliasMetricFamilies(legacyMetricAliases, metricFamilies)
+	}
+	metricFamilies = generator.PrefixMetricFamilies(b.metricPrefix, metricFamilies)
+	metricFamilies = generator.OverrideMetricFamilies(b.familyOverrides, metricFamilies)
+	metricFamilies = generator.FilterMetricFamilies(b.allowDenyList, metricFamilies)
+	if b.clusterName != "" {
+		metricFamilies = withClusterLabel(b.clusterName, metricFamilies)
+	}
+	return metricFamilies
+}
+
+// withFieldSelector adds the field selector configured for the resource to
+// the list and watch requests of listWatchFunc. If the apiserver rejects it,
+// the requests fall back to the given field selector alone.
+func (b *Builder) withFieldSelector(
+	resource string,
+	listWatchFunc func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher,
+) func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+	if name, ok := b.objectNames[resource]; ok {
+		listWatchFunc = withObjectName(name, listWatchFunc)
+	}
+	selector, ok := b.fieldSelectors[resource]
+	if !ok || selector == "" {
+		return listWatchFunc
+	}
+
+	return func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+		fallback := listWatchFunc(kubeClient, ns, fieldSelector)
+		primary, ok := listWatchFunc(kubeClient, ns, joinFieldSelectors(fieldSelector, selector)).(*contextListWatch)
+		if !ok {
+			return fallback
+		}
+		if fallback, ok := fallback.(*contextListWatch); ok {
+			return withFieldSelectorFallback(resource, selector, primary, fallback)
+		}
+		return primary
+	}
+}
+
+// withObjectName restricts the list and watch requests of listWatchFunc to
+// the object of the given name.
+func withObjectName(
+	name string,
+	listWatchFunc func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher,
+) func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+	nameSelector := fields.OneTermEqualSelector("metadata.name", name).String()
+	return func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+		return listWatchFunc(kubeClient, ns, joinFieldSelectors(fieldSelector, nameSelector))
+	}
+}
+
 // startReflector starts a Kubernetes client-go reflector with the given
-// listWatcher and registers it with the given store.
+// listWatcher of the namespace and registers it with the given store.
 func (b *Builder) startReflector(
 	expectedType interface{},
+	namespace string,
 	store cache.Store,
 	listWatcher cache.ListerWatcher,
 	useAPIServerCache bool,
 ) {
+	if lw, ok := listWatcher.(*contextListWatch); ok {
+		if b.objectLabelSelector != "" {
+			lw = lw.withLabelSelector(b.objectLabelSelector)
+		}
+		listWatcher = lw.withContext(b.ctx, b.requestTimeout)
+	}
+	resource := resourceName(expectedType)
+	if b.objectSeriesCount {
+		store = newSeriesCountStore(store, resource)
+	}
+	if _, ok := expectedType.(*vpaautoscaling.VerticalPodAutoscaler); ok && b.vpaAggregates != nil {
+		store = b.vpaAggregates.wrap(store)
+	}
+	if b.vpaTargetReplicas != nil {
+		store = b.vpaTargetReplicas.wrap(store, expectedType)
+	}
+	if b.vpaHPAConflicts != nil {
+		store = b.vpaHPAConflicts.wrap(store, expectedType)
+	}
+	store = newSkippedObjectsStore(store, resource, expectedType)
+	if b.storeQueueDepth > 0 {
+		queued := newQueuedStore(store, resource, b.storeQueueDepth)
+		go queued.run(b.ctx)
+		store = queued
+	}
+	listWatcher = &storeHealthListWatch{ListerWatcher: listWatcher, resource: resource, namespace: namespace}
 	instrumentedListWatch := watch.NewInstrumentedListerWatcher(listWatcher, b.listWatchMetrics, reflect.TypeOf(expectedType).String(), useAPIServerCache)
-	reflector := cache.NewReflector(sharding.NewShardedListWatch(b.shard, b.totalShards, instrumentedListWatch), expectedType, store, 0)
+	var ownership *prometheus.GaugeVec
+	if b.shardingMetrics != nil {
+		ownership = b.shardingMetrics.Ownership
+	}
+	shardedListWatch := sharding.NewOwnershipShardedListWatch(b.shard, b.totalShards, b.shardBy, instrumentedListWatch, ownership, resource)
+	reflector := cache.NewReflector(shardedListWatch, expectedType, store, 0)
 	go reflector.Run(b.ctx.Done())
 }
 
@@ -141,13 +141,13 @@ func wrapCSRFunc(f func(*certv1.CertificateSigningRequest) *metric.Family) func(
 	}
 }
 
-func createCSRListWatch(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-	return &cache.ListWatch{
-		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return kubeClient.CertificatesV1().CertificateSigningRequests().List(context.TODO(), opts)
+func createCSRListWatch(kubeClient clientset.Interface, ns string, _ string) cache.ListerWatcher {
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return kubeClient.CertificatesV1().CertificateSigningRequests().List(ctx, opts)
 		},
-		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return kubeClient.CertificatesV1().CertificateSigningRequests().Watch(context.TODO(), opts)
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			return kubeClient.CertificatesV1().CertificateSigningRequests().Watch(ctx, opts)
 		},
 	}
 }
@@ -122,13 +122,15 @@ func configMapMetricFamilies(allowAnnotationsList, allowLabelsList []string) []g
 	}
 }
 
-func createConfigMapListWatch(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-	return &cache.ListWatch{
-		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return kubeClient.CoreV1().ConfigMaps(ns).List(context.TODO(), opts)
+func createConfigMapListWatch(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.CoreV1().ConfigMaps(ns).List(ctx, opts)
 		},
-		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return kubeClient.CoreV1().ConfigMaps(ns).Watch(context.TODO(), opts)
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.CoreV1().ConfigMaps(ns).Watch(ctx, opts)
 		},
 	}
 }
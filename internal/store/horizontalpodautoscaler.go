@@ -118,14 +118,14 @@ func hpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 					case autoscaling.ObjectMetricSourceType:
 						metricName = m.Object.Metric.Name
 
-						v[value], ok[value] = m.Object.Target.Value.AsInt64()
+						v[value], ok[value] = quantityToInt64("horizontalpodautoscalers", m.Object.Target.Value)
 						if m.Object.Target.AverageValue != nil {
-							v[average], ok[average] = m.Object.Target.AverageValue.AsInt64()
+							v[average], ok[average] = quantityToInt64("horizontalpodautoscalers", m.Object.Target.AverageValue)
 						}
 					case autoscaling.PodsMetricSourceType:
 						metricName = m.Pods.Metric.Name
 
-						v[average], ok[average] = m.Pods.Target.AverageValue.AsInt64()
+						v[average], ok[average] = quantityToInt64("horizontalpodautoscalers", m.Pods.Target.AverageValue)
 					case autoscaling.ResourceMetricSourceType:
 						metricName = string(m.Resource.Name)
 
@@ -134,16 +134,16 @@ func hpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 						}
 
 						if m.Resource.Target.AverageValue != nil {
-							v[average], ok[average] = m.Resource.Target.AverageValue.AsInt64()
+							v[average], ok[average] = quantityToInt64("horizontalpodautoscalers", m.Resource.Target.AverageValue)
 						}
 					case autoscaling.ExternalMetricSourceType:
 						metricName = m.External.Metric.Name
 
 						if m.External.Target.Value != nil {
-							v[value], ok[value] = m.External.Target.Value.AsInt64()
+							v[value], ok[value] = quantityToInt64("horizontalpodautoscalers", m.External.Target.Value)
 						}
 						if m.External.Target.AverageValue != nil {
-							v[average], ok[average] = m.External.Target.AverageValue.AsInt64()
+							v[average], ok[average] = quantityToInt64("horizontalpodautoscalers", m.External.Target.AverageValue)
 						}
 					default:
 						// Skip unsupported metric type
@@ -271,13 +271,15 @@ func wrapHPAFunc(f func(*autoscaling.HorizontalPodAutoscaler) *metric.Family) fu
 	}
 }
 
-func createHPAListWatch(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-	return &cache.ListWatch{
-		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(ns).List(context.TODO(), opts)
+func createHPAListWatch(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(ns).List(ctx, opts)
 		},
-		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(ns).Watch(context.TODO(), opts)
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(ns).Watch(ctx, opts)
 		},
 	}
 }
@@ -47,35 +47,35 @@ var (
 					for resource, min := range rawLimitRange.Min {
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{string(resource), string(rawLimitRange.Type), "min"},
-							Value:       float64(min.MilliValue()) / 1000,
+							Value:       milliQuantityToFloat64("limitranges", min),
 						})
 					}
 
 					for resource, max := range rawLimitRange.Max {
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{string(resource), string(rawLimitRange.Type), "max"},
-							Value:       float64(max.MilliValue()) / 1000,
+							Value:       milliQuantityToFloat64("limitranges", max),
 						})
 					}
 
 					for resource, df := range rawLimitRange.Default {
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{string(resource), string(rawLimitRange.Type), "default"},
-							Value:       float64(df.MilliValue()) / 1000,
+							Value:       milliQuantityToFloat64("limitranges", df),
 						})
 					}
 
 					for resource, dfR := range rawLimitRange.DefaultRequest {
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{string(resource), string(rawLimitRange.Type), "defaultRequest"},
-							Value:       float64(dfR.MilliValue()) / 1000,
+							Value:       milliQuantityToFloat64("limitranges", dfR),
 						})
 					}
 
 					for resource, mLR := range rawLimitRange.MaxLimitRequestRatio {
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{string(resource), string(rawLimitRange.Type), "maxLimitRequestRatio"},
-							Value:       float64(mLR.MilliValue()) / 1000,
+							Value:       milliQuantityToFloat64("limitranges", mLR),
 						})
 					}
 				}
@@ -127,13 +127,15 @@ func wrapLimitRangeFunc(f func(*v1.LimitRange) *metric.Family) func(interface{})
 	}
 }
 
-func createLimitRangeListWatch(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-	return &cache.ListWatch{
-		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return kubeClient.CoreV1().LimitRanges(ns).List(context.TODO(), opts)
+func createLimitRangeListWatch(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.CoreV1().LimitRanges(ns).List(ctx, opts)
 		},
-		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return kubeClient.CoreV1().LimitRanges(ns).Watch(context.TODO(), opts)
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.CoreV1().LimitRanges(ns).Watch(ctx, opts)
 		},
 	}
 }
@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"k8s.io/kube-state-metrics/v2/pkg/logging"
+)
+
+// contextListWatch implements the k8s.io/client-go/tools/cache.ListerWatcher
+// interface, passing a context to the list and watch functions. Each list
+// call is bound by requestTimeout, while watch calls last until ctx is
+// canceled.
+type contextListWatch struct {
+	ctx            context.Context
+	requestTimeout time.Duration
+
+	listFunc  func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error)
+	watchFunc func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// withContext returns a copy of the contextListWatch using the given context
+// and list request timeout. A timeout of zero disables it.
+func (l *contextListWatch) withContext(ctx context.Context, requestTimeout time.Duration) *contextListWatch {
+	return &contextListWatch{
+		ctx:            ctx,
+		requestTimeout: requestTimeout,
+		listFunc:       l.listFunc,
+		watchFunc:      l.watchFunc,
+	}
+}
+
+// withLabelSelector returns a copy of the contextListWatch whose list and
+// watch requests only select the objects matching the given label selector.
+func (l *contextListWatch) withLabelSelector(selector string) *contextListWatch {
+	return &contextListWatch{
+		ctx:            l.ctx,
+		requestTimeout: l.requestTimeout,
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			opts.LabelSelector = selector
+			return l.listFunc(ctx, opts)
+		},
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = selector
+			return l.watchFunc(ctx, opts)
+		},
+	}
+}
+
+// List lists the objects with the configured request timeout.
+func (l *contextListWatch) List(opts metav1.ListOptions) (runtime.Object, error) {
+	ctx := l.context()
+	if l.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.requestTimeout)
+		defer cancel()
+	}
+	return l.listFunc(ctx, opts)
+}
+
+// Watch watches the objects until the context is canceled. Bookmark events
+// are always requested, so the reflector keeps track of the latest resource
+// version and resumes a restarted watch from it instead of relisting.
+// Apiservers not supporting them ignore the option.
+func (l *contextListWatch) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	opts.AllowWatchBookmarks = true
+	return l.watchFunc(l.context(), opts)
+}
+
+func (l *contextListWatch) context() context.Context {
+	if l.ctx == nil {
+		return context.Background()
+	}
+	return l.ctx
+}
+
+// withFieldSelectorFallback returns a contextListWatch using primary, whose
+// requests carry the given field selector. Once the apiserver rejects the
+// selector, all following requests are made through fallback instead.
+func withFieldSelectorFallback(resource, selector string, primary, fallback *contextListWatch) *contextListWatch {
+	var rejected int32
+	reject := func(err error) bool {
+		if !apierrors.IsBadRequest(err) {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&rejected, 0, 1) {
+			logging.Warningf("Field selector %q rejected for %s, falling back to list and watch without it: %v", selector, resource, err)
+		}
+		return true
+	}
+
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			if atomic.LoadInt32(&rejected) == 0 {
+				obj, err := primary.listFunc(ctx, opts)
+				if !reject(err) {
+					return obj, err
+				}
+			}
+			return fallback.listFunc(ctx, opts)
+		},
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			if atomic.LoadInt32(&rejected) == 0 {
+				w, err := primary.watchFunc(ctx, opts)
+				if !reject(err) {
+					return w, err
+				}
+			}
+			return fallback.watchFunc(ctx, opts)
+		},
+	}
+}
+
+// joinFieldSelectors combines the non-empty field selectors into one
+// selector requiring all of them.
+func joinFieldSelectors(selectors ...string) string {
+	nonEmpty := make([]string, 0, len(selectors))
+	for _, s := range selectors {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return strings.Join(nonEmpty, ",")
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	vpafake "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/fake"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestContextListWatch(t *testing.T) {
+	var listCtx, watchCtx context.Context
+	lw := &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			listCtx = ctx
+			return &v1.PodList{}, nil
+		},
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			watchCtx = ctx
+			if !opts.AllowWatchBookmarks {
+				t.Error("expected watch to request bookmarks")
+			}
+			return watch.NewFake(), nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clw := lw.withContext(ctx, time.Minute)
+
+	if _, err := clw.List(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := listCtx.Deadline(); !ok {
+		t.Error("expected list context to have a deadline")
+	}
+	if listCtx.Err() == nil {
+		t.Error("expected list context to be canceled once the list call returned")
+	}
+
+	if _, err := clw.Watch(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := watchCtx.Deadline(); ok {
+		t.Error("expected watch context to not have a deadline")
+	}
+	cancel()
+	if watchCtx.Err() == nil {
+		t.Error("expected watch context to be canceled with the parent context")
+	}
+
+	if _, err := lw.withContext(context.Background(), 0).List(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := listCtx.Deadline(); ok {
+		t.Error("expected list context to not have a deadline when the timeout is disabled")
+	}
+}
+
+func TestFieldSelectorFallback(t *testing.T) {
+	var requests []string
+	newListWatch := func(fieldSelector string, reject bool) *contextListWatch {
+		return &contextListWatch{
+			listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+				requests = append(requests, "list "+fieldSelector)
+				if reject {
+					return nil, apierrors.NewBadRequest("field label not supported")
+				}
+				return &v1.PodList{}, nil
+			},
+			watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+				requests = append(requests, "watch "+fieldSelector)
+				return watch.NewFake(), nil
+			},
+		}
+	}
+
+	selector := joinFieldSelectors("metadata.namespace!=kube-system", "", "status.phase=Running")
+	if selector != "metadata.namespace!=kube-system,status.phase=Running" {
+		t.Fatalf("unexpected joined field selector %q", selector)
+	}
+
+	lw := withFieldSelectorFallback("pods", "status.phase=Running", newListWatch(selector, true), newListWatch("metadata.namespace!=kube-system", false))
+	for i := 0; i < 2; i++ {
+		if _, err := lw.List(metav1.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := lw.Watch(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"list " + selector,
+		"list metadata.namespace!=kube-system",
+		"list metadata.namespace!=kube-system",
+		"watch metadata.namespace!=kube-system",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("want requests %v, got %v", want, requests)
+	}
+}
+
+func TestObjectNames(t *testing.T) {
+	var requests []string
+	listWatchFunc := func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+		return &contextListWatch{
+			listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+				requests = append(requests, "list "+fieldSelector)
+				if fieldSelector != "metadata.namespace!=kube-system,metadata.name=pod1" {
+					return nil, apierrors.NewBadRequest("field label not supported")
+				}
+				return &v1.PodList{}, nil
+			},
+		}
+	}
+
+	b := NewBuilder()
+	if err := b.WithObjectNames(map[string]string{"pods": "pod1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WithFieldSelectors(map[string]string{"pods": "status.phase=Running"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WithObjectNames(map[string]string{"foo": "pod1"}); err == nil {
+		t.Error("expected an error for an unknown resource")
+	}
+
+	// The rejected field selector is dropped, while the name is kept.
+	lw := b.withFieldSelector("pods", listWatchFunc)(nil, "", "metadata.namespace!=kube-system")
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"list metadata.namespace!=kube-system,status.phase=Running,metadata.name=pod1",
+		"list metadata.namespace!=kube-system,metadata.name=pod1",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("want requests %v, got %v", want, requests)
+	}
+}
+
+func TestLabelSelector(t *testing.T) {
+	vpa := func(name string, labels map[string]string) *autoscaling.VerticalPodAutoscaler {
+		return &autoscaling.VerticalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name, Labels: labels}}
+	}
+	vpaClient := vpafake.NewSimpleClientset(vpa("vpa1", map[string]string{"monitoring": "enabled"}), vpa("vpa2", nil))
+	vpaClient.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: autoscaling.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{{Name: "verticalpodautoscalers"}},
+		},
+	}
+
+	lw := createVPAListWatchFunc(vpaClient, nil)(nil, "ns1", "").(*contextListWatch).withLabelSelector("monitoring=enabled")
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := obj.(*autoscaling.VerticalPodAutoscalerList); len(list.Items) != 1 || list.Items[0].Name != "vpa1" {
+		t.Errorf("expected only the VPA matching the label selector to be listed, got %v", list.Items)
+	}
+
+	if err := NewBuilder().WithObjectLabelSelector("monitoring in (enabled"); err == nil {
+		t.Error("expected an invalid label selector to be rejected")
+	}
+}
@@ -0,0 +1,396 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+var (
+	// resourceParseErrorsTotal counts resource quantities which could not be
+	// converted into a metric value. It is registered by Builder.WithMetrics.
+	resourceParseErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kube_state_metrics_resource_parse_errors_total",
+			Help: "Number of resource quantities kube-state-metrics failed to convert into a metric value",
+		},
+		[]string{"resource"},
+	)
+
+	// unexpectedObjectsTotal counts objects handed to the metric generation
+	// functions which are not of the type expected by the store. It is
+	// registered by Builder.WithMetrics.
+	unexpectedObjectsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kube_state_metrics_unexpected_objects_total",
+			Help: "Number of objects kube-state-metrics skipped because they were not of the expected type",
+		},
+		[]string{"resource"},
+	)
+
+	// objectsSkippedTotal counts objects kube-state-metrics generated no
+	// metrics for, by the reason they were skipped for. It is registered by
+	// Builder.WithMetrics.
+	objectsSkippedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kube_state_metrics_objects_skipped_total",
+			Help: "Number of objects kube-state-metrics skipped because they could not be converted or handled",
+		},
+		[]string{"resource", "reason"},
+	)
+
+	// storeGenerateDuration observes the time it takes to generate the metric
+	// families of a single object. It is registered by Builder.WithMetrics.
+	storeGenerateDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "kube_state_metrics_store_generate_duration_seconds",
+			Help:    "Time it took kube-state-metrics to generate the metric families of an object",
+			Buckets: []float64{0.00001, 0.0001, 0.001, 0.01, 0.1, 1},
+		},
+		[]string{"resource"},
+	)
+
+	// storeUp reports whether the reflector of a store lists and watches its
+	// resource without errors. It is registered by Builder.WithMetrics.
+	storeUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kube_state_metrics_store_up",
+			Help: "Whether the list and watch requests of a resource succeed (1) or fail (0)",
+		},
+		[]string{"resource"},
+	)
+
+	// storeLastErrorTimestamp records when the reflector of a store last
+	// failed to list or watch its resource. It is registered by
+	// Builder.WithMetrics.
+	storeLastErrorTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kube_state_metrics_store_last_error_timestamp",
+			Help: "Unix timestamp of the last failed list or watch request of a resource",
+		},
+		[]string{"resource"},
+	)
+
+	// storeWaitingForAPI reports whether a store waits for its resource to be
+	// served by the apiserver, e.g. for the CustomResourceDefinition of
+	// VerticalPodAutoscalers to be installed. It is registered by
+	// Builder.WithMetrics.
+	storeWaitingForAPI = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kube_state_metrics_store_waiting_for_api",
+			Help: "Whether a resource is not served by the apiserver yet (1) or served (0)",
+		},
+		[]string{"resource"},
+	)
+
+	// objectSeriesCount reports the number of series each object produced
+	// when its metrics were last generated. It is only populated if enabled
+	// with Builder.WithObjectSeriesCount, and registered by
+	// Builder.WithMetrics.
+	objectSeriesCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kube_state_metrics_object_series_count",
+			Help: "Number of series an object produced when its metrics were last generated",
+		},
+		[]string{"resource", "namespace", "name"},
+	)
+
+	// seriesDroppedTotal counts the series which were not stored because
+	// they exceeded the budget set with Builder.WithMaxSeries. It is
+	// registered by Builder.WithMetrics.
+	seriesDroppedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "kube_state_metrics_series_dropped_total",
+			Help: "Number of series kube-state-metrics dropped because they exceeded the maximum number of series",
+		},
+	)
+
+	// queueDepth reports the number of updates of a resource queued to be
+	// applied to its stores. It is only populated if enabled with
+	// Builder.WithStoreQueueDepth, and registered by Builder.WithMetrics.
+	queueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kube_state_metrics_queue_depth",
+			Help: "Number of updates of a resource queued to be applied to its stores",
+		},
+		[]string{"resource"},
+	)
+)
+
+// registerStoreMetrics registers the metrics kube-state-metrics exposes about
+// the generation of the store metrics with the given registerer.
+func registerStoreMetrics(r prometheus.Registerer) {
+	r.MustRegister(resourceParseErrorsTotal, unexpectedObjectsTotal, objectsSkippedTotal, storeGenerateDuration, storeUp, storeLastErrorTimestamp, storeWaitingForAPI, objectSeriesCount, seriesDroppedTotal, queueDepth)
+}
+
+// The reasons objects are skipped for in objectsSkippedTotal.
+const (
+	// skipReasonMissingMetadata skips objects without object metadata.
+	skipReasonMissingMetadata = "missing_metadata"
+	// skipReasonUnexpectedType skips objects which are not of the type
+	// expected by the store.
+	skipReasonUnexpectedType = "unexpected_type"
+	// skipReasonConversion skips objects which could not be converted to the
+	// version the metrics are generated from.
+	skipReasonConversion = "conversion"
+)
+
+// skipObject counts the object of the resource as skipped for the reason and
+// logs its key at debug level.
+func skipObject(resource, reason string, obj interface{}) {
+	objectsSkippedTotal.WithLabelValues(resource, reason).Inc()
+	if klog.V(4).Enabled() {
+		key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		klog.V(4).InfoS("Skipped object", "resource", resource, "reason", reason, "key", key, "type", fmt.Sprintf("%T", obj))
+	}
+}
+
+// skippedObjectsStore drops the objects added to the cache.Store it wraps
+// which metrics cannot be generated for, counting them in
+// objectsSkippedTotal.
+type skippedObjectsStore struct {
+	cache.Store
+	resource     string
+	expectedType reflect.Type
+}
+
+func newSkippedObjectsStore(store cache.Store, resource string, expectedType interface{}) *skippedObjectsStore {
+	return &skippedObjectsStore{
+		Store:        store,
+		resource:     resource,
+		expectedType: reflect.TypeOf(expectedType),
+	}
+}
+
+// Add adds the object to the wrapped store unless it is skipped.
+func (s *skippedObjectsStore) Add(obj interface{}) error {
+	if err := s.check(obj); err != nil {
+		return err
+	}
+	return s.Store.Add(obj)
+}
+
+// Update updates the object in the wrapped store unless it is skipped.
+func (s *skippedObjectsStore) Update(obj interface{}) error {
+	if err := s.check(obj); err != nil {
+		return err
+	}
+	return s.Store.Update(obj)
+}
+
+// Replace replaces the objects of the wrapped store with the ones which are
+// not skipped.
+func (s *skippedObjectsStore) Replace(list []interface{}, resourceVersion string) error {
+	kept := make([]interface{}, 0, len(list))
+	for _, obj := range list {
+		if s.check(obj) == nil {
+			kept = append(kept, obj)
+		}
+	}
+	return s.Store.Replace(kept, resourceVersion)
+}
+
+func (s *skippedObjectsStore) check(obj interface{}) error {
+	if t := reflect.TypeOf(obj); t != s.expectedType {
+		skipObject(s.resource, skipReasonUnexpectedType, obj)
+		return errors.Errorf("unexpected object of type %v, expected %v", t, s.expectedType)
+	}
+	if _, err := meta.Accessor(obj); err != nil {
+		skipObject(s.resource, skipReasonMissingMetadata, obj)
+		return err
+	}
+	return nil
+}
+
+// instrumentGenerateFunc wraps the given metric generation function of a store
+// to observe its duration in storeGenerateDuration.
+func instrumentGenerateFunc(resource string, f func(interface{}) []metric.FamilyInterface) func(interface{}) []metric.FamilyInterface {
+	observer := storeGenerateDuration.WithLabelValues(resource)
+	return func(obj interface{}) []metric.FamilyInterface {
+		start := time.Now()
+		families := f(obj)
+		observer.Observe(time.Since(start).Seconds())
+		return families
+	}
+}
+
+// countSeriesFunc wraps the given metric generation function of a store to
+// report the number of series of each object in objectSeriesCount.
+func countSeriesFunc(resource string, f func(interface{}) []metric.FamilyInterface) func(interface{}) []metric.FamilyInterface {
+	return func(obj interface{}) []metric.FamilyInterface {
+		families := f(obj)
+		o, err := meta.Accessor(obj)
+		if err != nil {
+			return families
+		}
+
+		count := 0
+		for _, family := range families {
+			family.Inspect(func(f metric.Family) {
+				count += len(f.Metrics)
+			})
+		}
+		objectSeriesCount.WithLabelValues(resource, o.GetNamespace(), o.GetName()).Set(float64(count))
+		return families
+	}
+}
+
+// seriesCountStore removes the objectSeriesCount series of the objects which
+// are deleted from the cache.Store it wraps.
+type seriesCountStore struct {
+	cache.Store
+	resource string
+
+	mu      sync.Mutex
+	objects map[types.NamespacedName]struct{}
+}
+
+func newSeriesCountStore(store cache.Store, resource string) *seriesCountStore {
+	return &seriesCountStore{
+		Store:    store,
+		resource: resource,
+		objects:  map[types.NamespacedName]struct{}{},
+	}
+}
+
+// Add adds the object to the wrapped store and tracks it.
+func (s *seriesCountStore) Add(obj interface{}) error {
+	s.track(obj)
+	return s.Store.Add(obj)
+}
+
+// Update updates the object in the wrapped store and tracks it.
+func (s *seriesCountStore) Update(obj interface{}) error {
+	s.track(obj)
+	return s.Store.Update(obj)
+}
+
+// Delete deletes the object from the wrapped store and removes its series.
+func (s *seriesCountStore) Delete(obj interface{}) error {
+	o, err := meta.Accessor(obj)
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		o, err = meta.Accessor(tombstone.Obj)
+	}
+	if err == nil {
+		s.mu.Lock()
+		s.forget(types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()})
+		s.mu.Unlock()
+	}
+	return s.Store.Delete(obj)
+}
+
+// Replace replaces the objects of the wrapped store and removes the series of
+// the objects which are gone.
+func (s *seriesCountStore) Replace(list []interface{}, resourceVersion string) error {
+	s.mu.Lock()
+	previous := s.objects
+	s.objects = make(map[types.NamespacedName]struct{}, len(list))
+	s.mu.Unlock()
+	for _, obj := range list {
+		s.track(obj)
+	}
+
+	s.mu.Lock()
+	for key := range previous {
+		if _, ok := s.objects[key]; !ok {
+			objectSeriesCount.DeleteLabelValues(s.resource, key.Namespace, key.Name)
+		}
+	}
+	s.mu.Unlock()
+	return s.Store.Replace(list, resourceVersion)
+}
+
+func (s *seriesCountStore) track(obj interface{}) {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.objects[types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *seriesCountStore) forget(key types.NamespacedName) {
+	delete(s.objects, key)
+	objectSeriesCount.DeleteLabelValues(s.resource, key.Namespace, key.Name)
+}
+
+// resourceName returns the plural resource name, e.g. "pods", of the given
+// expected type of a store.
+func resourceName(expectedType interface{}) string {
+	t := reflect.TypeOf(expectedType)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	plural, _ := meta.UnsafeGuessKindToResource(schema.GroupVersionKind{Kind: t.Name()})
+	return plural.Resource
+}
+
+// storeHealthListWatch tracks in storeUp and storeLastErrorTimestamp
+// whether the list and watch requests of its resource succeed. A store is up
+// once a request succeeded, and down from a failed request until the next
+// successful list or watch. Failed requests are logged with their resource
+// and namespace.
+type storeHealthListWatch struct {
+	cache.ListerWatcher
+	resource  string
+	namespace string
+}
+
+// List lists the objects and records whether the request succeeded.
+func (l *storeHealthListWatch) List(opts metav1.ListOptions) (runtime.Object, error) {
+	obj, err := l.ListerWatcher.List(opts)
+	if err != nil {
+		l.failed("list", err)
+		return obj, err
+	}
+	storeUp.WithLabelValues(l.resource).Set(1)
+	return obj, nil
+}
+
+// Watch watches the objects and records whether the request succeeded.
+func (l *storeHealthListWatch) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	w, err := l.ListerWatcher.Watch(opts)
+	if err != nil {
+		l.failed("watch", err)
+		return w, err
+	}
+	storeUp.WithLabelValues(l.resource).Set(1)
+	return w, nil
+}
+
+func (l *storeHealthListWatch) failed(verb string, err error) {
+	klog.ErrorS(err, "List and watch request failed", "resource", l.resource, "namespace", l.namespace, "verb", verb)
+	storeUp.WithLabelValues(l.resource).Set(0)
+	storeLastErrorTimestamp.WithLabelValues(l.resource).SetToCurrentTime()
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+func TestResourceName(t *testing.T) {
+	tests := []struct {
+		expectedType interface{}
+		want         string
+	}{
+		{&v1.Pod{}, "pods"},
+		{&v1.Endpoints{}, "endpoints"},
+		{&networkingv1.Ingress{}, "ingresses"},
+		{&networkingv1.NetworkPolicy{}, "networkpolicies"},
+		{&storagev1.StorageClass{}, "storageclasses"},
+		{&autoscaling.VerticalPodAutoscaler{}, "verticalpodautoscalers"},
+	}
+
+	for _, test := range tests {
+		if got := resourceName(test.expectedType); got != test.want {
+			t.Errorf("expected resource name %q for %T, got %q", test.want, test.expectedType, got)
+		}
+	}
+}
+
+func TestInstrumentGenerateFunc(t *testing.T) {
+	generate := instrumentGenerateFunc("test", func(obj interface{}) []metric.FamilyInterface {
+		return []metric.FamilyInterface{&metric.Family{}}
+	})
+
+	if families := generate(&v1.Pod{}); len(families) != 1 {
+		t.Fatalf("expected the generated families to be returned, got %v", families)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(storeGenerateDuration)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count uint64
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "resource" && l.GetValue() == "test" {
+					count = m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 observed generate duration, got %d", count)
+	}
+}
+
+func TestStoreHealthListWatch(t *testing.T) {
+	listErr := errors.New("verticalpodautoscalers.autoscaling.k8s.io is forbidden")
+	watchErr := errors.New("connection refused")
+	var failList, failWatch bool
+	lw := &storeHealthListWatch{
+		ListerWatcher: &cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				if failList {
+					return nil, listErr
+				}
+				return &autoscaling.VerticalPodAutoscalerList{}, nil
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				if failWatch {
+					return nil, watchErr
+				}
+				return watch.NewFake(), nil
+			},
+		},
+		resource: "test-health",
+	}
+	up := storeUp.WithLabelValues("test-health")
+	lastError := storeLastErrorTimestamp.WithLabelValues("test-health")
+
+	failList = true
+	if _, err := lw.List(metav1.ListOptions{}); err != listErr {
+		t.Fatalf("expected list error %v, got %v", listErr, err)
+	}
+	if v := testutil.ToFloat64(up); v != 0 {
+		t.Errorf("expected store to be down after a failed list, got %v", v)
+	}
+	if v := testutil.ToFloat64(lastError); v == 0 {
+		t.Error("expected the last error timestamp to be set after a failed list")
+	}
+
+	failList = false
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(up); v != 1 {
+		t.Errorf("expected store to be up after a successful list, got %v", v)
+	}
+
+	// The reflector only relists after a failed watch if the watch cannot
+	// be resumed, so a successful watch brings the store up again.
+	failWatch = true
+	if _, err := lw.Watch(metav1.ListOptions{}); err != watchErr {
+		t.Fatalf("expected watch error %v, got %v", watchErr, err)
+	}
+	if v := testutil.ToFloat64(up); v != 0 {
+		t.Errorf("expected store to be down after a failed watch, got %v", v)
+	}
+
+	failWatch = false
+	if _, err := lw.Watch(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(up); v != 1 {
+		t.Errorf("expected store to be up after a successful watch, got %v", v)
+	}
+}
+
+func TestObjectSeriesCount(t *testing.T) {
+	generate := countSeriesFunc("test-series", func(obj interface{}) []metric.FamilyInterface {
+		return []metric.FamilyInterface{
+			&metric.Family{Metrics: []*metric.Metric{{Value: 1}, {Value: 2}}},
+			&metric.Family{Metrics: []*metric.Metric{{Value: 3}}},
+		}
+	})
+	store := newSeriesCountStore(cache.NewStore(cache.MetaNamespaceKeyFunc), "test-series")
+	vpa := func(name string) *autoscaling.VerticalPodAutoscaler {
+		return &autoscaling.VerticalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+	}
+	series := func() map[string]float64 {
+		reg := prometheus.NewPedanticRegistry()
+		reg.MustRegister(objectSeriesCount)
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]float64{}
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				labels := map[string]string{}
+				for _, l := range m.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				if labels["resource"] == "test-series" {
+					got[labels["namespace"]+"/"+labels["name"]] = m.GetGauge().GetValue()
+				}
+			}
+		}
+		return got
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if err := store.Add(vpa(name)); err != nil {
+			t.Fatal(err)
+		}
+		generate(vpa(name))
+	}
+	if got := series(); len(got) != 2 || got["default/a"] != 3 || got["default/b"] != 3 {
+		t.Fatalf("expected 3 series for both objects, got %v", got)
+	}
+
+	if err := store.Delete(vpa("a")); err != nil {
+		t.Fatal(err)
+	}
+	if got := series(); len(got) != 1 || got["default/b"] != 3 {
+		t.Fatalf("expected the series of the deleted object to be removed, got %v", got)
+	}
+
+	if err := store.Replace([]interface{}{vpa("c")}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	generate(vpa("c"))
+	if got := series(); len(got) != 1 || got["default/c"] != 3 {
+		t.Fatalf("expected only the series of the replacing object, got %v", got)
+	}
+}
+
+func TestSkippedObjectsStore(t *testing.T) {
+	store := newSkippedObjectsStore(cache.NewStore(cache.MetaNamespaceKeyFunc), "test-skipped", &autoscaling.VerticalPodAutoscaler{})
+	skipped := func(reason string) float64 {
+		return testutil.ToFloat64(objectsSkippedTotal.WithLabelValues("test-skipped", reason))
+	}
+	vpa := &autoscaling.VerticalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}}
+
+	if err := store.Add(vpa); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b"}}); err == nil {
+		t.Error("expected an object of an unexpected type to be rejected")
+	}
+	if got := skipped(skipReasonUnexpectedType); got != 1 {
+		t.Errorf("expected 1 object skipped for its type, got %v", got)
+	}
+
+	if err := store.Replace([]interface{}{vpa, "not an object"}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := skipped(skipReasonUnexpectedType); got != 2 {
+		t.Errorf("expected 2 objects skipped for their type, got %v", got)
+	}
+	if keys := store.ListKeys(); len(keys) != 1 || keys[0] != "default/a" {
+		t.Errorf("expected only the expected object to be stored, got %v", keys)
+	}
+}
@@ -259,7 +259,7 @@ func createNodeStatusAllocatableFamilyGenerator() generator.FamilyGenerator {
 							sanitizeLabelName(string(resourceName)),
 							string(constant.UnitCore),
 						},
-						Value: float64(val.MilliValue()) / 1000,
+						Value: milliQuantityToFloat64("nodes", val),
 					})
 				case v1.ResourceStorage:
 					fallthrough
@@ -271,7 +271,7 @@ func createNodeStatusAllocatableFamilyGenerator() generator.FamilyGenerator {
 							sanitizeLabelName(string(resourceName)),
 							string(constant.UnitByte),
 						},
-						Value: float64(val.MilliValue()) / 1000,
+						Value: milliQuantityToFloat64("nodes", val),
 					})
 				case v1.ResourcePods:
 					ms = append(ms, &metric.Metric{
@@ -279,7 +279,7 @@ func createNodeStatusAllocatableFamilyGenerator() generator.FamilyGenerator {
 							sanitizeLabelName(string(resourceName)),
 							string(constant.UnitInteger),
 						},
-						Value: float64(val.MilliValue()) / 1000,
+						Value: milliQuantityToFloat64("nodes", val),
 					})
 				default:
 					if isHugePageResourceName(resourceName) {
@@ -288,7 +288,7 @@ func createNodeStatusAllocatableFamilyGenerator() generator.FamilyGenerator {
 								sanitizeLabelName(string(resourceName)),
 								string(constant.UnitByte),
 							},
-							Value: float64(val.MilliValue()) / 1000,
+							Value: milliQuantityToFloat64("nodes", val),
 						})
 					}
 					if isAttachableVolumeResourceName(resourceName) {
@@ -297,7 +297,7 @@ func createNodeStatusAllocatableFamilyGenerator() generator.FamilyGenerator {
 								sanitizeLabelName(string(resourceName)),
 								string(constant.UnitByte),
 							},
-							Value: float64(val.MilliValue()) / 1000,
+							Value: milliQuantityToFloat64("nodes", val),
 						})
 					}
 					if isExtendedResourceName(resourceName) {
@@ -306,7 +306,7 @@ func createNodeStatusAllocatableFamilyGenerator() generator.FamilyGenerator {
 								sanitizeLabelName(string(resourceName)),
 								string(constant.UnitInteger),
 							},
-							Value: float64(val.MilliValue()) / 1000,
+							Value: milliQuantityToFloat64("nodes", val),
 						})
 					}
 				}
@@ -341,7 +341,7 @@ func createNodeStatusCapacityFamilyGenerator() generator.FamilyGenerator {
 							sanitizeLabelName(string(resourceName)),
 							string(constant.UnitCore),
 						},
-						Value: float64(val.MilliValue()) / 1000,
+						Value: milliQuantityToFloat64("nodes", val),
 					})
 				case v1.ResourceStorage:
 					fallthrough
@@ -353,7 +353,7 @@ func createNodeStatusCapacityFamilyGenerator() generator.FamilyGenerator {
 							sanitizeLabelName(string(resourceName)),
 							string(constant.UnitByte),
 						},
-						Value: float64(val.MilliValue()) / 1000,
+						Value: milliQuantityToFloat64("nodes", val),
 					})
 				case v1.ResourcePods:
 					ms = append(ms, &metric.Metric{
@@ -361,7 +361,7 @@ func createNodeStatusCapacityFamilyGenerator() generator.FamilyGenerator {
 							sanitizeLabelName(string(resourceName)),
 							string(constant.UnitInteger),
 						},
-						Value: float64(val.MilliValue()) / 1000,
+						Value: milliQuantityToFloat64("nodes", val),
 					})
 				default:
 					if isHugePageResourceName(resourceName) {
@@ -370,7 +370,7 @@ func createNodeStatusCapacityFamilyGenerator() generator.FamilyGenerator {
 								sanitizeLabelName(string(resourceName)),
 								string(constant.UnitByte),
 							},
-							Value: float64(val.MilliValue()) / 1000,
+							Value: milliQuantityToFloat64("nodes", val),
 						})
 					}
 					if isAttachableVolumeResourceName(resourceName) {
@@ -379,7 +379,7 @@ func createNodeStatusCapacityFamilyGenerator() generator.FamilyGenerator {
 								sanitizeLabelName(string(resourceName)),
 								string(constant.UnitByte),
 							},
-							Value: float64(val.MilliValue()) / 1000,
+							Value: milliQuantityToFloat64("nodes", val),
 						})
 					}
 					if isExtendedResourceName(resourceName) {
@@ -388,7 +388,7 @@ func createNodeStatusCapacityFamilyGenerator() generator.FamilyGenerator {
 								sanitizeLabelName(string(resourceName)),
 								string(constant.UnitInteger),
 							},
-							Value: float64(val.MilliValue()) / 1000,
+							Value: milliQuantityToFloat64("nodes", val),
 						})
 					}
 				}
@@ -454,13 +454,13 @@ func wrapNodeFunc(f func(*v1.Node) *metric.Family) func(interface{}) *metric.Fam
 	}
 }
 
-func createNodeListWatch(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-	return &cache.ListWatch{
-		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return kubeClient.CoreV1().Nodes().List(context.TODO(), opts)
+func createNodeListWatch(kubeClient clientset.Interface, ns string, _ string) cache.ListerWatcher {
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return kubeClient.CoreV1().Nodes().List(ctx, opts)
 		},
-		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return kubeClient.CoreV1().Nodes().Watch(context.TODO(), opts)
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			return kubeClient.CoreV1().Nodes().Watch(ctx, opts)
 		},
 	}
 }
@@ -250,7 +250,7 @@ func persistentVolumeMetricFamilies(allowAnnotationsList, allowLabelsList []stri
 				return &metric.Family{
 					Metrics: []*metric.Metric{
 						{
-							Value: float64(storage.Value()),
+							Value: unitQuantityToFloat64("persistentvolumes", storage),
 						},
 					},
 				}
@@ -274,13 +274,13 @@ func wrapPersistentVolumeFunc(f func(*v1.PersistentVolume) *metric.Family) func(
 	}
 }
 
-func createPersistentVolumeListWatch(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-	return &cache.ListWatch{
-		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return kubeClient.CoreV1().PersistentVolumes().List(context.TODO(), opts)
+func createPersistentVolumeListWatch(kubeClient clientset.Interface, ns string, _ string) cache.ListerWatcher {
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return kubeClient.CoreV1().PersistentVolumes().List(ctx, opts)
 		},
-		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return kubeClient.CoreV1().PersistentVolumes().Watch(context.TODO(), opts)
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			return kubeClient.CoreV1().PersistentVolumes().Watch(ctx, opts)
 		},
 	}
 }
@@ -162,7 +162,7 @@ func createPodContainerResourceLimitsFamilyGenerator() generator.FamilyGenerator
 					case v1.ResourceCPU:
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitCore)},
-							Value:       float64(val.MilliValue()) / 1000,
+							Value:       milliQuantityToFloat64("pods", val),
 						})
 					case v1.ResourceStorage:
 						fallthrough
@@ -171,24 +171,24 @@ func createPodContainerResourceLimitsFamilyGenerator() generator.FamilyGenerator
 					case v1.ResourceMemory:
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-							Value:       float64(val.Value()),
+							Value:       unitQuantityToFloat64("pods", val),
 						})
 					default:
 						if isHugePageResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 							})
 						}
 						if isAttachableVolumeResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
 							})
 						}
 						if isExtendedResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitInteger)},
 							})
 
@@ -225,7 +225,7 @@ func createPodContainerResourceRequestsFamilyGenerator() generator.FamilyGenerat
 					case v1.ResourceCPU:
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitCore)},
-							Value:       float64(val.MilliValue()) / 1000,
+							Value:       milliQuantityToFloat64("pods", val),
 						})
 					case v1.ResourceStorage:
 						fallthrough
@@ -234,25 +234,25 @@ func createPodContainerResourceRequestsFamilyGenerator() generator.FamilyGenerat
 					case v1.ResourceMemory:
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-							Value:       float64(val.Value()),
+							Value:       unitQuantityToFloat64("pods", val),
 						})
 					default:
 						if isHugePageResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 							})
 						}
 						if isAttachableVolumeResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 							})
 						}
 						if isExtendedResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitInteger)},
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 							})
 						}
 					}
@@ -619,7 +619,7 @@ func createPodInitContainerResourceLimitsFamilyGenerator() generator.FamilyGener
 					case v1.ResourceCPU:
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitCore)},
-							Value:       float64(val.MilliValue()) / 1000,
+							Value:       milliQuantityToFloat64("pods", val),
 						})
 					case v1.ResourceStorage:
 						fallthrough
@@ -628,24 +628,24 @@ func createPodInitContainerResourceLimitsFamilyGenerator() generator.FamilyGener
 					case v1.ResourceMemory:
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-							Value:       float64(val.Value()),
+							Value:       unitQuantityToFloat64("pods", val),
 						})
 					default:
 						if isHugePageResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 							})
 						}
 						if isAttachableVolumeResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
 							})
 						}
 						if isExtendedResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitInteger)},
 							})
 
@@ -682,7 +682,7 @@ func createPodInitContainerResourceRequestsFamilyGenerator() generator.FamilyGen
 					case v1.ResourceCPU:
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitCore)},
-							Value:       float64(val.MilliValue()) / 1000,
+							Value:       milliQuantityToFloat64("pods", val),
 						})
 					case v1.ResourceStorage:
 						fallthrough
@@ -691,25 +691,25 @@ func createPodInitContainerResourceRequestsFamilyGenerator() generator.FamilyGen
 					case v1.ResourceMemory:
 						ms = append(ms, &metric.Metric{
 							LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-							Value:       float64(val.Value()),
+							Value:       unitQuantityToFloat64("pods", val),
 						})
 					default:
 						if isHugePageResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 							})
 						}
 						if isAttachableVolumeResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 							})
 						}
 						if isExtendedResourceName(resourceName) {
 							ms = append(ms, &metric.Metric{
 								LabelValues: []string{c.Name, p.Spec.NodeName, sanitizeLabelName(string(resourceName)), string(constant.UnitInteger)},
-								Value:       float64(val.Value()),
+								Value:       unitQuantityToFloat64("pods", val),
 							})
 						}
 					}
@@ -975,7 +975,7 @@ func createPodOverheadCPUCoresFamilyGenerator() generator.FamilyGenerator {
 				for resourceName, val := range p.Spec.Overhead {
 					if resourceName == v1.ResourceCPU {
 						ms = append(ms, &metric.Metric{
-							Value: float64(val.MilliValue()) / 1000,
+							Value: milliQuantityToFloat64("pods", val),
 						})
 					}
 				}
@@ -1000,7 +1000,7 @@ func createPodOverheadMemoryBytesFamilyGenerator() generator.FamilyGenerator {
 				for resourceName, val := range p.Spec.Overhead {
 					if resourceName == v1.ResourceMemory {
 						ms = append(ms, &metric.Metric{
-							Value: float64(val.Value()),
+							Value: unitQuantityToFloat64("pods", val),
 						})
 					}
 				}
@@ -1373,13 +1373,15 @@ func wrapPodFunc(f func(*v1.Pod) *metric.Family) func(interface{}) *metric.Famil
 	}
 }
 
-func createPodListWatch(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-	return &cache.ListWatch{
-		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return kubeClient.CoreV1().Pods(ns).List(context.TODO(), opts)
+func createPodListWatch(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.CoreV1().Pods(ns).List(ctx, opts)
 		},
-		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return kubeClient.CoreV1().Pods(ns).Watch(context.TODO(), opts)
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.CoreV1().Pods(ns).Watch(ctx, opts)
 		},
 	}
 }
@@ -0,0 +1,192 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// queuedUpdate is an update of an object waiting to be applied to the store.
+type queuedUpdate struct {
+	obj     interface{}
+	deleted bool
+}
+
+// queuedReplace is a list of objects waiting to replace the ones of the store.
+type queuedReplace struct {
+	list            []interface{}
+	resourceVersion string
+}
+
+// queuedStore decouples the reflector of a resource from the cache.Store it
+// wraps. Updates are queued and applied by a single worker, see run. An
+// update of an object which is still queued is coalesced into the queued one,
+// so a burst of updates of the same objects only has their latest state
+// applied. At most depth objects are queued; queueing further ones blocks the
+// reflector until the worker caught up, which bounds the memory a flood of
+// events takes without losing any of them. A replace drops all queued
+// updates, as the list supersedes them.
+type queuedStore struct {
+	cache.Store
+	resource string
+	depth    int
+	gauge    prometheus.Gauge
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// order holds the UIDs of the queued updates in the order they were
+	// queued in. Objects are identified by UID rather than by name, so that
+	// the deletion of an object is not coalesced into the addition of a
+	// recreated one of the same name.
+	order   []types.UID
+	pending map[types.UID]queuedUpdate
+	replace *queuedReplace
+	stopped bool
+}
+
+func newQueuedStore(store cache.Store, resource string, depth int) *queuedStore {
+	s := &queuedStore{
+		Store:    store,
+		resource: resource,
+		depth:    depth,
+		gauge:    queueDepth.WithLabelValues(resource),
+		pending:  map[types.UID]queuedUpdate{},
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Add queues the addition of the object.
+func (s *queuedStore) Add(obj interface{}) error {
+	return s.enqueue(obj, false)
+}
+
+// Update queues the update of the object.
+func (s *queuedStore) Update(obj interface{}) error {
+	return s.enqueue(obj, false)
+}
+
+// Delete queues the deletion of the object.
+func (s *queuedStore) Delete(obj interface{}) error {
+	return s.enqueue(obj, true)
+}
+
+// Replace queues the objects to replace the ones of the wrapped store and
+// drops the queued updates.
+func (s *queuedStore) Replace(list []interface{}, resourceVersion string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gauge.Sub(float64(len(s.order)))
+	s.order = nil
+	s.pending = map[types.UID]queuedUpdate{}
+	if s.replace == nil {
+		s.gauge.Inc()
+	}
+	s.replace = &queuedReplace{list: list, resourceVersion: resourceVersion}
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *queuedStore) enqueue(obj interface{}, deleted bool) error {
+	o, err := meta.Accessor(obj)
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		o, err = meta.Accessor(tombstone.Obj)
+	}
+	if err != nil {
+		// Let the wrapped store skip and count the object.
+		if deleted {
+			return s.Store.Delete(obj)
+		}
+		return s.Store.Update(obj)
+	}
+	uid := o.GetUID()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if _, ok := s.pending[uid]; ok || len(s.order) < s.depth || s.stopped {
+			break
+		}
+		s.cond.Wait()
+	}
+	if s.stopped {
+		return nil
+	}
+	if _, ok := s.pending[uid]; !ok {
+		s.order = append(s.order, uid)
+		s.gauge.Inc()
+	}
+	s.pending[uid] = queuedUpdate{obj: obj, deleted: deleted}
+	s.cond.Broadcast()
+	return nil
+}
+
+// run applies the queued updates to the wrapped store until ctx is canceled.
+// A queued replace is applied before any update queued after it.
+func (s *queuedStore) run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		s.stopped = true
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.mu.Lock()
+		for s.replace == nil && len(s.order) == 0 && !s.stopped {
+			s.cond.Wait()
+		}
+		if s.stopped {
+			s.mu.Unlock()
+			return
+		}
+
+		var err error
+		if r := s.replace; r != nil {
+			s.replace = nil
+			s.gauge.Dec()
+			s.mu.Unlock()
+			err = s.Store.Replace(r.list, r.resourceVersion)
+		} else {
+			uid := s.order[0]
+			s.order = s.order[1:]
+			u := s.pending[uid]
+			delete(s.pending, uid)
+			s.gauge.Dec()
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			if u.deleted {
+				err = s.Store.Delete(u.obj)
+			} else {
+				err = s.Store.Update(u.obj)
+			}
+		}
+		if err != nil {
+			klog.ErrorS(err, "Failed to apply queued update", "resource", s.resource)
+		}
+	}
+}
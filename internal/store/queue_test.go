@@ -0,0 +1,202 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// gatedStore blocks all updates until the gate is opened, and records them.
+type gatedStore struct {
+	cache.Store
+	gate chan struct{}
+
+	mu      sync.Mutex
+	applied []string
+}
+
+func (s *gatedStore) record(op string, obj interface{}) {
+	<-s.gate
+	a := obj.(*autoscaling.VerticalPodAutoscaler)
+	s.mu.Lock()
+	s.applied = append(s.applied, fmt.Sprintf("%s %s@%s", op, a.Name, a.ResourceVersion))
+	s.mu.Unlock()
+}
+
+func (s *gatedStore) Update(obj interface{}) error {
+	s.record("update", obj)
+	return s.Store.Update(obj)
+}
+
+func (s *gatedStore) Delete(obj interface{}) error {
+	s.record("delete", obj)
+	return s.Store.Delete(obj)
+}
+
+func (s *gatedStore) Replace(list []interface{}, resourceVersion string) error {
+	<-s.gate
+	s.mu.Lock()
+	s.applied = append(s.applied, fmt.Sprintf("replace %d", len(list)))
+	s.mu.Unlock()
+	return s.Store.Replace(list, resourceVersion)
+}
+
+func (s *gatedStore) appliedUpdates() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.applied...)
+}
+
+func TestQueuedStoreFlood(t *testing.T) {
+	vpa := func(name string, version int) *autoscaling.VerticalPodAutoscaler {
+		return &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "ns1",
+				Name:            name,
+				UID:             types.UID(name),
+				ResourceVersion: fmt.Sprint(version),
+			},
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gated := &gatedStore{Store: cache.NewStore(cache.MetaNamespaceKeyFunc), gate: make(chan struct{})}
+	queued := newQueuedStore(gated, "queuetest", 3)
+	go queued.run(ctx)
+
+	// The worker blocks on the first update, while a flood of updates of the
+	// same objects is coalesced without blocking the reflector.
+	if err := queued.Add(vpa("vpa1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	waitForQueue(t, queued)
+	for i := 2; i <= 1000; i++ {
+		if err := queued.Update(vpa(fmt.Sprintf("vpa%d", i%3), i)); err != nil {
+			t.Fatal(err)
+		}
+		if depth := testutil.ToFloat64(queueDepth.WithLabelValues("queuetest")); depth > 3 {
+			t.Fatalf("want at most 3 queued updates, got %v", depth)
+		}
+	}
+	if err := queued.Delete(vpa("vpa2", 1001)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another object does not fit anymore until the worker caught up.
+	added := make(chan struct{})
+	go func() {
+		queued.Add(vpa("vpa3", 1002))
+		close(added)
+	}()
+	select {
+	case <-added:
+		t.Fatal("expected adding a fourth object to block")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(gated.gate)
+	select {
+	case <-added:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the queue to catch up")
+	}
+	waitForQueue(t, queued)
+
+	// The flood was coalesced into the latest state of each object, which
+	// keeps the position of its first queued update.
+	want := []string{"update vpa1@1", "delete vpa2@1001", "update vpa0@999", "update vpa1@1000", "update vpa3@1002"}
+	got := gated.appliedUpdates()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want updates\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if keys := gated.ListKeys(); !equalSorted(keys, []string{"ns1/vpa0", "ns1/vpa1", "ns1/vpa3"}) {
+		t.Errorf("unexpected objects in the store: %v", keys)
+	}
+}
+
+func TestQueuedStoreReplace(t *testing.T) {
+	vpa := func(name string) *autoscaling.VerticalPodAutoscaler {
+		return &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name, UID: types.UID(name)},
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gated := &gatedStore{Store: cache.NewStore(cache.MetaNamespaceKeyFunc), gate: make(chan struct{})}
+	queued := newQueuedStore(gated, "queuetest", 10)
+
+	// Updates queued before a replace are dropped, the ones queued after it
+	// are applied after it.
+	for _, name := range []string{"a", "b"} {
+		if err := queued.Add(vpa(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queued.Replace([]interface{}{vpa("c")}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := queued.Add(vpa("d")); err != nil {
+		t.Fatal(err)
+	}
+	close(gated.gate)
+	go queued.run(ctx)
+	waitForQueue(t, queued)
+
+	want := []string{"replace 1", "update d@"}
+	if got := gated.appliedUpdates(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want updates\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if keys := gated.ListKeys(); !equalSorted(keys, []string{"ns1/c", "ns1/d"}) {
+		t.Errorf("unexpected objects in the store: %v", keys)
+	}
+}
+
+// waitForQueue waits for the worker of the store to take all queued
+// updates.
+func waitForQueue(t *testing.T, s *queuedStore) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if testutil.ToFloat64(queueDepth.WithLabelValues(s.resource)) == 0 {
+			// The last update is dequeued right before it is applied.
+			time.Sleep(10 * time.Millisecond)
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the queued updates to be applied")
+}
+
+func equalSorted(got, want []string) bool {
+	sort.Strings(got)
+	return strings.Join(got, ",") == strings.Join(want, ",")
+}
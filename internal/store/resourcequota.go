@@ -65,13 +65,13 @@ var (
 				for res, qty := range r.Status.Hard {
 					ms = append(ms, &metric.Metric{
 						LabelValues: []string{string(res), "hard"},
-						Value:       float64(qty.MilliValue()) / 1000,
+						Value:       milliQuantityToFloat64("resourcequotas", qty),
 					})
 				}
 				for res, qty := range r.Status.Used {
 					ms = append(ms, &metric.Metric{
 						LabelValues: []string{string(res), "used"},
-						Value:       float64(qty.MilliValue()) / 1000,
+						Value:       milliQuantityToFloat64("resourcequotas", qty),
 					})
 				}
 
@@ -102,13 +102,15 @@ func wrapResourceQuotaFunc(f func(*v1.ResourceQuota) *metric.Family) func(interf
 	}
 }
 
-func createResourceQuotaListWatch(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-	return &cache.ListWatch{
-		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return kubeClient.CoreV1().ResourceQuotas(ns).List(context.TODO(), opts)
+func createResourceQuotaListWatch(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.CoreV1().ResourceQuotas(ns).List(ctx, opts)
 		},
-		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return kubeClient.CoreV1().ResourceQuotas(ns).Watch(context.TODO(), opts)
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = fieldSelector
+			return kubeClient.CoreV1().ResourceQuotas(ns).Watch(ctx, opts)
 		},
 	}
 }
@@ -18,15 +18,24 @@ package store
 
 import (
 	"fmt"
+	"math"
+	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
 
 	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
 	"k8s.io/kube-state-metrics/v2/pkg/options"
 )
 
@@ -34,6 +43,22 @@ var (
 	invalidLabelCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
 	matchAllCap        = regexp.MustCompile("([a-z0-9])([A-Z])")
 	conditionStatuses  = []v1.ConditionStatus{v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown}
+
+	// allowListRegexps caches the compiled regular expressions of label and
+	// annotation allowlist entries, keyed by entry.
+	allowListRegexps sync.Map
+	// sanitizedLabelNames caches the results of sanitizeLabelName, keyed by
+	// the raw name. Its inputs are resource names and label or annotation
+	// keys, which are user controlled, so at most maxSanitizedLabelNames of
+	// them are cached and any further ones are sanitized on every call.
+	sanitizedLabelNames sync.Map
+	// sanitizedLabelNamesCount is the number of entries of
+	// sanitizedLabelNames.
+	sanitizedLabelNamesCount int64
+	// maxLabelValueLength is the maximum length in bytes of the values of the
+	// labels converted from Kubernetes labels and annotations, unlimited if
+	// 0. It is set by Builder.WithMaxLabelValueLength.
+	maxLabelValueLength int64
 )
 
 func resourceVersionMetric(rv string) []*metric.Metric {
@@ -50,6 +75,51 @@ func resourceVersionMetric(rv string) []*metric.Metric {
 
 }
 
+// quantityToFloat64 converts the quantity to a float64 at the given scale,
+// e.g. resource.Milli for CPU cores. Quantities that overflow an int64 at that
+// scale would silently yield wrong values, so instead the conversion fails
+// and kube_state_metrics_resource_parse_errors_total is increased for the
+// given resource.
+func quantityToFloat64(resourceName string, q resource.Quantity, scale resource.Scale) (float64, bool) {
+	if q.Cmp(*resource.NewScaledQuantity(math.MaxInt64, scale)) > 0 || q.Cmp(*resource.NewScaledQuantity(math.MinInt64, scale)) < 0 {
+		resourceParseErrorsTotal.WithLabelValues(resourceName).Inc()
+		return 0, false
+	}
+
+	return float64(q.ScaledValue(scale)) / math.Pow10(-int(scale)), true
+}
+
+// milliQuantityToFloat64 converts the quantity to a float64 at milli scale,
+// e.g. to CPU cores. Quantities that overflow at that scale are counted like
+// by quantityToFloat64, but approximated rather than dropped, so that their
+// series are kept.
+func milliQuantityToFloat64(resourceName string, q resource.Quantity) float64 {
+	if v, ok := quantityToFloat64(resourceName, q, resource.Milli); ok {
+		return v
+	}
+	return q.AsApproximateFloat64()
+}
+
+// unitQuantityToFloat64 converts the quantity to a float64 rounded up to a
+// whole number, e.g. of bytes, the same way as milliQuantityToFloat64.
+func unitQuantityToFloat64(resourceName string, q resource.Quantity) float64 {
+	if v, ok := quantityToFloat64(resourceName, q, 0); ok {
+		return v
+	}
+	return q.AsApproximateFloat64()
+}
+
+// quantityToInt64 returns the quantity as an int64 if it is a whole number
+// which fits one. Otherwise the conversion fails and is counted like by
+// quantityToFloat64.
+func quantityToInt64(resourceName string, q *resource.Quantity) (int64, bool) {
+	v, ok := q.AsInt64()
+	if !ok {
+		resourceParseErrorsTotal.WithLabelValues(resourceName).Inc()
+	}
+	return v, ok
+}
+
 func boolFloat64(b bool) float64 {
 	if b {
 		return 1
@@ -126,8 +196,23 @@ func labelName(prefix, labelName string) string {
 	return prefix + "_" + lintLabelName(sanitizeLabelName(labelName))
 }
 
+// maxSanitizedLabelNames is the maximum number of entries of
+// sanitizedLabelNames.
+const maxSanitizedLabelNames = 4096
+
 func sanitizeLabelName(s string) string {
-	return invalidLabelCharRE.ReplaceAllString(s, "_")
+	if sanitized, ok := sanitizedLabelNames.Load(s); ok {
+		return sanitized.(string)
+	}
+	sanitized := invalidLabelCharRE.ReplaceAllString(s, "_")
+	if atomic.AddInt64(&sanitizedLabelNamesCount, 1) <= maxSanitizedLabelNames {
+		if _, loaded := sanitizedLabelNames.LoadOrStore(s, sanitized); loaded {
+			atomic.AddInt64(&sanitizedLabelNamesCount, -1)
+		}
+	} else {
+		atomic.AddInt64(&sanitizedLabelNamesCount, -1)
+	}
+	return sanitized
 }
 
 func lintLabelName(s string) string {
@@ -180,15 +265,222 @@ func createPrometheusLabelKeysValues(prefix string, allKubeData map[string]strin
 
 	if len(allowList) > 0 {
 		if allowList[0] == options.LabelWildcard {
-			return kubeMapToPrometheusLabels(prefix, allKubeData)
+			for k, v := range allKubeData {
+				// The last applied configuration holds the whole serialized
+				// object and is never useful as a metric label.
+				if k == v1.LastAppliedConfigAnnotation {
+					continue
+				}
+				allowedKubeData[k] = truncateLabelValue(v)
+			}
+			return kubeMapToPrometheusLabels(prefix, allowedKubeData)
 		}
 
 		for _, l := range allowList {
+			if re := allowListRegexp(l); re != nil {
+				for k, v := range allKubeData {
+					if re.MatchString(k) {
+						allowedKubeData[k] = truncateLabelValue(v)
+					}
+				}
+				continue
+			}
+
 			v, found := allKubeData[l]
 			if found {
-				allowedKubeData[l] = v
+				allowedKubeData[l] = truncateLabelValue(v)
 			}
 		}
 	}
 	return kubeMapToPrometheusLabels(prefix, allowedKubeData)
 }
+
+// truncatedLabelValueSuffix marks label values truncated to
+// maxLabelValueLength.
+const truncatedLabelValueSuffix = "..."
+
+// truncateLabelValue truncates values longer than maxLabelValueLength to
+// that length, ending with truncatedLabelValueSuffix. Values are only cut at
+// the start of a UTF-8 character.
+func truncateLabelValue(v string) string {
+	max := int(atomic.LoadInt64(&maxLabelValueLength))
+	if max == 0 || len(v) <= max {
+		return v
+	}
+	cut := max - len(truncatedLabelValueSuffix)
+	for cut > 0 && !utf8.RuneStart(v[cut]) {
+		cut--
+	}
+	return v[:cut] + truncatedLabelValueSuffix
+}
+
+// allowListRegexp returns the compiled regular expression of the allowlist
+// entry, or nil if the entry is an exact label key.
+func allowListRegexp(entry string) *regexp.Regexp {
+	if re, ok := allowListRegexps.Load(entry); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	// Invalid regular expressions are already rejected when parsing the flags.
+	re, ok, err := options.ParseLabelRegex(entry)
+	if !ok || err != nil {
+		re = nil
+	}
+	allowListRegexps.Store(entry, re)
+	return re
+}
+
+// compileAllowListRegexps compiles the regular expressions of all the given
+// allowlist entries upfront.
+func compileAllowListRegexps(allowLists map[string][]string) {
+	for _, allowList := range allowLists {
+		for _, entry := range allowList {
+			allowListRegexp(entry)
+		}
+	}
+}
+
+// withUIDLabel wraps the given metric families so that their metrics carry a
+// trailing uid label holding the UID of the object. Metrics which already have
+// a uid label are left untouched.
+func withUIDLabel(families []generator.FamilyGenerator) []generator.FamilyGenerator {
+	return withObjectLabel("uid", objectUID, families)
+}
+
+// withResourceVersionLabel wraps the given metric families so that their
+// metrics carry a trailing resource_version label holding the resource
+// version of the object. Metrics which already have a resource_version label
+// are left untouched.
+func withResourceVersionLabel(families []generator.FamilyGenerator) []generator.FamilyGenerator {
+	return withObjectLabel("resource_version", objectResourceVersion, families)
+}
+
+// withObjectLabel wraps the given metric families so that their metrics carry
+// a trailing label of the given key, holding the value returned by value for
+// the object. Metrics which already have such a label are left untouched.
+func withObjectLabel(key string, value func(obj interface{}) string, families []generator.FamilyGenerator) []generator.FamilyGenerator {
+	wrapped := make([]generator.FamilyGenerator, len(families))
+	for i, family := range families {
+		generate := family.GenerateFunc
+		family.GenerateFunc = func(obj interface{}) *metric.Family {
+			f := generate(obj)
+			v := value(obj)
+			for _, m := range f.Metrics {
+				if hasLabelKey(m.LabelKeys, key) {
+					continue
+				}
+				// The label slices may be shared between metrics, never
+				// append to them in place.
+				m.LabelKeys = append(m.LabelKeys[:len(m.LabelKeys):len(m.LabelKeys)], key)
+				m.LabelValues = append(m.LabelValues[:len(m.LabelValues):len(m.LabelValues)], v)
+			}
+			return f
+		}
+		wrapped[i] = family
+	}
+	return wrapped
+}
+
+// withClusterLabel wraps the given metric families so that their metrics carry
+// a trailing cluster label holding the given cluster name. Metrics which
+// already have a cluster label are left untouched.
+func withClusterLabel(cluster string, families []generator.FamilyGenerator) []generator.FamilyGenerator {
+	return withObjectLabel("cluster", func(interface{}) string { return cluster }, families)
+}
+
+// objectUID returns the UID of a Kubernetes object, or an empty string if obj
+// is not one.
+func objectUID(obj interface{}) string {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return string(o.GetUID())
+}
+
+// objectResourceVersion returns the resource version of a Kubernetes object,
+// or an empty string if obj is not one.
+func objectResourceVersion(obj interface{}) string {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return o.GetResourceVersion()
+}
+
+func hasLabelKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// probeDepth is the depth up to which probeObject sets the fields of nested
+// structs, pointers, slices and maps.
+const probeDepth = 12
+
+// probeObject returns a new object of the type of the given one with all its
+// exported fields set: slices hold a single element, maps a single entry,
+// strings are "probe", numbers 1 and booleans true. The metric families
+// generate most of their series, and thereby most of their label keys, from
+// it.
+func probeObject(expectedType interface{}) interface{} {
+	t := reflect.TypeOf(expectedType)
+	if t.Kind() != reflect.Ptr {
+		v := reflect.New(t).Elem()
+		probeValue(v, 0)
+		return v.Interface()
+	}
+	v := reflect.New(t.Elem())
+	probeValue(v.Elem(), 0)
+	return v.Interface()
+}
+
+func probeValue(v reflect.Value, depth int) {
+	if depth > probeDepth || !v.CanSet() {
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("probe")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		probeValue(p.Elem(), depth+1)
+		v.Set(p)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), 1, 1)
+		probeValue(s.Index(0), depth+1)
+		v.Set(s)
+	case reflect.Map:
+		k := reflect.New(v.Type().Key()).Elem()
+		probeValue(k, depth+1)
+		e := reflect.New(v.Type().Elem()).Elem()
+		probeValue(e, depth+1)
+		m := reflect.MakeMapWithSize(v.Type(), 1)
+		m.SetMapIndex(k, e)
+		v.Set(m)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			probeValue(v.Field(i), depth+1)
+		}
+	}
+}
@@ -18,9 +18,18 @@ package store
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
 )
 
 func TestIsHugePageSizeFromResourceName(t *testing.T) {
@@ -126,6 +135,73 @@ func TestIsExtendedResourceName(t *testing.T) {
 	}
 }
 
+func TestQuantityToFloat64(t *testing.T) {
+	testCases := []struct {
+		quantity  string
+		scale     resource.Scale
+		expectVal float64
+		expectOK  bool
+	}{
+		{
+			quantity:  "250m",
+			scale:     resource.Milli,
+			expectVal: 0.25,
+			expectOK:  true,
+		},
+		{
+			quantity:  "4Gi",
+			expectVal: 4294967296,
+			expectOK:  true,
+		},
+		{
+			quantity: "10E",
+			scale:    resource.Milli,
+			expectOK: false,
+		},
+		{
+			quantity: "100E",
+			expectOK: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("quantity input=%s, scale=%d", tc.quantity, tc.scale), func(t *testing.T) {
+			before := testutil.ToFloat64(resourceParseErrorsTotal.WithLabelValues("test"))
+			v, ok := quantityToFloat64("test", resource.MustParse(tc.quantity), tc.scale)
+			if ok != tc.expectOK {
+				t.Fatalf("Got ok %v but expected %v", ok, tc.expectOK)
+			}
+			if v != tc.expectVal {
+				t.Errorf("Got %v but expected %v", v, tc.expectVal)
+			}
+			errors := testutil.ToFloat64(resourceParseErrorsTotal.WithLabelValues("test")) - before
+			if tc.expectOK && errors != 0 || !tc.expectOK && errors != 1 {
+				t.Errorf("Got %v parse errors for quantity %s", errors, tc.quantity)
+			}
+		})
+	}
+}
+
+func TestQuantityConversionsCountErrors(t *testing.T) {
+	errors := func() float64 {
+		return testutil.ToFloat64(resourceParseErrorsTotal.WithLabelValues("conversions"))
+	}
+
+	if v := milliQuantityToFloat64("conversions", resource.MustParse("1500m")); v != 1.5 || errors() != 0 {
+		t.Errorf("want 1.5 without parse errors, got %v with %v", v, errors())
+	}
+	if v := milliQuantityToFloat64("conversions", resource.MustParse("10E")); v != 1e19 || errors() != 1 {
+		t.Errorf("want the overflowing quantity approximated as 1e19 and counted, got %v with %v parse errors", v, errors())
+	}
+	if v := unitQuantityToFloat64("conversions", resource.MustParse("100E")); v != 1e20 || errors() != 2 {
+		t.Errorf("want the overflowing quantity approximated as 1e20 and counted, got %v with %v parse errors", v, errors())
+	}
+	q := resource.MustParse("500m")
+	if _, ok := quantityToInt64("conversions", &q); ok || errors() != 3 {
+		t.Errorf("want the fractional quantity to fail and be counted, got %v parse errors", errors())
+	}
+}
+
 func TestKubeLabelsToPrometheusLabels(t *testing.T) {
 	testCases := []struct {
 		kubeLabels   map[string]string
@@ -264,3 +340,214 @@ func TestKubeLabelsToPrometheusLabels(t *testing.T) {
 	}
 
 }
+
+func TestCreatePrometheusLabelKeysValues(t *testing.T) {
+	kubeLabels := map[string]string{
+		"app":                          "foobar",
+		"team.backend":                 "alpha",
+		"team.frontend":                "beta",
+		"teams":                        "gamma",
+		v1.LastAppliedConfigAnnotation: "{}",
+	}
+
+	testCases := []struct {
+		allowList    []string
+		expectKeys   []string
+		expectValues []string
+	}{
+		{
+			allowList:    []string{"app"},
+			expectKeys:   []string{"label_app"},
+			expectValues: []string{"foobar"},
+		},
+		{
+			allowList:    []string{"/team\\..*/"},
+			expectKeys:   []string{"label_team_backend", "label_team_frontend"},
+			expectValues: []string{"alpha", "beta"},
+		},
+		{
+			allowList:    []string{"*"},
+			expectKeys:   []string{"label_app", "label_team_backend", "label_team_frontend", "label_teams"},
+			expectValues: []string{"foobar", "alpha", "beta", "gamma"},
+		},
+		{
+			allowList:    []string{"/team/", "app"},
+			expectKeys:   []string{"label_app"},
+			expectValues: []string{"foobar"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("allowlist=%v", tc.allowList), func(t *testing.T) {
+			labelKeys, labelValues := createPrometheusLabelKeysValues("label", kubeLabels, tc.allowList)
+			if !reflect.DeepEqual(labelKeys, tc.expectKeys) {
+				t.Errorf("Got Prometheus label keys %v but expected %v", labelKeys, tc.expectKeys)
+			}
+			if !reflect.DeepEqual(labelValues, tc.expectValues) {
+				t.Errorf("Got Prometheus label values %v but expected %v", labelValues, tc.expectValues)
+			}
+		})
+	}
+}
+
+func TestMaxLabelValueLength(t *testing.T) {
+	b := NewBuilder()
+	if err := b.WithMaxLabelValueLength(3); err == nil {
+		t.Error("expected a length not exceeding the suffix to be rejected")
+	}
+	if err := b.WithMaxLabelValueLength(8); err != nil {
+		t.Fatal(err)
+	}
+	defer b.WithMaxLabelValueLength(0)
+
+	kubeAnnotations := map[string]string{
+		"short":   "12345678",
+		"long":    "123456789",
+		"unicode": "1234€6789",
+	}
+	keys, values := createPrometheusLabelKeysValues("annotation", kubeAnnotations, []string{"short", "long", "unicode"})
+	got := map[string]string{}
+	for i, k := range keys {
+		got[k] = values[i]
+	}
+	want := map[string]string{
+		"annotation_short":   "12345678",
+		"annotation_long":    "12345...",
+		"annotation_unicode": "1234...",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected truncated label values %v, got %v", want, got)
+	}
+}
+
+func TestSanitizeLabelName(t *testing.T) {
+	for _, name := range []string{"nvidia.com/gpu", "nvidia.com/gpu", "hugepages-2Mi", "cpu"} {
+		if got, want := sanitizeLabelName(name), invalidLabelCharRE.ReplaceAllString(name, "_"); got != want {
+			t.Errorf("sanitizeLabelName(%q): want %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestSanitizeLabelNameBounded(t *testing.T) {
+	for i := 0; i < 2*maxSanitizedLabelNames; i++ {
+		name := fmt.Sprintf("example.com/key-%d", i)
+		if got, want := sanitizeLabelName(name), fmt.Sprintf("example_com_key_%d", i); got != want {
+			t.Fatalf("sanitizeLabelName(%q): want %q, got %q", name, want, got)
+		}
+	}
+
+	entries := 0
+	sanitizedLabelNames.Range(func(_, _ interface{}) bool {
+		entries++
+		return true
+	})
+	if entries > maxSanitizedLabelNames {
+		t.Errorf("expected at most %d cached label names, got %d", maxSanitizedLabelNames, entries)
+	}
+}
+
+func BenchmarkSanitizeLabelName(b *testing.B) {
+	names := []string{"cpu", "memory", "ephemeral-storage", "hugepages-2Mi", "nvidia.com/gpu", "attachable-volumes-aws-ebs"}
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			invalidLabelCharRE.ReplaceAllString(names[i%len(names)], "_")
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sanitizeLabelName(names[i%len(names)])
+		}
+	})
+}
+
+func TestWithUIDLabel(t *testing.T) {
+	sharedKeys := make([]string, 1, 2)
+	sharedKeys[0] = "namespace"
+	families := withUIDLabel([]generator.FamilyGenerator{
+		*generator.NewFamilyGenerator("test_info", "", metric.Gauge, "", func(obj interface{}) *metric.Family {
+			return &metric.Family{
+				Metrics: []*metric.Metric{
+					{LabelKeys: sharedKeys, LabelValues: []string{"ns1"}, Value: 1},
+					{LabelKeys: []string{"namespace", "uid"}, LabelValues: []string{"ns1", "uid0"}, Value: 1},
+				},
+			}
+		}),
+	})
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", UID: "uid1"}}
+	want := `test_info{namespace="ns1",uid="uid1"} 1
+test_info{namespace="ns1",uid="uid0"} 1
+`
+	for _, obj := range []interface{}{pod, cache.DeletedFinalStateUnknown{Key: "ns1/pod1", Obj: pod}} {
+		if got := string(families[0].Generate(obj).ByteSlice()); got != want {
+			t.Errorf("unexpected metrics for %T:\nwant: %sgot:  %s", obj, want, got)
+		}
+	}
+	if len(sharedKeys[:cap(sharedKeys)][1]) != 0 {
+		t.Errorf("expected shared label keys to be left untouched, got %v", sharedKeys[:cap(sharedKeys)])
+	}
+}
+
+func TestWithResourceVersionLabel(t *testing.T) {
+	families := withResourceVersionLabel([]generator.FamilyGenerator{
+		*generator.NewFamilyGenerator("test_info", "", metric.Gauge, "", func(obj interface{}) *metric.Family {
+			return &metric.Family{
+				Metrics: []*metric.Metric{
+					{LabelKeys: []string{"namespace"}, LabelValues: []string{"ns1"}, Value: 1},
+				},
+			}
+		}),
+	})
+
+	vpa := &autoscaling.VerticalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", ResourceVersion: "42"}}
+	want := `test_info{namespace="ns1",resource_version="42"} 1
+`
+	for _, obj := range []interface{}{vpa, cache.DeletedFinalStateUnknown{Key: "ns1/vpa1", Obj: vpa}} {
+		if got := string(families[0].Generate(obj).ByteSlice()); got != want {
+			t.Errorf("unexpected metrics for %T:\nwant: %sgot:  %s", obj, want, got)
+		}
+	}
+}
+
+func TestWithClusterLabel(t *testing.T) {
+	families := withClusterLabel("prod-eu", []generator.FamilyGenerator{
+		*generator.NewFamilyGenerator("test_info", "", metric.Gauge, "", func(obj interface{}) *metric.Family {
+			return &metric.Family{
+				Metrics: []*metric.Metric{
+					{LabelKeys: []string{"namespace"}, LabelValues: []string{"ns1"}, Value: 1},
+					{LabelKeys: []string{"namespace", "cluster"}, LabelValues: []string{"ns1", "other"}, Value: 1},
+					{Value: 2},
+				},
+			}
+		}),
+	})
+	want := `test_info{namespace="ns1",cluster="prod-eu"} 1
+test_info{namespace="ns1",cluster="other"} 1
+test_info{cluster="prod-eu"} 2
+`
+	if got := string(families[0].Generate(nil).ByteSlice()); got != want {
+		t.Errorf("unexpected metrics:\nwant: %sgot:  %s", want, got)
+	}
+
+	// The VerticalPodAutoscaler metrics get the label after their default
+	// labels and own labels.
+	vpa := &autoscaling.VerticalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "vpa1"}}
+	found := false
+	for _, f := range withClusterLabel("prod-eu", vpaMetricFamilies(nil, nil, vpaOptions{})) {
+		if f.Name != "kube_verticalpodautoscaler_metadata_generation" {
+			continue
+		}
+		found = true
+		want := `kube_verticalpodautoscaler_metadata_generation{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",cluster="prod-eu"} 0
+`
+		if got := string(f.Generate(vpa).ByteSlice()); got != want {
+			t.Errorf("unexpected metrics:\nwant: %sgot:  %s", want, got)
+		}
+	}
+	if !found {
+		t.Error("expected kube_verticalpodautoscaler_metadata_generation to be generated")
+	}
+}
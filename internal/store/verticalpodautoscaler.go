@@ -18,18 +18,29 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/pkg/errors"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
-	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	autoscalingv1beta2 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 	vpaclientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
 
 	"k8s.io/kube-state-metrics/v2/pkg/constant"
+	"k8s.io/kube-state-metrics/v2/pkg/logging"
 	"k8s.io/kube-state-metrics/v2/pkg/metric"
 	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
 )
@@ -40,16 +51,142 @@ var (
 	descVerticalPodAutoscalerLabelsName          = "kube_verticalpodautoscaler_labels"
 	descVerticalPodAutoscalerLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descVerticalPodAutoscalerLabelsDefaultLabels = []string{"namespace", "verticalpodautoscaler", "target_api_version", "target_kind", "target_name"}
+
+	// vpaDefaultLabelValues returns the value of each label which can be
+	// chosen as a default label of the VerticalPodAutoscaler metrics.
+	vpaDefaultLabelValues = map[string]func(vpa *autoscaling.VerticalPodAutoscaler, targetRef *autoscalingv1.CrossVersionObjectReference) string{
+		"namespace": func(vpa *autoscaling.VerticalPodAutoscaler, _ *autoscalingv1.CrossVersionObjectReference) string {
+			return vpa.Namespace
+		},
+		"verticalpodautoscaler": func(vpa *autoscaling.VerticalPodAutoscaler, _ *autoscalingv1.CrossVersionObjectReference) string {
+			return vpa.Name
+		},
+		"uid": func(vpa *autoscaling.VerticalPodAutoscaler, _ *autoscalingv1.CrossVersionObjectReference) string {
+			return string(vpa.UID)
+		},
+		"target_api_version": func(_ *autoscaling.VerticalPodAutoscaler, ref *autoscalingv1.CrossVersionObjectReference) string {
+			return ref.APIVersion
+		},
+		"target_kind": func(_ *autoscaling.VerticalPodAutoscaler, ref *autoscalingv1.CrossVersionObjectReference) string {
+			return ref.Kind
+		},
+		"target_name": func(_ *autoscaling.VerticalPodAutoscaler, ref *autoscalingv1.CrossVersionObjectReference) string {
+			return ref.Name
+		},
+	}
 )
 
-func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generator.FamilyGenerator {
-	return []generator.FamilyGenerator{
-		*generator.NewFamilyGenerator(
+// vpaOptions configures the metric families of VerticalPodAutoscalers beyond
+// the annotations and labels allowlists.
+type vpaOptions struct {
+	// defaultLabels prefix all metrics, descVerticalPodAutoscalerLabelsDefaultLabels
+	// if nil.
+	defaultLabels []string
+	// zeroMissingResources makes the container recommendations always carry
+	// cpu and memory.
+	zeroMissingResources bool
+	// preciseCPU exposes cpu without rounding it to millicores.
+	preciseCPU bool
+	// memoryUnit is the unit of the resources measured in bytes, either
+	// constant.UnitByte or constant.UnitMebibyte. Bytes if empty.
+	memoryUnit constant.ResourceUnit
+	// recommenderAnnotation is the annotation holding the recommendations of
+	// each recommender, none if empty.
+	recommenderAnnotation string
+	// recommenderLabelAnnotation is the annotation whose value all metrics
+	// carry as recommender label, none if empty.
+	recommenderLabelAnnotation string
+	// ownerReferences exposes the owner references of the
+	// VerticalPodAutoscalers.
+	ownerReferences bool
+	// dropZeroStateSet exposes only the active series of the families
+	// exposing a state set, e.g. the update mode.
+	dropZeroStateSet bool
+	// recommendationBounds selects the families exposing the bounds of the
+	// container recommendations, vpaRecommendationBoundsSeparate if empty.
+	recommendationBounds string
+	// containerDenylist holds the names or glob patterns of the containers
+	// no recommendations are exposed for.
+	containerDenylist []string
+	// observedContainersAnnotation is the annotation listing the containers
+	// the recommender has observed, none if empty.
+	observedContainersAnnotation string
+	// omitEmptyDefaultLabels drops the default labels whose value is empty,
+	// e.g. the target labels of VerticalPodAutoscalers without targetRef.
+	omitEmptyDefaultLabels bool
+	// skipOffModeRecommendations drops the container recommendations of
+	// VerticalPodAutoscalers whose update mode is Off.
+	skipOffModeRecommendations bool
+}
+
+// containerDenied reports whether no recommendations are exposed for the
+// container.
+func (o vpaOptions) containerDenied(containerName string) bool {
+	for _, pattern := range o.containerDenylist {
+		if ok, _ := path.Match(pattern, containerName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recommended returns the recommended resources as exposed, i.e. always
+// carrying cpu and memory if zeroMissingResources is set.
+func (o vpaOptions) recommended(resources v1.ResourceList) v1.ResourceList {
+	if !o.zeroMissingResources {
+		return resources
+	}
+	return withMissingResources(resources, v1.ResourceCPU, v1.ResourceMemory)
+}
+
+// skipRecommendations reports whether the container recommendations of the
+// VerticalPodAutoscaler are dropped, as they are merely advisory in Off mode.
+// An unset update mode defaults to Auto.
+func (o vpaOptions) skipRecommendations(a *autoscaling.VerticalPodAutoscaler) bool {
+	return o.skipOffModeRecommendations && a.Spec.UpdatePolicy != nil && a.Spec.UpdatePolicy.UpdateMode != nil &&
+		*a.Spec.UpdatePolicy.UpdateMode == autoscaling.UpdateModeOff
+}
+
+// The families exposing the bounds of the container recommendations of
+// VerticalPodAutoscalers.
+const (
+	// vpaRecommendationBoundsSeparate exposes one family per bound of the
+	// recommendation, e.g. kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target.
+	vpaRecommendationBoundsSeparate = "separate"
+	// vpaRecommendationBoundsConsolidated exposes all bounds in
+	// kube_verticalpodautoscaler_status_recommendation, labelled by bound.
+	vpaRecommendationBoundsConsolidated = "consolidated"
+	// vpaRecommendationBoundsBoth exposes the separate and the
+	// consolidated families.
+	vpaRecommendationBoundsBoth = "both"
+)
+
+// vpaMetricFamilies returns the metric families of VerticalPodAutoscalers.
+func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string, opts vpaOptions) []generator.FamilyGenerator {
+	labelNames := opts.defaultLabels
+	if labelNames == nil {
+		labelNames = descVerticalPodAutoscalerLabelsDefaultLabels
+	}
+	defaultLabels := newVPALabels(labelNames, opts.recommenderLabelAnnotation, opts.omitEmptyDefaultLabels)
+	recommended := opts.recommended
+	skipRecommendations := opts.skipRecommendations
+	stateSet := func(ms []*metric.Metric) []*metric.Metric {
+		if !opts.dropZeroStateSet {
+			return ms
+		}
+		return metric.ActiveStates(ms)
+	}
+	families := []generator.FamilyGenerator{}
+
+	// The annotations and labels info metrics carry nothing but the default
+	// labels when no keys are allowed, so they are only generated on demand.
+	if len(allowAnnotationsList) > 0 {
+		families = append(families, *generator.NewFamilyGenerator(
 			descVerticalPodAutoscalerAnnotationsName,
 			descVerticalPodAutoscalerAnnotationsHelp,
 			metric.Gauge,
 			"",
-			wrapVPAFunc(func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
 				annotationKeys, annotationValues := createPrometheusLabelKeysValues("annotation", a.Annotations, allowAnnotationsList)
 				return &metric.Family{
 					Metrics: []*metric.Metric{
@@ -61,13 +198,15 @@ func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 					},
 				}
 			}),
-		),
-		*generator.NewFamilyGenerator(
+		))
+	}
+	if len(allowLabelsList) > 0 {
+		families = append(families, *generator.NewFamilyGenerator(
 			descVerticalPodAutoscalerLabelsName,
 			descVerticalPodAutoscalerLabelsHelp,
 			metric.Gauge,
 			"",
-			wrapVPAFunc(func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
 				labelKeys, labelValues := createPrometheusLabelKeysValues("label", a.Labels, allowLabelsList)
 				return &metric.Family{
 					Metrics: []*metric.Metric{
@@ -79,40 +218,161 @@ func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 					},
 				}
 			}),
+		))
+	}
+
+	families = append(families, []generator.FamilyGenerator{
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_created",
+			"Unix creation timestamp",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+
+				if !a.CreationTimestamp.IsZero() {
+					ms = append(ms, &metric.Metric{
+						Value: float64(a.CreationTimestamp.Unix()),
+					})
+				}
+
+				return &metric.Family{
+					Metrics: ms,
+				}
+			}),
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_info",
+			"Information about the VerticalPodAutoscaler and its target.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				labelKeys, labelValues := defaultLabels.missingTargetLabels(a)
+				return &metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							LabelKeys:   labelKeys,
+							LabelValues: labelValues,
+							Value:       1,
+						},
+					},
+				}
+			}),
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_metadata_generation",
+			"Sequence number representing a specific generation of the desired state.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				return &metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							Value: float64(a.ObjectMeta.Generation),
+						},
+					},
+				}
+			}),
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_spec_target_valid",
+			"Whether the VerticalPodAutoscaler references a target by kind and name.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ref := a.Spec.TargetRef
+				return &metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							Value: boolFloat64(ref != nil && ref.Kind != "" && ref.Name != ""),
+						},
+					},
+				}
+			}),
 		),
 		*generator.NewFamilyGenerator(
 			"kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
 			"Update mode of the VerticalPodAutoscaler.",
 			metric.Gauge,
 			"",
-			wrapVPAFunc(func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
-				ms := []*metric.Metric{}
-
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
 				if a.Spec.UpdatePolicy == nil || a.Spec.UpdatePolicy.UpdateMode == nil {
 					return &metric.Family{
-						Metrics: ms,
+						Metrics: []*metric.Metric{},
 					}
 				}
 
-				for _, mode := range []autoscaling.UpdateMode{
-					autoscaling.UpdateModeOff,
-					autoscaling.UpdateModeInitial,
-					autoscaling.UpdateModeRecreate,
-					autoscaling.UpdateModeAuto,
-				} {
-					var v float64
-					if *a.Spec.UpdatePolicy.UpdateMode == mode {
-						v = 1
-					} else {
-						v = 0
+				modes := []string{
+					string(autoscaling.UpdateModeOff),
+					string(autoscaling.UpdateModeInitial),
+					string(autoscaling.UpdateModeRecreate),
+					string(autoscaling.UpdateModeAuto),
+				}
+				active := string(*a.Spec.UpdatePolicy.UpdateMode)
+				ms := metric.StateSet("update_mode", modes, active)
+
+				// Expose modes unknown to kube-state-metrics as well, e.g. ones
+				// introduced by newer VerticalPodAutoscaler versions.
+				known := false
+				for _, mode := range modes {
+					if mode == active {
+						known = true
+						break
 					}
+				}
+				if !known {
 					ms = append(ms, &metric.Metric{
 						LabelKeys:   []string{"update_mode"},
-						LabelValues: []string{string(mode)},
-						Value:       v,
+						LabelValues: []string{active},
+						Value:       1,
 					})
 				}
 
+				return &metric.Family{
+					Metrics: stateSet(ms),
+				}
+			}),
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_spec_resourcepolicy_container_count",
+			"Number of container policies of the VerticalPodAutoscaler.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				count := 0
+				if a.Spec.ResourcePolicy != nil {
+					count = len(a.Spec.ResourcePolicy.ContainerPolicies)
+				}
+
+				return &metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							Value: float64(count),
+						},
+					},
+				}
+			}),
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_info",
+			"Information about the container policies of the VerticalPodAutoscaler, telling the wildcard policy applying to all containers without a policy of their own from the policies of named containers.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+				if a.Spec.ResourcePolicy == nil || a.Spec.ResourcePolicy.ContainerPolicies == nil {
+					return &metric.Family{
+						Metrics: ms,
+					}
+				}
+
+				for _, c := range a.Spec.ResourcePolicy.ContainerPolicies {
+					ms = append(ms, &metric.Metric{
+						LabelKeys:   []string{"container", "container_policy"},
+						LabelValues: []string{c.ContainerName, vpaContainerPolicy(c.ContainerName)},
+						Value:       1,
+					})
+				}
 				return &metric.Family{
 					Metrics: ms,
 				}
@@ -123,7 +383,7 @@ func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 			"Minimum resources the VerticalPodAutoscaler can set for containers matching the name.",
 			metric.Gauge,
 			"",
-			wrapVPAFunc(func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
 				ms := []*metric.Metric{}
 				if a.Spec.ResourcePolicy == nil || a.Spec.ResourcePolicy.ContainerPolicies == nil {
 					return &metric.Family{
@@ -132,7 +392,7 @@ func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 				}
 
 				for _, c := range a.Spec.ResourcePolicy.ContainerPolicies {
-					ms = append(ms, vpaResourcesToMetrics(c.ContainerName, c.MinAllowed)...)
+					ms = append(ms, vpaResourcesToMetrics(c.ContainerName, c.MinAllowed, opts)...)
 
 				}
 				return &metric.Family{
@@ -145,7 +405,7 @@ func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 			"Maximum resources the VerticalPodAutoscaler can set for containers matching the name.",
 			metric.Gauge,
 			"",
-			wrapVPAFunc(func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
 				ms := []*metric.Metric{}
 				if a.Spec.ResourcePolicy == nil || a.Spec.ResourcePolicy.ContainerPolicies == nil {
 					return &metric.Family{
@@ -154,7 +414,7 @@ func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 				}
 
 				for _, c := range a.Spec.ResourcePolicy.ContainerPolicies {
-					ms = append(ms, vpaResourcesToMetrics(c.ContainerName, c.MaxAllowed)...)
+					ms = append(ms, vpaResourcesToMetrics(c.ContainerName, c.MaxAllowed, opts)...)
 				}
 				return &metric.Family{
 					Metrics: ms,
@@ -162,20 +422,32 @@ func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 			}),
 		),
 		*generator.NewFamilyGenerator(
-			"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound",
-			"Minimum resources the container can use before the VerticalPodAutoscaler updater evicts it.",
+			"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set",
+			"Whether the VerticalPodAutoscaler bounds the resources it can set for containers matching the name.",
 			metric.Gauge,
 			"",
-			wrapVPAFunc(func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
 				ms := []*metric.Metric{}
-				if a.Status.Recommendation == nil || a.Status.Recommendation.ContainerRecommendations == nil {
+				if a.Spec.ResourcePolicy == nil || a.Spec.ResourcePolicy.ContainerPolicies == nil {
 					return &metric.Family{
 						Metrics: ms,
 					}
 				}
 
-				for _, c := range a.Status.Recommendation.ContainerRecommendations {
-					ms = append(ms, vpaResourcesToMetrics(c.ContainerName, c.LowerBound)...)
+				for _, c := range a.Spec.ResourcePolicy.ContainerPolicies {
+					for _, bound := range []struct {
+						name      string
+						resources v1.ResourceList
+					}{
+						{"minallowed", c.MinAllowed},
+						{"maxallowed", c.MaxAllowed},
+					} {
+						ms = append(ms, &metric.Metric{
+							LabelKeys:   []string{"container", "bound"},
+							LabelValues: []string{c.ContainerName, bound.name},
+							Value:       boolFloat64(len(bound.resources) > 0),
+						})
+					}
 				}
 				return &metric.Family{
 					Metrics: ms,
@@ -183,40 +455,340 @@ func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 			}),
 		),
 		*generator.NewFamilyGenerator(
-			"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound",
-			"Maximum resources the container can use before the VerticalPodAutoscaler updater evicts it.",
+			"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode",
+			"Whether the VerticalPodAutoscaler is enabled for containers matching the name.",
 			metric.Gauge,
 			"",
-			wrapVPAFunc(func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
 				ms := []*metric.Metric{}
-				if a.Status.Recommendation == nil || a.Status.Recommendation.ContainerRecommendations == nil {
+				if a.Spec.ResourcePolicy == nil || a.Spec.ResourcePolicy.ContainerPolicies == nil {
 					return &metric.Family{
 						Metrics: ms,
 					}
 				}
 
-				for _, c := range a.Status.Recommendation.ContainerRecommendations {
-					ms = append(ms, vpaResourcesToMetrics(c.ContainerName, c.UpperBound)...)
+				for _, c := range a.Spec.ResourcePolicy.ContainerPolicies {
+					// The VerticalPodAutoscaler defaults to Auto when no mode is set.
+					active := autoscaling.ContainerScalingModeAuto
+					if c.Mode != nil {
+						active = *c.Mode
+					}
+
+					for _, mode := range []autoscaling.ContainerScalingMode{
+						autoscaling.ContainerScalingModeAuto,
+						autoscaling.ContainerScalingModeOff,
+					} {
+						ms = append(ms, &metric.Metric{
+							LabelKeys:   []string{"container", "mode"},
+							LabelValues: []string{c.ContainerName, string(mode)},
+							Value:       boolFloat64(active == mode),
+						})
+					}
+				}
+				return &metric.Family{
+					Metrics: stateSet(ms),
+				}
+			}),
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledresources",
+			"Resources the VerticalPodAutoscaler controls for containers matching the name.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+				if a.Spec.ResourcePolicy == nil || a.Spec.ResourcePolicy.ContainerPolicies == nil {
+					return &metric.Family{
+						Metrics: ms,
+					}
+				}
+
+				for _, c := range a.Spec.ResourcePolicy.ContainerPolicies {
+					if c.ControlledResources == nil {
+						continue
+					}
+					for _, resourceName := range *c.ControlledResources {
+						ms = append(ms, &metric.Metric{
+							LabelKeys:   []string{"container", "resource"},
+							LabelValues: []string{c.ContainerName, sanitizeLabelName(string(resourceName))},
+							Value:       1,
+						})
+					}
+				}
+				return &metric.Family{
+					Metrics: ms,
+				}
+			}),
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledvalues",
+			"Which resource values the VerticalPodAutoscaler controls for containers matching the name.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+				if a.Spec.ResourcePolicy == nil || a.Spec.ResourcePolicy.ContainerPolicies == nil {
+					return &metric.Family{
+						Metrics: ms,
+					}
+				}
+
+				for _, c := range a.Spec.ResourcePolicy.ContainerPolicies {
+					if c.ControlledValues == nil {
+						continue
+					}
+					for _, values := range []autoscaling.ContainerControlledValues{
+						autoscaling.ContainerControlledValuesRequestsAndLimits,
+						autoscaling.ContainerControlledValuesRequestsOnly,
+					} {
+						ms = append(ms, &metric.Metric{
+							LabelKeys:   []string{"container", "controlled_values"},
+							LabelValues: []string{c.ContainerName, string(values)},
+							Value:       boolFloat64(*c.ControlledValues == values),
+						})
+					}
+				}
+				return &metric.Family{
+					Metrics: stateSet(ms),
+				}
+			}),
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_status_condition",
+			"The current status conditions of the VerticalPodAutoscaler.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := make([]*metric.Metric, len(a.Status.Conditions)*len(conditionStatuses))
+
+				for i, c := range a.Status.Conditions {
+					conditionMetrics := addConditionMetrics(c.Status)
+
+					for j, m := range conditionMetrics {
+						metric := m
+
+						metric.LabelKeys = []string{"condition", "status"}
+						metric.LabelValues = append([]string{string(c.Type)}, metric.LabelValues...)
+						ms[i*len(conditionStatuses)+j] = metric
+					}
+				}
+
+				return &metric.Family{
+					Metrics: stateSet(ms),
+				}
+			}),
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_status_recommendation_lastupdate",
+			"Unix timestamp of the last recommendation of the VerticalPodAutoscaler.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+				if a.Status.Recommendation == nil {
+					return &metric.Family{
+						Metrics: ms,
+					}
 				}
+
+				// The status does not carry the time of the last recommendation,
+				// approximate it with the time the recommendation was provided.
+				for _, c := range a.Status.Conditions {
+					if c.Type == autoscaling.RecommendationProvided && c.Status == v1.ConditionTrue && !c.LastTransitionTime.IsZero() {
+						ms = append(ms, &metric.Metric{
+							Value: float64(c.LastTransitionTime.Unix()),
+						})
+					}
+				}
+
 				return &metric.Family{
 					Metrics: ms,
 				}
 			}),
 		),
 		*generator.NewFamilyGenerator(
-			"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target",
-			"Target resources the VerticalPodAutoscaler recommends for the container.",
+			"kube_verticalpodautoscaler_status_recommendation_container_count",
+			"Number of containers the VerticalPodAutoscaler provides a recommendation for.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				count := 0
+				if a.Status.Recommendation != nil {
+					count = len(a.Status.Recommendation.ContainerRecommendations)
+				}
+
+				return &metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							Value: float64(count),
+						},
+					},
+				}
+			}),
+		),
+	}...)
+
+	if opts.recommendationBounds != vpaRecommendationBoundsConsolidated {
+		families = append(families, []generator.FamilyGenerator{
+			*generator.NewFamilyGenerator(
+				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound",
+				"Minimum resources the container can use before the VerticalPodAutoscaler updater evicts it.",
+				metric.Gauge,
+				"",
+				wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+					ms := []*metric.Metric{}
+					if a.Status.Recommendation == nil || a.Status.Recommendation.ContainerRecommendations == nil || skipRecommendations(a) {
+						return &metric.Family{
+							Metrics: ms,
+						}
+					}
+
+					for _, c := range a.Status.Recommendation.ContainerRecommendations {
+						ms = append(ms, vpaResourcesToMetrics(c.ContainerName, recommended(c.LowerBound), opts)...)
+					}
+					return &metric.Family{
+						Metrics: ms,
+					}
+				}),
+			),
+			*generator.NewFamilyGenerator(
+				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound",
+				"Maximum resources the container can use before the VerticalPodAutoscaler updater evicts it.",
+				metric.Gauge,
+				"",
+				wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+					ms := []*metric.Metric{}
+					if a.Status.Recommendation == nil || a.Status.Recommendation.ContainerRecommendations == nil || skipRecommendations(a) {
+						return &metric.Family{
+							Metrics: ms,
+						}
+					}
+
+					for _, c := range a.Status.Recommendation.ContainerRecommendations {
+						ms = append(ms, vpaResourcesToMetrics(c.ContainerName, recommended(c.UpperBound), opts)...)
+					}
+					return &metric.Family{
+						Metrics: ms,
+					}
+				}),
+			),
+			*generator.NewFamilyGenerator(
+				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target",
+				"Target resources the VerticalPodAutoscaler recommends for the container.",
+				metric.Gauge,
+				"",
+				wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+					ms := []*metric.Metric{}
+					if a.Status.Recommendation == nil || a.Status.Recommendation.ContainerRecommendations == nil || skipRecommendations(a) {
+						return &metric.Family{
+							Metrics: ms,
+						}
+					}
+					for _, c := range a.Status.Recommendation.ContainerRecommendations {
+						ms = append(ms, vpaResourcesToMetrics(c.ContainerName, recommended(c.Target), opts)...)
+					}
+					return &metric.Family{
+						Metrics: ms,
+					}
+				}),
+			),
+			*generator.NewFamilyGenerator(
+				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_uncappedtarget",
+				"Target resources the VerticalPodAutoscaler recommends for the container ignoring bounds.",
+				metric.Gauge,
+				"",
+				wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+					ms := []*metric.Metric{}
+					if a.Status.Recommendation == nil || a.Status.Recommendation.ContainerRecommendations == nil || skipRecommendations(a) {
+						return &metric.Family{
+							Metrics: ms,
+						}
+					}
+					for _, c := range a.Status.Recommendation.ContainerRecommendations {
+						ms = append(ms, vpaResourcesToMetrics(c.ContainerName, recommended(c.UncappedTarget), opts)...)
+					}
+					return &metric.Family{
+						Metrics: ms,
+					}
+				}),
+			),
+		}...)
+	}
+	if opts.recommendationBounds == vpaRecommendationBoundsConsolidated || opts.recommendationBounds == vpaRecommendationBoundsBoth {
+		families = append(families, *generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_status_recommendation",
+			"Resources the VerticalPodAutoscaler recommends for the container, by bound of the recommendation.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+				if a.Status.Recommendation == nil || skipRecommendations(a) {
+					return &metric.Family{
+						Metrics: ms,
+					}
+				}
+				for _, c := range a.Status.Recommendation.ContainerRecommendations {
+					for _, bound := range []struct {
+						name      string
+						resources v1.ResourceList
+					}{
+						{"lowerbound", c.LowerBound},
+						{"upperbound", c.UpperBound},
+						{"target", c.Target},
+						{"uncappedtarget", c.UncappedTarget},
+					} {
+						for _, m := range vpaResourcesToMetrics(c.ContainerName, recommended(bound.resources), opts) {
+							m.LabelKeys = append([]string{"bound"}, m.LabelKeys...)
+							m.LabelValues = append([]string{bound.name}, m.LabelValues...)
+							ms = append(ms, m)
+						}
+					}
+				}
+				return &metric.Family{
+					Metrics: ms,
+				}
+			}),
+		))
+	}
+
+	families = append(families, []generator.FamilyGenerator{
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_status_recommendation_clamped",
+			"Whether the target the VerticalPodAutoscaler recommends for the container is clamped, as the uncapped target exceeds the maximum allowed by the container policy.",
 			metric.Gauge,
 			"",
-			wrapVPAFunc(func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
 				ms := []*metric.Metric{}
-				if a.Status.Recommendation == nil || a.Status.Recommendation.ContainerRecommendations == nil {
+				if a.Status.Recommendation == nil {
 					return &metric.Family{
 						Metrics: ms,
 					}
 				}
 				for _, c := range a.Status.Recommendation.ContainerRecommendations {
-					ms = append(ms, vpaResourcesToMetrics(c.ContainerName, c.Target)...)
+					if opts.containerDenied(c.ContainerName) {
+						continue
+					}
+					policy := vpaContainerPolicyFor(a, c.ContainerName)
+					if policy == nil {
+						continue
+					}
+					resourceNames := make([]string, 0, len(c.UncappedTarget))
+					for resourceName := range c.UncappedTarget {
+						resourceNames = append(resourceNames, string(resourceName))
+					}
+					sort.Strings(resourceNames)
+					for _, resourceName := range resourceNames {
+						maxAllowed, ok := policy.MaxAllowed[v1.ResourceName(resourceName)]
+						if !ok {
+							continue
+						}
+						uncapped := c.UncappedTarget[v1.ResourceName(resourceName)]
+						ms = append(ms, &metric.Metric{
+							LabelKeys:   []string{"container", "resource"},
+							LabelValues: []string{c.ContainerName, sanitizeLabelName(resourceName)},
+							Value:       boolFloat64(uncapped.Cmp(maxAllowed) > 0),
+						})
+					}
 				}
 				return &metric.Family{
 					Metrics: ms,
@@ -224,47 +796,212 @@ func vpaMetricFamilies(allowAnnotationsList, allowLabelsList []string) []generat
 			}),
 		),
 		*generator.NewFamilyGenerator(
-			"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_uncappedtarget",
-			"Target resources the VerticalPodAutoscaler recommends for the container ignoring bounds.",
+			"kube_verticalpodautoscaler_status_recommendation_target_to_lowerbound_ratio",
+			"Ratio of the target the VerticalPodAutoscaler recommends for the container to its lower bound.",
 			metric.Gauge,
 			"",
-			wrapVPAFunc(func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
 				ms := []*metric.Metric{}
-				if a.Status.Recommendation == nil || a.Status.Recommendation.ContainerRecommendations == nil {
+				if a.Status.Recommendation == nil {
 					return &metric.Family{
 						Metrics: ms,
 					}
 				}
 				for _, c := range a.Status.Recommendation.ContainerRecommendations {
-					ms = append(ms, vpaResourcesToMetrics(c.ContainerName, c.UncappedTarget)...)
+					if opts.containerDenied(c.ContainerName) {
+						continue
+					}
+					resourceNames := make([]string, 0, len(c.Target))
+					for resourceName := range c.Target {
+						resourceNames = append(resourceNames, string(resourceName))
+					}
+					sort.Strings(resourceNames)
+					for _, resourceName := range resourceNames {
+						// A missing or zero lower bound has no meaningful
+						// ratio, so the series is skipped rather than
+						// exposing +Inf or NaN.
+						lowerBound, ok := c.LowerBound[v1.ResourceName(resourceName)]
+						if !ok || lowerBound.IsZero() {
+							continue
+						}
+						target := c.Target[v1.ResourceName(resourceName)]
+						ms = append(ms, &metric.Metric{
+							LabelKeys:   []string{"container", "resource"},
+							LabelValues: []string{c.ContainerName, sanitizeLabelName(resourceName)},
+							Value:       target.AsApproximateFloat64() / lowerBound.AsApproximateFloat64(),
+						})
+					}
 				}
 				return &metric.Family{
 					Metrics: ms,
 				}
 			}),
 		),
+	}...)
+
+	if opts.recommenderAnnotation != "" {
+		families = append(families, *generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_status_recommendation_by_recommender",
+			"Target resources each recommender of the VerticalPodAutoscaler recommends for the container.",
+			metric.Gauge,
+			"",
+			// The series carry the recommender they were recommended by
+			// already, never the one of the annotation.
+			wrapVPAFunc(newVPALabels(labelNames, "", opts.omitEmptyDefaultLabels), func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+				recommendations, err := vpaRecommendationsByRecommender(a, opts.recommenderAnnotation)
+				if err != nil {
+					klog.ErrorS(err, "Failed to parse recommendations by recommender", "namespace", a.Namespace, "verticalpodautoscaler", a.Name, "annotation", opts.recommenderAnnotation)
+					return &metric.Family{
+						Metrics: ms,
+					}
+				}
+
+				recommenders := make([]string, 0, len(recommendations))
+				for recommender := range recommendations {
+					recommenders = append(recommenders, recommender)
+				}
+				sort.Strings(recommenders)
+				for _, recommender := range recommenders {
+					for _, c := range recommendations[recommender].ContainerRecommendations {
+						for _, m := range vpaResourcesToMetrics(c.ContainerName, recommended(c.Target), opts) {
+							m.LabelKeys = append([]string{"recommender"}, m.LabelKeys...)
+							m.LabelValues = append([]string{recommender}, m.LabelValues...)
+							ms = append(ms, m)
+						}
+					}
+				}
+				return &metric.Family{
+					Metrics: ms,
+				}
+			}),
+		))
+	}
+
+	if opts.observedContainersAnnotation != "" {
+		families = append(families, *generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_status_observed_containers",
+			"Number of containers the recommender of the VerticalPodAutoscaler has observed, including those without a recommendation yet.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+				containers, ok := vpaObservedContainers(a, opts.observedContainersAnnotation)
+				if ok {
+					ms = append(ms, &metric.Metric{
+						Value: float64(len(containers)),
+					})
+				}
+				return &metric.Family{
+					Metrics: ms,
+				}
+			}),
+		))
+	}
+
+	if opts.ownerReferences {
+		families = append(families, *generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_owner",
+			"Information about the owners of the VerticalPodAutoscaler.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(defaultLabels, func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				owners := a.GetOwnerReferences()
+				ms := make([]*metric.Metric, len(owners))
+				for i, owner := range owners {
+					ms[i] = &metric.Metric{
+						LabelKeys:   []string{"owner_kind", "owner_name", "owner_is_controller"},
+						LabelValues: []string{owner.Kind, owner.Name, strconv.FormatBool(owner.Controller != nil && *owner.Controller)},
+						Value:       1,
+					}
+				}
+				return &metric.Family{
+					Metrics: ms,
+				}
+			}),
+		))
 	}
+
+	return families
+}
+
+// vpaContainerPolicy returns the container_policy label value of a container
+// policy, which is wildcard for the default policy applying to all containers
+// without a policy of their own, and container otherwise.
+func vpaContainerPolicy(containerName string) string {
+	if containerName == autoscaling.DefaultContainerResourcePolicy {
+		return "wildcard"
+	}
+	return "container"
 }
 
-func vpaResourcesToMetrics(containerName string, resources v1.ResourceList) []*metric.Metric {
+// vpaContainerPolicyFor returns the container policy applying to the
+// container, which is the policy of its own or else the default policy, and
+// nil without either of them.
+func vpaContainerPolicyFor(a *autoscaling.VerticalPodAutoscaler, containerName string) *autoscaling.ContainerResourcePolicy {
+	if a.Spec.ResourcePolicy == nil {
+		return nil
+	}
+	var defaultPolicy *autoscaling.ContainerResourcePolicy
+	for i, policy := range a.Spec.ResourcePolicy.ContainerPolicies {
+		switch policy.ContainerName {
+		case containerName:
+			return &a.Spec.ResourcePolicy.ContainerPolicies[i]
+		case autoscaling.DefaultContainerResourcePolicy:
+			defaultPolicy = &a.Spec.ResourcePolicy.ContainerPolicies[i]
+		}
+	}
+	return defaultPolicy
+}
+
+// vpaResourcesToMetrics converts the resources of a container to metrics. CPU
+// is rounded to millicores unless preciseCPU is set, and resources measured in
+// bytes are exposed in the memoryUnit of the options.
+func vpaResourcesToMetrics(containerName string, resources v1.ResourceList, opts vpaOptions) []*metric.Metric {
 	ms := []*metric.Metric{}
+	if opts.containerDenied(containerName) {
+		return ms
+	}
 	for resourceName, val := range resources {
+		var (
+			scale resource.Scale
+			unit  constant.ResourceUnit
+		)
 		switch resourceName {
 		case v1.ResourceCPU:
-			ms = append(ms, &metric.Metric{
-				LabelValues: []string{containerName, sanitizeLabelName(string(resourceName)), string(constant.UnitCore)},
-				Value:       float64(val.MilliValue()) / 1000,
-			})
+			scale, unit = resource.Milli, constant.UnitCore
 		case v1.ResourceStorage:
 			fallthrough
 		case v1.ResourceEphemeralStorage:
 			fallthrough
 		case v1.ResourceMemory:
-			ms = append(ms, &metric.Metric{
-				LabelValues: []string{containerName, sanitizeLabelName(string(resourceName)), string(constant.UnitByte)},
-				Value:       float64(val.Value()),
-			})
+			unit = constant.UnitByte
+		default:
+			if isHugePageResourceName(resourceName) {
+				unit = constant.UnitByte
+			} else if isExtendedResourceName(resourceName) {
+				unit = constant.UnitInteger
+			} else {
+				continue
+			}
+		}
+
+		var v float64
+		if resourceName == v1.ResourceCPU && opts.preciseCPU {
+			v = val.AsApproximateFloat64()
+		} else {
+			var ok bool
+			if v, ok = quantityToFloat64("verticalpodautoscalers", val, scale); !ok {
+				continue
+			}
+		}
+		if unit == constant.UnitByte && opts.memoryUnit == constant.UnitMebibyte {
+			v, unit = v/(1<<20), constant.UnitMebibyte
 		}
+		ms = append(ms, &metric.Metric{
+			LabelValues: []string{containerName, sanitizeLabelName(string(resourceName)), string(unit)},
+			Value:       v,
+		})
 	}
 	for _, metric := range ms {
 		metric.LabelKeys = []string{"container", "resource", "unit"}
@@ -272,9 +1009,171 @@ func vpaResourcesToMetrics(containerName string, resources v1.ResourceList) []*m
 	return ms
 }
 
-func wrapVPAFunc(f func(*autoscaling.VerticalPodAutoscaler) *metric.Family) func(interface{}) *metric.Family {
+// vpaDefaultRecommender is the name of the recommender the status
+// recommendation is attributed to when the VerticalPodAutoscaler lacks the
+// recommendations by recommender.
+const vpaDefaultRecommender = "default"
+
+// vpaRecommendationsByRecommender decodes the recommendations keyed by
+// recommender from the given annotation. Without the annotation, the status
+// recommendation is returned as the one of the default recommender.
+func vpaRecommendationsByRecommender(a *autoscaling.VerticalPodAutoscaler, annotation string) (map[string]autoscaling.RecommendedPodResources, error) {
+	value, ok := a.Annotations[annotation]
+	if !ok {
+		if a.Status.Recommendation == nil {
+			return nil, nil
+		}
+		return map[string]autoscaling.RecommendedPodResources{vpaDefaultRecommender: *a.Status.Recommendation}, nil
+	}
+
+	var recommendations map[string]autoscaling.RecommendedPodResources
+	if err := json.Unmarshal([]byte(value), &recommendations); err != nil {
+		return nil, err
+	}
+	return recommendations, nil
+}
+
+// vpaObservedContainers parses the containers the recommender has observed
+// from the given annotation, a comma-separated list of container names as
+// written by the recommender, e.g. "app, sidecar". It reports false if the
+// annotation is missing or holds an empty container name.
+func vpaObservedContainers(a *autoscaling.VerticalPodAutoscaler, annotation string) ([]string, bool) {
+	value, ok := a.Annotations[annotation]
+	if !ok {
+		return nil, false
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return []string{}, true
+	}
+
+	containers := strings.Split(value, ",")
+	for i, c := range containers {
+		containers[i] = strings.TrimSpace(c)
+		if containers[i] == "" {
+			klog.V(4).InfoS("Skipping unparseable observed containers annotation", "namespace", a.Namespace, "verticalpodautoscaler", a.Name, "annotation", annotation, "value", value)
+			return nil, false
+		}
+	}
+	return containers, true
+}
+
+// withMissingResources returns a copy of resources in which the given
+// resources are set to zero if they are missing.
+func withMissingResources(resources v1.ResourceList, names ...v1.ResourceName) v1.ResourceList {
+	filled := make(v1.ResourceList, len(resources)+len(names))
+	for name, val := range resources {
+		filled[name] = val
+	}
+	for _, name := range names {
+		if _, ok := filled[name]; !ok {
+			filled[name] = resource.Quantity{}
+		}
+	}
+	return filled
+}
+
+// isVPADefaultLabel returns whether label can be chosen as a default label of
+// the VerticalPodAutoscaler metrics.
+func isVPADefaultLabel(label string) bool {
+	_, ok := vpaDefaultLabelValues[label]
+	return ok
+}
+
+// vpaLabels are the labels all VerticalPodAutoscaler metrics start with.
+type vpaLabels struct {
+	keys   []string
+	values []func(*autoscaling.VerticalPodAutoscaler, *autoscalingv1.CrossVersionObjectReference) string
+	// omitEmpty drops the labels whose value is empty.
+	omitEmpty bool
+}
+
+// newVPALabels returns the given default labels, which must be keys of
+// vpaDefaultLabelValues, followed by a recommender label holding the value of
+// the recommender annotation if it is not empty. VerticalPodAutoscalers
+// without the annotation get an empty recommender label, unless omitEmpty
+// drops all labels with an empty value.
+func newVPALabels(defaultLabels []string, recommenderAnnotation string, omitEmpty bool) vpaLabels {
+	l := vpaLabels{
+		keys:      append([]string{}, defaultLabels...),
+		values:    make([]func(*autoscaling.VerticalPodAutoscaler, *autoscalingv1.CrossVersionObjectReference) string, len(defaultLabels)),
+		omitEmpty: omitEmpty,
+	}
+	for i, label := range defaultLabels {
+		l.values[i] = vpaDefaultLabelValues[label]
+	}
+	if recommenderAnnotation != "" {
+		l.keys = append(l.keys, "recommender")
+		l.values = append(l.values, func(vpa *autoscaling.VerticalPodAutoscaler, _ *autoscalingv1.CrossVersionObjectReference) string {
+			return vpa.Annotations[recommenderAnnotation]
+		})
+	}
+	return l
+}
+
+// missingTargetLabels returns the labels naming the target of the
+// VerticalPodAutoscaler which the labels do not carry, either as they are not
+// among them or as they are omitted for being empty.
+func (l vpaLabels) missingTargetLabels(vpa *autoscaling.VerticalPodAutoscaler) ([]string, []string) {
+	targetRef := vpa.Spec.TargetRef
+	if targetRef == nil {
+		targetRef = &autoscalingv1.CrossVersionObjectReference{}
+	}
+	var keys, values []string
+	for _, key := range []string{"target_api_version", "target_kind", "target_name"} {
+		value := vpaDefaultLabelValues[key](vpa, targetRef)
+		if hasLabelKey(l.keys, key) && (!l.omitEmpty || value != "") {
+			continue
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return keys, values
+}
+
+// omitEmptyLabels returns the given labels without the ones whose value is
+// empty. The given slices are returned as is if no value is empty.
+func omitEmptyLabels(keys, values []string) ([]string, []string) {
+	empty := 0
+	for _, v := range values {
+		if v == "" {
+			empty++
+		}
+	}
+	if empty == 0 {
+		return keys, values
+	}
+
+	k := make([]string, 0, len(keys)-empty)
+	v := make([]string, 0, len(values)-empty)
+	for i := range values {
+		if values[i] != "" {
+			k = append(k, keys[i])
+			v = append(v, values[i])
+		}
+	}
+	return k, v
+}
+
+// wrapVPAFunc prefixes the metrics generated by f with the given labels.
+func wrapVPAFunc(defaultLabels vpaLabels, f func(*autoscaling.VerticalPodAutoscaler) *metric.Family) func(interface{}) *metric.Family {
+	labelValues := defaultLabels.values
+
 	return func(obj interface{}) *metric.Family {
-		vpa := obj.(*autoscaling.VerticalPodAutoscaler)
+		vpa, ok := obj.(*autoscaling.VerticalPodAutoscaler)
+		if !ok {
+			// Deleted objects may be handed over in their last known state.
+			if tombstone, isTombstone := obj.(cache.DeletedFinalStateUnknown); isTombstone {
+				vpa, ok = tombstone.Obj.(*autoscaling.VerticalPodAutoscaler)
+			}
+		}
+		if !ok {
+			klog.Errorf("unexpected object of type %T, expected %T", obj, vpa)
+			unexpectedObjectsTotal.WithLabelValues("verticalpodautoscalers").Inc()
+			return &metric.Family{
+				Metrics: []*metric.Metric{},
+			}
+		}
 
 		metricFamily := f(vpa)
 		targetRef := vpa.Spec.TargetRef
@@ -287,24 +1186,297 @@ func wrapVPAFunc(f func(*autoscaling.VerticalPodAutoscaler) *metric.Family) func
 			targetRef = &autoscalingv1.CrossVersionObjectReference{}
 		}
 
+		keys := defaultLabels.keys
+		values := make([]string, len(labelValues))
+		for i, value := range labelValues {
+			values[i] = value(vpa, targetRef)
+		}
+		if defaultLabels.omitEmpty {
+			keys, values = omitEmptyLabels(keys, values)
+		}
 		for _, m := range metricFamily.Metrics {
-			m.LabelKeys = append(descVerticalPodAutoscalerLabelsDefaultLabels, m.LabelKeys...)
-			m.LabelValues = append([]string{vpa.Namespace, vpa.Name, targetRef.APIVersion, targetRef.Kind, targetRef.Name}, m.LabelValues...)
+			m.LabelKeys = append(append(make([]string, 0, len(keys)+len(m.LabelKeys)), keys...), m.LabelKeys...)
+			m.LabelValues = append(append(make([]string, 0, len(values)+len(m.LabelValues)), values...), m.LabelValues...)
 		}
 
 		return metricFamily
 	}
 }
 
-func createVPAListWatchFunc(vpaClient vpaclientset.Interface) func(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-	return func(kubeClient clientset.Interface, ns string) cache.ListerWatcher {
-		return &cache.ListWatch{
-			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-				return vpaClient.AutoscalingV1beta2().VerticalPodAutoscalers(ns).List(context.TODO(), opts)
+// errVPANotServed is returned by the list requests of VerticalPodAutoscalers
+// as long as the apiserver serves none of their API versions.
+var errVPANotServed = errors.New("verticalpodautoscalers are not served by the apiserver, waiting for their CustomResourceDefinition to be installed")
+
+// createVPAListWatchFunc returns a list-watch factory for VerticalPodAutoscalers.
+// The stable autoscaling.k8s.io/v1 API is preferred when it is served by the
+// apiserver, otherwise v1beta2 objects are listed and watched and converted to
+// v1, so that the metric families only have to deal with a single version.
+// If targetKinds is not empty, VerticalPodAutoscalers targeting other kinds
+// are dropped.
+func createVPAListWatchFunc(vpaClient vpaclientset.Interface, targetKinds map[string]struct{}) func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+	resolver := &vpaAPIResolver{vpaClient: vpaClient}
+
+	return func(_ clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher {
+		lw := &contextListWatch{
+			listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+				listWatchFunc, err := resolver.resolve()
+				if err != nil {
+					return nil, err
+				}
+				return listWatchFunc(ns, fieldSelector).listFunc(ctx, opts)
+			},
+			watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+				listWatchFunc, err := resolver.resolve()
+				if err != nil {
+					return nil, err
+				}
+				return listWatchFunc(ns, fieldSelector).watchFunc(ctx, opts)
+			},
+		}
+		if len(targetKinds) == 0 {
+			return lw
+		}
+		return filterVPATargetKinds(lw, targetKinds)
+	}
+}
+
+// vpaAPIResolver discovers the API version VerticalPodAutoscalers are served
+// in. The version is only discovered by the first list request, so that no
+// request is made if VerticalPodAutoscalers are not enabled. As long as no
+// version is served, e.g. because the operator installing the
+// CustomResourceDefinition is still starting up, the list requests fail with
+// errVPANotServed and the reflectors retry them with their exponential
+// backoff, capped at 30 seconds, until the store comes online.
+type vpaAPIResolver struct {
+	vpaClient vpaclientset.Interface
+
+	mu            sync.Mutex
+	listWatchFunc func(ns string, fieldSelector string) *contextListWatch
+	waiting       bool
+}
+
+func (r *vpaAPIResolver) resolve() (func(ns string, fieldSelector string) *contextListWatch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.listWatchFunc != nil {
+		return r.listWatchFunc, nil
+	}
+	switch {
+	case isVPAServed(r.vpaClient, autoscaling.SchemeGroupVersion.String()):
+		r.listWatchFunc = createVPAV1ListWatchFunc(r.vpaClient)
+	case isVPAServed(r.vpaClient, autoscalingv1beta2.SchemeGroupVersion.String()):
+		klog.Infof("%s is not served, falling back to %s for verticalpodautoscalers", autoscaling.SchemeGroupVersion, autoscalingv1beta2.SchemeGroupVersion)
+		r.listWatchFunc = createVPAV1beta2ListWatchFunc(r.vpaClient)
+	default:
+		if !r.waiting {
+			logging.Warningf("%v", errVPANotServed)
+			r.waiting = true
+		}
+		storeWaitingForAPI.WithLabelValues("verticalpodautoscalers").Set(1)
+		return nil, errVPANotServed
+	}
+
+	if r.waiting {
+		klog.Info("verticalpodautoscalers are served by the apiserver now")
+	}
+	storeWaitingForAPI.WithLabelValues("verticalpodautoscalers").Set(0)
+	return r.listWatchFunc, nil
+}
+
+func createVPAV1ListWatchFunc(vpaClient vpaclientset.Interface) func(ns string, fieldSelector string) *contextListWatch {
+	return func(ns string, fieldSelector string) *contextListWatch {
+		return &contextListWatch{
+			listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+				opts.FieldSelector = fieldSelector
+				return vpaClient.AutoscalingV1().VerticalPodAutoscalers(ns).List(ctx, opts)
+			},
+			watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+				opts.FieldSelector = fieldSelector
+				return vpaClient.AutoscalingV1().VerticalPodAutoscalers(ns).Watch(ctx, opts)
 			},
-			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-				return vpaClient.AutoscalingV1beta2().VerticalPodAutoscalers(ns).Watch(context.TODO(), opts)
+		}
+	}
+}
+
+func createVPAV1beta2ListWatchFunc(vpaClient vpaclientset.Interface) func(ns string, fieldSelector string) *contextListWatch {
+	return func(ns string, fieldSelector string) *contextListWatch {
+		return &contextListWatch{
+			listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+				opts.FieldSelector = fieldSelector
+				list, err := vpaClient.AutoscalingV1beta2().VerticalPodAutoscalers(ns).List(ctx, opts)
+				if err != nil {
+					return nil, err
+				}
+				return convertVPAV1beta2List(list), nil
+			},
+			watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+				opts.FieldSelector = fieldSelector
+				w, err := vpaClient.AutoscalingV1beta2().VerticalPodAutoscalers(ns).Watch(ctx, opts)
+				if err != nil {
+					return nil, err
+				}
+				return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+					if e.Type == watch.Error {
+						return e, true
+					}
+					vpa, ok := e.Object.(*autoscalingv1beta2.VerticalPodAutoscaler)
+					if !ok {
+						skipObject("verticalpodautoscalers", skipReasonConversion, e.Object)
+						return e, false
+					}
+					e.Object = convertVPAV1beta2(vpa)
+					return e, true
+				}), nil
 			},
 		}
 	}
 }
+
+// filterVPATargetKinds wraps a VerticalPodAutoscaler list-watch and drops all
+// objects whose target kind is not part of kinds.
+func filterVPATargetKinds(lw *contextListWatch, kinds map[string]struct{}) *contextListWatch {
+	keep := func(vpa *autoscaling.VerticalPodAutoscaler) bool {
+		if vpa.Spec.TargetRef == nil {
+			return false
+		}
+		_, ok := kinds[vpa.Spec.TargetRef.Kind]
+		return ok
+	}
+
+	return &contextListWatch{
+		listFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			obj, err := lw.listFunc(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			list, ok := obj.(*autoscaling.VerticalPodAutoscalerList)
+			if !ok {
+				return obj, nil
+			}
+
+			res := &autoscaling.VerticalPodAutoscalerList{
+				ListMeta: list.ListMeta,
+				Items:    make([]autoscaling.VerticalPodAutoscaler, 0, len(list.Items)),
+			}
+			for i := range list.Items {
+				if keep(&list.Items[i]) {
+					res.Items = append(res.Items, list.Items[i])
+				}
+			}
+			return res, nil
+		},
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			w, err := lw.watchFunc(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+
+			return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+				// Bookmarks only carry the resource version, which the
+				// reflector needs regardless of the target kind.
+				if e.Type == watch.Bookmark {
+					return e, true
+				}
+				vpa, ok := e.Object.(*autoscaling.VerticalPodAutoscaler)
+				if !ok || keep(vpa) {
+					return e, true
+				}
+				// The target of a previously kept VerticalPodAutoscaler may have
+				// changed, make sure its metrics are removed from the store.
+				// Deleting objects that were never kept is a no-op.
+				if e.Type == watch.Modified {
+					e.Type = watch.Deleted
+					return e, true
+				}
+				return e, false
+			}), nil
+		},
+	}
+}
+
+// isVPAServed checks whether the apiserver serves VerticalPodAutoscalers in
+// the given group version.
+func isVPAServed(vpaClient vpaclientset.Interface, groupVersion string) bool {
+	resources, err := vpaClient.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "verticalpodautoscalers" {
+			return true
+		}
+	}
+	return false
+}
+
+func convertVPAV1beta2List(in *autoscalingv1beta2.VerticalPodAutoscalerList) *autoscaling.VerticalPodAutoscalerList {
+	out := &autoscaling.VerticalPodAutoscalerList{
+		ListMeta: in.ListMeta,
+		Items:    make([]autoscaling.VerticalPodAutoscaler, 0, len(in.Items)),
+	}
+	for i := range in.Items {
+		out.Items = append(out.Items, *convertVPAV1beta2(&in.Items[i]))
+	}
+	return out
+}
+
+// convertVPAV1beta2 converts a v1beta2 VerticalPodAutoscaler into its v1
+// counterpart. The v1 API is a superset of v1beta2, so no information is lost.
+func convertVPAV1beta2(in *autoscalingv1beta2.VerticalPodAutoscaler) *autoscaling.VerticalPodAutoscaler {
+	out := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: in.ObjectMeta,
+		Spec: autoscaling.VerticalPodAutoscalerSpec{
+			TargetRef: in.Spec.TargetRef,
+		},
+	}
+
+	if in.Spec.UpdatePolicy != nil {
+		out.Spec.UpdatePolicy = &autoscaling.PodUpdatePolicy{}
+		if in.Spec.UpdatePolicy.UpdateMode != nil {
+			updateMode := autoscaling.UpdateMode(*in.Spec.UpdatePolicy.UpdateMode)
+			out.Spec.UpdatePolicy.UpdateMode = &updateMode
+		}
+	}
+
+	if in.Spec.ResourcePolicy != nil {
+		out.Spec.ResourcePolicy = &autoscaling.PodResourcePolicy{}
+		for _, c := range in.Spec.ResourcePolicy.ContainerPolicies {
+			policy := autoscaling.ContainerResourcePolicy{
+				ContainerName: c.ContainerName,
+				MinAllowed:    c.MinAllowed,
+				MaxAllowed:    c.MaxAllowed,
+			}
+			if c.Mode != nil {
+				mode := autoscaling.ContainerScalingMode(*c.Mode)
+				policy.Mode = &mode
+			}
+			out.Spec.ResourcePolicy.ContainerPolicies = append(out.Spec.ResourcePolicy.ContainerPolicies, policy)
+		}
+	}
+
+	if in.Status.Recommendation != nil {
+		out.Status.Recommendation = &autoscaling.RecommendedPodResources{}
+		for _, c := range in.Status.Recommendation.ContainerRecommendations {
+			out.Status.Recommendation.ContainerRecommendations = append(out.Status.Recommendation.ContainerRecommendations, autoscaling.RecommendedContainerResources{
+				ContainerName:  c.ContainerName,
+				Target:         c.Target,
+				LowerBound:     c.LowerBound,
+				UpperBound:     c.UpperBound,
+				UncappedTarget: c.UncappedTarget,
+			})
+		}
+	}
+
+	for _, c := range in.Status.Conditions {
+		out.Status.Conditions = append(out.Status.Conditions, autoscaling.VerticalPodAutoscalerCondition{
+			Type:               autoscaling.VerticalPodAutoscalerConditionType(c.Type),
+			Status:             c.Status,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+
+	return out
+}
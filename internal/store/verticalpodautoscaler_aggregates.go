@@ -0,0 +1,384 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/kube-state-metrics/v2/pkg/constant"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+// vpaTargets are the cpu and memory targets recommended for the containers
+// of one or more VerticalPodAutoscalers, along with their update modes and
+// namespaces.
+type vpaTargets struct {
+	cpuCores    []float64
+	memoryBytes []float64
+	// updateModes holds the update mode of each VerticalPodAutoscaler, Auto
+	// if it does not set one.
+	updateModes []string
+	// namespaces holds the namespace of each VerticalPodAutoscaler.
+	namespaces []string
+}
+
+// vpaRecommendationSumFamilies returns the metric families of the sums of the
+// targets recommended across all VerticalPodAutoscalers. They are generated
+// from vpaTargets rather than a single VerticalPodAutoscaler.
+func vpaRecommendationSumFamilies() []generator.FamilyGenerator {
+	return []generator.FamilyGenerator{
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum",
+			"Sum of the cpu targets the VerticalPodAutoscalers recommend for their containers.",
+			metric.Gauge,
+			"",
+			func(obj interface{}) *metric.Family {
+				return &metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							Value: sumFloat64(obj.(vpaTargets).cpuCores),
+						},
+					},
+				}
+			},
+		),
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum",
+			"Sum of the memory targets the VerticalPodAutoscalers recommend for their containers.",
+			metric.Gauge,
+			"",
+			func(obj interface{}) *metric.Family {
+				return &metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							Value: sumFloat64(obj.(vpaTargets).memoryBytes),
+						},
+					},
+				}
+			},
+		),
+	}
+}
+
+// vpaRecommendationHistogramFamilies returns the metric families of the
+// distribution of the targets recommended across all VerticalPodAutoscalers,
+// observed in the given buckets.
+func vpaRecommendationHistogramFamilies(cpuBuckets []float64) []generator.FamilyGenerator {
+	return []generator.FamilyGenerator{
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_status_recommendation_target_cpu_cores",
+			"Distribution of the cpu targets the VerticalPodAutoscalers recommend for their containers.",
+			metric.Histogram,
+			"",
+			func(obj interface{}) *metric.Family {
+				return &metric.Family{
+					Metrics: metric.HistogramMetrics(cpuBuckets, obj.(vpaTargets).cpuCores),
+				}
+			},
+		),
+	}
+}
+
+// vpaUpdateModeCountFamilies returns the metric family of the number of
+// VerticalPodAutoscalers in each update mode. The known modes are always
+// exposed, modes unknown to kube-state-metrics only if in use.
+func vpaUpdateModeCountFamilies() []generator.FamilyGenerator {
+	return []generator.FamilyGenerator{
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_update_mode_count",
+			"Number of VerticalPodAutoscalers in each update mode.",
+			metric.Gauge,
+			"",
+			func(obj interface{}) *metric.Family {
+				counts := map[string]float64{
+					string(autoscaling.UpdateModeOff):      0,
+					string(autoscaling.UpdateModeInitial):  0,
+					string(autoscaling.UpdateModeRecreate): 0,
+					string(autoscaling.UpdateModeAuto):     0,
+				}
+				for _, mode := range obj.(vpaTargets).updateModes {
+					counts[mode]++
+				}
+				modes := make([]string, 0, len(counts))
+				for mode := range counts {
+					modes = append(modes, mode)
+				}
+				sort.Strings(modes)
+
+				ms := make([]*metric.Metric, 0, len(modes))
+				for _, mode := range modes {
+					ms = append(ms, &metric.Metric{
+						LabelKeys:   []string{"update_mode"},
+						LabelValues: []string{mode},
+						Value:       counts[mode],
+					})
+				}
+				return &metric.Family{
+					Metrics: ms,
+				}
+			},
+		),
+	}
+}
+
+// vpaNamespaceCountFamilies returns the metric family of the number of
+// VerticalPodAutoscalers in each namespace. Namespaces without any are not
+// exposed.
+func vpaNamespaceCountFamilies() []generator.FamilyGenerator {
+	return []generator.FamilyGenerator{
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_namespace_count",
+			"Number of VerticalPodAutoscalers in each namespace.",
+			metric.Gauge,
+			"",
+			func(obj interface{}) *metric.Family {
+				counts := map[string]float64{}
+				for _, namespace := range obj.(vpaTargets).namespaces {
+					counts[namespace]++
+				}
+				namespaces := make([]string, 0, len(counts))
+				for namespace := range counts {
+					namespaces = append(namespaces, namespace)
+				}
+				sort.Strings(namespaces)
+
+				ms := make([]*metric.Metric, 0, len(namespaces))
+				for _, namespace := range namespaces {
+					ms = append(ms, &metric.Metric{
+						LabelKeys:   []string{"namespace"},
+						LabelValues: []string{namespace},
+						Value:       counts[namespace],
+					})
+				}
+				return &metric.Family{
+					Metrics: ms,
+				}
+			},
+		),
+	}
+}
+
+func sumFloat64(values []float64) float64 {
+	var s float64
+	for _, v := range values {
+		s += v
+	}
+	return s
+}
+
+// vpaRecommendationAggregates implements the metricsstore.MetricsWriter
+// interface, writing the aggregates of the targets recommended across all
+// VerticalPodAutoscalers watched by the stores it wraps.
+type vpaRecommendationAggregates struct {
+	mu       sync.RWMutex
+	families []generator.FamilyGenerator
+	headers  []string
+	// opts are the options of the VerticalPodAutoscaler store, so the
+	// targets are aggregated the way they are exposed per container, except
+	// that memory is always aggregated in bytes.
+	opts   vpaOptions
+	stores []*vpaRecommendationAggregateStore
+	// nativeHistograms holds the names of the families of the cpu targets
+	// histogram, which is exposed as a native histogram as well.
+	nativeHistograms []string
+}
+
+func newVPARecommendationAggregates(families []generator.FamilyGenerator, opts vpaOptions) *vpaRecommendationAggregates {
+	opts.memoryUnit = constant.UnitByte
+	return &vpaRecommendationAggregates{
+		families: families,
+		headers:  generator.ExtractMetricFamilyHeaders(families),
+		opts:     opts,
+	}
+}
+
+// withNativeHistograms sets the names of the families of the cpu targets
+// histogram to expose as native histograms as well.
+func (s *vpaRecommendationAggregates) withNativeHistograms(names []string) {
+	s.nativeHistograms = names
+}
+
+// wrap returns a cache.Store adding the targets of the VerticalPodAutoscalers
+// of the given store to the aggregates.
+func (s *vpaRecommendationAggregates) wrap(store cache.Store) cache.Store {
+	wrapped := &vpaRecommendationAggregateStore{
+		Store:      store,
+		aggregates: s,
+		targets:    map[types.UID]vpaTargets{},
+	}
+	s.mu.Lock()
+	s.stores = append(s.stores, wrapped)
+	s.mu.Unlock()
+	return wrapped
+}
+
+// collect returns the targets of all VerticalPodAutoscalers.
+func (s *vpaRecommendationAggregates) collect() vpaTargets {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var all vpaTargets
+	for _, store := range s.stores {
+		for _, targets := range store.targets {
+			all.cpuCores = append(all.cpuCores, targets.cpuCores...)
+			all.memoryBytes = append(all.memoryBytes, targets.memoryBytes...)
+			all.updateModes = append(all.updateModes, targets.updateModes...)
+			all.namespaces = append(all.namespaces, targets.namespaces...)
+		}
+	}
+	return all
+}
+
+// WriteAll writes the aggregates in the Prometheus text format.
+func (s *vpaRecommendationAggregates) WriteAll(w io.Writer) {
+	all := s.collect()
+	for i, f := range s.families {
+		w.Write([]byte(s.headers[i]))
+		w.Write([]byte{'\n'})
+		f.Generate(all).WriteTo(w)
+	}
+}
+
+// WriteAllOpenMetrics writes the aggregates in the OpenMetrics text format,
+// which does not differ from the Prometheus one for gauges and histograms.
+func (s *vpaRecommendationAggregates) WriteAllOpenMetrics(w io.Writer) {
+	s.WriteAll(w)
+}
+
+// NativeHistograms returns the cpu targets recommended across all
+// VerticalPodAutoscalers, keyed by the names of the families of the cpu
+// targets histogram.
+func (s *vpaRecommendationAggregates) NativeHistograms() map[string][]float64 {
+	if len(s.nativeHistograms) == 0 {
+		return nil
+	}
+	cpuCores := s.collect().cpuCores
+	histograms := make(map[string][]float64, len(s.nativeHistograms))
+	for _, name := range s.nativeHistograms {
+		histograms[name] = cpuCores
+	}
+	return histograms
+}
+
+// HasSynced returns true once all wrapped stores were populated with the
+// initial list of VerticalPodAutoscalers.
+func (s *vpaRecommendationAggregates) HasSynced() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, store := range s.stores {
+		if !store.synced {
+			return false
+		}
+	}
+	return true
+}
+
+var _ metricsstore.NativeHistogramWriter = &vpaRecommendationAggregates{}
+
+// vpaRecommendationAggregateStore keeps track of the targets recommended for
+// the VerticalPodAutoscalers of the cache.Store it wraps.
+type vpaRecommendationAggregateStore struct {
+	cache.Store
+	aggregates *vpaRecommendationAggregates
+
+	// targets and synced are protected by the mutex of aggregates.
+	targets map[types.UID]vpaTargets
+	synced  bool
+}
+
+// Add adds the object to the wrapped store and its targets to the
+// aggregates.
+func (s *vpaRecommendationAggregateStore) Add(obj interface{}) error {
+	s.aggregates.mu.Lock()
+	s.add(obj)
+	s.aggregates.mu.Unlock()
+	return s.Store.Add(obj)
+}
+
+// Update updates the object in the wrapped store and its targets in the
+// aggregates.
+func (s *vpaRecommendationAggregateStore) Update(obj interface{}) error {
+	return s.Add(obj)
+}
+
+// Delete deletes the object from the wrapped store and its targets from the
+// aggregates.
+func (s *vpaRecommendationAggregateStore) Delete(obj interface{}) error {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if a, ok := obj.(*autoscaling.VerticalPodAutoscaler); ok {
+		s.aggregates.mu.Lock()
+		delete(s.targets, a.UID)
+		s.aggregates.mu.Unlock()
+	}
+	return s.Store.Delete(obj)
+}
+
+// Replace replaces the objects of the wrapped store and the targets of the
+// aggregates.
+func (s *vpaRecommendationAggregateStore) Replace(list []interface{}, resourceVersion string) error {
+	s.aggregates.mu.Lock()
+	s.targets = make(map[types.UID]vpaTargets, len(list))
+	for _, obj := range list {
+		s.add(obj)
+	}
+	s.synced = true
+	s.aggregates.mu.Unlock()
+	return s.Store.Replace(list, resourceVersion)
+}
+
+// add collects the targets recommended for the containers of the
+// VerticalPodAutoscaler, the same way kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target
+// exposes them, and its update mode and namespace.
+func (s *vpaRecommendationAggregateStore) add(obj interface{}) {
+	a, ok := obj.(*autoscaling.VerticalPodAutoscaler)
+	if !ok {
+		return
+	}
+
+	opts := s.aggregates.opts
+	targets := vpaTargets{namespaces: []string{a.Namespace}}
+	if a.Status.Recommendation != nil && !opts.skipRecommendations(a) {
+		for _, c := range a.Status.Recommendation.ContainerRecommendations {
+			for _, m := range vpaResourcesToMetrics(c.ContainerName, opts.recommended(c.Target), opts) {
+				switch m.LabelValues[1] {
+				case "cpu":
+					targets.cpuCores = append(targets.cpuCores, m.Value)
+				case "memory":
+					targets.memoryBytes = append(targets.memoryBytes, m.Value)
+				}
+			}
+		}
+	}
+	// The VerticalPodAutoscaler defaults to Auto mode.
+	mode := autoscaling.UpdateModeAuto
+	if a.Spec.UpdatePolicy != nil && a.Spec.UpdatePolicy.UpdateMode != nil {
+		mode = *a.Spec.UpdatePolicy.UpdateMode
+	}
+	targets.updateModes = []string{string(mode)}
+	s.targets[a.UID] = targets
+}
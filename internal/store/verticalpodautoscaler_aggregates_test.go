@@ -0,0 +1,360 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/kube-state-metrics/v2/pkg/constant"
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+func TestVPARecommendationSums(t *testing.T) {
+	vpa := func(namespace string, uid types.UID, targets ...v1.ResourceList) *autoscaling.VerticalPodAutoscaler {
+		a := &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: string(uid), UID: uid},
+		}
+		if len(targets) > 0 {
+			a.Status.Recommendation = &autoscaling.RecommendedPodResources{}
+		}
+		for _, target := range targets {
+			a.Status.Recommendation.ContainerRecommendations = append(a.Status.Recommendation.ContainerRecommendations, autoscaling.RecommendedContainerResources{
+				ContainerName: "container",
+				Target:        target,
+			})
+		}
+		return a
+	}
+	target := func(cpu, mem string) v1.ResourceList {
+		return v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(mem),
+		}
+	}
+
+	sums := newVPARecommendationAggregates(vpaRecommendationSumFamilies(), vpaOptions{})
+	ns1 := sums.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc))
+	ns2 := sums.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc))
+	if sums.HasSynced() {
+		t.Error("expected the sums not to be synced before the stores are populated")
+	}
+
+	if err := ns1.Replace([]interface{}{vpa("ns1", "a", target("250m", "1Gi"), target("750m", "1Gi")), vpa("ns1", "b")}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns2.Replace([]interface{}{vpa("ns2", "c", target("2", "2Gi"))}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if !sums.HasSynced() {
+		t.Error("expected the sums to be synced once all stores are populated")
+	}
+	wantAggregates(t, sums, `
+		# HELP kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum Sum of the cpu targets the VerticalPodAutoscalers recommend for their containers.
+		# TYPE kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum gauge
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum 3
+		# HELP kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum Sum of the memory targets the VerticalPodAutoscalers recommend for their containers.
+		# TYPE kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum gauge
+		kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum 4.294967296e+09
+	`)
+
+	if err := ns1.Update(vpa("ns1", "b", target("1", "1Gi"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns2.Delete(vpa("ns2", "c")); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, sums, `
+		# HELP kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum Sum of the cpu targets the VerticalPodAutoscalers recommend for their containers.
+		# TYPE kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum gauge
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum 2
+		# HELP kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum Sum of the memory targets the VerticalPodAutoscalers recommend for their containers.
+		# TYPE kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum gauge
+		kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum 3.221225472e+09
+	`)
+}
+
+// vpaAggregatesWithOptions returns VerticalPodAutoscalers and options of
+// their store that drop some of their container recommendations and change
+// the others.
+func vpaAggregatesWithOptions() ([]*autoscaling.VerticalPodAutoscaler, vpaOptions) {
+	off := autoscaling.UpdateModeOff
+	vpas := []*autoscaling.VerticalPodAutoscaler{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "a", UID: "a"},
+			Status: autoscaling.VerticalPodAutoscalerStatus{
+				Recommendation: &autoscaling.RecommendedPodResources{
+					ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+						{ContainerName: "app", Target: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100500u"), v1.ResourceMemory: resource.MustParse("1Gi")}},
+						{ContainerName: "istio-proxy", Target: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("1Gi")}},
+						{ContainerName: "sidecar", Target: v1.ResourceList{v1.ResourceMemory: resource.MustParse("512Mi")}},
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "b", UID: "b"},
+			Spec:       autoscaling.VerticalPodAutoscalerSpec{UpdatePolicy: &autoscaling.PodUpdatePolicy{UpdateMode: &off}},
+			Status: autoscaling.VerticalPodAutoscalerStatus{
+				Recommendation: &autoscaling.RecommendedPodResources{
+					ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+						{ContainerName: "app", Target: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2"), v1.ResourceMemory: resource.MustParse("2Gi")}},
+					},
+				},
+			},
+		},
+	}
+	opts := vpaOptions{
+		zeroMissingResources:       true,
+		preciseCPU:                 true,
+		memoryUnit:                 constant.UnitMebibyte,
+		containerDenylist:          []string{"istio-*"},
+		skipOffModeRecommendations: true,
+	}
+	return vpas, opts
+}
+
+func TestVPARecommendationSumsOptions(t *testing.T) {
+	vpas, opts := vpaAggregatesWithOptions()
+
+	// Sum up the series of the per container targets.
+	var cpuCores, memoryBytes float64
+	for _, f := range vpaMetricFamilies(nil, nil, opts) {
+		if f.Name != "kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target" {
+			continue
+		}
+		for _, a := range vpas {
+			for _, m := range f.Generate(a).Metrics {
+				labels := map[string]string{}
+				for i, key := range m.LabelKeys {
+					labels[key] = m.LabelValues[i]
+				}
+				switch labels["unit"] {
+				case string(constant.UnitCore):
+					cpuCores += m.Value
+				case string(constant.UnitMebibyte):
+					memoryBytes += m.Value * (1 << 20)
+				default:
+					t.Fatalf("unexpected unit %q", labels["unit"])
+				}
+			}
+		}
+	}
+	// Without preciseCPU, 100500u would be rounded up to 101m.
+	if math.Abs(cpuCores-0.1005) > 1e-9 || memoryBytes != 1.5*(1<<30) {
+		t.Fatalf("want per container targets of 0.1005 cores and 1.5Gi, got %v and %v", cpuCores, memoryBytes)
+	}
+
+	sums := newVPARecommendationAggregates(vpaRecommendationSumFamilies(), opts)
+	store := sums.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc))
+	if err := store.Replace([]interface{}{vpas[0], vpas[1]}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, sums, fmt.Sprintf(`
+		# HELP kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum Sum of the cpu targets the VerticalPodAutoscalers recommend for their containers.
+		# TYPE kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum gauge
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum %v
+		# HELP kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum Sum of the memory targets the VerticalPodAutoscalers recommend for their containers.
+		# TYPE kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum gauge
+		kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum %v
+	`, cpuCores, memoryBytes))
+}
+
+func TestVPARecommendationHistogram(t *testing.T) {
+	vpa := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "a", UID: "a"},
+		Status: autoscaling.VerticalPodAutoscalerStatus{
+			Recommendation: &autoscaling.RecommendedPodResources{
+				ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+					{ContainerName: "app", Target: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")}},
+					{ContainerName: "sidecar", Target: v1.ResourceList{v1.ResourceCPU: resource.MustParse("3")}},
+				},
+			},
+		},
+	}
+
+	b := &Builder{}
+	if err := b.WithVPARecommendationBuckets([]float64{4, 0.25, 1}); err != nil {
+		t.Fatal(err)
+	}
+	histogram := newVPARecommendationAggregates(vpaRecommendationHistogramFamilies(b.vpaRecommendationBuckets), vpaOptions{})
+	store := histogram.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc))
+	if err := store.Replace([]interface{}{vpa}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, histogram, `
+		# HELP kube_verticalpodautoscaler_status_recommendation_target_cpu_cores Distribution of the cpu targets the VerticalPodAutoscalers recommend for their containers.
+		# TYPE kube_verticalpodautoscaler_status_recommendation_target_cpu_cores histogram
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="0.25"} 1
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="1"} 1
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="4"} 2
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="+Inf"} 2
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum 3.1
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_count 2
+	`)
+
+	if got := histogram.NativeHistograms(); got != nil {
+		t.Errorf("expected no native histograms unless enabled, got %v", got)
+	}
+	histogram.withNativeHistograms([]string{"kube_verticalpodautoscaler_status_recommendation_target_cpu_cores"})
+	got := histogram.NativeHistograms()["kube_verticalpodautoscaler_status_recommendation_target_cpu_cores"]
+	if len(got) != 2 || got[0]+got[1] != 3.1 {
+		t.Errorf("want the observations 0.1 and 3 of the native histogram, got %v", got)
+	}
+
+	if err := b.WithVPARecommendationBuckets([]float64{1, 1}); err == nil {
+		t.Error("expected duplicate buckets to be rejected")
+	}
+}
+
+func TestVPARecommendationHistogramOptions(t *testing.T) {
+	vpas, opts := vpaAggregatesWithOptions()
+
+	// Collect the series of the per container cpu targets.
+	var cpuCores []float64
+	for _, f := range vpaMetricFamilies(nil, nil, opts) {
+		if f.Name != "kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target" {
+			continue
+		}
+		for _, a := range vpas {
+			for _, m := range f.Generate(a).Metrics {
+				for i, key := range m.LabelKeys {
+					if key == "unit" && m.LabelValues[i] == string(constant.UnitCore) {
+						cpuCores = append(cpuCores, m.Value)
+					}
+				}
+			}
+		}
+	}
+	// The cpu target of the sidecar is zeroed, the other ones dropped.
+	if len(cpuCores) != 2 {
+		t.Fatalf("want 2 per container cpu targets, got %v", cpuCores)
+	}
+
+	histogram := newVPARecommendationAggregates(vpaRecommendationHistogramFamilies([]float64{1}), opts)
+	store := histogram.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc))
+	if err := store.Replace([]interface{}{vpas[0], vpas[1]}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, histogram, fmt.Sprintf(`
+		# HELP kube_verticalpodautoscaler_status_recommendation_target_cpu_cores Distribution of the cpu targets the VerticalPodAutoscalers recommend for their containers.
+		# TYPE kube_verticalpodautoscaler_status_recommendation_target_cpu_cores histogram
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="1"} %d
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="+Inf"} %d
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum %v
+		kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_count %d
+	`, len(cpuCores), len(cpuCores), sumFloat64(cpuCores), len(cpuCores)))
+}
+
+func TestVPAUpdateModeCount(t *testing.T) {
+	vpa := func(uid types.UID, mode autoscaling.UpdateMode) *autoscaling.VerticalPodAutoscaler {
+		a := &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: string(uid), UID: uid},
+		}
+		if mode != "" {
+			a.Spec.UpdatePolicy = &autoscaling.PodUpdatePolicy{UpdateMode: &mode}
+		}
+		return a
+	}
+	// VerticalPodAutoscalers without update policy or mode default to Auto.
+	withoutMode := vpa("e", "")
+	withoutMode.Spec.UpdatePolicy = &autoscaling.PodUpdatePolicy{}
+
+	counts := newVPARecommendationAggregates(vpaUpdateModeCountFamilies(), vpaOptions{})
+	store := counts.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc))
+	if err := store.Replace([]interface{}{vpa("a", autoscaling.UpdateModeAuto), vpa("b", autoscaling.UpdateModeAuto), vpa("c", autoscaling.UpdateModeOff), vpa("d", ""), withoutMode}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, counts, `
+		# HELP kube_verticalpodautoscaler_update_mode_count Number of VerticalPodAutoscalers in each update mode.
+		# TYPE kube_verticalpodautoscaler_update_mode_count gauge
+		kube_verticalpodautoscaler_update_mode_count{update_mode="Auto"} 4
+		kube_verticalpodautoscaler_update_mode_count{update_mode="Initial"} 0
+		kube_verticalpodautoscaler_update_mode_count{update_mode="Off"} 1
+		kube_verticalpodautoscaler_update_mode_count{update_mode="Recreate"} 0
+	`)
+
+	if err := store.Update(vpa("b", "InPlace")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(vpa("c", autoscaling.UpdateModeOff)); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, counts, `
+		# HELP kube_verticalpodautoscaler_update_mode_count Number of VerticalPodAutoscalers in each update mode.
+		# TYPE kube_verticalpodautoscaler_update_mode_count gauge
+		kube_verticalpodautoscaler_update_mode_count{update_mode="Auto"} 3
+		kube_verticalpodautoscaler_update_mode_count{update_mode="InPlace"} 1
+		kube_verticalpodautoscaler_update_mode_count{update_mode="Initial"} 0
+		kube_verticalpodautoscaler_update_mode_count{update_mode="Off"} 0
+		kube_verticalpodautoscaler_update_mode_count{update_mode="Recreate"} 0
+	`)
+}
+
+func TestVPANamespaceCount(t *testing.T) {
+	vpa := func(namespace string, uid types.UID) *autoscaling.VerticalPodAutoscaler {
+		return &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: string(uid), UID: uid},
+		}
+	}
+
+	counts := newVPARecommendationAggregates(vpaNamespaceCountFamilies(), vpaOptions{})
+	store := counts.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc))
+	if err := store.Replace([]interface{}{vpa("ns2", "a"), vpa("ns1", "b"), vpa("ns2", "c")}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, counts, `
+		# HELP kube_verticalpodautoscaler_namespace_count Number of VerticalPodAutoscalers in each namespace.
+		# TYPE kube_verticalpodautoscaler_namespace_count gauge
+		kube_verticalpodautoscaler_namespace_count{namespace="ns1"} 1
+		kube_verticalpodautoscaler_namespace_count{namespace="ns2"} 2
+	`)
+
+	if err := store.Delete(vpa("ns1", "b")); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, counts, `
+		# HELP kube_verticalpodautoscaler_namespace_count Number of VerticalPodAutoscalers in each namespace.
+		# TYPE kube_verticalpodautoscaler_namespace_count gauge
+		kube_verticalpodautoscaler_namespace_count{namespace="ns2"} 2
+	`)
+}
+
+func wantAggregates(t *testing.T, aggregates metricsstore.MetricsWriter, want string) {
+	t.Helper()
+
+	var w strings.Builder
+	aggregates.WriteAll(&w)
+	var lines []string
+	for _, line := range strings.Split(want, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if got, want := w.String(), strings.Join(lines, "\n")+"\n"; got != want {
+		t.Errorf("unexpected aggregates, want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	hpaautoscaling "k8s.io/api/autoscaling/v2beta2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
+)
+
+// hpaScaling is the workload a HorizontalPodAutoscaler scales and the
+// resources whose usage it scales on.
+type hpaScaling struct {
+	target    vpaWorkload
+	resources map[v1.ResourceName]struct{}
+}
+
+// newHPAScaling returns the workload the HorizontalPodAutoscaler scales and
+// the resources of its resource and container resource metrics. Without any
+// metrics it scales on cpu utilization.
+func newHPAScaling(h *hpaautoscaling.HorizontalPodAutoscaler) hpaScaling {
+	s := hpaScaling{
+		target:    vpaWorkload{kind: h.Spec.ScaleTargetRef.Kind, namespace: h.Namespace, name: h.Spec.ScaleTargetRef.Name},
+		resources: map[v1.ResourceName]struct{}{},
+	}
+	if len(h.Spec.Metrics) == 0 {
+		s.resources[v1.ResourceCPU] = struct{}{}
+	}
+	for _, m := range h.Spec.Metrics {
+		switch {
+		case m.Type == hpaautoscaling.ResourceMetricSourceType && m.Resource != nil:
+			s.resources[m.Resource.Name] = struct{}{}
+		case m.Type == hpaautoscaling.ContainerResourceMetricSourceType && m.ContainerResource != nil:
+			s.resources[m.ContainerResource.Name] = struct{}{}
+		}
+	}
+	return s
+}
+
+// vpaControlledResources returns the resources the VerticalPodAutoscaler
+// updates the requests of for any of the containers of its target, nil in
+// Off mode. Containers without a policy of their own fall back to the
+// default policy, or to cpu and memory in Auto mode without one.
+func vpaControlledResources(a *autoscaling.VerticalPodAutoscaler) map[v1.ResourceName]struct{} {
+	if a.Spec.UpdatePolicy != nil && a.Spec.UpdatePolicy.UpdateMode != nil && *a.Spec.UpdatePolicy.UpdateMode == autoscaling.UpdateModeOff {
+		return nil
+	}
+
+	var policies []autoscaling.ContainerResourcePolicy
+	if a.Spec.ResourcePolicy != nil {
+		policies = a.Spec.ResourcePolicy.ContainerPolicies
+	}
+	hasDefault := false
+	for _, c := range policies {
+		if c.ContainerName == autoscaling.DefaultContainerResourcePolicy {
+			hasDefault = true
+		}
+	}
+	if !hasDefault {
+		policies = append(policies, autoscaling.ContainerResourcePolicy{ContainerName: autoscaling.DefaultContainerResourcePolicy})
+	}
+
+	resources := map[v1.ResourceName]struct{}{}
+	for _, c := range policies {
+		if c.Mode != nil && *c.Mode == autoscaling.ContainerScalingModeOff {
+			continue
+		}
+		controlled := []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+		if c.ControlledResources != nil {
+			controlled = *c.ControlledResources
+		}
+		for _, r := range controlled {
+			resources[r] = struct{}{}
+		}
+	}
+	return resources
+}
+
+// vpaHPAConflicts writes whether the workload targeted by each
+// VerticalPodAutoscaler is scaled by a HorizontalPodAutoscaler on a resource
+// the VerticalPodAutoscaler controls, as resolved from the
+// HorizontalPodAutoscaler stores it wraps.
+type vpaHPAConflicts struct {
+	vpaTargetWriter
+
+	// hpas is protected by the mutex of the writer.
+	hpas []*vpaHPAScalingTracker
+}
+
+func newVPAHPAConflicts(defaultLabels []string, recommenderLabelAnnotation string, omitEmptyDefaultLabels bool) *vpaHPAConflicts {
+	if defaultLabels == nil {
+		defaultLabels = descVerticalPodAutoscalerLabelsDefaultLabels
+	}
+	c := &vpaHPAConflicts{}
+	c.recommenderAnnotation = recommenderLabelAnnotation
+	c.families = []generator.FamilyGenerator{
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_hpa_conflict",
+			"Whether a HorizontalPodAutoscaler scales the workload targeted by the VerticalPodAutoscaler on a resource the VerticalPodAutoscaler controls.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(newVPALabels(defaultLabels, recommenderLabelAnnotation, omitEmptyDefaultLabels), func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+				if a.Spec.TargetRef != nil {
+					ms = append(ms, &metric.Metric{
+						Value: boolFloat64(c.conflicts(a)),
+					})
+				}
+				return &metric.Family{
+					Metrics: ms,
+				}
+			}),
+		),
+	}
+	return c
+}
+
+// wrap returns a cache.Store tracking the VerticalPodAutoscalers or
+// HorizontalPodAutoscalers of the given store, which is returned as is if it
+// holds neither.
+func (c *vpaHPAConflicts) wrap(store cache.Store, expectedType interface{}) cache.Store {
+	if _, ok := expectedType.(*autoscaling.VerticalPodAutoscaler); ok {
+		return c.wrapVPAs(store)
+	}
+	if _, ok := expectedType.(*hpaautoscaling.HorizontalPodAutoscaler); !ok {
+		return store
+	}
+	tracker := newVPAHPAScalingTracker(c)
+	c.mu.Lock()
+	c.hpas = append(c.hpas, tracker)
+	c.mu.Unlock()
+	return c.wrapTracked(store, tracker)
+}
+
+// conflicts returns whether a HorizontalPodAutoscaler scales the target of
+// the VerticalPodAutoscaler on a resource it controls. The caller must hold
+// the lock.
+func (c *vpaHPAConflicts) conflicts(a *autoscaling.VerticalPodAutoscaler) bool {
+	target, ok := vpaTargetOf(a)
+	if !ok {
+		return false
+	}
+	controlled := vpaControlledResources(a)
+	if len(controlled) == 0 {
+		return false
+	}
+	for _, hpas := range c.hpas {
+		for _, resources := range hpas.resources[target] {
+			for r := range resources {
+				if _, ok := controlled[r]; ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// vpaHPAScalingTracker keeps track of the resources the
+// HorizontalPodAutoscalers of a store scale on, by the UIDs of the
+// HorizontalPodAutoscalers scaling each workload, regenerating the metrics of
+// the VerticalPodAutoscalers targeting the workloads whose scaling changes.
+type vpaHPAScalingTracker struct {
+	targets   *vpaHPAConflicts
+	resources map[vpaWorkload]map[types.UID]map[v1.ResourceName]struct{}
+	scaled    map[types.UID]vpaWorkload
+}
+
+func newVPAHPAScalingTracker(targets *vpaHPAConflicts) *vpaHPAScalingTracker {
+	return &vpaHPAScalingTracker{
+		targets:   targets,
+		resources: map[vpaWorkload]map[types.UID]map[v1.ResourceName]struct{}{},
+		scaled:    map[types.UID]vpaWorkload{},
+	}
+}
+
+func (t *vpaHPAScalingTracker) add(obj interface{}) error {
+	h, ok := obj.(*hpaautoscaling.HorizontalPodAutoscaler)
+	if !ok {
+		return nil
+	}
+	changed := make([]vpaWorkload, 0, 2)
+	if previous, ok := t.remove(h.UID); ok {
+		changed = append(changed, previous)
+	}
+	s := newHPAScaling(h)
+	t.keep(h.UID, s)
+	if len(changed) == 0 || changed[0] != s.target {
+		changed = append(changed, s.target)
+	}
+	return t.targets.regenerate(changed...)
+}
+
+func (t *vpaHPAScalingTracker) delete(obj interface{}) error {
+	h, ok := obj.(*hpaautoscaling.HorizontalPodAutoscaler)
+	if !ok {
+		return nil
+	}
+	previous, ok := t.remove(h.UID)
+	if !ok {
+		return nil
+	}
+	return t.targets.regenerate(previous)
+}
+
+func (t *vpaHPAScalingTracker) replace(list []interface{}, _ string) error {
+	changed := make([]vpaWorkload, 0, len(t.resources)+len(list))
+	for workload := range t.resources {
+		changed = append(changed, workload)
+	}
+	t.resources = map[vpaWorkload]map[types.UID]map[v1.ResourceName]struct{}{}
+	t.scaled = make(map[types.UID]vpaWorkload, len(list))
+	for _, obj := range list {
+		if h, ok := obj.(*hpaautoscaling.HorizontalPodAutoscaler); ok {
+			s := newHPAScaling(h)
+			t.keep(h.UID, s)
+			changed = append(changed, s.target)
+		}
+	}
+	return t.targets.regenerate(changed...)
+}
+
+func (t *vpaHPAScalingTracker) keep(uid types.UID, s hpaScaling) {
+	if t.resources[s.target] == nil {
+		t.resources[s.target] = map[types.UID]map[v1.ResourceName]struct{}{}
+	}
+	t.resources[s.target][uid] = s.resources
+	t.scaled[uid] = s.target
+}
+
+// remove stops tracking the HorizontalPodAutoscaler, returning the workload
+// it scaled if it was tracked.
+func (t *vpaHPAScalingTracker) remove(uid types.UID) (vpaWorkload, bool) {
+	target, ok := t.scaled[uid]
+	if !ok {
+		return vpaWorkload{}, false
+	}
+	delete(t.scaled, uid)
+	delete(t.resources[target], uid)
+	if len(t.resources[target]) == 0 {
+		delete(t.resources, target)
+	}
+	return target, true
+}
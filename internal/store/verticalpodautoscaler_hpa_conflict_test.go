@@ -0,0 +1,238 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	hpaautoscaling "k8s.io/api/autoscaling/v2beta2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestVPAHPAConflict(t *testing.T) {
+	vpa := func(mutate func(*autoscaling.VerticalPodAutoscaler)) *autoscaling.VerticalPodAutoscaler {
+		a := &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "vpa", UID: "uid-vpa"},
+			Spec: autoscaling.VerticalPodAutoscalerSpec{
+				TargetRef: &autoscalingv1.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "app"},
+			},
+		}
+		if mutate != nil {
+			mutate(a)
+		}
+		return a
+	}
+	hpa := func(name, kind, target string, metrics ...hpaautoscaling.MetricSpec) *hpaautoscaling.HorizontalPodAutoscaler {
+		return &hpaautoscaling.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name, UID: types.UID("uid-" + name)},
+			Spec: hpaautoscaling.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: hpaautoscaling.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: kind, Name: target},
+				Metrics:        metrics,
+			},
+		}
+	}
+	resourceMetric := func(name v1.ResourceName) hpaautoscaling.MetricSpec {
+		return hpaautoscaling.MetricSpec{
+			Type:     hpaautoscaling.ResourceMetricSourceType,
+			Resource: &hpaautoscaling.ResourceMetricSource{Name: name},
+		}
+	}
+	containerResourceMetric := func(name v1.ResourceName) hpaautoscaling.MetricSpec {
+		return hpaautoscaling.MetricSpec{
+			Type:              hpaautoscaling.ContainerResourceMetricSourceType,
+			ContainerResource: &hpaautoscaling.ContainerResourceMetricSource{Name: name, Container: "app"},
+		}
+	}
+	externalMetric := hpaautoscaling.MetricSpec{
+		Type:     hpaautoscaling.ExternalMetricSourceType,
+		External: &hpaautoscaling.ExternalMetricSource{Metric: hpaautoscaling.MetricIdentifier{Name: "queue_length"}},
+	}
+	updateMode := func(mode autoscaling.UpdateMode) func(*autoscaling.VerticalPodAutoscaler) {
+		return func(a *autoscaling.VerticalPodAutoscaler) {
+			a.Spec.UpdatePolicy = &autoscaling.PodUpdatePolicy{UpdateMode: &mode}
+		}
+	}
+	containerPolicies := func(policies ...autoscaling.ContainerResourcePolicy) func(*autoscaling.VerticalPodAutoscaler) {
+		return func(a *autoscaling.VerticalPodAutoscaler) {
+			a.Spec.ResourcePolicy = &autoscaling.PodResourcePolicy{ContainerPolicies: policies}
+		}
+	}
+	modeOff := autoscaling.ContainerScalingModeOff
+	memoryOnly := []v1.ResourceName{v1.ResourceMemory}
+
+	tests := []struct {
+		name string
+		vpa  *autoscaling.VerticalPodAutoscaler
+		hpas []interface{}
+		want float64
+	}{
+		{
+			name: "no HorizontalPodAutoscaler",
+			vpa:  vpa(nil),
+			want: 0,
+		},
+		{
+			name: "HorizontalPodAutoscaler without metrics scales on cpu",
+			vpa:  vpa(nil),
+			hpas: []interface{}{hpa("hpa", "Deployment", "app")},
+			want: 1,
+		},
+		{
+			name: "memory container resource metric",
+			vpa:  vpa(nil),
+			hpas: []interface{}{hpa("hpa", "Deployment", "app", externalMetric, containerResourceMetric(v1.ResourceMemory))},
+			want: 1,
+		},
+		{
+			name: "external metric only",
+			vpa:  vpa(nil),
+			hpas: []interface{}{hpa("hpa", "Deployment", "app", externalMetric)},
+			want: 0,
+		},
+		{
+			name: "other target",
+			vpa:  vpa(nil),
+			hpas: []interface{}{hpa("hpa", "Deployment", "other"), hpa("sts", "StatefulSet", "app")},
+			want: 0,
+		},
+		{
+			name: "VerticalPodAutoscaler in Off mode",
+			vpa:  vpa(updateMode(autoscaling.UpdateModeOff)),
+			hpas: []interface{}{hpa("hpa", "Deployment", "app")},
+			want: 0,
+		},
+		{
+			name: "VerticalPodAutoscaler only controlling memory",
+			vpa:  vpa(containerPolicies(autoscaling.ContainerResourcePolicy{ContainerName: "*", ControlledResources: &memoryOnly})),
+			hpas: []interface{}{hpa("hpa", "Deployment", "app", resourceMetric(v1.ResourceCPU))},
+			want: 0,
+		},
+		{
+			name: "VerticalPodAutoscaler only controlling the memory of a named container",
+			vpa: vpa(containerPolicies(
+				autoscaling.ContainerResourcePolicy{ContainerName: "*", Mode: &modeOff},
+				autoscaling.ContainerResourcePolicy{ContainerName: "app", ControlledResources: &memoryOnly},
+			)),
+			hpas: []interface{}{hpa("hpa", "Deployment", "app", resourceMetric(v1.ResourceCPU), resourceMetric(v1.ResourceMemory))},
+			want: 1,
+		},
+		{
+			// Containers without a policy fall back to cpu and memory.
+			name: "VerticalPodAutoscaler without default policy",
+			vpa:  vpa(containerPolicies(autoscaling.ContainerResourcePolicy{ContainerName: "app", Mode: &modeOff})),
+			hpas: []interface{}{hpa("hpa", "Deployment", "app", resourceMetric(v1.ResourceCPU))},
+			want: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conflicts := newVPAHPAConflicts(nil, "", false)
+			withTestMetricsStores(&conflicts.vpaTargetWriter, conflicts.families)
+			vpas := conflicts.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc), &autoscaling.VerticalPodAutoscaler{})
+			hpas := conflicts.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc), &hpaautoscaling.HorizontalPodAutoscaler{})
+			if err := vpas.Replace([]interface{}{test.vpa}, "1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := hpas.Replace(test.hpas, "1"); err != nil {
+				t.Fatal(err)
+			}
+			wantAggregates(t, conflicts, fmt.Sprintf(`
+				# HELP kube_verticalpodautoscaler_hpa_conflict Whether a HorizontalPodAutoscaler scales the workload targeted by the VerticalPodAutoscaler on a resource the VerticalPodAutoscaler controls.
+				# TYPE kube_verticalpodautoscaler_hpa_conflict gauge
+				kube_verticalpodautoscaler_hpa_conflict{namespace="ns1",verticalpodautoscaler="vpa",target_api_version="apps/v1",target_kind="Deployment",target_name="app"} %v
+			`, test.want))
+		})
+	}
+}
+
+func TestVPAHPAConflictUpdates(t *testing.T) {
+	conflicts := newVPAHPAConflicts(nil, "", false)
+	withTestMetricsStores(&conflicts.vpaTargetWriter, withUIDLabel(conflicts.families))
+	vpas := conflicts.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc), &autoscaling.VerticalPodAutoscaler{})
+	hpas := conflicts.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc), &hpaautoscaling.HorizontalPodAutoscaler{})
+	deployments := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	if conflicts.wrap(deployments, &appsv1.Deployment{}) != deployments {
+		t.Error("expected stores of other resources not to be wrapped")
+	}
+
+	if err := vpas.Replace([]interface{}{&autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "vpa", UID: "uid-vpa"},
+		Spec: autoscaling.VerticalPodAutoscalerSpec{
+			TargetRef: &autoscalingv1.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "app"},
+		},
+	}}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if conflicts.HasSynced() {
+		t.Error("expected the conflicts not to be synced before the HorizontalPodAutoscaler store is populated")
+	}
+	if err := hpas.Replace(nil, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if !conflicts.HasSynced() {
+		t.Error("expected the conflicts to be synced once all stores are populated")
+	}
+
+	// Adding and deleting a HorizontalPodAutoscaler is picked up without the
+	// VerticalPodAutoscaler changing.
+	h := &hpaautoscaling.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "hpa", UID: "uid-hpa"},
+		Spec: hpaautoscaling.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: hpaautoscaling.CrossVersionObjectReference{Kind: "Deployment", Name: "app"},
+		},
+	}
+	if err := hpas.Add(h); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, conflicts, `
+		# HELP kube_verticalpodautoscaler_hpa_conflict Whether a HorizontalPodAutoscaler scales the workload targeted by the VerticalPodAutoscaler on a resource the VerticalPodAutoscaler controls.
+		# TYPE kube_verticalpodautoscaler_hpa_conflict gauge
+		kube_verticalpodautoscaler_hpa_conflict{namespace="ns1",verticalpodautoscaler="vpa",target_api_version="apps/v1",target_kind="Deployment",target_name="app",uid="uid-vpa"} 1
+	`)
+
+	// Retargeting the HorizontalPodAutoscaler resolves the conflict.
+	retargeted := h.DeepCopy()
+	retargeted.Spec.ScaleTargetRef.Name = "other"
+	if err := hpas.Update(retargeted); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, conflicts, `
+		# HELP kube_verticalpodautoscaler_hpa_conflict Whether a HorizontalPodAutoscaler scales the workload targeted by the VerticalPodAutoscaler on a resource the VerticalPodAutoscaler controls.
+		# TYPE kube_verticalpodautoscaler_hpa_conflict gauge
+		kube_verticalpodautoscaler_hpa_conflict{namespace="ns1",verticalpodautoscaler="vpa",target_api_version="apps/v1",target_kind="Deployment",target_name="app",uid="uid-vpa"} 0
+	`)
+
+	if err := hpas.Update(h); err != nil {
+		t.Fatal(err)
+	}
+	if err := hpas.Delete(cache.DeletedFinalStateUnknown{Key: "ns1/hpa", Obj: h}); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, conflicts, `
+		# HELP kube_verticalpodautoscaler_hpa_conflict Whether a HorizontalPodAutoscaler scales the workload targeted by the VerticalPodAutoscaler on a resource the VerticalPodAutoscaler controls.
+		# TYPE kube_verticalpodautoscaler_hpa_conflict gauge
+		kube_verticalpodautoscaler_hpa_conflict{namespace="ns1",verticalpodautoscaler="vpa",target_api_version="apps/v1",target_kind="Deployment",target_name="app",uid="uid-vpa"} 0
+	`)
+}
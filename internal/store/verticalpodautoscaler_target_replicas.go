@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
+)
+
+// vpaWorkloadReplicas returns the workload and its current number of replicas
+// if obj is a workload whose replicas kube_verticalpodautoscaler_target_replicas
+// exposes.
+func vpaWorkloadReplicas(obj interface{}) (vpaWorkload, int32, bool) {
+	switch w := obj.(type) {
+	case *appsv1.Deployment:
+		return vpaWorkload{kind: "Deployment", namespace: w.Namespace, name: w.Name}, w.Status.Replicas, true
+	case *appsv1.StatefulSet:
+		return vpaWorkload{kind: "StatefulSet", namespace: w.Namespace, name: w.Name}, w.Status.Replicas, true
+	case *appsv1.ReplicaSet:
+		return vpaWorkload{kind: "ReplicaSet", namespace: w.Namespace, name: w.Name}, w.Status.Replicas, true
+	case *v1.ReplicationController:
+		return vpaWorkload{kind: "ReplicationController", namespace: w.Namespace, name: w.Name}, w.Status.Replicas, true
+	}
+	return vpaWorkload{}, 0, false
+}
+
+// vpaTargetReplicas writes the current number of replicas of the workloads
+// targeted by the VerticalPodAutoscalers, as resolved from the workload stores
+// it wraps. VerticalPodAutoscalers whose target is not watched are skipped.
+type vpaTargetReplicas struct {
+	vpaTargetWriter
+
+	// workloads is protected by the mutex of the writer.
+	workloads []*vpaWorkloadReplicasTracker
+}
+
+func newVPATargetReplicas(defaultLabels []string, recommenderLabelAnnotation string, omitEmptyDefaultLabels bool) *vpaTargetReplicas {
+	if defaultLabels == nil {
+		defaultLabels = descVerticalPodAutoscalerLabelsDefaultLabels
+	}
+	r := &vpaTargetReplicas{}
+	r.recommenderAnnotation = recommenderLabelAnnotation
+	r.families = []generator.FamilyGenerator{
+		*generator.NewFamilyGenerator(
+			"kube_verticalpodautoscaler_target_replicas",
+			"Current number of replicas of the workload targeted by the VerticalPodAutoscaler.",
+			metric.Gauge,
+			"",
+			wrapVPAFunc(newVPALabels(defaultLabels, recommenderLabelAnnotation, omitEmptyDefaultLabels), func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+				ms := []*metric.Metric{}
+				if replicas, ok := r.resolve(a); ok {
+					ms = append(ms, &metric.Metric{
+						Value: float64(replicas),
+					})
+				}
+				return &metric.Family{
+					Metrics: ms,
+				}
+			}),
+		),
+	}
+	return r
+}
+
+// wrap returns a cache.Store tracking the VerticalPodAutoscalers or workloads
+// of the given store, which is returned as is if it holds neither.
+func (r *vpaTargetReplicas) wrap(store cache.Store, expectedType interface{}) cache.Store {
+	if _, ok := expectedType.(*autoscaling.VerticalPodAutoscaler); ok {
+		return r.wrapVPAs(store)
+	}
+	if _, _, ok := vpaWorkloadReplicas(expectedType); !ok {
+		return store
+	}
+	tracker := &vpaWorkloadReplicasTracker{
+		targets:  r,
+		replicas: map[vpaWorkload]int32{},
+	}
+	r.mu.Lock()
+	r.workloads = append(r.workloads, tracker)
+	r.mu.Unlock()
+	return r.wrapTracked(store, tracker)
+}
+
+// resolve returns the current number of replicas of the workload targeted by
+// the VerticalPodAutoscaler. The caller must hold the lock.
+func (r *vpaTargetReplicas) resolve(a *autoscaling.VerticalPodAutoscaler) (int32, bool) {
+	target, ok := vpaTargetOf(a)
+	if !ok {
+		return 0, false
+	}
+	for _, workloads := range r.workloads {
+		if replicas, ok := workloads.replicas[target]; ok {
+			return replicas, true
+		}
+	}
+	return 0, false
+}
+
+// vpaWorkloadReplicasTracker keeps track of the replicas of the workloads of
+// a store, regenerating the metrics of the VerticalPodAutoscalers targeting
+// the ones that change.
+type vpaWorkloadReplicasTracker struct {
+	targets  *vpaTargetReplicas
+	replicas map[vpaWorkload]int32
+}
+
+func (t *vpaWorkloadReplicasTracker) add(obj interface{}) error {
+	workload, replicas, ok := vpaWorkloadReplicas(obj)
+	if !ok {
+		return nil
+	}
+	if current, ok := t.replicas[workload]; ok && current == replicas {
+		return nil
+	}
+	t.replicas[workload] = replicas
+	return t.targets.regenerate(workload)
+}
+
+func (t *vpaWorkloadReplicasTracker) delete(obj interface{}) error {
+	workload, _, ok := vpaWorkloadReplicas(obj)
+	if !ok {
+		return nil
+	}
+	delete(t.replicas, workload)
+	return t.targets.regenerate(workload)
+}
+
+func (t *vpaWorkloadReplicasTracker) replace(list []interface{}, _ string) error {
+	changed := make([]vpaWorkload, 0, len(t.replicas)+len(list))
+	for workload := range t.replicas {
+		changed = append(changed, workload)
+	}
+	t.replicas = make(map[vpaWorkload]int32, len(list))
+	for _, obj := range list {
+		if workload, replicas, ok := vpaWorkloadReplicas(obj); ok {
+			t.replicas[workload] = replicas
+			changed = append(changed, workload)
+		}
+	}
+	return t.targets.regenerate(changed...)
+}
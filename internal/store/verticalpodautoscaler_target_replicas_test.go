@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+// withTestMetricsStores makes the writer generate the given families, derived
+// from its own ones, in stores of their own.
+func withTestMetricsStores(w *vpaTargetWriter, families []generator.FamilyGenerator) {
+	w.withMetricsStores(func() *metricsstore.MetricsStore {
+		return metricsstore.NewMetricsStore(generator.ExtractMetricFamilyHeaders(families), generator.ComposeMetricGenFuncs(families))
+	})
+}
+
+func TestVPATargetReplicas(t *testing.T) {
+	vpa := func(name, kind, target string) *autoscaling.VerticalPodAutoscaler {
+		return &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name, UID: types.UID("uid-" + name)},
+			Spec: autoscaling.VerticalPodAutoscalerSpec{
+				TargetRef: &autoscalingv1.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: kind, Name: target},
+			},
+		}
+	}
+	deployment := func(name string, replicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name},
+			Status:     appsv1.DeploymentStatus{Replicas: replicas},
+		}
+	}
+
+	replicas := newVPATargetReplicas(nil, "", false)
+	withTestMetricsStores(&replicas.vpaTargetWriter, withUIDLabel(replicas.families))
+	vpas := replicas.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc), &autoscaling.VerticalPodAutoscaler{})
+	deployments := replicas.wrap(cache.NewStore(cache.MetaNamespaceKeyFunc), &appsv1.Deployment{})
+	pods := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	if replicas.wrap(pods, &v1.Pod{}) != pods {
+		t.Error("expected stores of other resources not to be wrapped")
+	}
+
+	if err := vpas.Replace([]interface{}{vpa("a", "Deployment", "app"), vpa("b", "StatefulSet", "db")}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if replicas.HasSynced() {
+		t.Error("expected the replicas not to be synced before the workload stores are populated")
+	}
+	if err := deployments.Replace([]interface{}{deployment("app", 3), deployment("db", 1)}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if !replicas.HasSynced() {
+		t.Error("expected the replicas to be synced once all stores are populated")
+	}
+	// The StatefulSet db is not watched, so the VerticalPodAutoscaler b is
+	// skipped rather than resolved to the Deployment of the same name.
+	wantAggregates(t, replicas, `
+		# HELP kube_verticalpodautoscaler_target_replicas Current number of replicas of the workload targeted by the VerticalPodAutoscaler.
+		# TYPE kube_verticalpodautoscaler_target_replicas gauge
+		kube_verticalpodautoscaler_target_replicas{namespace="ns1",verticalpodautoscaler="a",target_api_version="apps/v1",target_kind="Deployment",target_name="app",uid="uid-a"} 3
+	`)
+
+	// Scaling the workload is picked up without the VerticalPodAutoscaler
+	// changing.
+	if err := deployments.Update(deployment("app", 5)); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, replicas, `
+		# HELP kube_verticalpodautoscaler_target_replicas Current number of replicas of the workload targeted by the VerticalPodAutoscaler.
+		# TYPE kube_verticalpodautoscaler_target_replicas gauge
+		kube_verticalpodautoscaler_target_replicas{namespace="ns1",verticalpodautoscaler="a",target_api_version="apps/v1",target_kind="Deployment",target_name="app",uid="uid-a"} 5
+	`)
+
+	// Retargeting the VerticalPodAutoscaler is picked up as well.
+	if err := vpas.Update(vpa("a", "Deployment", "db")); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, replicas, `
+		# HELP kube_verticalpodautoscaler_target_replicas Current number of replicas of the workload targeted by the VerticalPodAutoscaler.
+		# TYPE kube_verticalpodautoscaler_target_replicas gauge
+		kube_verticalpodautoscaler_target_replicas{namespace="ns1",verticalpodautoscaler="a",target_api_version="apps/v1",target_kind="Deployment",target_name="db",uid="uid-a"} 1
+	`)
+	if err := vpas.Update(vpa("a", "Deployment", "app")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := deployments.Delete(cache.DeletedFinalStateUnknown{Key: "ns1/app", Obj: deployment("app", 5)}); err != nil {
+		t.Fatal(err)
+	}
+	wantAggregates(t, replicas, `
+		# HELP kube_verticalpodautoscaler_target_replicas Current number of replicas of the workload targeted by the VerticalPodAutoscaler.
+		# TYPE kube_verticalpodautoscaler_target_replicas gauge
+	`)
+}
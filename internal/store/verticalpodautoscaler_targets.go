@@ -0,0 +1,328 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"io"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+// vpaWorkload identifies a workload VerticalPodAutoscalers may target.
+type vpaWorkload struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// vpaTargetOf returns the workload targeted by the VerticalPodAutoscaler, if
+// it has a target.
+func vpaTargetOf(a *autoscaling.VerticalPodAutoscaler) (vpaWorkload, bool) {
+	if a.Spec.TargetRef == nil {
+		return vpaWorkload{}, false
+	}
+	return vpaWorkload{kind: a.Spec.TargetRef.Kind, namespace: a.Namespace, name: a.Spec.TargetRef.Name}, true
+}
+
+// vpaTargetWriter implements the metricsstore.MetricsWriter interface,
+// writing metric families of the VerticalPodAutoscalers watched by the stores
+// it wraps whose values depend on other objects referring to their target,
+// e.g. the workload itself, watched by the stores it wraps as well. Both have
+// to be handled by the same shard, i.e. sharding has to be disabled or by
+// namespace.
+//
+// The metrics are generated through the same pipeline as the ones of any
+// other family, by a metricsstore.MetricsStore per VerticalPodAutoscaler
+// store, and regenerated whenever an object they depend on changes.
+type vpaTargetWriter struct {
+	mu                    sync.Mutex
+	families              []generator.FamilyGenerator
+	recommenderAnnotation string
+	newMetricsStore       func() *metricsstore.MetricsStore
+
+	// vpas and stores are protected by mu.
+	vpas   []*vpaTargetMetrics
+	stores []*vpaTargetStore
+}
+
+// withMetricsStores sets the function returning the metrics store of each
+// wrapped VerticalPodAutoscaler store, which generates the families of the
+// writer.
+func (w *vpaTargetWriter) withMetricsStores(newMetricsStore func() *metricsstore.MetricsStore) {
+	w.newMetricsStore = newMetricsStore
+}
+
+// wrapVPAs returns a cache.Store keeping the metrics of the
+// VerticalPodAutoscalers of the given store.
+func (w *vpaTargetWriter) wrapVPAs(store cache.Store) cache.Store {
+	m := &vpaTargetMetrics{
+		store:                 w.newMetricsStore(),
+		recommenderAnnotation: w.recommenderAnnotation,
+		vpas:                  map[vpaWorkload]map[types.UID]*autoscaling.VerticalPodAutoscaler{},
+		targets:               map[types.UID]vpaWorkload{},
+	}
+	w.mu.Lock()
+	w.vpas = append(w.vpas, m)
+	w.mu.Unlock()
+	return w.wrapTracked(store, m)
+}
+
+// wrapTracked returns a cache.Store tracking the objects of the given store
+// with the given tracker.
+func (w *vpaTargetWriter) wrapTracked(store cache.Store, tracker vpaTargetTracker) cache.Store {
+	wrapped := &vpaTargetStore{
+		Store:   store,
+		mu:      &w.mu,
+		tracker: tracker,
+	}
+	w.mu.Lock()
+	w.stores = append(w.stores, wrapped)
+	w.mu.Unlock()
+	return wrapped
+}
+
+// regenerate regenerates the metrics of the VerticalPodAutoscalers targeting
+// the given workloads. The caller must hold the lock.
+func (w *vpaTargetWriter) regenerate(targets ...vpaWorkload) error {
+	for _, m := range w.vpas {
+		if err := m.regenerate(targets...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metricsWriter returns the writer of the metrics stores of the wrapped
+// VerticalPodAutoscaler stores.
+func (w *vpaTargetWriter) metricsWriter() metricsstore.MetricsWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stores := make([]*metricsstore.MetricsStore, len(w.vpas))
+	for i, m := range w.vpas {
+		stores[i] = m.store
+	}
+	return metricsstore.NewMultiStoreMetricsWriter(stores)
+}
+
+// WriteAll writes the metrics in the Prometheus text format.
+func (w *vpaTargetWriter) WriteAll(out io.Writer) {
+	w.metricsWriter().WriteAll(out)
+}
+
+// WriteAllOpenMetrics writes the metrics in the OpenMetrics text format.
+func (w *vpaTargetWriter) WriteAllOpenMetrics(out io.Writer) {
+	w.metricsWriter().WriteAllOpenMetrics(out)
+}
+
+// HasSynced returns true once all wrapped stores were populated with the
+// initial list of their objects.
+func (w *vpaTargetWriter) HasSynced() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, store := range w.stores {
+		if !store.synced {
+			return false
+		}
+	}
+	return true
+}
+
+var _ metricsstore.MetricsWriter = &vpaTargetWriter{}
+
+// vpaTargetMetrics keeps the metrics of the VerticalPodAutoscalers of a
+// store, see vpaTargetWriter. To regenerate them, the VerticalPodAutoscalers
+// are kept by their target, trimmed to the fields their metrics are generated
+// from. VerticalPodAutoscalers without target are not kept, as none of these
+// metrics can depend on other objects.
+type vpaTargetMetrics struct {
+	store                 *metricsstore.MetricsStore
+	recommenderAnnotation string
+
+	vpas    map[vpaWorkload]map[types.UID]*autoscaling.VerticalPodAutoscaler
+	targets map[types.UID]vpaWorkload
+}
+
+// trim returns a copy of the VerticalPodAutoscaler holding only its identity,
+// resource version, recommender annotation and spec.
+func (m *vpaTargetMetrics) trim(a *autoscaling.VerticalPodAutoscaler) *autoscaling.VerticalPodAutoscaler {
+	trimmed := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       a.Namespace,
+			Name:            a.Name,
+			UID:             a.UID,
+			ResourceVersion: a.ResourceVersion,
+		},
+		Spec: a.Spec,
+	}
+	if recommender, ok := a.Annotations[m.recommenderAnnotation]; ok && m.recommenderAnnotation != "" {
+		trimmed.Annotations = map[string]string{m.recommenderAnnotation: recommender}
+	}
+	return trimmed
+}
+
+// add generates the metrics of the VerticalPodAutoscaler and keeps it by its
+// target.
+func (m *vpaTargetMetrics) add(obj interface{}) error {
+	a, ok := obj.(*autoscaling.VerticalPodAutoscaler)
+	if !ok {
+		return nil
+	}
+	m.remove(a.UID)
+	if !m.keep(a) {
+		return m.store.Delete(a)
+	}
+	return m.store.Add(m.vpas[m.targets[a.UID]][a.UID])
+}
+
+// delete deletes the metrics of the VerticalPodAutoscaler.
+func (m *vpaTargetMetrics) delete(obj interface{}) error {
+	a, ok := obj.(*autoscaling.VerticalPodAutoscaler)
+	if !ok {
+		return nil
+	}
+	m.remove(a.UID)
+	return m.store.Delete(a)
+}
+
+// replace replaces the VerticalPodAutoscalers and their metrics.
+func (m *vpaTargetMetrics) replace(list []interface{}, resourceVersion string) error {
+	m.vpas = map[vpaWorkload]map[types.UID]*autoscaling.VerticalPodAutoscaler{}
+	m.targets = map[types.UID]vpaWorkload{}
+	trimmed := make([]interface{}, 0, len(list))
+	for _, obj := range list {
+		if a, ok := obj.(*autoscaling.VerticalPodAutoscaler); ok && m.keep(a) {
+			trimmed = append(trimmed, m.vpas[m.targets[a.UID]][a.UID])
+		}
+	}
+	return m.store.Replace(trimmed, resourceVersion)
+}
+
+// keep keeps the trimmed VerticalPodAutoscaler by its target, if it has one.
+func (m *vpaTargetMetrics) keep(a *autoscaling.VerticalPodAutoscaler) bool {
+	target, ok := vpaTargetOf(a)
+	if !ok {
+		return false
+	}
+	if m.vpas[target] == nil {
+		m.vpas[target] = map[types.UID]*autoscaling.VerticalPodAutoscaler{}
+	}
+	m.vpas[target][a.UID] = m.trim(a)
+	m.targets[a.UID] = target
+	return true
+}
+
+func (m *vpaTargetMetrics) remove(uid types.UID) {
+	target, ok := m.targets[uid]
+	if !ok {
+		return
+	}
+	delete(m.targets, uid)
+	delete(m.vpas[target], uid)
+	if len(m.vpas[target]) == 0 {
+		delete(m.vpas, target)
+	}
+}
+
+// regenerate regenerates the metrics of the VerticalPodAutoscalers targeting
+// the given workloads.
+func (m *vpaTargetMetrics) regenerate(targets ...vpaWorkload) error {
+	for _, target := range targets {
+		for _, a := range m.vpas[target] {
+			if err := m.store.Add(a); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// vpaTargetTracker tracks the objects of the cache.Store wrapped by a
+// vpaTargetStore.
+type vpaTargetTracker interface {
+	add(obj interface{}) error
+	delete(obj interface{}) error
+	replace(list []interface{}, resourceVersion string) error
+}
+
+// vpaTargetStore wraps a cache.Store, keeping the metrics of its
+// VerticalPodAutoscalers or the objects they depend on up to date through its
+// tracker, which is called with the given lock held.
+type vpaTargetStore struct {
+	cache.Store
+	mu      sync.Locker
+	tracker vpaTargetTracker
+
+	// synced is protected by mu.
+	synced bool
+}
+
+// Add adds the object to the wrapped store and tracks it.
+func (s *vpaTargetStore) Add(obj interface{}) error {
+	s.mu.Lock()
+	err := s.tracker.add(obj)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.Store.Add(obj)
+}
+
+// Update updates the object in the wrapped store and tracks it.
+func (s *vpaTargetStore) Update(obj interface{}) error {
+	s.mu.Lock()
+	err := s.tracker.add(obj)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.Store.Update(obj)
+}
+
+// Delete deletes the object from the wrapped store and stops tracking it.
+func (s *vpaTargetStore) Delete(obj interface{}) error {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	s.mu.Lock()
+	err := s.tracker.delete(obj)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.Store.Delete(obj)
+}
+
+// Replace replaces the objects of the wrapped store and the tracked ones.
+func (s *vpaTargetStore) Replace(list []interface{}, resourceVersion string) error {
+	s.mu.Lock()
+	err := s.tracker.replace(list, resourceVersion)
+	s.synced = true
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.Store.Replace(list, resourceVersion)
+}
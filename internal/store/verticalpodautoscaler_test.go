@@ -17,31 +17,70 @@ limitations under the License.
 package store
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/apimachinery/pkg/watch"
+	autoscaling "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	autoscalingv1beta2 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	vpafake "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/fake"
+	"k8s.io/client-go/tools/cache"
 
+	"k8s.io/kube-state-metrics/v2/pkg/constant"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
 	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
 )
 
 func TestVPAStore(t *testing.T) {
 	const metadata = `
-		# HELP kube_verticalpodautoscaler_labels Kubernetes labels converted to Prometheus labels.
+		# HELP kube_verticalpodautoscaler_created Unix creation timestamp
+        # HELP kube_verticalpodautoscaler_labels Kubernetes labels converted to Prometheus labels.
+        # HELP kube_verticalpodautoscaler_spec_resourcepolicy_container_count Number of container policies of the VerticalPodAutoscaler.
+        # HELP kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set Whether the VerticalPodAutoscaler bounds the resources it can set for containers matching the name.
+        # HELP kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledresources Resources the VerticalPodAutoscaler controls for containers matching the name.
+        # HELP kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledvalues Which resource values the VerticalPodAutoscaler controls for containers matching the name.
+        # HELP kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_info Information about the container policies of the VerticalPodAutoscaler, telling the wildcard policy applying to all containers without a policy of their own from the policies of named containers.
         # HELP kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_maxallowed Maximum resources the VerticalPodAutoscaler can set for containers matching the name.
         # HELP kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_minallowed Minimum resources the VerticalPodAutoscaler can set for containers matching the name.
+        # HELP kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode Whether the VerticalPodAutoscaler is enabled for containers matching the name.
+        # HELP kube_verticalpodautoscaler_info Information about the VerticalPodAutoscaler and its target.
+        # HELP kube_verticalpodautoscaler_metadata_generation Sequence number representing a specific generation of the desired state.
+        # HELP kube_verticalpodautoscaler_spec_target_valid Whether the VerticalPodAutoscaler references a target by kind and name.
         # HELP kube_verticalpodautoscaler_spec_updatepolicy_updatemode Update mode of the VerticalPodAutoscaler.
+        # HELP kube_verticalpodautoscaler_status_condition The current status conditions of the VerticalPodAutoscaler.
+        # HELP kube_verticalpodautoscaler_status_recommendation_lastupdate Unix timestamp of the last recommendation of the VerticalPodAutoscaler.
+        # HELP kube_verticalpodautoscaler_status_recommendation_container_count Number of containers the VerticalPodAutoscaler provides a recommendation for.
         # HELP kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound Minimum resources the container can use before the VerticalPodAutoscaler updater evicts it.
         # HELP kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target Target resources the VerticalPodAutoscaler recommends for the container.
         # HELP kube_verticalpodautoscaler_status_recommendation_containerrecommendations_uncappedtarget Target resources the VerticalPodAutoscaler recommends for the container ignoring bounds.
         # HELP kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound Maximum resources the container can use before the VerticalPodAutoscaler updater evicts it.
+        # TYPE kube_verticalpodautoscaler_created gauge
         # TYPE kube_verticalpodautoscaler_labels gauge
+        # TYPE kube_verticalpodautoscaler_spec_resourcepolicy_container_count gauge
+        # TYPE kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set gauge
+        # TYPE kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledresources gauge
+        # TYPE kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledvalues gauge
+        # TYPE kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_info gauge
         # TYPE kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_maxallowed gauge
         # TYPE kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_minallowed gauge
+        # TYPE kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode gauge
+        # TYPE kube_verticalpodautoscaler_info gauge
+        # TYPE kube_verticalpodautoscaler_metadata_generation gauge
+        # TYPE kube_verticalpodautoscaler_spec_target_valid gauge
         # TYPE kube_verticalpodautoscaler_spec_updatepolicy_updatemode gauge
+        # TYPE kube_verticalpodautoscaler_status_condition gauge
+        # TYPE kube_verticalpodautoscaler_status_recommendation_lastupdate gauge
+        # TYPE kube_verticalpodautoscaler_status_recommendation_container_count gauge
         # TYPE kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound gauge
         # TYPE kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target gauge
         # TYPE kube_verticalpodautoscaler_status_recommendation_containerrecommendations_uncappedtarget gauge
@@ -49,6 +88,10 @@ func TestVPAStore(t *testing.T) {
 	`
 
 	updateMode := autoscaling.UpdateModeRecreate
+	unknownUpdateMode := autoscaling.UpdateMode("InPlaceOrRecreate")
+	containerScalingModeOff := autoscaling.ContainerScalingModeOff
+	controlledResources := []v1.ResourceName{v1.ResourceCPU}
+	controlledValues := autoscaling.ContainerControlledValuesRequestsOnly
 
 	v1Resource := func(cpu, mem string) v1.ResourceList {
 		return v1.ResourceList{
@@ -61,9 +104,10 @@ func TestVPAStore(t *testing.T) {
 		{
 			Obj: &autoscaling.VerticalPodAutoscaler{
 				ObjectMeta: metav1.ObjectMeta{
-					Generation: 2,
-					Name:       "vpa1",
-					Namespace:  "ns1",
+					Generation:        2,
+					Name:              "vpa1",
+					Namespace:         "ns1",
+					CreationTimestamp: metav1.Time{Time: time.Unix(1500000000, 0)},
 					Labels: map[string]string{
 						"app": "foobar",
 					},
@@ -80,14 +124,31 @@ func TestVPAStore(t *testing.T) {
 					ResourcePolicy: &autoscaling.PodResourcePolicy{
 						ContainerPolicies: []autoscaling.ContainerResourcePolicy{
 							{
-								ContainerName: "*",
-								MinAllowed:    v1Resource("1", "4Gi"),
-								MaxAllowed:    v1Resource("4", "8Gi"),
+								ContainerName:       "*",
+								MinAllowed:          v1Resource("1", "4Gi"),
+								MaxAllowed:          v1Resource("4", "8Gi"),
+								ControlledResources: &controlledResources,
+								ControlledValues:    &controlledValues,
+							},
+							{
+								ContainerName: "sidecar",
+								Mode:          &containerScalingModeOff,
 							},
 						},
 					},
 				},
 				Status: autoscaling.VerticalPodAutoscalerStatus{
+					Conditions: []autoscaling.VerticalPodAutoscalerCondition{
+						{
+							Type:               autoscaling.RecommendationProvided,
+							Status:             v1.ConditionTrue,
+							LastTransitionTime: metav1.Time{Time: time.Unix(1600000000, 0)},
+						},
+						{
+							Type:   autoscaling.LowConfidence,
+							Status: v1.ConditionFalse,
+						},
+					},
 					Recommendation: &autoscaling.RecommendedPodResources{
 						ContainerRecommendations: []autoscaling.RecommendedContainerResources{
 							{
@@ -101,11 +162,33 @@ func TestVPAStore(t *testing.T) {
 					},
 				},
 			},
+			AllowLabelsList: []string{"app"},
 			Want: metadata + `
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_count{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 2
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_info{container="*",container_policy="wildcard",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_info{container="sidecar",container_policy="container",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set{bound="maxallowed",container="*",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set{bound="maxallowed",container="sidecar",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 0
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set{bound="minallowed",container="*",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set{bound="minallowed",container="sidecar",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 0
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledresources{container="*",namespace="ns1",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledvalues{container="*",controlled_values="RequestsAndLimits",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 0
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledvalues{container="*",controlled_values="RequestsOnly",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
 				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_maxallowed{container="*",namespace="ns1",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="core",verticalpodautoscaler="vpa1"} 4
 				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_maxallowed{container="*",namespace="ns1",resource="memory",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="byte",verticalpodautoscaler="vpa1"} 8.589934592e+09
 				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_minallowed{container="*",namespace="ns1",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="core",verticalpodautoscaler="vpa1"} 1
 				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_minallowed{container="*",namespace="ns1",resource="memory",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="byte",verticalpodautoscaler="vpa1"} 4.294967296e+09
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode{container="*",mode="Auto",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode{container="*",mode="Off",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 0
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode{container="sidecar",mode="Auto",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 0
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode{container="sidecar",mode="Off",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_status_condition{condition="LowConfidence",namespace="ns1",status="false",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_status_condition{condition="LowConfidence",namespace="ns1",status="true",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 0
+				kube_verticalpodautoscaler_status_condition{condition="LowConfidence",namespace="ns1",status="unknown",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 0
+				kube_verticalpodautoscaler_status_condition{condition="RecommendationProvided",namespace="ns1",status="false",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 0
+				kube_verticalpodautoscaler_status_condition{condition="RecommendationProvided",namespace="ns1",status="true",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_status_condition{condition="RecommendationProvided",namespace="ns1",status="unknown",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 0
+				kube_verticalpodautoscaler_status_recommendation_lastupdate{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1.6e+09
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound{container="container1",namespace="ns1",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="core",verticalpodautoscaler="vpa1"} 1
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound{container="container1",namespace="ns1",resource="memory",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="byte",verticalpodautoscaler="vpa1"} 4.294967296e+09
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{container="container1",namespace="ns1",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="core",verticalpodautoscaler="vpa1"} 3
@@ -114,17 +197,35 @@ func TestVPAStore(t *testing.T) {
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_uncappedtarget{container="container1",namespace="ns1",resource="memory",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="byte",verticalpodautoscaler="vpa1"} 1.073741824e+10
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound{container="container1",namespace="ns1",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="core",verticalpodautoscaler="vpa1"} 4
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound{container="container1",namespace="ns1",resource="memory",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",unit="byte",verticalpodautoscaler="vpa1"} 8.589934592e+09
-				kube_verticalpodautoscaler_labels{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_created{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1.5e+09
+				kube_verticalpodautoscaler_labels{label_app="foobar",namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_info{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_status_recommendation_container_count{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
+				kube_verticalpodautoscaler_metadata_generation{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 2
+				kube_verticalpodautoscaler_spec_target_valid{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",verticalpodautoscaler="vpa1"} 1
 				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",update_mode="Auto",verticalpodautoscaler="vpa1"} 0
 				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",update_mode="Initial",verticalpodautoscaler="vpa1"} 0
 				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",update_mode="Off",verticalpodautoscaler="vpa1"} 0
 				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns1",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment1",update_mode="Recreate",verticalpodautoscaler="vpa1"} 1
 			`,
 			MetricNames: []string{
+				"kube_verticalpodautoscaler_created",
 				"kube_verticalpodautoscaler_labels",
+				"kube_verticalpodautoscaler_info",
+				"kube_verticalpodautoscaler_metadata_generation",
+				"kube_verticalpodautoscaler_spec_target_valid",
 				"kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_count",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_info",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set",
 				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_minallowed",
 				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_maxallowed",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledresources",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledvalues",
+				"kube_verticalpodautoscaler_status_condition",
+				"kube_verticalpodautoscaler_status_recommendation_lastupdate",
+				"kube_verticalpodautoscaler_status_recommendation_container_count",
 				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound",
 				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound",
 				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target",
@@ -169,11 +270,18 @@ func TestVPAStore(t *testing.T) {
 					},
 				},
 			},
+			AllowLabelsList: []string{"app"},
 			Want: metadata + `
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_count{namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_info{container="*",container_policy="wildcard",namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set{bound="maxallowed",container="*",namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set{bound="minallowed",container="*",namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 1
 				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_maxallowed{container="*",namespace="ns2",resource="cpu",target_api_version="",target_kind="",target_name="",unit="core",verticalpodautoscaler="vpa-without-target-ref"} 4
 				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_maxallowed{container="*",namespace="ns2",resource="memory",target_api_version="",target_kind="",target_name="",unit="byte",verticalpodautoscaler="vpa-without-target-ref"} 8.589934592e+09
 				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_minallowed{container="*",namespace="ns2",resource="cpu",target_api_version="",target_kind="",target_name="",unit="core",verticalpodautoscaler="vpa-without-target-ref"} 1
 				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_minallowed{container="*",namespace="ns2",resource="memory",target_api_version="",target_kind="",target_name="",unit="byte",verticalpodautoscaler="vpa-without-target-ref"} 4.294967296e+09
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode{container="*",mode="Auto",namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 1
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode{container="*",mode="Off",namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 0
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound{container="container1",namespace="ns2",resource="cpu",target_api_version="",target_kind="",target_name="",unit="core",verticalpodautoscaler="vpa-without-target-ref"} 1
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound{container="container1",namespace="ns2",resource="memory",target_api_version="",target_kind="",target_name="",unit="byte",verticalpodautoscaler="vpa-without-target-ref"} 4.294967296e+09
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{container="container1",namespace="ns2",resource="cpu",target_api_version="",target_kind="",target_name="",unit="core",verticalpodautoscaler="vpa-without-target-ref"} 3
@@ -182,29 +290,1145 @@ func TestVPAStore(t *testing.T) {
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_uncappedtarget{container="container1",namespace="ns2",resource="memory",target_api_version="",target_kind="",target_name="",unit="byte",verticalpodautoscaler="vpa-without-target-ref"} 1.073741824e+10
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound{container="container1",namespace="ns2",resource="cpu",target_api_version="",target_kind="",target_name="",unit="core",verticalpodautoscaler="vpa-without-target-ref"} 4
 				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound{container="container1",namespace="ns2",resource="memory",target_api_version="",target_kind="",target_name="",unit="byte",verticalpodautoscaler="vpa-without-target-ref"} 8.589934592e+09				
-				kube_verticalpodautoscaler_labels{namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 1
+				kube_verticalpodautoscaler_labels{label_app="foobar",namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 1
+				kube_verticalpodautoscaler_info{namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 1
+				kube_verticalpodautoscaler_status_recommendation_container_count{namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 1
+				kube_verticalpodautoscaler_metadata_generation{namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 2
+				kube_verticalpodautoscaler_spec_target_valid{namespace="ns2",target_api_version="",target_kind="",target_name="",verticalpodautoscaler="vpa-without-target-ref"} 0
 				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns2",target_api_version="",target_kind="",target_name="",update_mode="Auto",verticalpodautoscaler="vpa-without-target-ref"} 0
 				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns2",target_api_version="",target_kind="",target_name="",update_mode="Initial",verticalpodautoscaler="vpa-without-target-ref"} 0
 				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns2",target_api_version="",target_kind="",target_name="",update_mode="Off",verticalpodautoscaler="vpa-without-target-ref"} 0
 				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns2",target_api_version="",target_kind="",target_name="",update_mode="Recreate",verticalpodautoscaler="vpa-without-target-ref"} 1
 			`,
 			MetricNames: []string{
+				"kube_verticalpodautoscaler_created",
 				"kube_verticalpodautoscaler_labels",
+				"kube_verticalpodautoscaler_info",
+				"kube_verticalpodautoscaler_metadata_generation",
+				"kube_verticalpodautoscaler_spec_target_valid",
 				"kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_count",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_info",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_bounds_set",
 				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_minallowed",
 				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_maxallowed",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledresources",
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_controlledvalues",
+				"kube_verticalpodautoscaler_status_condition",
+				"kube_verticalpodautoscaler_status_recommendation_lastupdate",
+				"kube_verticalpodautoscaler_status_recommendation_container_count",
 				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound",
 				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound",
 				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target",
 				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_uncappedtarget",
 			},
 		},
+		{
+			Obj: &autoscaling.VerticalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "vpa-extended-resources",
+					Namespace: "ns3",
+				},
+				Spec: autoscaling.VerticalPodAutoscalerSpec{
+					TargetRef: &autoscalingv1.CrossVersionObjectReference{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       "deployment3",
+					},
+				},
+				Status: autoscaling.VerticalPodAutoscalerStatus{
+					Recommendation: &autoscaling.RecommendedPodResources{
+						ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+							{
+								ContainerName: "container1",
+								Target: v1.ResourceList{
+									v1.ResourceCPU:                    resource.MustParse("1"),
+									v1.ResourceName("hugepages-2Mi"):  resource.MustParse("4Mi"),
+									v1.ResourceName("nvidia.com/gpu"): resource.MustParse("2"),
+								},
+							},
+						},
+					},
+				},
+			},
+			Want: `
+				# HELP kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target Target resources the VerticalPodAutoscaler recommends for the container.
+				# TYPE kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target gauge
+				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{container="container1",namespace="ns3",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment3",unit="core",verticalpodautoscaler="vpa-extended-resources"} 1
+				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{container="container1",namespace="ns3",resource="hugepages_2Mi",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment3",unit="byte",verticalpodautoscaler="vpa-extended-resources"} 4.194304e+06
+				kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{container="container1",namespace="ns3",resource="nvidia_com_gpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment3",unit="integer",verticalpodautoscaler="vpa-extended-resources"} 2
+			`,
+			MetricNames: []string{
+				"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target",
+			},
+		},
+		{
+			Obj: &autoscaling.VerticalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "vpa-unknown-update-mode",
+					Namespace: "ns4",
+				},
+				Spec: autoscaling.VerticalPodAutoscalerSpec{
+					TargetRef: &autoscalingv1.CrossVersionObjectReference{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       "deployment4",
+					},
+					UpdatePolicy: &autoscaling.PodUpdatePolicy{
+						UpdateMode: &unknownUpdateMode,
+					},
+				},
+			},
+			Want: `
+				# HELP kube_verticalpodautoscaler_spec_updatepolicy_updatemode Update mode of the VerticalPodAutoscaler.
+				# TYPE kube_verticalpodautoscaler_spec_updatepolicy_updatemode gauge
+				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns4",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment4",update_mode="Auto",verticalpodautoscaler="vpa-unknown-update-mode"} 0
+				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns4",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment4",update_mode="InPlaceOrRecreate",verticalpodautoscaler="vpa-unknown-update-mode"} 1
+				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns4",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment4",update_mode="Initial",verticalpodautoscaler="vpa-unknown-update-mode"} 0
+				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns4",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment4",update_mode="Off",verticalpodautoscaler="vpa-unknown-update-mode"} 0
+				kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns4",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment4",update_mode="Recreate",verticalpodautoscaler="vpa-unknown-update-mode"} 0
+			`,
+			MetricNames: []string{
+				"kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+			},
+		},
+		{
+			Obj: &autoscaling.VerticalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "vpa-without-target-name",
+					Namespace: "ns5",
+				},
+				Spec: autoscaling.VerticalPodAutoscalerSpec{
+					TargetRef: &autoscalingv1.CrossVersionObjectReference{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+					},
+				},
+			},
+			Want: `
+				# HELP kube_verticalpodautoscaler_spec_resourcepolicy_container_count Number of container policies of the VerticalPodAutoscaler.
+				# TYPE kube_verticalpodautoscaler_spec_resourcepolicy_container_count gauge
+				# HELP kube_verticalpodautoscaler_spec_target_valid Whether the VerticalPodAutoscaler references a target by kind and name.
+				# TYPE kube_verticalpodautoscaler_spec_target_valid gauge
+				# HELP kube_verticalpodautoscaler_status_recommendation_container_count Number of containers the VerticalPodAutoscaler provides a recommendation for.
+				# TYPE kube_verticalpodautoscaler_status_recommendation_container_count gauge
+				kube_verticalpodautoscaler_spec_resourcepolicy_container_count{namespace="ns5",target_api_version="apps/v1",target_kind="Deployment",target_name="",verticalpodautoscaler="vpa-without-target-name"} 0
+				kube_verticalpodautoscaler_spec_target_valid{namespace="ns5",target_api_version="apps/v1",target_kind="Deployment",target_name="",verticalpodautoscaler="vpa-without-target-name"} 0
+				kube_verticalpodautoscaler_status_recommendation_container_count{namespace="ns5",target_api_version="apps/v1",target_kind="Deployment",target_name="",verticalpodautoscaler="vpa-without-target-name"} 0
+			`,
+			MetricNames: []string{
+				"kube_verticalpodautoscaler_spec_resourcepolicy_container_count",
+				"kube_verticalpodautoscaler_spec_target_valid",
+				"kube_verticalpodautoscaler_status_recommendation_container_count",
+			},
+		},
+		{
+			// The sidecar falls back to the default policy, which lacks a
+			// memory bound.
+			Obj: &autoscaling.VerticalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "vpa-clamped",
+					Namespace: "ns6",
+				},
+				Spec: autoscaling.VerticalPodAutoscalerSpec{
+					TargetRef: &autoscalingv1.CrossVersionObjectReference{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       "deployment6",
+					},
+					ResourcePolicy: &autoscaling.PodResourcePolicy{
+						ContainerPolicies: []autoscaling.ContainerResourcePolicy{
+							{
+								ContainerName: "*",
+								MaxAllowed:    v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")},
+							},
+							{
+								ContainerName: "app",
+								MaxAllowed:    v1Resource("1", "1Gi"),
+							},
+						},
+					},
+				},
+				Status: autoscaling.VerticalPodAutoscalerStatus{
+					Recommendation: &autoscaling.RecommendedPodResources{
+						ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+							{
+								ContainerName:  "app",
+								Target:         v1Resource("500m", "1Gi"),
+								UncappedTarget: v1Resource("500m", "2Gi"),
+							},
+							{
+								ContainerName:  "sidecar",
+								Target:         v1Resource("2", "1Gi"),
+								UncappedTarget: v1Resource("3", "1Gi"),
+							},
+						},
+					},
+				},
+			},
+			Want: `
+				# HELP kube_verticalpodautoscaler_status_recommendation_clamped Whether the target the VerticalPodAutoscaler recommends for the container is clamped, as the uncapped target exceeds the maximum allowed by the container policy.
+				# TYPE kube_verticalpodautoscaler_status_recommendation_clamped gauge
+				kube_verticalpodautoscaler_status_recommendation_clamped{container="app",namespace="ns6",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment6",verticalpodautoscaler="vpa-clamped"} 0
+				kube_verticalpodautoscaler_status_recommendation_clamped{container="app",namespace="ns6",resource="memory",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment6",verticalpodautoscaler="vpa-clamped"} 1
+				kube_verticalpodautoscaler_status_recommendation_clamped{container="sidecar",namespace="ns6",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment6",verticalpodautoscaler="vpa-clamped"} 1
+			`,
+			MetricNames: []string{
+				"kube_verticalpodautoscaler_status_recommendation_clamped",
+			},
+		},
+		{
+			// The sidecar lacks a memory lower bound and its CPU lower bound
+			// is zero, the init container lacks lower bounds altogether.
+			Obj: &autoscaling.VerticalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "vpa-ratio",
+					Namespace: "ns7",
+				},
+				Spec: autoscaling.VerticalPodAutoscalerSpec{
+					TargetRef: &autoscalingv1.CrossVersionObjectReference{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       "deployment7",
+					},
+				},
+				Status: autoscaling.VerticalPodAutoscalerStatus{
+					Recommendation: &autoscaling.RecommendedPodResources{
+						ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+							{
+								ContainerName: "app",
+								LowerBound:    v1Resource("250m", "256Mi"),
+								Target:        v1Resource("500m", "1Gi"),
+							},
+							{
+								ContainerName: "sidecar",
+								LowerBound:    v1.ResourceList{v1.ResourceCPU: resource.MustParse("0")},
+								Target:        v1Resource("100m", "64Mi"),
+							},
+							{
+								ContainerName: "init",
+								Target:        v1Resource("100m", "64Mi"),
+							},
+						},
+					},
+				},
+			},
+			Want: `
+				# HELP kube_verticalpodautoscaler_status_recommendation_target_to_lowerbound_ratio Ratio of the target the VerticalPodAutoscaler recommends for the container to its lower bound.
+				# TYPE kube_verticalpodautoscaler_status_recommendation_target_to_lowerbound_ratio gauge
+				kube_verticalpodautoscaler_status_recommendation_target_to_lowerbound_ratio{container="app",namespace="ns7",resource="cpu",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment7",verticalpodautoscaler="vpa-ratio"} 2
+				kube_verticalpodautoscaler_status_recommendation_target_to_lowerbound_ratio{container="app",namespace="ns7",resource="memory",target_api_version="apps/v1",target_kind="Deployment",target_name="deployment7",verticalpodautoscaler="vpa-ratio"} 4
+			`,
+			MetricNames: []string{
+				"kube_verticalpodautoscaler_status_recommendation_target_to_lowerbound_ratio",
+			},
+		},
 	}
 	for i, c := range cases {
-		c.Func = generator.ComposeMetricGenFuncs(vpaMetricFamilies(nil, nil))
-		c.Headers = generator.ExtractMetricFamilyHeaders(vpaMetricFamilies(nil, nil))
+		c.Func = generator.ComposeMetricGenFuncs(vpaMetricFamilies(c.AllowAnnotationsList, c.AllowLabelsList, vpaOptions{}))
+		c.Headers = generator.ExtractMetricFamilyHeaders(vpaMetricFamilies(c.AllowAnnotationsList, c.AllowLabelsList, vpaOptions{}))
 		if err := c.run(); err != nil {
 			t.Errorf("unexpected collecting result in %vth run:\n%s", i, err)
 		}
 	}
 }
+
+func TestVPAListWatchVersionFallback(t *testing.T) {
+	updateMode := autoscalingv1beta2.UpdateModeAuto
+	vpaClient := vpafake.NewSimpleClientset(&autoscalingv1beta2.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vpa1",
+			Namespace: "ns1",
+		},
+		Spec: autoscalingv1beta2.VerticalPodAutoscalerSpec{
+			UpdatePolicy: &autoscalingv1beta2.PodUpdatePolicy{
+				UpdateMode: &updateMode,
+			},
+		},
+	})
+	vpaClient.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: autoscalingv1beta2.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{{Name: "verticalpodautoscalers"}},
+		},
+	}
+
+	obj, err := createVPAListWatchFunc(vpaClient, nil)(nil, "ns1", "").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error listing VPAs: %v", err)
+	}
+	list, ok := obj.(*autoscaling.VerticalPodAutoscalerList)
+	if !ok {
+		t.Fatalf("expected *v1.VerticalPodAutoscalerList, got %T", obj)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "vpa1" {
+		t.Fatalf("expected the v1beta2 VPA to be listed, got %v", list.Items)
+	}
+	if got := *list.Items[0].Spec.UpdatePolicy.UpdateMode; got != autoscaling.UpdateModeAuto {
+		t.Errorf("expected update mode %q, got %q", autoscaling.UpdateModeAuto, got)
+	}
+}
+
+func TestVPAListWatchWaitsForAPI(t *testing.T) {
+	vpaClient := vpafake.NewSimpleClientset(&autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vpa1",
+			Namespace: "ns1",
+		},
+	})
+	lw := createVPAListWatchFunc(vpaClient, nil)(nil, "ns1", "")
+
+	if _, err := lw.List(metav1.ListOptions{}); err != errVPANotServed {
+		t.Fatalf("expected %v while no version is served, got %v", errVPANotServed, err)
+	}
+	if got := testutil.ToFloat64(storeWaitingForAPI.WithLabelValues("verticalpodautoscalers")); got != 1 {
+		t.Errorf("expected the store to wait for the API, got %v", got)
+	}
+
+	// The CustomResourceDefinition gets installed.
+	vpaClient.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: autoscaling.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{{Name: "verticalpodautoscalers"}},
+		},
+	}
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error listing VPAs: %v", err)
+	}
+	if list := obj.(*autoscaling.VerticalPodAutoscalerList); len(list.Items) != 1 {
+		t.Fatalf("expected the VPA to be listed once it is served, got %v", list.Items)
+	}
+	if got := testutil.ToFloat64(storeWaitingForAPI.WithLabelValues("verticalpodautoscalers")); got != 0 {
+		t.Errorf("expected the store to no longer wait for the API, got %v", got)
+	}
+}
+
+func TestVPAInfoMetricsRequireAllowList(t *testing.T) {
+	names := func(families []generator.FamilyGenerator) map[string]bool {
+		m := map[string]bool{}
+		for _, f := range families {
+			m[f.Name] = true
+		}
+		return m
+	}
+
+	got := names(vpaMetricFamilies(nil, nil, vpaOptions{}))
+	for _, name := range []string{descVerticalPodAutoscalerAnnotationsName, descVerticalPodAutoscalerLabelsName} {
+		if got[name] {
+			t.Errorf("expected %s to be omitted without an allowlist", name)
+		}
+	}
+
+	got = names(vpaMetricFamilies([]string{"app"}, []string{"*"}, vpaOptions{}))
+	for _, name := range []string{descVerticalPodAutoscalerAnnotationsName, descVerticalPodAutoscalerLabelsName} {
+		if !got[name] {
+			t.Errorf("expected %s to be generated with a non-empty allowlist", name)
+		}
+	}
+}
+
+func TestVPALabelsOrdering(t *testing.T) {
+	vpa := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vpa1",
+			Namespace: "ns1",
+			Labels: map[string]string{
+				"zone":    "eu-west-1",
+				"app":     "foobar",
+				"tier":    "backend",
+				"release": "stable",
+				"owner":   "team-a",
+			},
+		},
+	}
+	want := `kube_verticalpodautoscaler_labels{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",label_app="foobar",label_owner="team-a",label_release="stable",label_tier="backend",label_zone="eu-west-1"} 1
+`
+
+	for _, allowLabels := range [][]string{{"*"}, {"zone", "tier", "app", "owner", "release"}} {
+		for _, f := range vpaMetricFamilies(nil, allowLabels, vpaOptions{}) {
+			if f.Name != descVerticalPodAutoscalerLabelsName {
+				continue
+			}
+			// Render the family repeatedly, map iteration order must not leak
+			// into the output.
+			for i := 0; i < 10; i++ {
+				if got := string(f.Generate(vpa).ByteSlice()); got != want {
+					t.Fatalf("unexpected labels with allowlist %v:\nwant: %sgot:  %s", allowLabels, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestVPAInfoTargetLabels(t *testing.T) {
+	vpa := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vpa1",
+			Namespace: "ns1",
+		},
+		Spec: autoscaling.VerticalPodAutoscalerSpec{
+			TargetRef: &autoscalingv1.CrossVersionObjectReference{Kind: "Deployment", Name: "app"},
+		},
+	}
+	for _, c := range []struct {
+		opts vpaOptions
+		want string
+	}{
+		{
+			opts: vpaOptions{},
+			want: `kube_verticalpodautoscaler_info{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="Deployment",target_name="app"} 1
+`,
+		},
+		{
+			opts: vpaOptions{defaultLabels: []string{"namespace", "verticalpodautoscaler"}},
+			want: `kube_verticalpodautoscaler_info{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="Deployment",target_name="app"} 1
+`,
+		},
+		{
+			opts: vpaOptions{defaultLabels: []string{"namespace", "verticalpodautoscaler", "target_name"}},
+			want: `kube_verticalpodautoscaler_info{namespace="ns1",verticalpodautoscaler="vpa1",target_name="app",target_api_version="",target_kind="Deployment"} 1
+`,
+		},
+		{
+			// The info metric keeps naming the target, even with
+			// its empty api version.
+			opts: vpaOptions{omitEmptyDefaultLabels: true},
+			want: `kube_verticalpodautoscaler_info{namespace="ns1",verticalpodautoscaler="vpa1",target_kind="Deployment",target_name="app",target_api_version=""} 1
+`,
+		},
+	} {
+		for _, f := range vpaMetricFamilies(nil, nil, c.opts) {
+			if f.Name != "kube_verticalpodautoscaler_info" {
+				continue
+			}
+			if got := string(f.Generate(vpa).ByteSlice()); got != c.want {
+				t.Errorf("unexpected metric for default labels %v:\nwant: %sgot:  %s", c.opts.defaultLabels, c.want, got)
+			}
+		}
+	}
+}
+
+func TestVPADefaultLabels(t *testing.T) {
+	vpa := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "vpa1",
+			Namespace:         "ns1",
+			UID:               "uid1",
+			CreationTimestamp: metav1.Time{Time: time.Unix(1500000000, 0)},
+		},
+	}
+	want := `kube_verticalpodautoscaler_created{namespace="ns1",verticalpodautoscaler="vpa1",uid="uid1",target_kind="",target_name=""} 1.5e+09
+`
+
+	for _, f := range vpaMetricFamilies(nil, nil, vpaOptions{defaultLabels: []string{"namespace", "verticalpodautoscaler", "uid", "target_kind", "target_name"}}) {
+		if f.Name != "kube_verticalpodautoscaler_created" {
+			continue
+		}
+		if got := string(f.Generate(vpa).ByteSlice()); got != want {
+			t.Fatalf("unexpected metric:\nwant: %sgot:  %s", want, got)
+		}
+	}
+
+	for _, c := range []struct {
+		labels  map[string][]string
+		wantErr bool
+	}{
+		{labels: nil},
+		{labels: map[string][]string{"verticalpodautoscalers": {"namespace", "verticalpodautoscaler", "uid"}}},
+		{labels: map[string][]string{"pods": {"namespace"}}, wantErr: true},
+		{labels: map[string][]string{"verticalpodautoscalers": {"namespace", "container"}}, wantErr: true},
+		{labels: map[string][]string{"verticalpodautoscalers": {"namespace", "namespace"}}, wantErr: true},
+		{labels: map[string][]string{"verticalpodautoscalers": {}}, wantErr: true},
+	} {
+		if err := NewBuilder().WithDefaultLabels(c.labels); (err != nil) != c.wantErr {
+			t.Errorf("unexpected error for %v: %v", c.labels, err)
+		}
+	}
+}
+
+func TestVPAMetricFamiliesRecommenderLabel(t *testing.T) {
+	opts := vpaOptions{
+		recommenderAnnotation:      "recommendations",
+		recommenderLabelAnnotation: "recommender",
+	}
+	for _, c := range []struct {
+		annotations map[string]string
+		want        string
+	}{
+		{
+			annotations: map[string]string{"recommender": "custom"},
+			want: `kube_verticalpodautoscaler_info{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",recommender="custom"} 1
+`,
+		},
+		{
+			annotations: nil,
+			want: `kube_verticalpodautoscaler_info{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",recommender=""} 1
+`,
+		},
+	} {
+		vpa := &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "vpa1",
+				Namespace:   "ns1",
+				Annotations: c.annotations,
+			},
+			Status: autoscaling.VerticalPodAutoscalerStatus{
+				Recommendation: &autoscaling.RecommendedPodResources{
+					ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+						{
+							ContainerName: "container1",
+							Target: v1.ResourceList{
+								v1.ResourceCPU: resource.MustParse("1"),
+							},
+						},
+					},
+				},
+			},
+		}
+		for _, f := range vpaMetricFamilies(nil, nil, opts) {
+			switch f.Name {
+			case "kube_verticalpodautoscaler_info":
+				if got := string(f.Generate(vpa).ByteSlice()); got != c.want {
+					t.Errorf("unexpected metric:\nwant: %sgot:  %s", c.want, got)
+				}
+			case "kube_verticalpodautoscaler_status_recommendation_by_recommender":
+				ms := f.Generate(vpa).Metrics
+				if len(ms) == 0 {
+					t.Error("expected the default recommender to be exposed")
+				}
+				for _, m := range ms {
+					if strings.Count(strings.Join(m.LabelKeys, ","), "recommender") != 1 {
+						t.Errorf("expected a single recommender label, got %v", m.LabelKeys)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestVPAZeroMissingResources(t *testing.T) {
+	vpa := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vpa1",
+			Namespace: "ns1",
+		},
+		Status: autoscaling.VerticalPodAutoscalerStatus{
+			Recommendation: &autoscaling.RecommendedPodResources{
+				ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+					{
+						ContainerName: "container1",
+						Target: v1.ResourceList{
+							v1.ResourceCPU: resource.MustParse("250m"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range []struct {
+		zeroMissingResources bool
+		want                 string
+	}{
+		{
+			zeroMissingResources: false,
+			want: `kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",container="container1",resource="cpu",unit="core"} 0.25
+`,
+		},
+		{
+			zeroMissingResources: true,
+			want: `kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",container="container1",resource="cpu",unit="core"} 0.25
+kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",container="container1",resource="memory",unit="byte"} 0
+`,
+		},
+	} {
+		for _, f := range vpaMetricFamilies(nil, nil, vpaOptions{zeroMissingResources: c.zeroMissingResources}) {
+			if f.Name != "kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target" {
+				continue
+			}
+			got := sortByLine(string(f.Generate(vpa).ByteSlice()))
+			if want := sortByLine(c.want); got != want {
+				t.Errorf("unexpected metrics with zeroMissingResources=%v:\nwant: %sgot:  %s", c.zeroMissingResources, want, got)
+			}
+		}
+	}
+	if len(vpa.Status.Recommendation.ContainerRecommendations[0].Target) != 1 {
+		t.Error("expected the recommendation of the object to be left untouched")
+	}
+}
+
+func TestVPAMetricFamiliesDropZeroStateSet(t *testing.T) {
+	updateMode := autoscaling.UpdateModeRecreate
+	mode := autoscaling.ContainerScalingModeOff
+	vpa := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vpa1",
+			Namespace: "ns1",
+		},
+		Spec: autoscaling.VerticalPodAutoscalerSpec{
+			UpdatePolicy: &autoscaling.PodUpdatePolicy{
+				UpdateMode: &updateMode,
+			},
+			ResourcePolicy: &autoscaling.PodResourcePolicy{
+				ContainerPolicies: []autoscaling.ContainerResourcePolicy{
+					{
+						ContainerName: "sidecar",
+						Mode:          &mode,
+					},
+				},
+			},
+		},
+		Status: autoscaling.VerticalPodAutoscalerStatus{
+			Conditions: []autoscaling.VerticalPodAutoscalerCondition{
+				{
+					Type:   autoscaling.RecommendationProvided,
+					Status: v1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	want := map[string]string{
+		"kube_verticalpodautoscaler_spec_updatepolicy_updatemode": `kube_verticalpodautoscaler_spec_updatepolicy_updatemode{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",update_mode="Recreate"} 1
+`,
+		"kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode": `kube_verticalpodautoscaler_spec_resourcepolicy_container_policies_mode{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",container="sidecar",mode="Off"} 1
+`,
+		"kube_verticalpodautoscaler_status_condition": `kube_verticalpodautoscaler_status_condition{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",condition="RecommendationProvided",status="true"} 1
+`,
+	}
+	for _, f := range vpaMetricFamilies(nil, nil, vpaOptions{dropZeroStateSet: true}) {
+		w, ok := want[f.Name]
+		if !ok {
+			continue
+		}
+		if got := string(f.Generate(vpa).ByteSlice()); got != w {
+			t.Errorf("unexpected metrics of %s:\nwant: %sgot:  %s", f.Name, w, got)
+		}
+	}
+}
+
+func TestVPAResourcesToMetricsPreciseCPU(t *testing.T) {
+	resources := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("100u"),
+		v1.ResourceMemory: resource.MustParse("1Ki"),
+	}
+
+	for _, c := range []struct {
+		preciseCPU bool
+		want       map[string]float64
+	}{
+		{preciseCPU: false, want: map[string]float64{"cpu": 0.001, "memory": 1024}},
+		{preciseCPU: true, want: map[string]float64{"cpu": 0.0001, "memory": 1024}},
+	} {
+		got := map[string]float64{}
+		for _, m := range vpaResourcesToMetrics("container1", resources, vpaOptions{preciseCPU: c.preciseCPU}) {
+			got[m.LabelValues[1]] = m.Value
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("unexpected values with preciseCPU=%v: want %v, got %v", c.preciseCPU, c.want, got)
+		}
+		for name, want := range c.want {
+			// Approximate floats may be off in the last digits.
+			if math.Abs(got[name]-want) > 1e-12 {
+				t.Errorf("unexpected %s with preciseCPU=%v: want %v, got %v", name, c.preciseCPU, want, got[name])
+			}
+		}
+	}
+}
+
+func TestVPAResourcesToMetricsMemoryUnit(t *testing.T) {
+	resources := v1.ResourceList{
+		v1.ResourceCPU:                   resource.MustParse("500m"),
+		v1.ResourceMemory:                resource.MustParse("1536Mi"),
+		v1.ResourceName("hugepages-2Mi"): resource.MustParse("4Mi"),
+	}
+
+	for _, c := range []struct {
+		memoryUnit constant.ResourceUnit
+		want       map[string]string
+	}{
+		{memoryUnit: "", want: map[string]string{"cpu": "0.5 core", "memory": "1.610612736e+09 byte", "hugepages_2Mi": "4.194304e+06 byte"}},
+		{memoryUnit: constant.UnitByte, want: map[string]string{"cpu": "0.5 core", "memory": "1.610612736e+09 byte", "hugepages_2Mi": "4.194304e+06 byte"}},
+		{memoryUnit: constant.UnitMebibyte, want: map[string]string{"cpu": "0.5 core", "memory": "1536 mebibyte", "hugepages_2Mi": "4 mebibyte"}},
+	} {
+		got := map[string]string{}
+		for _, m := range vpaResourcesToMetrics("container1", resources, vpaOptions{memoryUnit: c.memoryUnit}) {
+			got[m.LabelValues[1]] = fmt.Sprintf("%v %s", m.Value, m.LabelValues[2])
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("unexpected values with memory unit %q: want %v, got %v", c.memoryUnit, c.want, got)
+		}
+	}
+}
+
+func TestVPARecommendationsByRecommender(t *testing.T) {
+	const annotation = "recommender.example.com/recommendations"
+	generate := func(opts vpaOptions, vpa *autoscaling.VerticalPodAutoscaler) (string, bool) {
+		for _, f := range vpaMetricFamilies(nil, nil, opts) {
+			if f.Name == "kube_verticalpodautoscaler_status_recommendation_by_recommender" {
+				return sortByLine(string(f.Generate(vpa).ByteSlice())), true
+			}
+		}
+		return "", false
+	}
+
+	if _, ok := generate(vpaOptions{}, &autoscaling.VerticalPodAutoscaler{}); ok {
+		t.Error("expected the family to be omitted without a recommender annotation")
+	}
+
+	status := autoscaling.VerticalPodAutoscalerStatus{
+		Recommendation: &autoscaling.RecommendedPodResources{
+			ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+				{
+					ContainerName: "container1",
+					Target: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("1"),
+					},
+				},
+			},
+		},
+	}
+	for _, c := range []struct {
+		annotations map[string]string
+		want        string
+	}{
+		{
+			annotations: map[string]string{
+				annotation: `{"default":{"containerRecommendations":[{"containerName":"container1","target":{"cpu":"1"}}]},"canary":{"containerRecommendations":[{"containerName":"container1","target":{"cpu":"500m","memory":"1Gi"}}]}}`,
+			},
+			want: `kube_verticalpodautoscaler_status_recommendation_by_recommender{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",recommender="canary",container="container1",resource="cpu",unit="core"} 0.5
+kube_verticalpodautoscaler_status_recommendation_by_recommender{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",recommender="canary",container="container1",resource="memory",unit="byte"} 1.073741824e+09
+kube_verticalpodautoscaler_status_recommendation_by_recommender{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",recommender="default",container="container1",resource="cpu",unit="core"} 1
+`,
+		},
+		{
+			// Without the annotation, the status recommendation is attributed
+			// to the default recommender.
+			want: `kube_verticalpodautoscaler_status_recommendation_by_recommender{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",recommender="default",container="container1",resource="cpu",unit="core"} 1
+`,
+		},
+		{
+			annotations: map[string]string{
+				annotation: `{"default":`,
+			},
+			want: ``,
+		},
+	} {
+		vpa := &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "vpa1",
+				Namespace:   "ns1",
+				Annotations: c.annotations,
+			},
+			Status: status,
+		}
+		got, _ := generate(vpaOptions{recommenderAnnotation: annotation}, vpa)
+		if want := sortByLine(c.want); got != want {
+			t.Errorf("unexpected metrics with annotations %v:\nwant: %s\ngot:  %s", c.annotations, want, got)
+		}
+	}
+}
+
+func TestVPARecommendationBounds(t *testing.T) {
+	vpa := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vpa1",
+			Namespace: "ns1",
+		},
+		Status: autoscaling.VerticalPodAutoscalerStatus{
+			Recommendation: &autoscaling.RecommendedPodResources{
+				ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+					{
+						ContainerName:  "container1",
+						LowerBound:     v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m")},
+						UpperBound:     v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+						Target:         v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")},
+						UncappedTarget: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")},
+					},
+				},
+			},
+		},
+	}
+	separate := []string{
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound",
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound",
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target",
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_uncappedtarget",
+	}
+	const consolidated = "kube_verticalpodautoscaler_status_recommendation"
+
+	for _, c := range []struct {
+		bounds string
+		want   []string
+	}{
+		{bounds: "", want: separate},
+		{bounds: vpaRecommendationBoundsSeparate, want: separate},
+		{bounds: vpaRecommendationBoundsConsolidated, want: []string{consolidated}},
+		{bounds: vpaRecommendationBoundsBoth, want: append(append([]string{}, separate...), consolidated)},
+	} {
+		var got []string
+		for _, f := range vpaMetricFamilies(nil, nil, vpaOptions{recommendationBounds: c.bounds}) {
+			if f.Name == consolidated || strings.HasPrefix(f.Name, "kube_verticalpodautoscaler_status_recommendation_containerrecommendations_") {
+				got = append(got, f.Name)
+			}
+			if f.Name != consolidated {
+				continue
+			}
+			want := sortByLine(`kube_verticalpodautoscaler_status_recommendation{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",bound="lowerbound",container="container1",resource="cpu",unit="core"} 0.25
+kube_verticalpodautoscaler_status_recommendation{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",bound="upperbound",container="container1",resource="cpu",unit="core"} 1
+kube_verticalpodautoscaler_status_recommendation{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",bound="target",container="container1",resource="cpu",unit="core"} 0.5
+kube_verticalpodautoscaler_status_recommendation{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",bound="uncappedtarget",container="container1",resource="cpu",unit="core"} 2
+`)
+			if got := sortByLine(string(f.Generate(vpa).ByteSlice())); got != want {
+				t.Errorf("unexpected consolidated metrics with bounds %q:\nwant: %sgot:  %s", c.bounds, want, got)
+			}
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("unexpected recommendation families with bounds %q, want %v, got %v", c.bounds, c.want, got)
+		}
+	}
+
+	if err := (&Builder{}).WithVPARecommendationBounds("separated"); err == nil {
+		t.Error("expected unknown recommendation bounds to be rejected")
+	}
+}
+
+func TestVPAOwnerReferences(t *testing.T) {
+	generate := func(opts vpaOptions, vpa *autoscaling.VerticalPodAutoscaler) (string, bool) {
+		for _, f := range vpaMetricFamilies(nil, nil, opts) {
+			if f.Name == "kube_verticalpodautoscaler_owner" {
+				return sortByLine(string(f.Generate(vpa).ByteSlice())), true
+			}
+		}
+		return "", false
+	}
+
+	if _, ok := generate(vpaOptions{}, &autoscaling.VerticalPodAutoscaler{}); ok {
+		t.Error("expected the family to be omitted without owner references enabled")
+	}
+
+	controller := true
+	for _, c := range []struct {
+		owners []metav1.OwnerReference
+		want   string
+	}{
+		{
+			owners: []metav1.OwnerReference{
+				{Kind: "Autoscaler", Name: "operator1", Controller: &controller},
+				{Kind: "ConfigMap", Name: "policy1"},
+			},
+			want: `kube_verticalpodautoscaler_owner{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",owner_kind="Autoscaler",owner_name="operator1",owner_is_controller="true"} 1
+kube_verticalpodautoscaler_owner{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",owner_kind="ConfigMap",owner_name="policy1",owner_is_controller="false"} 1
+`,
+		},
+		{
+			// VerticalPodAutoscalers without owners are skipped.
+			want: ``,
+		},
+	} {
+		vpa := &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "vpa1",
+				Namespace:       "ns1",
+				OwnerReferences: c.owners,
+			},
+		}
+		got, _ := generate(vpaOptions{ownerReferences: true}, vpa)
+		if want := sortByLine(c.want); got != want {
+			t.Errorf("unexpected metrics with owners %v:\nwant: %s\ngot:  %s", c.owners, want, got)
+		}
+	}
+}
+
+func TestVPAListWatchTargetKinds(t *testing.T) {
+	newVPA := func(name, kind string) *autoscaling.VerticalPodAutoscaler {
+		return &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "ns1",
+			},
+			Spec: autoscaling.VerticalPodAutoscalerSpec{
+				TargetRef: &autoscalingv1.CrossVersionObjectReference{
+					APIVersion: "apps/v1",
+					Kind:       kind,
+					Name:       name,
+				},
+			},
+		}
+	}
+	vpaClient := vpafake.NewSimpleClientset(newVPA("vpa1", "Deployment"), newVPA("vpa2", "DaemonSet"))
+	vpaClient.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: autoscaling.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{{Name: "verticalpodautoscalers"}},
+		},
+	}
+
+	lw := createVPAListWatchFunc(vpaClient, map[string]struct{}{"Deployment": {}})(nil, "ns1", "")
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error listing VPAs: %v", err)
+	}
+	list := obj.(*autoscaling.VerticalPodAutoscalerList)
+	if len(list.Items) != 1 || list.Items[0].Name != "vpa1" {
+		t.Fatalf("expected only the VPA targeting a Deployment to be listed, got %v", list.Items)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error watching VPAs: %v", err)
+	}
+	defer w.Stop()
+
+	vpas := vpaClient.AutoscalingV1().VerticalPodAutoscalers("ns1")
+	if _, err := vpas.Update(context.TODO(), newVPA("vpa1", "StatefulSet"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-w.ResultChan():
+		if vpa := e.Object.(*autoscaling.VerticalPodAutoscaler); e.Type != watch.Deleted || vpa.Name != "vpa1" {
+			t.Fatalf("expected vpa1 to be deleted once it no longer targets a Deployment, got %s event for %s", e.Type, vpa.Name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for watch event")
+	}
+}
+
+func TestFilterVPATargetKindsBookmarks(t *testing.T) {
+	fw := watch.NewFake()
+	lw := filterVPATargetKinds(&contextListWatch{
+		watchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			return fw, nil
+		},
+	}, map[string]struct{}{"Deployment": {}})
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	go fw.Action(watch.Bookmark, &autoscaling.VerticalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "5"}})
+	select {
+	case e := <-w.ResultChan():
+		if vpa := e.Object.(*autoscaling.VerticalPodAutoscaler); e.Type != watch.Bookmark || vpa.ResourceVersion != "5" {
+			t.Fatalf("expected the bookmark to be forwarded, got %s event with resource version %s", e.Type, vpa.ResourceVersion)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the bookmark")
+	}
+}
+
+func TestWrapVPAFuncUnexpectedObjects(t *testing.T) {
+	generate := wrapVPAFunc(newVPALabels(descVerticalPodAutoscalerLabelsDefaultLabels, "", false), func(a *autoscaling.VerticalPodAutoscaler) *metric.Family {
+		return &metric.Family{
+			Metrics: []*metric.Metric{
+				{
+					Value: 1,
+				},
+			},
+		}
+	})
+	vpa := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vpa1",
+			Namespace: "ns1",
+		},
+	}
+
+	before := testutil.ToFloat64(unexpectedObjectsTotal.WithLabelValues("verticalpodautoscalers"))
+
+	f := generate(cache.DeletedFinalStateUnknown{Key: "ns1/vpa1", Obj: vpa})
+	if len(f.Metrics) != 1 || f.Metrics[0].LabelValues[1] != "vpa1" {
+		t.Fatalf("expected the tombstone to be unwrapped, got %v", f.Metrics)
+	}
+
+	for _, obj := range []interface{}{&v1.Pod{}, cache.DeletedFinalStateUnknown{Key: "ns1/pod1", Obj: &v1.Pod{}}} {
+		if f := generate(obj); len(f.Metrics) != 0 {
+			t.Errorf("expected no metrics for %T, got %v", obj, f.Metrics)
+		}
+	}
+
+	if got := testutil.ToFloat64(unexpectedObjectsTotal.WithLabelValues("verticalpodautoscalers")) - before; got != 2 {
+		t.Errorf("expected 2 unexpected objects to be counted, got %v", got)
+	}
+}
+
+func TestVPAContainerDenylist(t *testing.T) {
+	vpa := &autoscaling.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vpa1",
+			Namespace: "ns1",
+		},
+		Status: autoscaling.VerticalPodAutoscalerStatus{
+			Recommendation: &autoscaling.RecommendedPodResources{
+				ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+					{
+						ContainerName: "app",
+						LowerBound:    v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+						Target:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+					},
+					{
+						ContainerName: "istio-proxy",
+						LowerBound:    v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+						Target:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+					},
+					{
+						ContainerName: "linkerd-proxy",
+						LowerBound:    v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+						Target:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+					},
+				},
+			},
+		},
+	}
+
+	want := map[string]string{
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target": `kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",container="app",resource="cpu",unit="core"} 1
+`,
+		"kube_verticalpodautoscaler_status_recommendation_target_to_lowerbound_ratio": `kube_verticalpodautoscaler_status_recommendation_target_to_lowerbound_ratio{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name="",container="app",resource="cpu"} 1
+`,
+	}
+	for _, f := range vpaMetricFamilies(nil, nil, vpaOptions{containerDenylist: []string{"istio-proxy", "linkerd-*"}}) {
+		w, ok := want[f.Name]
+		if !ok {
+			continue
+		}
+		if got := string(f.Generate(vpa).ByteSlice()); got != w {
+			t.Errorf("unexpected metrics of %s:\nwant: %sgot:  %s", f.Name, w, got)
+		}
+	}
+}
+
+func TestVPAObservedContainers(t *testing.T) {
+	for _, c := range []struct {
+		annotations map[string]string
+		want        string
+	}{
+		{
+			annotations: map[string]string{"vpaObservedContainers": "app, sidecar"},
+			want: `kube_verticalpodautoscaler_status_observed_containers{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name=""} 2
+`,
+		},
+		{
+			annotations: map[string]string{"vpaObservedContainers": ""},
+			want: `kube_verticalpodautoscaler_status_observed_containers{namespace="ns1",verticalpodautoscaler="vpa1",target_api_version="",target_kind="",target_name=""} 0
+`,
+		},
+		{
+			// Missing annotation.
+			annotations: nil,
+			want:        "",
+		},
+		{
+			// Unparseable annotation.
+			annotations: map[string]string{"vpaObservedContainers": "app,,sidecar"},
+			want:        "",
+		},
+	} {
+		vpa := &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "vpa1",
+				Namespace:   "ns1",
+				Annotations: c.annotations,
+			},
+		}
+		found := false
+		for _, f := range vpaMetricFamilies(nil, nil, vpaOptions{observedContainersAnnotation: "vpaObservedContainers"}) {
+			if f.Name != "kube_verticalpodautoscaler_status_observed_containers" {
+				continue
+			}
+			found = true
+			if got := string(f.Generate(vpa).ByteSlice()); got != c.want {
+				t.Errorf("unexpected metrics for annotations %v:\nwant: %sgot:  %s", c.annotations, c.want, got)
+			}
+		}
+		if !found {
+			t.Fatal("expected kube_verticalpodautoscaler_status_observed_containers to be generated")
+		}
+	}
+
+	for _, f := range vpaMetricFamilies(nil, nil, vpaOptions{}) {
+		if f.Name == "kube_verticalpodautoscaler_status_observed_containers" {
+			t.Error("expected kube_verticalpodautoscaler_status_observed_containers not to be generated without annotation")
+		}
+	}
+}
+
+func TestVPAOmitEmptyDefaultLabels(t *testing.T) {
+	for _, c := range []struct {
+		targetRef *autoscalingv1.CrossVersionObjectReference
+		want      string
+	}{
+		{
+			targetRef: nil,
+			want: `kube_verticalpodautoscaler_metadata_generation{namespace="ns1",verticalpodautoscaler="vpa1",recommender="default"} 2
+`,
+		},
+		{
+			targetRef: &autoscalingv1.CrossVersionObjectReference{Kind: "Deployment", Name: "app"},
+			want: `kube_verticalpodautoscaler_metadata_generation{namespace="ns1",verticalpodautoscaler="vpa1",target_kind="Deployment",target_name="app",recommender="default"} 2
+`,
+		},
+	} {
+		vpa := &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "vpa1",
+				Namespace:   "ns1",
+				Generation:  2,
+				Annotations: map[string]string{"recommender": "default"},
+			},
+			Spec: autoscaling.VerticalPodAutoscalerSpec{TargetRef: c.targetRef},
+		}
+		for _, f := range vpaMetricFamilies(nil, nil, vpaOptions{omitEmptyDefaultLabels: true, recommenderLabelAnnotation: "recommender"}) {
+			if f.Name != "kube_verticalpodautoscaler_metadata_generation" {
+				continue
+			}
+			if got := string(f.Generate(vpa).ByteSlice()); got != c.want {
+				t.Errorf("unexpected metrics for target %v:\nwant: %sgot:  %s", c.targetRef, c.want, got)
+			}
+		}
+	}
+}
+
+func TestVPASkipOffModeRecommendations(t *testing.T) {
+	recommended := []string{
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound",
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound",
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target",
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_uncappedtarget",
+		"kube_verticalpodautoscaler_status_recommendation",
+	}
+	resources := v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}
+	for _, mode := range []autoscaling.UpdateMode{autoscaling.UpdateModeOff, autoscaling.UpdateModeAuto} {
+		mode := mode
+		vpa := &autoscaling.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "vpa1", Namespace: "ns1"},
+			Spec: autoscaling.VerticalPodAutoscalerSpec{
+				UpdatePolicy: &autoscaling.PodUpdatePolicy{UpdateMode: &mode},
+			},
+			Status: autoscaling.VerticalPodAutoscalerStatus{
+				Recommendation: &autoscaling.RecommendedPodResources{
+					ContainerRecommendations: []autoscaling.RecommendedContainerResources{
+						{ContainerName: "app", LowerBound: resources, UpperBound: resources, Target: resources, UncappedTarget: resources},
+					},
+				},
+			},
+		}
+		families := map[string]string{}
+		for _, f := range vpaMetricFamilies(nil, nil, vpaOptions{skipOffModeRecommendations: true, recommendationBounds: vpaRecommendationBoundsBoth}) {
+			families[f.Name] = string(f.Generate(vpa).ByteSlice())
+		}
+		for _, name := range recommended {
+			if skipped := families[name] == ""; skipped != (mode == autoscaling.UpdateModeOff) {
+				t.Errorf("want %s skipped in %s mode: %t, got metrics %q", name, mode, mode == autoscaling.UpdateModeOff, families[name])
+			}
+		}
+		if families["kube_verticalpodautoscaler_spec_updatepolicy_updatemode"] == "" {
+			t.Errorf("want the update mode to be exposed in %s mode", mode)
+		}
+	}
+}
@@ -165,8 +165,8 @@
         path: '/healthz',
       } },
       readinessProbe: { timeoutSeconds: 5, initialDelaySeconds: 5, httpGet: {
-        port: 8081,
-        path: '/',
+        port: 8080,
+        path: '/readyz',
       } },
     };
 
@@ -18,12 +18,18 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/oklog/run"
@@ -34,22 +40,35 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/version"
 	"github.com/prometheus/exporter-toolkit/web"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	vpaclientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
 	clientset "k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/transport"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 
 	"k8s.io/kube-state-metrics/v2/internal/store"
 	"k8s.io/kube-state-metrics/v2/pkg/allowdenylist"
+	"k8s.io/kube-state-metrics/v2/pkg/logging"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
 	"k8s.io/kube-state-metrics/v2/pkg/metricshandler"
 	"k8s.io/kube-state-metrics/v2/pkg/options"
+	"k8s.io/kube-state-metrics/v2/pkg/seriesfilter"
+	"k8s.io/kube-state-metrics/v2/pkg/sharding"
 	"k8s.io/kube-state-metrics/v2/pkg/util/proc"
 )
 
 const (
-	metricsPath = "/metrics"
-	healthzPath = "/healthz"
+	metricsPath     = "/metrics"
+	metricsJSONPath = "/metrics.json"
+	healthzPath     = "/healthz"
+	readyzPath      = "/readyz"
+	catalogPath     = "/catalog"
+	pprofPath       = "/debug/pprof/"
 )
 
 // promLogger implements promhttp.Logger
@@ -75,7 +94,11 @@ func main() {
 
 	err := opts.Parse()
 	if err != nil {
-		klog.Fatalf("Error: %s", err)
+		logging.Fatalf("Error: %s", err)
+	}
+
+	if err := logging.Setup(opts.LogFormat, opts.LogLevel); err != nil {
+		logging.Fatalf("Failed to set up logging: %v", err)
 	}
 
 	if opts.Version {
@@ -90,7 +113,6 @@ func main() {
 	storeBuilder := store.NewBuilder()
 
 	ksmMetricsRegistry := prometheus.NewRegistry()
-	ksmMetricsRegistry.MustRegister(version.NewCollector("kube_state_metrics"))
 	durationVec := promauto.With(ksmMetricsRegistry).NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:        "http_request_duration_seconds",
@@ -109,31 +131,51 @@ func main() {
 		klog.Infof("Using resources %s", opts.Resources.String())
 		resources = opts.Resources.AsSlice()
 	}
+	ksmMetricsRegistry.MustRegister(newBuildInfoCollector(resources))
 
 	if err := storeBuilder.WithEnabledResources(resources); err != nil {
-		klog.Fatalf("Failed to set up resources: %v", err)
+		logging.Fatalf("Failed to set up resources: %v", err)
+	}
+
+	if err := storeBuilder.WithMetricPrefix(opts.MetricPrefix); err != nil {
+		logging.Fatalf("Failed to set up metric prefix: %v", err)
 	}
 
-	if len(opts.Namespaces) == 0 {
+	namespaces := opts.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = options.DefaultNamespaces
+	}
+	if namespaces.IsAllNamespaces() {
 		klog.Info("Using all namespace")
-		storeBuilder.WithNamespaces(options.DefaultNamespaces)
+		if len(opts.NamespacesDenylist) > 0 {
+			klog.Infof("Excluding %s namespaces", opts.NamespacesDenylist.String())
+		}
 	} else {
-		if opts.Namespaces.IsAllNamespaces() {
-			klog.Info("Using all namespace")
-		} else {
-			klog.Infof("Using %s namespaces", opts.Namespaces)
+		klog.Infof("Using %s namespaces", namespaces)
+		if len(opts.NamespacesDenylist) > 0 {
+			logging.Warningf("--namespaces-denylist is ignored as --namespaces is set to a list of namespaces")
 		}
-		storeBuilder.WithNamespaces(opts.Namespaces)
+	}
+	storeBuilder.WithNamespaces(namespaces)
+	storeBuilder.WithFieldSelectorFilter(namespaces.GetExcludeNSFieldSelector(opts.NamespacesDenylist))
+	if err := storeBuilder.WithObjectLabelSelector(opts.ObjectLabelSelector); err != nil {
+		logging.Fatalf("Failed to set up the object label selector: %v", err)
+	}
+	if err := storeBuilder.WithFieldSelectors(opts.FieldSelectors); err != nil {
+		logging.Fatalf("Failed to set up field selectors: %v", err)
+	}
+	if err := storeBuilder.WithObjectNames(opts.ObjectNames); err != nil {
+		logging.Fatalf("Failed to set up object names: %v", err)
 	}
 
 	allowDenyList, err := allowdenylist.New(opts.MetricAllowlist, opts.MetricDenylist)
 	if err != nil {
-		klog.Fatal(err)
+		logging.Fatalf("%v", err)
 	}
 
 	err = allowDenyList.Parse()
 	if err != nil {
-		klog.Fatalf("error initializing the allowdeny list : %v", err)
+		logging.Fatalf("error initializing the allowdeny list : %v", err)
 	}
 
 	klog.Infof("metric allow-denylisting: %v", allowDenyList.Status())
@@ -144,15 +186,108 @@ func main() {
 
 	proc.StartReaper()
 
-	kubeClient, vpaClient, err := createKubeClient(opts.Apiserver, opts.Kubeconfig)
-	if err != nil {
-		klog.Fatalf("Failed to create client: %v", err)
+	storeBuilder.WithRequestTimeout(opts.APIServerTimeout)
+	storeBuilder.WithUIDLabel(opts.EnableUIDLabel)
+	if opts.EnableResourceVersionLabel {
+		logging.Warningf("--enable-resource-version-label is meant for debugging only: every update of an object creates new series, disable it as soon as possible")
+	}
+	storeBuilder.WithResourceVersionLabel(opts.EnableResourceVersionLabel)
+	storeBuilder.WithClusterName(opts.ClusterName)
+	storeBuilder.WithObjectSeriesCount(opts.EnableObjectSeriesCount)
+	storeBuilder.WithVPATargetKinds(opts.VPATargetKinds.AsSlice())
+	storeBuilder.WithVPAZeroMissingResources(opts.VPAZeroMissingResources)
+	storeBuilder.WithVPAPreciseCPU(opts.VPAPreciseCPU)
+	if err := storeBuilder.WithVPAMemoryUnit(opts.VPAMemoryUnit); err != nil {
+		logging.Fatalf("Failed to set up the VerticalPodAutoscaler memory unit: %v", err)
+	}
+	storeBuilder.WithVPARecommenderAnnotation(opts.VPARecommenderAnnotation)
+	storeBuilder.WithVPAObservedContainersAnnotation(opts.VPAObservedContainers)
+	storeBuilder.WithVPARecommenderLabel(opts.VPARecommenderLabel)
+	storeBuilder.WithVPARecommendationSums(opts.VPARecommendationSums)
+	storeBuilder.WithVPAUpdateModeCount(opts.VPAUpdateModeCount)
+	storeBuilder.WithVPANamespaceCount(opts.VPANamespaceCount)
+	storeBuilder.WithVPATargetReplicas(opts.VPATargetReplicas)
+	storeBuilder.WithVPAHPAConflict(opts.VPAHPAConflict)
+	storeBuilder.WithVPAOmitEmptyDefaultLabels(opts.VPAOmitEmptyLabels)
+	storeBuilder.WithVPASkipOffModeRecommendations(opts.VPASkipOffModeRecs)
+	if err := storeBuilder.WithVPARecommendationBuckets(opts.VPARecommendationBuckets); err != nil {
+		logging.Fatalf("Failed to set up the VerticalPodAutoscaler recommendation buckets: %v", err)
+	}
+	storeBuilder.WithVPARecommendationNativeHistogram(opts.VPANativeHistogram)
+	if err := storeBuilder.WithVPARecommendationBounds(opts.VPARecommendationBounds); err != nil {
+		logging.Fatalf("Failed to set up the VerticalPodAutoscaler recommendation bounds: %v", err)
+	}
+	storeBuilder.WithVPAOwnerReferences(opts.VPAOwnerReferences)
+	if err := storeBuilder.WithVPAContainerDenylist(opts.VPAContainerDenylist); err != nil {
+		logging.Fatalf("Failed to set up the VerticalPodAutoscaler container denylist: %v", err)
 	}
-	storeBuilder.WithKubeClient(kubeClient)
-	storeBuilder.WithVPAClient(vpaClient)
 	storeBuilder.WithSharding(opts.Shard, opts.TotalShards)
+	if err := storeBuilder.WithShardBy(opts.ShardBy); err != nil {
+		logging.Fatalf("Failed to set up sharding: %v", err)
+	}
+	// Objects depending on the target of a VerticalPodAutoscaler are only
+	// guaranteed to be handled by the same shard with sharding by namespace.
+	sharded := opts.TotalShards > 1 || (opts.Pod != "" && opts.Namespace != "")
+	if sharded && opts.ShardBy != sharding.ByNamespace && opts.VPATargetReplicas {
+		logging.Fatalf("Failed to set up sharding: --vpa-target-replicas requires --shard-by=%s, got %s", sharding.ByNamespace, opts.ShardBy)
+	}
+	if sharded && opts.ShardBy != sharding.ByNamespace && opts.VPAHPAConflict {
+		logging.Fatalf("Failed to set up sharding: --vpa-hpa-conflict requires --shard-by=%s, got %s", sharding.ByNamespace, opts.ShardBy)
+	}
 	storeBuilder.WithAllowAnnotations(opts.AnnotationsAllowList)
 	storeBuilder.WithAllowLabels(opts.LabelsAllowList)
+	if err := storeBuilder.WithDefaultLabels(opts.DefaultLabels); err != nil {
+		logging.Fatalf("Failed to set up default labels: %v", err)
+	}
+	// The overrides are checked against the exposed families, so all options
+	// changing their names have to be set before.
+	storeBuilder.WithLegacyMetricAliases(opts.EnableLegacyMetricAliases)
+	if opts.MetricOverridesConfig != "" {
+		overrides, err := loadFamilyOverrides(opts.MetricOverridesConfig)
+		if err != nil {
+			logging.Fatalf("Failed to load metric overrides: %v", err)
+		}
+		storeBuilder.WithFamilyOverrides(checkFamilyOverrides(storeBuilder.Catalog(), overrides))
+	}
+	seriesFilter, err := seriesfilter.Parse(opts.SeriesFilter)
+	if err != nil {
+		logging.Fatalf("Failed to set up the series filter: %v", err)
+	}
+	storeBuilder.WithMetricFilter(seriesFilter)
+	if err := storeBuilder.WithLabelRenames(opts.LabelRenames); err != nil {
+		logging.Fatalf("Failed to set up the label renames: %v", err)
+	}
+	storeBuilder.WithDropZeroStateSet(opts.DropZeroStateSet)
+	if err := storeBuilder.WithMaxSeries(opts.MaxSeries); err != nil {
+		logging.Fatalf("Failed to set up max series: %v", err)
+	}
+	if err := storeBuilder.WithGenerationConcurrency(opts.GenerationConcurrency); err != nil {
+		logging.Fatalf("Failed to set up generation concurrency: %v", err)
+	}
+	if err := storeBuilder.WithStoreQueueDepth(opts.StoreQueueDepth); err != nil {
+		logging.Fatalf("Failed to set up the store queue depth: %v", err)
+	}
+	if err := storeBuilder.WithMaxLabelValueLength(opts.MaxLabelValueLength); err != nil {
+		logging.Fatalf("Failed to set up the maximum label value length: %v", err)
+	}
+	if err := storeBuilder.ValidateLabelRenames(); err != nil {
+		logging.Fatalf("Failed to set up the label renames: %v", err)
+	}
+
+	if opts.ValidateConfig {
+		if err := validateCatalog(storeBuilder.Catalog()); err != nil {
+			logging.Fatalf("Invalid configuration: %v", err)
+		}
+		klog.Info("Configuration is valid")
+		os.Exit(0)
+	}
+
+	kubeClient, vpaClient, err := createKubeClient(opts.Apiserver, opts.Kubeconfig, opts.VPAKubeconfig, opts.VPAContext, opts.ClientCAFile)
+	if err != nil {
+		logging.Fatalf("Failed to create client: %v", err)
+	}
+	storeBuilder.WithKubeClient(kubeClient)
+	storeBuilder.WithVPAClient(vpaClient)
 
 	ksmMetricsRegistry.MustRegister(
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
@@ -167,6 +302,45 @@ func main() {
 		storeBuilder,
 		opts.EnableGZIPEncoding,
 	)
+	// Stop on SIGINT and SIGTERM. The group interrupts its actors in the
+	// order they are added, so the servers drain their in-flight scrapes
+	// before the reflectors of the MetricsHandler are stopped.
+	g.Add(run.SignalHandler(ctx, os.Interrupt, syscall.SIGTERM))
+	shutdown := &gracefulShutdown{period: opts.ServerShutdownGracePeriod}
+
+	servers := &serverGroup{
+		group:     &g,
+		shutdown:  shutdown,
+		tlsConfig: opts.TLSConfig,
+		logger:    promLogger,
+	}
+
+	routePrefix := normalizeRoutePrefix(opts.RoutePrefix)
+	if routePrefix != "" {
+		klog.Infof("Serving all endpoints under %s", routePrefix)
+	}
+	telemetryMux := buildTelemetryServer(ksmMetricsRegistry, opts.EnablePprof, routePrefix)
+	metricsHandler := serverHandler(buildMetricsServer(m, durationVec, routePrefix), opts)
+
+	// Run Telemetry servers, on TCP and the Unix socket sharing the same
+	// handler.
+	if opts.TelemetryPort != 0 || opts.TelemetrySocket == "" {
+		telemetryListenAddress := net.JoinHostPort(opts.TelemetryHost, strconv.Itoa(opts.TelemetryPort))
+		servers.add("kube-state-metrics self metrics server", &http.Server{Handler: telemetryMux, Addr: telemetryListenAddress}, "tcp")
+	}
+	if opts.TelemetrySocket != "" {
+		servers.add("kube-state-metrics self metrics server", &http.Server{Handler: telemetryMux, Addr: opts.TelemetrySocket}, "unix")
+	}
+	// Run Metrics servers, one per listen address and the Unix socket
+	// sharing the same handler.
+	if opts.Port != 0 || opts.MetricsSocket == "" {
+		for _, metricsServerListenAddress := range listenAddresses(opts.Host, opts.Port) {
+			servers.add("metrics server", newMetricsServer(metricsHandler, metricsServerListenAddress, opts), "tcp")
+		}
+	}
+	if opts.MetricsSocket != "" {
+		servers.add("metrics server", newMetricsServer(metricsHandler, opts.MetricsSocket, opts), "unix")
+	}
 	// Run MetricsHandler
 	{
 		ctxMetricsHandler, cancel := context.WithCancel(ctx)
@@ -176,62 +350,246 @@ func main() {
 			cancel()
 		})
 	}
-
-	tlsConfig := opts.TLSConfig
-
-	telemetryMux := buildTelemetryServer(ksmMetricsRegistry)
-	telemetryListenAddress := net.JoinHostPort(opts.TelemetryHost, strconv.Itoa(opts.TelemetryPort))
-	telemetryServer := http.Server{Handler: telemetryMux, Addr: telemetryListenAddress}
-
-	metricsMux := buildMetricsServer(m, durationVec)
-	metricsServerListenAddress := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
-	metricsServer := http.Server{Handler: metricsMux, Addr: metricsServerListenAddress}
-
-	// Run Telemetry server
-	{
+	// Push to the Pushgateway
+	if opts.PushTo != "" {
+		if opts.PushInterval <= 0 {
+			logging.Fatalf("Failed to set up pushing: invalid push interval %v, must be greater than 0", opts.PushInterval)
+		}
+		klog.Infof("Pushing metrics to %s every %v", opts.PushTo, opts.PushInterval)
+		ctxPush, cancel := context.WithCancel(ctx)
 		g.Add(func() error {
-			klog.Infof("Starting kube-state-metrics self metrics server: %s", telemetryListenAddress)
-			return web.ListenAndServe(&telemetryServer, tlsConfig, promLogger)
+			return m.RunPush(ctxPush, opts.PushTo, opts.PushGrouping, opts.PushInterval)
 		}, func(error) {
-			ctxShutDown, cancel := context.WithTimeout(ctx, 3*time.Second)
-			defer cancel()
-			telemetryServer.Shutdown(ctxShutDown)
+			cancel()
 		})
 	}
-	// Run Metrics server
-	{
+	// Send to the remote write endpoint
+	if opts.RemoteWriteURL != "" {
+		if opts.RemoteWriteInterval <= 0 {
+			logging.Fatalf("Failed to set up remote write: invalid remote write interval %v, must be greater than 0", opts.RemoteWriteInterval)
+		}
+		if opts.RemoteWriteMaxSamples <= 0 {
+			logging.Fatalf("Failed to set up remote write: invalid maximum number of samples per send %d, must be greater than 0", opts.RemoteWriteMaxSamples)
+		}
+		klog.Infof("Sending metrics to the remote write endpoint every %v", opts.RemoteWriteInterval)
+		ctxRemoteWrite, cancel := context.WithCancel(ctx)
 		g.Add(func() error {
-			klog.Infof("Starting metrics server: %s", metricsServerListenAddress)
-			return web.ListenAndServe(&metricsServer, tlsConfig, promLogger)
+			return m.RunRemoteWrite(ctxRemoteWrite, opts.RemoteWriteURL, opts.RemoteWriteBearerTokenFile, opts.RemoteWriteInterval, opts.RemoteWriteMaxSamples)
 		}, func(error) {
-			ctxShutDown, cancel := context.WithTimeout(ctx, 3*time.Second)
-			defer cancel()
-			metricsServer.Shutdown(ctxShutDown)
+			cancel()
 		})
 	}
 
 	if err := g.Run(); err != nil {
-		klog.Fatalf("RunGroup Error: %v", err)
+		var signalErr run.SignalError
+		if !errors.As(err, &signalErr) {
+			logging.Fatalf("RunGroup Error: %v", err)
+		}
+		klog.Infof("Shut down on %v", signalErr.Signal)
 	}
 	klog.Info("Exiting")
 }
 
-func createKubeClient(apiserver string, kubeconfig string) (clientset.Interface, vpaclientset.Interface, error) {
-	config, err := clientcmd.BuildConfigFromFlags(apiserver, kubeconfig)
+// validateCatalog checks that no metric family is exposed more than once
+// across the resources of the catalog.
+func validateCatalog(catalog map[string][]generator.FamilyGenerator) error {
+	resources := make([]string, 0, len(catalog))
+	for resource := range catalog {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	exposedBy := map[string]string{}
+	var duplicates []string
+	for _, resource := range resources {
+		for _, family := range catalog[resource] {
+			if other, ok := exposedBy[family.Name]; ok {
+				duplicates = append(duplicates, fmt.Sprintf("%s (%s, %s)", family.Name, other, resource))
+				continue
+			}
+			exposedBy[family.Name] = resource
+		}
+	}
+	if len(duplicates) > 0 {
+		return errors.Errorf("duplicate metric families: %s", strings.Join(duplicates, ", "))
+	}
+	return nil
+}
+
+// loadFamilyOverrides reads the metric family overrides from the given YAML
+// file.
+func loadFamilyOverrides(path string) (map[string]generator.FamilyOverride, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	overrides := map[string]generator.FamilyOverride{}
+	if err := yaml.UnmarshalStrict(data, &overrides); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
 	}
+	return overrides, nil
+}
 
-	config.UserAgent = version.Version
-	config.AcceptContentTypes = "application/vnd.kubernetes.protobuf,application/json"
-	config.ContentType = "application/vnd.kubernetes.protobuf"
+// checkFamilyOverrides returns the overrides which apply to a metric family of
+// the catalog. The others are logged as a warning and dropped, as are units
+// which do not match the name of their family.
+func checkFamilyOverrides(catalog map[string][]generator.FamilyGenerator, overrides map[string]generator.FamilyOverride) map[string]generator.FamilyOverride {
+	families := map[string]generator.FamilyGenerator{}
+	for _, resourceFamilies := range catalog {
+		for _, family := range resourceFamilies {
+			families[family.Name] = family
+		}
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checked := make(map[string]generator.FamilyOverride, len(overrides))
+	for _, name := range names {
+		override := overrides[name]
+		family, ok := families[name]
+		if !ok {
+			logging.Warningf("Ignoring the override of metric family %s, as it is not exposed", name)
+			continue
+		}
+		if err := generator.ValidateFamilyOverride(family, override); err != nil {
+			logging.Warningf("Ignoring the unit override of metric family %s: %v", name, err)
+			override.Unit = ""
+		}
+		checked[name] = override
+	}
+	return checked
+}
+
+// gracefulShutdown shuts down servers within a grace period, which starts
+// with the first server shut down and is shared by all of them.
+type gracefulShutdown struct {
+	period   time.Duration
+	once     sync.Once
+	deadline time.Time
+}
+
+// shutdown stops the server from accepting new connections and waits for its
+// in-flight requests until the grace period is over.
+func (s *gracefulShutdown) shutdown(server *http.Server) {
+	s.once.Do(func() {
+		s.deadline = time.Now().Add(s.period)
+	})
+	ctx, cancel := context.WithDeadline(context.Background(), s.deadline)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logging.Warningf("Failed to drain the server %s within the grace period of %v: %v", server.Addr, s.period, err)
+	}
+}
+
+// serverGroup adds servers to a run group, which share the TLS configuration
+// and the grace period of their shutdown.
+type serverGroup struct {
+	group     *run.Group
+	shutdown  *gracefulShutdown
+	tlsConfig string
+	logger    promLogger
+}
+
+// add adds an actor serving the server on its address, a TCP address or the
+// path of a Unix socket depending on the network.
+func (s *serverGroup) add(description string, server *http.Server, network string) {
+	s.group.Add(func() error {
+		klog.Infof("Starting %s: %s", description, server.Addr)
+		l, err := listen(network, server.Addr)
+		if err != nil {
+			return err
+		}
+		return web.Serve(l, server, s.tlsConfig, s.logger)
+	}, func(error) {
+		s.shutdown.shutdown(server)
+	})
+}
+
+// listen listens on the address of the network. A Unix socket left over at
+// the path, e.g. by a previous container in the same volume, is replaced.
+func listen(network, address string) (net.Listener, error) {
+	if network == "unix" {
+		if fi, err := os.Lstat(address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(address); err != nil {
+				return nil, errors.Wrapf(err, "failed to remove the socket %s", address)
+			}
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// newMetricsServer returns a metrics server listening on the given address
+// with the timeouts and header limit of the options.
+func newMetricsServer(handler http.Handler, addr string, opts *options.Options) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		Addr:              addr,
+		ReadTimeout:       opts.ServerReadTimeout,
+		ReadHeaderTimeout: opts.ServerReadHeaderTimeout,
+		WriteTimeout:      opts.ServerWriteTimeout,
+		IdleTimeout:       opts.ServerIdleTimeout,
+		MaxHeaderBytes:    opts.ServerMaxHeaderBytes,
+	}
+}
+
+// serverHandler wraps the handler of the metrics server to serve HTTP/2
+// without TLS if enabled. With TLS, HTTP/2 is negotiated by the TLS
+// configuration instead.
+func serverHandler(handler http.Handler, opts *options.Options) http.Handler {
+	if !opts.ServerHTTP2 || opts.TLSConfig != "" {
+		return handler
+	}
+	return h2c.NewHandler(handler, &http2.Server{IdleTimeout: opts.ServerIdleTimeout})
+}
+
+// listenAddresses returns the addresses to listen on for the comma-separated
+// list of hosts and the given port.
+func listenAddresses(hosts string, port int) []string {
+	var addresses []string
+	for _, host := range strings.Split(hosts, ",") {
+		addresses = append(addresses, net.JoinHostPort(strings.TrimSpace(host), strconv.Itoa(port)))
+	}
+	return addresses
+}
+
+// newBuildInfoCollector returns the collector of kube_state_metrics_build_info,
+// which extends the build information of version.NewCollector with the sorted,
+// comma-separated list of enabled resources.
+func newBuildInfoCollector(resources []string) prometheus.Collector {
+	enabled := append([]string(nil), resources...)
+	sort.Strings(enabled)
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "kube_state_metrics_build_info",
+			Help: "A metric with a constant '1' value labeled by version, revision, branch, goversion and the resources kube-state-metrics was built and runs with.",
+			ConstLabels: prometheus.Labels{
+				"version":   version.Version,
+				"revision":  version.Revision,
+				"branch":    version.Branch,
+				"goversion": version.GoVersion,
+				"resources": strings.Join(enabled, ","),
+			},
+		},
+		func() float64 { return 1 },
+	)
+}
+
+func createKubeClient(apiserver, kubeconfig, vpaKubeconfig, vpaContext, caFile string) (clientset.Interface, vpaclientset.Interface, error) {
+	config, vpaConfig, err := createClientConfigs(apiserver, kubeconfig, vpaKubeconfig, vpaContext, caFile)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	kubeClient, err := clientset.NewForConfig(config)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	vpaClient, err := vpaclientset.NewForConfig(config)
+	vpaClient, err := vpaclientset.NewForConfig(vpaConfig)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -250,19 +608,149 @@ func createKubeClient(apiserver string, kubeconfig string) (clientset.Interface,
 	return kubeClient, vpaClient, nil
 }
 
-func buildTelemetryServer(registry prometheus.Gatherer) *http.ServeMux {
+// createClientConfigs returns the configurations of the client of the core
+// resources and of the VerticalPodAutoscaler client, which only differ if
+// vpaKubeconfig or vpaContext is set. Both trust the additional CA bundle at
+// caFile if set.
+func createClientConfigs(apiserver, kubeconfig, vpaKubeconfig, vpaContext, caFile string) (*rest.Config, *rest.Config, error) {
+	config, err := clientcmd.BuildConfigFromFlags(apiserver, kubeconfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config.UserAgent = version.Version
+	config.AcceptContentTypes = "application/vnd.kubernetes.protobuf,application/json"
+	config.ContentType = "application/vnd.kubernetes.protobuf"
+	if err := withCABundle(config, caFile); err != nil {
+		return nil, nil, err
+	}
+
+	vpaConfig := config
+	if vpaKubeconfig != "" || vpaContext != "" {
+		vpaConfig, err = createVPAClientConfig(kubeconfig, vpaKubeconfig, vpaContext)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error while building the VerticalPodAutoscaler client configuration")
+		}
+		if err := withCABundle(vpaConfig, caFile); err != nil {
+			return nil, nil, errors.Wrap(err, "error while building the VerticalPodAutoscaler client configuration")
+		}
+	}
+	return config, vpaConfig, nil
+}
+
+// withCABundle makes the client of config trust the certificates of the CA
+// bundle at caFile in addition to the CA it trusts already, e.g. the one of
+// the in-cluster service account. If config does not set a CA, the bundle is
+// trusted in addition to the system roots. Nothing is changed if caFile is
+// empty.
+func withCABundle(config *rest.Config, caFile string) error {
+	if caFile == "" {
+		return nil
+	}
+	if config.Insecure {
+		return errors.New("an additional CA bundle cannot be trusted by an insecure client")
+	}
+	bundle, err := os.ReadFile(caFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the CA bundle")
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(bundle) {
+		return errors.Errorf("no certificates found in the CA bundle %s", caFile)
+	}
+
+	ca := config.CAData
+	if len(ca) == 0 && config.CAFile != "" {
+		if ca, err = os.ReadFile(config.CAFile); err != nil {
+			return errors.Wrap(err, "failed to read the CA of the client configuration")
+		}
+	}
+	if len(ca) > 0 {
+		config.CAData = append(append(ca[:len(ca):len(ca)], '\n'), bundle...)
+		config.CAFile = ""
+		return nil
+	}
+
+	// The CA data of the configuration replaces the system roots, so the
+	// bundle is added to them in the transport instead.
+	if config.Transport != nil {
+		return errors.New("an additional CA bundle cannot be trusted by a client with a custom transport")
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		return errors.Wrap(err, "failed to load the system roots")
+	}
+	roots.AppendCertsFromPEM(bundle)
+	// The transport is handed over as created by client-go, before any
+	// other wrapper of the configuration.
+	config.WrapTransport = transport.Wrappers(func(rt http.RoundTripper) http.RoundTripper {
+		t, ok := rt.(*http.Transport)
+		if !ok {
+			return rt
+		}
+		// The transport may be shared with other clients.
+		t = t.Clone()
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.RootCAs = roots
+		return t
+	}, config.WrapTransport)
+	return nil
+}
+
+// createVPAClientConfig returns the configuration of the VerticalPodAutoscaler
+// client for setups where VerticalPodAutoscalers are served by a different
+// apiserver than the core resources. It reads vpaKubeconfig, or kubeconfig if
+// unset, using vpaContext instead of the current context if set.
+func createVPAClientConfig(kubeconfig, vpaKubeconfig, vpaContext string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	if vpaKubeconfig != "" {
+		loadingRules.ExplicitPath = vpaKubeconfig
+	}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: vpaContext},
+	).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	config.UserAgent = version.Version
+	klog.Infof("Using a separate configuration for the VerticalPodAutoscaler client, server: %s", config.Host)
+	return config, nil
+}
+
+// buildTelemetryServer returns the handler of the self metrics. The pprof
+// endpoints are only served if enablePprof is set, never on the metrics
+// server, so that they are not exposed to scrapers. Like the ones of the
+// metrics server, all paths start with the normalized routePrefix.
+func buildTelemetryServer(registry prometheus.Gatherer, enablePprof bool, routePrefix string) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Add metricsPath
-	mux.Handle(metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: promLogger{}}))
+	mux.Handle(routePrefix+metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: promLogger{}}))
+
+	links := `<li><a href='` + routePrefix + metricsPath + `'>metrics</a></li>`
+	if enablePprof {
+		// The pprof handlers expect their paths at the root.
+		mux.Handle(routePrefix+pprofPath, stripRoutePrefix(routePrefix, http.HandlerFunc(pprof.Index)))
+		mux.Handle(routePrefix+pprofPath+"cmdline", stripRoutePrefix(routePrefix, http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle(routePrefix+pprofPath+"profile", stripRoutePrefix(routePrefix, http.HandlerFunc(pprof.Profile)))
+		mux.Handle(routePrefix+pprofPath+"symbol", stripRoutePrefix(routePrefix, http.HandlerFunc(pprof.Symbol)))
+		mux.Handle(routePrefix+pprofPath+"trace", stripRoutePrefix(routePrefix, http.HandlerFunc(pprof.Trace)))
+		links += `
+             <li><a href='` + routePrefix + pprofPath + `'>pprof</a></li>`
+	}
 	// Add index
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(routePrefix+"/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>Kube-State-Metrics Metrics Server</title></head>
              <body>
              <h1>Kube-State-Metrics Metrics</h1>
 			 <ul>
-             <li><a href='` + metricsPath + `'>metrics</a></li>
+             ` + links + `
 			 </ul>
              </body>
              </html>`))
@@ -270,35 +758,55 @@ func buildTelemetryServer(registry prometheus.Gatherer) *http.ServeMux {
 	return mux
 }
 
-func buildMetricsServer(m *metricshandler.MetricsHandler, durationObserver prometheus.ObserverVec) *http.ServeMux {
+func buildMetricsServer(m *metricshandler.MetricsHandler, durationObserver prometheus.ObserverVec, routePrefix string) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	// TODO: This doesn't belong into serveMetrics
-	mux.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
-	mux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
-	mux.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
-	mux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
-	mux.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
-
-	mux.Handle(metricsPath, promhttp.InstrumentHandlerDuration(durationObserver, m))
+	mux.Handle(routePrefix+metricsPath, promhttp.InstrumentHandlerDuration(durationObserver, m))
+	mux.HandleFunc(routePrefix+metricsJSONPath, m.ServeJSON)
+	mux.HandleFunc(routePrefix+catalogPath, m.ServeCatalog)
 
 	// Add healthzPath
-	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(routePrefix+healthzPath, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(http.StatusText(http.StatusOK)))
 	})
+	// Add readyzPath
+	mux.HandleFunc(routePrefix+readyzPath, m.ServeReady)
 	// Add index
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(routePrefix+"/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>Kube Metrics Server</title></head>
              <body>
              <h1>Kube Metrics</h1>
 			 <ul>
-             <li><a href='` + metricsPath + `'>metrics</a></li>
-             <li><a href='` + healthzPath + `'>healthz</a></li>
+             <li><a href='` + routePrefix + metricsPath + `'>metrics</a></li>
+             <li><a href='` + routePrefix + metricsJSONPath + `'>metrics as JSON</a></li>
+             <li><a href='` + routePrefix + catalogPath + `'>metric catalog</a></li>
+             <li><a href='` + routePrefix + healthzPath + `'>healthz</a></li>
+             <li><a href='` + routePrefix + readyzPath + `'>readyz</a></li>
 			 </ul>
              </body>
              </html>`))
 	})
 	return mux
 }
+
+// normalizeRoutePrefix returns the route prefix with a leading and without a
+// trailing slash, so that it can be prepended to the paths served. Both an
+// empty prefix and "/" serve them at the root.
+func normalizeRoutePrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// stripRoutePrefix removes the route prefix from the path of the requests to
+// the handler.
+func stripRoutePrefix(routePrefix string, handler http.Handler) http.Handler {
+	if routePrefix == "" {
+		return handler
+	}
+	return http.StripPrefix(routePrefix, handler)
+}
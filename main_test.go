@@ -19,24 +19,44 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"math/big"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/oklog/run"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/http2"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 
 	"k8s.io/kube-state-metrics/v2/internal/store"
 	"k8s.io/kube-state-metrics/v2/pkg/allowdenylist"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
 	"k8s.io/kube-state-metrics/v2/pkg/metricshandler"
 	"k8s.io/kube-state-metrics/v2/pkg/options"
 )
@@ -324,7 +344,7 @@ kube_pod_status_reason{namespace="default",pod="pod0",uid="abc-0",reason="Unexpe
 		}
 	}
 
-	telemetryMux := buildTelemetryServer(reg)
+	telemetryMux := buildTelemetryServer(reg, false, "")
 
 	req2 := httptest.NewRequest("GET", "http://localhost:8081/metrics", nil)
 
@@ -536,6 +556,521 @@ func TestShardingEquivalenceScrapeCycle(t *testing.T) {
 	}
 }
 
+// TestMetricPrefixScrapeCycle covers the entire cycle from cache filling to
+// scraping with a custom metric prefix.
+func TestMetricPrefixScrapeCycle(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewSimpleClientset()
+
+	err := pod(kubeClient, 0)
+	if err != nil {
+		t.Fatalf("failed to insert sample pod %v", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg := prometheus.NewRegistry()
+	builder := store.NewBuilder()
+	builder.WithMetrics(reg)
+	builder.WithEnabledResources([]string{"pods"})
+	builder.WithKubeClient(kubeClient)
+	builder.WithNamespaces(options.DefaultNamespaces)
+	builder.WithGenerateStoresFunc(builder.DefaultGenerateStoresFunc(), false)
+
+	if err := builder.WithMetricPrefix("cluster1-"); err == nil {
+		t.Fatal("expected invalid metric prefix to be rejected")
+	}
+	if err := builder.WithMetricPrefix("cluster1_kube_"); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := allowdenylist.New(map[string]struct{}{"cluster1_kube_pod_info": {}}, map[string]struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithAllowDenyList(l)
+	builder.WithAllowLabels(map[string][]string{})
+
+	handler := metricshandler.New(&options.Options{}, kubeClient, builder, false)
+	handler.ConfigureSharding(ctx, 0, 1)
+
+	// Wait for caches to fill
+	time.Sleep(time.Second)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/metrics", nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 status code but got %v", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+
+	expected := `# HELP cluster1_kube_pod_info Information about pod.
+# TYPE cluster1_kube_pod_info gauge
+cluster1_kube_pod_info{namespace="default",pod="pod0",uid="abc-0",host_ip="1.1.1.1",pod_ip="1.2.3.4",node="node1",created_by_kind="<none>",created_by_name="<none>",priority_class="",host_network="false"} 1
+`
+
+	if got := string(body); got != expected {
+		t.Fatalf("expected:\n\n%s\nbut got:\n\n%s", expected, got)
+	}
+}
+
+// TestMaxSeriesReshardScrapeCycle checks that the stores built when
+// resharding get the full series budget, even though the ones they replace
+// never return their series.
+func TestMaxSeriesReshardScrapeCycle(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewSimpleClientset()
+
+	err := pod(kubeClient, 0)
+	if err != nil {
+		t.Fatalf("failed to insert sample pod %v", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg := prometheus.NewRegistry()
+	builder := store.NewBuilder()
+	builder.WithMetrics(reg)
+	builder.WithEnabledResources([]string{"pods"})
+	builder.WithKubeClient(kubeClient)
+	builder.WithNamespaces(options.DefaultNamespaces)
+	builder.WithGenerateStoresFunc(builder.DefaultGenerateStoresFunc(), false)
+	if err := builder.WithMaxSeries(1); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := allowdenylist.New(map[string]struct{}{"kube_pod_info": {}}, map[string]struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithAllowDenyList(l)
+	builder.WithAllowLabels(map[string][]string{})
+
+	handler := metricshandler.New(&options.Options{}, kubeClient, builder, false)
+	handler.ConfigureSharding(ctx, 0, 1)
+
+	// Wait for caches to fill
+	time.Sleep(time.Second)
+
+	// The stores of the first build take up the whole budget.
+	handler.ConfigureSharding(ctx, 0, 1)
+
+	// Wait for caches to fill
+	time.Sleep(time.Second)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/metrics", nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 status code but got %v", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+
+	expected := `# HELP kube_pod_info Information about pod.
+# TYPE kube_pod_info gauge
+kube_pod_info{namespace="default",pod="pod0",uid="abc-0",host_ip="1.1.1.1",pod_ip="1.2.3.4",node="node1",created_by_kind="<none>",created_by_name="<none>",priority_class="",host_network="false"} 1
+`
+
+	if got := string(body); got != expected {
+		t.Fatalf("expected:\n\n%s\nbut got:\n\n%s", expected, got)
+	}
+}
+
+func TestLabelRenamesScrapeCycle(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewSimpleClientset()
+
+	err := pod(kubeClient, 0)
+	if err != nil {
+		t.Fatalf("failed to insert sample pod %v", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg := prometheus.NewRegistry()
+	builder := store.NewBuilder()
+	builder.WithMetrics(reg)
+	builder.WithEnabledResources([]string{"pods"})
+	builder.WithKubeClient(kubeClient)
+	builder.WithNamespaces(options.DefaultNamespaces)
+	builder.WithGenerateStoresFunc(builder.DefaultGenerateStoresFunc(), false)
+	builder.WithClusterName("prod")
+
+	if err := builder.WithLabelRenames(map[string]string{"namespace": "k8s_namespace", "pod": "k8s_namespace"}); err == nil {
+		t.Fatal("expected renaming two labels to the same key to be rejected")
+	}
+	if err := builder.WithLabelRenames(map[string]string{"namespace": "k8s-namespace"}); err == nil {
+		t.Fatal("expected an invalid label name to be rejected")
+	}
+
+	l, err := allowdenylist.New(map[string]struct{}{"kube_pod_info": {}, "kube_pod_owner": {}}, map[string]struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithAllowDenyList(l)
+	builder.WithAllowLabels(map[string][]string{})
+
+	// Renaming node to pod_ip collides with the pod_ip label of
+	// kube_pod_info.
+	if err := builder.WithLabelRenames(map[string]string{"node": "pod_ip"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.ValidateLabelRenames(); err == nil {
+		t.Fatal("expected renaming a label to the key of another label of the same metric to be rejected")
+	}
+	if err := builder.WithLabelRenames(map[string]string{"namespace": "k8s_namespace", "pod": "k8s_pod", "cluster": "k8s_cluster"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.ValidateLabelRenames(); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := metricshandler.New(&options.Options{}, kubeClient, builder, false)
+	handler.ConfigureSharding(ctx, 0, 1)
+
+	// Wait for caches to fill
+	time.Sleep(time.Second)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/metrics", nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 status code but got %v", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+
+	expected := `# HELP kube_pod_info Information about pod.
+# TYPE kube_pod_info gauge
+kube_pod_info{k8s_namespace="default",k8s_pod="pod0",uid="abc-0",host_ip="1.1.1.1",pod_ip="1.2.3.4",node="node1",created_by_kind="<none>",created_by_name="<none>",priority_class="",host_network="false",k8s_cluster="prod"} 1
+# HELP kube_pod_owner Information about the Pod's owner.
+# TYPE kube_pod_owner gauge
+kube_pod_owner{k8s_namespace="default",k8s_pod="pod0",uid="abc-0",owner_kind="<none>",owner_name="<none>",owner_is_controller="<none>",k8s_cluster="prod"} 1
+`
+
+	if got := string(body); got != expected {
+		t.Fatalf("expected:\n\n%s\nbut got:\n\n%s", expected, got)
+	}
+}
+
+// TestOpenMetricsScrapeCycle covers the entire cycle from cache filling to
+// scraping in the OpenMetrics text format.
+func TestOpenMetricsScrapeCycle(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewSimpleClientset()
+
+	err := pod(kubeClient, 0)
+	if err != nil {
+		t.Fatalf("failed to insert sample pod %v", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg := prometheus.NewRegistry()
+	builder := store.NewBuilder()
+	builder.WithMetrics(reg)
+	builder.WithEnabledResources([]string{"pods"})
+	builder.WithKubeClient(kubeClient)
+	builder.WithNamespaces(options.DefaultNamespaces)
+	builder.WithGenerateStoresFunc(builder.DefaultGenerateStoresFunc(), false)
+
+	l, err := allowdenylist.New(map[string]struct{}{"kube_pod_container_status_restarts_total": {}}, map[string]struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithAllowDenyList(l)
+	builder.WithAllowLabels(map[string][]string{})
+
+	handler := metricshandler.New(&options.Options{}, kubeClient, builder, false)
+	handler.ConfigureSharding(ctx, 0, 1)
+
+	// Wait for caches to fill
+	time.Sleep(time.Second)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1,text/plain;version=0.0.4;q=0.5")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 status code but got %v", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Fatalf("expected OpenMetrics content type but got %q", contentType)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	got := string(body)
+
+	expectedHeader := `# HELP kube_pod_container_status_restarts The number of container restarts per container.
+# TYPE kube_pod_container_status_restarts counter
+`
+	if !strings.HasPrefix(got, expectedHeader) {
+		t.Fatalf("expected OpenMetrics counter header:\n\n%s\nbut got:\n\n%s", expectedHeader, got)
+	}
+	if !strings.Contains(got, "\nkube_pod_container_status_restarts_total{") {
+		t.Fatalf("expected counter samples to keep the _total suffix, got:\n\n%s", got)
+	}
+	if !strings.HasSuffix(got, "\n# EOF\n") {
+		t.Fatalf("expected OpenMetrics output to end with # EOF, got:\n\n%s", got)
+	}
+}
+
+// TestJSONScrapeCycle covers the entire cycle from cache filling to scraping
+// the metrics as JSON.
+func TestJSONScrapeCycle(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewSimpleClientset()
+
+	err := pod(kubeClient, 0)
+	if err != nil {
+		t.Fatalf("failed to insert sample pod %v", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg := prometheus.NewRegistry()
+	builder := store.NewBuilder()
+	builder.WithMetrics(reg)
+	builder.WithEnabledResources([]string{"pods"})
+	builder.WithKubeClient(kubeClient)
+	builder.WithNamespaces(options.DefaultNamespaces)
+	builder.WithGenerateStoresFunc(builder.DefaultGenerateStoresFunc(), false)
+
+	l, err := allowdenylist.New(map[string]struct{}{"kube_pod_info": {}, "kube_pod_container_status_restarts_total": {}}, map[string]struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithAllowDenyList(l)
+	builder.WithAllowLabels(map[string][]string{})
+
+	handler := metricshandler.New(&options.Options{}, kubeClient, builder, false)
+	handler.ConfigureSharding(ctx, 0, 1)
+
+	// Wait for caches to fill
+	time.Sleep(time.Second)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/metrics.json", nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeJSON(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 status code but got %v", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+
+	expected := `[{"name":"kube_pod_container_status_restarts_total","help":"The number of container restarts per container.","type":"counter","metrics":[{"labelKeys":["namespace","pod","uid","container"],"labelValues":["default","pod0","abc-0","container2"],"value":0},{"labelKeys":["namespace","pod","uid","container"],"labelValues":["default","pod0","abc-0","container3"],"value":0}]},{"name":"kube_pod_info","help":"Information about pod.","type":"gauge","metrics":[{"labelKeys":["namespace","pod","uid","host_ip","pod_ip","node","created_by_kind","created_by_name","priority_class","host_network"],"labelValues":["default","pod0","abc-0","1.1.1.1","1.2.3.4","node1","\u003cnone\u003e","\u003cnone\u003e","","false"],"value":1}]}]`
+
+	if got := strings.TrimSpace(string(body)); got != expected {
+		t.Fatalf("expected:\n\n%s\n\nbut got:\n\n%s", expected, got)
+	}
+}
+
+// TestReadyz verifies that the handler only reports readiness once the stores
+// have synced.
+func TestReadyz(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewSimpleClientset()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg := prometheus.NewRegistry()
+	builder := store.NewBuilder()
+	builder.WithMetrics(reg)
+	builder.WithEnabledResources([]string{"pods", "configmaps"})
+	builder.WithKubeClient(kubeClient)
+	builder.WithNamespaces(options.DefaultNamespaces)
+	builder.WithGenerateStoresFunc(builder.DefaultGenerateStoresFunc(), false)
+	builder.WithAllowLabels(map[string][]string{})
+
+	l, err := allowdenylist.New(options.MetricSet{}, options.MetricSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.WithAllowDenyList(l)
+
+	handler := metricshandler.New(&options.Options{}, kubeClient, builder, false)
+
+	readyz := func() int {
+		w := httptest.NewRecorder()
+		handler.ServeReady(w, httptest.NewRequest("GET", "http://localhost:8080/readyz", nil))
+		return w.Result().StatusCode
+	}
+
+	if status := readyz(); status != 503 {
+		t.Fatalf("expected 503 status code before sharding is configured but got %v", status)
+	}
+
+	handler.ConfigureSharding(ctx, 0, 1)
+
+	// Wait for caches to fill
+	time.Sleep(time.Second)
+
+	if status := readyz(); status != 200 {
+		t.Fatalf("expected 200 status code once the stores synced but got %v", status)
+	}
+}
+
+func TestPprof(t *testing.T) {
+	t.Parallel()
+
+	// Unknown paths are answered by the index page.
+	servesPprof := func(h http.Handler) bool {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8081/debug/pprof/cmdline", nil))
+		return strings.HasPrefix(w.Result().Header.Get("Content-Type"), "text/plain")
+	}
+
+	if servesPprof(buildTelemetryServer(prometheus.NewRegistry(), false, "")) {
+		t.Error("expected pprof to be disabled by default")
+	}
+	if !servesPprof(buildTelemetryServer(prometheus.NewRegistry(), true, "")) {
+		t.Error("expected pprof to be served on the telemetry port")
+	}
+	durationVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "http_request_duration_seconds"}, []string{"method"})
+	if servesPprof(buildMetricsServer(&metricshandler.MetricsHandler{}, durationVec, "")) {
+		t.Error("expected pprof not to be served on the metrics port")
+	}
+}
+
+func TestRoutePrefix(t *testing.T) {
+	t.Parallel()
+
+	routePrefix := normalizeRoutePrefix("ksm/")
+	if routePrefix != "/ksm" {
+		t.Fatalf("want the route prefix /ksm, got %q", routePrefix)
+	}
+	if got := normalizeRoutePrefix("/"); got != "" {
+		t.Errorf("want the root for the route prefix /, got %q", got)
+	}
+
+	get := func(h http.Handler, path string) *http.Response {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080"+path, nil))
+		return w.Result()
+	}
+
+	durationVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "http_request_duration_seconds"}, []string{"method"})
+	metricsServer := buildMetricsServer(&metricshandler.MetricsHandler{}, durationVec, routePrefix)
+	if res := get(metricsServer, "/ksm/healthz"); res.StatusCode != http.StatusOK {
+		t.Errorf("want /ksm/healthz to be served, got status %d", res.StatusCode)
+	}
+	if res := get(metricsServer, "/healthz"); res.StatusCode != http.StatusNotFound {
+		t.Errorf("want /healthz not to be served outside of the route prefix, got status %d", res.StatusCode)
+	}
+	if res := get(metricsServer, "/ksm"); res.StatusCode != http.StatusMovedPermanently || res.Header.Get("Location") != "/ksm/" {
+		t.Errorf("want /ksm to redirect to the index, got status %d to %q", res.StatusCode, res.Header.Get("Location"))
+	}
+	index, _ := io.ReadAll(get(metricsServer, "/ksm/").Body)
+	if !strings.Contains(string(index), "href='/ksm/metrics'") {
+		t.Errorf("want the index to link to /ksm/metrics, got:\n%s", index)
+	}
+
+	telemetryServer := buildTelemetryServer(prometheus.NewRegistry(), true, routePrefix)
+	if res := get(telemetryServer, "/ksm/metrics"); res.StatusCode != http.StatusOK {
+		t.Errorf("want /ksm/metrics to be served on the telemetry port, got status %d", res.StatusCode)
+	}
+	if res := get(telemetryServer, "/ksm/debug/pprof/cmdline"); !strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain") {
+		t.Error("expected pprof to be served under the route prefix")
+	}
+}
+
+// TestCatalog verifies that the catalog lists the effective metric families of
+// the enabled resources without any objects or API clients.
+func TestCatalog(t *testing.T) {
+	builder := store.NewBuilder()
+	builder.WithMetrics(prometheus.NewRegistry())
+	if err := builder.WithEnabledResources([]string{"verticalpodautoscalers", "configmaps"}); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithGenerateStoresFunc(builder.DefaultGenerateStoresFunc(), false)
+	builder.WithAllowLabels(map[string][]string{})
+
+	l, err := allowdenylist.New(options.MetricSet{}, options.MetricSet{"kube_configmap_created": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithAllowDenyList(l)
+
+	handler := metricshandler.New(&options.Options{}, nil, builder, false)
+
+	w := httptest.NewRecorder()
+	handler.ServeCatalog(w, httptest.NewRequest("GET", "http://localhost:8080/catalog", nil))
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 status code but got %v", resp.StatusCode)
+	}
+
+	var catalog []struct {
+		Resource string `json:"resource"`
+		Families []struct {
+			Name string `json:"name"`
+			Help string `json:"help"`
+			Type string `json:"type"`
+		} `json:"families"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(catalog) != 2 || catalog[0].Resource != "configmaps" || catalog[1].Resource != "verticalpodautoscalers" {
+		t.Fatalf("expected the catalog of configmaps and verticalpodautoscalers, got %+v", catalog)
+	}
+	names := map[string]string{}
+	for _, r := range catalog {
+		for _, f := range r.Families {
+			names[f.Name] = f.Type
+		}
+	}
+	if _, ok := names["kube_configmap_created"]; ok {
+		t.Error("expected denylisted kube_configmap_created to be omitted from the catalog")
+	}
+	if typ := names["kube_verticalpodautoscaler_spec_updatepolicy_updatemode"]; typ != "gauge" {
+		t.Errorf("expected kube_verticalpodautoscaler_spec_updatepolicy_updatemode of type gauge in the catalog, got %q", typ)
+	}
+}
+
 func injectFixtures(client *fake.Clientset, multiplier int) error {
 	creators := []func(*fake.Clientset, int) error{
 		configMap,
@@ -668,3 +1203,482 @@ func pod(client *fake.Clientset, index int) error {
 	_, err := client.CoreV1().Pods(metav1.NamespaceDefault).Create(context.TODO(), &pod, metav1.CreateOptions{})
 	return err
 }
+
+func TestListenAddresses(t *testing.T) {
+	got := listenAddresses("0.0.0.0, ::", 8080)
+	want := []string{"0.0.0.0:8080", "[::]:8080"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want listen addresses %v, got %v", want, got)
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newBuildInfoCollector([]string{"verticalpodautoscalers", "pods"}))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error gathering metrics: %v", err)
+	}
+	if len(families) != 1 || families[0].GetName() != "kube_state_metrics_build_info" || len(families[0].GetMetric()) != 1 {
+		t.Fatalf("expected a single kube_state_metrics_build_info series, got %v", families)
+	}
+	m := families[0].GetMetric()[0]
+	if got := m.GetGauge().GetValue(); got != 1 {
+		t.Errorf("want value 1, got %v", got)
+	}
+	labels := map[string]string{}
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if got, want := labels["resources"], "pods,verticalpodautoscalers"; got != want {
+		t.Errorf("want resources label %q, got %q", want, got)
+	}
+	for _, name := range []string{"branch", "goversion", "revision", "version"} {
+		if _, ok := labels[name]; !ok {
+			t.Errorf("expected label %q, got %v", name, labels)
+		}
+	}
+}
+
+func TestCreateVPAClientConfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: core
+  cluster:
+    server: https://core.example.com
+- name: vpa
+  cluster:
+    server: https://vpa.example.com
+contexts:
+- name: core
+  context:
+    cluster: core
+- name: vpa
+  context:
+    cluster: vpa
+current-context: core
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		vpaKubeconfig string
+		vpaContext    string
+		want          string
+	}{
+		{vpaKubeconfig: kubeconfig, want: "https://core.example.com"},
+		{vpaContext: "vpa", want: "https://vpa.example.com"},
+		{vpaKubeconfig: kubeconfig, vpaContext: "vpa", want: "https://vpa.example.com"},
+	}
+
+	for _, test := range tests {
+		config, err := createVPAClientConfig(kubeconfig, test.vpaKubeconfig, test.vpaContext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Host != test.want {
+			t.Errorf("want host %q for kubeconfig %q and context %q, got %q", test.want, test.vpaKubeconfig, test.vpaContext, config.Host)
+		}
+	}
+}
+
+func TestCreateClientConfigsCABundle(t *testing.T) {
+	server, serverCA := newSelfSignedTLSServer(t)
+	defer server.Close()
+	other, otherCA := newSelfSignedTLSServer(t)
+	defer other.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, serverCA, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The core cluster trusts the CA of the other server already, the VPA
+	// cluster none.
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	err := os.WriteFile(kubeconfig, []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: core
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+- name: vpa
+  cluster:
+    server: %s
+contexts:
+- name: core
+  context:
+    cluster: core
+- name: vpa
+  context:
+    cluster: vpa
+current-context: core
+`, server.URL, base64.StdEncoding.EncodeToString(otherCA), server.URL)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(config *rest.Config, url string) error {
+		transport, err := rest.TransportFor(config)
+		if err != nil {
+			return err
+		}
+		resp, err := (&http.Client{Transport: transport}).Get(url)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	config, vpaConfig, err := createClientConfigs("", kubeconfig, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get(config, server.URL); err == nil {
+		t.Error("expected the server to be untrusted without the CA bundle")
+	}
+
+	config, vpaConfig, err = createClientConfigs("", kubeconfig, "", "vpa", caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, url := range map[string]string{"core": server.URL, "core with its own CA": other.URL} {
+		if err := get(config, url); err != nil {
+			t.Errorf("unexpected error of the %s client: %v", name, err)
+		}
+	}
+	if vpaConfig == config {
+		t.Fatal("expected a separate VerticalPodAutoscaler client configuration")
+	}
+	if err := get(vpaConfig, server.URL); err != nil {
+		t.Errorf("unexpected error of the VerticalPodAutoscaler client: %v", err)
+	}
+	// Without a CA of its own, the VerticalPodAutoscaler client keeps
+	// trusting the system roots, which CA data would replace.
+	if len(vpaConfig.CAData) > 0 || vpaConfig.CAFile != "" {
+		t.Error("expected the CA bundle of the VerticalPodAutoscaler client to be added to the system roots")
+	}
+
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := createClientConfigs("", kubeconfig, "", "", caFile); err == nil {
+		t.Error("expected an error for a CA bundle without certificates")
+	}
+}
+
+// newSelfSignedTLSServer starts a TLS server with a certificate of its own,
+// unlike httptest.NewTLSServer, whose servers share one. It returns the
+// server and its PEM encoded certificate.
+func newSelfSignedTLSServer(t *testing.T) (*httptest.Server, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kube-state-metrics test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	return server, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestValidateCatalog(t *testing.T) {
+	builder := store.NewBuilder()
+	if err := builder.WithEnabledResources(append(options.DefaultResources.AsSlice(), "verticalpodautoscalers")); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithGenerateStoresFunc(builder.DefaultGenerateStoresFunc(), false)
+	builder.WithAllowLabels(map[string][]string{"verticalpodautoscalers": {"app"}})
+	l, err := allowdenylist.New(options.MetricSet{}, options.MetricSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithAllowDenyList(l)
+
+	if err := validateCatalog(builder.Catalog()); err != nil {
+		t.Errorf("expected the default catalog to be valid, got %v", err)
+	}
+
+	family := *generator.NewFamilyGenerator("kube_test_info", "", metric.Gauge, "", func(obj interface{}) *metric.Family {
+		return &metric.Family{}
+	})
+	err = validateCatalog(map[string][]generator.FamilyGenerator{
+		"pods":     {family},
+		"services": {family},
+	})
+	if err == nil || !strings.Contains(err.Error(), "kube_test_info (pods, services)") {
+		t.Errorf("expected duplicate kube_test_info to be reported, got %v", err)
+	}
+}
+
+func TestFamilyOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "overrides.yaml")
+	config := `kube_verticalpodautoscaler_spec_updatepolicy_updatemode:
+  help: Update mode of the VPA.
+kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target:
+  unit: seconds
+kube_test_duration_seconds:
+  help: Duration of the test.
+  unit: seconds
+kube_unknown:
+  help: Not exposed.
+`
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := loadFamilyOverrides(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generate := func(obj interface{}) *metric.Family { return &metric.Family{} }
+	catalog := map[string][]generator.FamilyGenerator{
+		"verticalpodautoscalers": {
+			*generator.NewFamilyGenerator("kube_verticalpodautoscaler_spec_updatepolicy_updatemode", "Update mode of the VerticalPodAutoscaler.", metric.Gauge, "", generate),
+			*generator.NewFamilyGenerator("kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target", "Target resources the VerticalPodAutoscaler recommends for the container.", metric.Gauge, "", generate),
+		},
+		"tests": {
+			*generator.NewFamilyGenerator("kube_test_duration_seconds", "Duration.", metric.Gauge, "", generate),
+		},
+	}
+
+	got := checkFamilyOverrides(catalog, overrides)
+	want := map[string]generator.FamilyOverride{
+		"kube_verticalpodautoscaler_spec_updatepolicy_updatemode":                          {Help: "Update mode of the VPA."},
+		"kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target": {},
+		"kube_test_duration_seconds":                                                       {Help: "Duration of the test.", Unit: "seconds"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected overrides, want %v, got %v", want, got)
+	}
+
+	if err := os.WriteFile(path, []byte("kube_pod_info:\n  halp: typo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadFamilyOverrides(path); err == nil {
+		t.Error("expected an unknown field to be rejected")
+	}
+}
+
+func TestLegacyMetricAliases(t *testing.T) {
+	builder := store.NewBuilder()
+	builder.WithMetrics(prometheus.NewRegistry())
+	if err := builder.WithEnabledResources([]string{"horizontalpodautoscalers"}); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithGenerateStoresFunc(builder.DefaultGenerateStoresFunc(), false)
+	builder.WithAllowLabels(map[string][]string{})
+	l, err := allowdenylist.New(options.MetricSet{}, options.MetricSet{"kube_hpa_labels": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	builder.WithAllowDenyList(l)
+	builder.WithLegacyMetricAliases(true)
+
+	families := map[string]generator.FamilyGenerator{}
+	for _, f := range builder.Catalog()["horizontalpodautoscalers"] {
+		families[f.Name] = f
+	}
+	alias, ok := families["kube_hpa_spec_max_replicas"]
+	if !ok {
+		t.Fatal("expected kube_hpa_spec_max_replicas to be exposed as alias")
+	}
+	if want := "(Deprecated alias of kube_horizontalpodautoscaler_spec_max_replicas) " + families["kube_horizontalpodautoscaler_spec_max_replicas"].Help; alias.Help != want {
+		t.Errorf("want help %q, got %q", want, alias.Help)
+	}
+	if _, ok := families["kube_hpa_labels"]; ok {
+		t.Error("expected the denylist to apply to aliases")
+	}
+	if _, ok := families["kube_horizontalpodautoscaler_labels"]; !ok {
+		t.Error("expected kube_horizontalpodautoscaler_labels to be exposed")
+	}
+
+	// Aliases can be overridden like any other exposed family.
+	overrides := checkFamilyOverrides(builder.Catalog(), map[string]generator.FamilyOverride{
+		"kube_hpa_spec_max_replicas": {Help: "Upper limit of the replicas."},
+	})
+	if _, ok := overrides["kube_hpa_spec_max_replicas"]; !ok {
+		t.Fatal("expected the override of kube_hpa_spec_max_replicas to be kept")
+	}
+	builder.WithFamilyOverrides(overrides)
+	for _, f := range builder.Catalog()["horizontalpodautoscalers"] {
+		if f.Name == "kube_hpa_spec_max_replicas" && f.Help != "Upper limit of the replicas." {
+			t.Errorf("want the overridden help of kube_hpa_spec_max_replicas, got %q", f.Help)
+		}
+	}
+}
+
+func TestMetricsServerHTTP2(t *testing.T) {
+	opts := options.NewOptions()
+	opts.ServerReadTimeout = 10 * time.Second
+	opts.ServerReadHeaderTimeout = time.Second
+	opts.ServerWriteTimeout = 20 * time.Second
+	opts.ServerIdleTimeout = time.Minute
+	opts.ServerMaxHeaderBytes = 4096
+	opts.ServerHTTP2 = true
+
+	handler := serverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	}), opts)
+	server := newMetricsServer(handler, "127.0.0.1:0", opts)
+	if server.ReadTimeout != 10*time.Second || server.ReadHeaderTimeout != time.Second || server.WriteTimeout != 20*time.Second ||
+		server.IdleTimeout != time.Minute || server.MaxHeaderBytes != 4096 {
+		t.Errorf("expected the server to be configured by the options, got %+v", server)
+	}
+
+	s := httptest.NewUnstartedServer(server.Handler)
+	s.Config = server
+	s.Start()
+	defer s.Close()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "HTTP/2.0" {
+		t.Errorf("expected the request to be served over HTTP/2, got %s", body)
+	}
+
+	opts.TLSConfig = "web-config.yaml"
+	if _, ok := serverHandler(http.NotFoundHandler(), opts).(http.HandlerFunc); !ok {
+		t.Error("expected the handler to be kept as is with TLS")
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		fmt.Fprint(w, "drained")
+	}))
+	defer s.Close()
+
+	body := make(chan string)
+	go func() {
+		resp, err := http.Get(s.URL)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+
+	shutdown := &gracefulShutdown{period: 10 * time.Second}
+	done := make(chan struct{})
+	go func() {
+		shutdown.shutdown(s.Config)
+		close(done)
+	}()
+
+	// The server must not have stopped before the in-flight request finished.
+	select {
+	case <-done:
+		t.Fatal("expected the server to wait for the in-flight request")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if got := <-body; got != "drained" {
+		t.Errorf("expected the in-flight request to finish, got %q", got)
+	}
+	<-done
+
+	// The grace period is shared, a later shutdown does not extend it.
+	deadline := shutdown.deadline
+	shutdown.shutdown(&http.Server{})
+	if shutdown.deadline != deadline {
+		t.Errorf("expected the grace period to start with the first shutdown")
+	}
+}
+
+func TestUnixSocketServer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "metrics.sock")
+	// Leave a socket behind, as a killed container would.
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	var g run.Group
+	servers := &serverGroup{group: &g, shutdown: &gracefulShutdown{period: time.Second}}
+	servers.add("metrics server", &http.Server{Addr: socket, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "kube_pod_info 1")
+	})}, "unix")
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	var body string
+	g.Add(func() error {
+		var resp *http.Response
+		for i := 0; i < 50; i++ {
+			if resp, err = client.Get("http://localhost/metrics"); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		body = string(b)
+		return err
+	}, func(error) {})
+
+	if err := g.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if body != "kube_pod_info 1" {
+		t.Errorf("expected the metrics to be served over the socket, got %q", body)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("expected the socket to be removed on shutdown, got %v", err)
+	}
+}
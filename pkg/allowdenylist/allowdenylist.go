@@ -57,11 +57,29 @@ func New(allow, deny map[string]struct{}) (*AllowDenyList, error) {
 	}, nil
 }
 
-// Parse parses and compiles all of the regexes in the allowDenyList.
+// globRegex matches the items which are glob patterns rather than regexes,
+// i.e. metric names with at least one '*' or '?' wildcard.
+var globRegex = regexp.MustCompile(`^[a-zA-Z0-9_:*?]*[*?][a-zA-Z0-9_:*?]*$`)
+
+// globToRegex returns the anchored regex equivalent to the glob pattern,
+// where '*' matches any sequence of characters and '?' any single one.
+func globToRegex(glob string) string {
+	r := strings.NewReplacer("*", ".*", "?", ".")
+	return "^" + r.Replace(glob) + "$"
+}
+
+// Parse parses and compiles all of the regexes in the allowDenyList. Items
+// which only consist of metric name characters and the '*' and '?' wildcards
+// are glob patterns matching the whole name, e.g.
+// kube_verticalpodautoscaler_status_recommendation_*.
 func (l *AllowDenyList) Parse() error {
 	regexes := make([]*regexp.Regexp, 0, len(l.list))
 	for item := range l.list {
-		r, err := regexp.Compile(item)
+		expr := item
+		if globRegex.MatchString(item) {
+			expr = globToRegex(item)
+		}
+		r, err := regexp.Compile(expr)
 		if err != nil {
 			return err
 		}
@@ -143,6 +143,58 @@ func TestInclude(t *testing.T) {
 			t.Fatalf("expected included %s to be included", item4)
 		}
 	})
+	t.Run("removes metric families by name prefix when in denylist mode", func(t *testing.T) {
+		item1 := "kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound"
+		item2 := "kube_verticalpodautoscaler_status_recommendation_containerrecommendations_upperbound"
+		item3 := "kube_verticalpodautoscaler_spec_updatepolicy_updatemode"
+
+		denylist, err := New(map[string]struct{}{}, map[string]struct{}{})
+		if err != nil {
+			t.Fatal("expected New() to not fail")
+		}
+
+		denylist.Exclude([]string{"kube_verticalpodautoscaler_status_recommendation_.*"})
+		err = denylist.Parse()
+		if err != nil {
+			t.Fatalf("expected Parse() to not fail, but got error : %v", err)
+		}
+
+		if denylist.IsIncluded(item1) {
+			t.Fatalf("expected included %s to be excluded", item1)
+		}
+		if denylist.IsIncluded(item2) {
+			t.Fatalf("expected included %s to be excluded", item2)
+		}
+		if denylist.IsExcluded(item3) {
+			t.Fatalf("expected included %s to be included", item3)
+		}
+	})
+	t.Run("removes metric families by glob pattern when in denylist mode", func(t *testing.T) {
+		item1 := "kube_verticalpodautoscaler_status_recommendation_containerrecommendations_lowerbound"
+		item2 := "kube_verticalpodautoscaler_spec_updatepolicy_updatemode"
+		item3 := "kube_pod_verticalpodautoscaler_status_recommendation"
+
+		denylist, err := New(map[string]struct{}{}, map[string]struct{}{})
+		if err != nil {
+			t.Fatal("expected New() to not fail")
+		}
+
+		denylist.Exclude([]string{"kube_verticalpodautoscaler_status_recommendation_*", "*_updatemod?"})
+		err = denylist.Parse()
+		if err != nil {
+			t.Fatalf("expected Parse() to not fail, but got error : %v", err)
+		}
+
+		if denylist.IsIncluded(item1) {
+			t.Fatalf("expected included %s to be excluded", item1)
+		}
+		if denylist.IsIncluded(item2) {
+			t.Fatalf("expected included %s to be excluded", item2)
+		}
+		if denylist.IsExcluded(item3) {
+			t.Fatalf("expected included %s to be included", item3)
+		}
+	})
 }
 
 func TestExclude(t *testing.T) {
@@ -184,7 +236,7 @@ func TestExclude(t *testing.T) {
 
 func TestParse(t *testing.T) {
 	t.Run("fails when an unparseable regex is passed", func(t *testing.T) {
-		invalidItem := "*_pod_info"
+		invalidItem := "kube_(pod_info"
 		wb, err := New(map[string]struct{}{invalidItem: {}}, map[string]struct{}{})
 		if err != nil {
 			t.Fatalf("unexpected error while trying to init a allowDenyList: %v", wb)
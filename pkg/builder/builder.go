@@ -18,6 +18,7 @@ package builder
 
 import (
 	"context"
+	"time"
 
 	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
 
@@ -27,6 +28,7 @@ import (
 
 	internalstore "k8s.io/kube-state-metrics/v2/internal/store"
 	ksmtypes "k8s.io/kube-state-metrics/v2/pkg/builder/types"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
 	"k8s.io/kube-state-metrics/v2/pkg/options"
 )
 
@@ -59,11 +61,86 @@ func (b *Builder) WithNamespaces(n options.NamespaceList) {
 	b.internal.WithNamespaces(n)
 }
 
+// WithFieldSelectorFilter sets the fieldSelector property of a Builder.
+func (b *Builder) WithFieldSelectorFilter(fieldSelectorFilter string) {
+	b.internal.WithFieldSelectorFilter(fieldSelectorFilter)
+}
+
+// WithObjectLabelSelector sets the label selector of the list and watch requests of all resources.
+func (b *Builder) WithObjectLabelSelector(selector string) error {
+	return b.internal.WithObjectLabelSelector(selector)
+}
+
+// WithMetricPrefix sets the metricPrefix property of a Builder.
+func (b *Builder) WithMetricPrefix(prefix string) error {
+	return b.internal.WithMetricPrefix(prefix)
+}
+
+// WithFieldSelectors sets the field selectors of individual resources.
+func (b *Builder) WithFieldSelectors(selectors map[string]string) error {
+	return b.internal.WithFieldSelectors(selectors)
+}
+
+// WithObjectNames restricts individual resources to the object of the given name.
+func (b *Builder) WithObjectNames(names map[string]string) error {
+	return b.internal.WithObjectNames(names)
+}
+
+// WithUIDLabel sets whether all metrics carry a uid label.
+func (b *Builder) WithUIDLabel(enabled bool) {
+	b.internal.WithUIDLabel(enabled)
+}
+
+// WithResourceVersionLabel sets whether all metrics carry a resource_version label.
+func (b *Builder) WithResourceVersionLabel(enabled bool) {
+	b.internal.WithResourceVersionLabel(enabled)
+}
+
+// WithObjectSeriesCount sets whether the number of series of each object is reported.
+func (b *Builder) WithObjectSeriesCount(enabled bool) {
+	b.internal.WithObjectSeriesCount(enabled)
+}
+
+// WithFamilyOverrides sets the help texts and units overriding the ones of the metric families.
+func (b *Builder) WithFamilyOverrides(overrides map[string]generator.FamilyOverride) {
+	b.internal.WithFamilyOverrides(overrides)
+}
+
+// WithLabelRenames sets the labelRenames property of a Builder.
+func (b *Builder) WithLabelRenames(renames map[string]string) error {
+	return b.internal.WithLabelRenames(renames)
+}
+
+// WithMetricFilter sets the filter deciding which series of the metric families are exposed.
+func (b *Builder) WithMetricFilter(filter generator.MetricFilter) {
+	b.internal.WithMetricFilter(filter)
+}
+
+// WithDropZeroStateSet sets whether only the active series of state sets are exposed.
+func (b *Builder) WithDropZeroStateSet(enabled bool) {
+	b.internal.WithDropZeroStateSet(enabled)
+}
+
+// WithMaxLabelValueLength sets the maximum length of the values of labels converted from Kubernetes labels and annotations.
+func (b *Builder) WithMaxLabelValueLength(length int) error {
+	return b.internal.WithMaxLabelValueLength(length)
+}
+
+// WithRequestTimeout sets the requestTimeout property of a Builder.
+func (b *Builder) WithRequestTimeout(timeout time.Duration) {
+	b.internal.WithRequestTimeout(timeout)
+}
+
 // WithSharding sets the shard and totalShards property of a Builder.
 func (b *Builder) WithSharding(shard int32, totalShards int) {
 	b.internal.WithSharding(shard, totalShards)
 }
 
+// WithShardBy sets the key objects are assigned to shards by.
+func (b *Builder) WithShardBy(by string) error {
+	return b.internal.WithShardBy(by)
+}
+
 // WithContext sets the ctx property of a Builder.
 func (b *Builder) WithContext(ctx context.Context) {
 	b.internal.WithContext(ctx)
@@ -79,12 +156,137 @@ func (b *Builder) WithVPAClient(c vpaclientset.Interface) {
 	b.internal.WithVPAClient(c)
 }
 
+// WithVPAZeroMissingResources sets the vpaZeroMissingResources property of a Builder.
+func (b *Builder) WithVPAZeroMissingResources(enabled bool) {
+	b.internal.WithVPAZeroMissingResources(enabled)
+}
+
+// WithVPAPreciseCPU sets the vpaPreciseCPU property of a Builder.
+func (b *Builder) WithVPAPreciseCPU(enabled bool) {
+	b.internal.WithVPAPreciseCPU(enabled)
+}
+
+// WithVPAMemoryUnit sets the vpaMemoryUnit property of a Builder.
+func (b *Builder) WithVPAMemoryUnit(unit string) error {
+	return b.internal.WithVPAMemoryUnit(unit)
+}
+
+// WithMaxSeries sets the maximum number of series of a Builder.
+func (b *Builder) WithMaxSeries(max int) error {
+	return b.internal.WithMaxSeries(max)
+}
+
+// WithStoreQueueDepth sets the storeQueueDepth property of a Builder.
+func (b *Builder) WithStoreQueueDepth(depth int) error {
+	return b.internal.WithStoreQueueDepth(depth)
+}
+
+// WithClusterName sets the clusterName property of a Builder.
+func (b *Builder) WithClusterName(name string) {
+	b.internal.WithClusterName(name)
+}
+
+// WithVPAOmitEmptyDefaultLabels sets the vpaOmitEmptyLabels property of a Builder.
+func (b *Builder) WithVPAOmitEmptyDefaultLabels(enabled bool) {
+	b.internal.WithVPAOmitEmptyDefaultLabels(enabled)
+}
+
+// WithVPASkipOffModeRecommendations sets the vpaSkipOffModeRecs property of a Builder.
+func (b *Builder) WithVPASkipOffModeRecommendations(enabled bool) {
+	b.internal.WithVPASkipOffModeRecommendations(enabled)
+}
+
+// WithVPAHPAConflict sets whether conflicts of VerticalPodAutoscalers with HorizontalPodAutoscalers are exposed by a Builder.
+func (b *Builder) WithVPAHPAConflict(enabled bool) {
+	b.internal.WithVPAHPAConflict(enabled)
+}
+
+// WithVPATargetReplicas sets whether the replicas of the targets of VerticalPodAutoscalers are exposed by a Builder.
+func (b *Builder) WithVPATargetReplicas(enabled bool) {
+	b.internal.WithVPATargetReplicas(enabled)
+}
+
+// WithGenerationConcurrency sets the number of workers generating metrics of a Builder.
+func (b *Builder) WithGenerationConcurrency(workers int) error {
+	return b.internal.WithGenerationConcurrency(workers)
+}
+
+// WithLegacyMetricAliases sets the legacyMetricAliases property of a Builder.
+func (b *Builder) WithLegacyMetricAliases(enabled bool) {
+	b.internal.WithLegacyMetricAliases(enabled)
+}
+
+// WithVPAObservedContainersAnnotation sets the vpaObservedContainers property of a Builder.
+func (b *Builder) WithVPAObservedContainersAnnotation(annotation string) {
+	b.internal.WithVPAObservedContainersAnnotation(annotation)
+}
+
+// WithVPARecommenderAnnotation sets the vpaRecommenderAnnotation property of a Builder.
+func (b *Builder) WithVPARecommenderAnnotation(annotation string) {
+	b.internal.WithVPARecommenderAnnotation(annotation)
+}
+
+// WithVPARecommenderLabel sets the vpaRecommenderLabel property of a Builder.
+func (b *Builder) WithVPARecommenderLabel(annotation string) {
+	b.internal.WithVPARecommenderLabel(annotation)
+}
+
+// WithVPARecommendationBuckets sets the vpaRecommendationBuckets property of a Builder.
+func (b *Builder) WithVPARecommendationBuckets(cpuBuckets []float64) error {
+	return b.internal.WithVPARecommendationBuckets(cpuBuckets)
+}
+
+// WithVPARecommendationBounds sets the vpaRecommendationBounds property of a Builder.
+func (b *Builder) WithVPARecommendationBounds(bounds string) error {
+	return b.internal.WithVPARecommendationBounds(bounds)
+}
+
+// WithVPAOwnerReferences sets the vpaOwnerReferences property of a Builder.
+func (b *Builder) WithVPAOwnerReferences(enabled bool) {
+	b.internal.WithVPAOwnerReferences(enabled)
+}
+
+// WithVPAContainerDenylist configures the containers no recommendations are exposed for.
+func (b *Builder) WithVPAContainerDenylist(patterns []string) error {
+	return b.internal.WithVPAContainerDenylist(patterns)
+}
+
+// WithVPARecommendationSums sets the vpaRecommendationSums property of a Builder.
+func (b *Builder) WithVPARecommendationSums(enabled bool) {
+	b.internal.WithVPARecommendationSums(enabled)
+}
+
+// WithVPAUpdateModeCount sets the vpaUpdateModeCount property of a Builder.
+func (b *Builder) WithVPAUpdateModeCount(enabled bool) {
+	b.internal.WithVPAUpdateModeCount(enabled)
+}
+
+// WithVPARecommendationNativeHistogram sets the vpaNativeHistogram property of a Builder.
+func (b *Builder) WithVPARecommendationNativeHistogram(enabled bool) {
+	b.internal.WithVPARecommendationNativeHistogram(enabled)
+}
+
+// WithVPANamespaceCount sets the vpaNamespaceCount property of a Builder.
+func (b *Builder) WithVPANamespaceCount(enabled bool) {
+	b.internal.WithVPANamespaceCount(enabled)
+}
+
+// WithVPATargetKinds sets the vpaTargetKinds property of a Builder.
+func (b *Builder) WithVPATargetKinds(kinds []string) {
+	b.internal.WithVPATargetKinds(kinds)
+}
+
 // WithAllowDenyList configures the allow or denylisted metric to be exposed
 // by the store build by the Builder.
 func (b *Builder) WithAllowDenyList(l ksmtypes.AllowDenyLister) {
 	b.internal.WithAllowDenyList(l)
 }
 
+// WithDefaultLabels overrides the labels each metric of the given resources starts with.
+func (b *Builder) WithDefaultLabels(l map[string][]string) error {
+	return b.internal.WithDefaultLabels(l)
+}
+
 // WithAllowLabels configures which labels can be returned for metrics
 func (b *Builder) WithAllowLabels(l map[string][]string) {
 	b.internal.WithAllowLabels(l)
@@ -104,3 +306,13 @@ func (b *Builder) DefaultGenerateStoresFunc() ksmtypes.BuildStoresFunc {
 func (b *Builder) Build() []metricsstore.MetricsWriter {
 	return b.internal.Build()
 }
+
+// Catalog returns the metric families exposed for each enabled resource.
+func (b *Builder) Catalog() map[string][]generator.FamilyGenerator {
+	return b.internal.Catalog()
+}
+
+// ValidateLabelRenames checks that no label rename collides with another label of the metric families.
+func (b *Builder) ValidateLabelRenames() error {
+	return b.internal.ValidateLabelRenames()
+}
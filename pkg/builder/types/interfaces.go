@@ -18,6 +18,7 @@ package types
 
 import (
 	"context"
+	"time"
 
 	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
 
@@ -35,21 +36,63 @@ type BuilderInterface interface {
 	WithMetrics(r prometheus.Registerer)
 	WithEnabledResources(c []string) error
 	WithNamespaces(n options.NamespaceList)
+	WithFieldSelectorFilter(fieldSelectors string)
+	WithMetricPrefix(prefix string) error
+	WithFieldSelectors(selectors map[string]string) error
+	WithObjectNames(names map[string]string) error
+	WithObjectLabelSelector(selector string) error
+	WithRequestTimeout(timeout time.Duration)
+	WithUIDLabel(enabled bool)
+	WithResourceVersionLabel(enabled bool)
+	WithObjectSeriesCount(enabled bool)
+	WithFamilyOverrides(overrides map[string]generator.FamilyOverride)
+	WithMetricFilter(filter generator.MetricFilter)
+	WithLabelRenames(renames map[string]string) error
+	WithDropZeroStateSet(enabled bool)
+	WithMaxLabelValueLength(length int) error
 	WithSharding(shard int32, totalShards int)
+	WithShardBy(by string) error
 	WithContext(ctx context.Context)
 	WithKubeClient(c clientset.Interface)
 	WithVPAClient(c vpaclientset.Interface)
+	WithVPATargetKinds(kinds []string)
+	WithVPAZeroMissingResources(enabled bool)
+	WithVPAPreciseCPU(enabled bool)
+	WithVPAMemoryUnit(unit string) error
+	WithVPARecommenderAnnotation(annotation string)
+	WithVPAObservedContainersAnnotation(annotation string)
+	WithLegacyMetricAliases(enabled bool)
+	WithMaxSeries(max int) error
+	WithGenerationConcurrency(workers int) error
+	WithStoreQueueDepth(depth int) error
+	WithVPATargetReplicas(enabled bool)
+	WithVPAHPAConflict(enabled bool)
+	WithClusterName(name string)
+	WithVPAOmitEmptyDefaultLabels(enabled bool)
+	WithVPASkipOffModeRecommendations(enabled bool)
+	WithVPARecommenderLabel(annotation string)
+	WithVPARecommendationSums(enabled bool)
+	WithVPAUpdateModeCount(enabled bool)
+	WithVPANamespaceCount(enabled bool)
+	WithVPARecommendationBuckets(cpuBuckets []float64) error
+	WithVPARecommendationNativeHistogram(enabled bool)
+	WithVPARecommendationBounds(bounds string) error
+	WithVPAOwnerReferences(enabled bool)
+	WithVPAContainerDenylist(patterns []string) error
 	WithAllowDenyList(l AllowDenyLister)
 	WithAllowLabels(l map[string][]string)
+	WithDefaultLabels(l map[string][]string) error
 	WithGenerateStoresFunc(f BuildStoresFunc, useAPIServerCache bool)
 	DefaultGenerateStoresFunc() BuildStoresFunc
 	Build() []metricsstore.MetricsWriter
+	Catalog() map[string][]generator.FamilyGenerator
+	ValidateLabelRenames() error
 }
 
 // BuildStoresFunc function signature that is used to return a list of metricsstore.MetricsStore
 type BuildStoresFunc func(metricFamilies []generator.FamilyGenerator,
 	expectedType interface{},
-	listWatchFunc func(kubeClient clientset.Interface, ns string) cache.ListerWatcher,
+	listWatchFunc func(kubeClient clientset.Interface, ns string, fieldSelector string) cache.ListerWatcher,
 	useAPIServerCache bool,
 ) []*metricsstore.MetricsStore
 
@@ -26,4 +26,6 @@ const (
 	UnitCore ResourceUnit = "core"
 	// UnitInteger is the unit of measure in integers.
 	UnitInteger ResourceUnit = "integer"
+	// UnitMebibyte is the unit of measure in mebibytes.
+	UnitMebibyte ResourceUnit = "mebibyte"
 )
@@ -0,0 +1,293 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging routes the klog output of kube-state-metrics through a
+// structured logger with a configurable format and level.
+package logging
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// Supported log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Supported log levels.
+const (
+	LevelError = "error"
+	LevelWarn  = "warn"
+	LevelInfo  = "info"
+	LevelDebug = "debug"
+
+	levelFatal = "fatal"
+)
+
+// severities ranks the levels by severity.
+var severities = map[string]int{
+	LevelDebug: 0,
+	LevelInfo:  0,
+	LevelWarn:  1,
+	LevelError: 2,
+	levelFatal: 3,
+}
+
+// debugVerbosity is the klog verbosity enabled by the debug level.
+const debugVerbosity = 4
+
+// Setup validates the given format and level and configures klog
+// accordingly. The text format at info level leaves the klog output as is.
+func Setup(format, level string) error {
+	if format != FormatText && format != FormatJSON {
+		return errors.Errorf("unknown log format %q, must be one of %s, %s", format, FormatText, FormatJSON)
+	}
+	if _, ok := severities[level]; !ok || level == levelFatal {
+		return errors.Errorf("unknown log level %q, must be one of %s, %s, %s, %s", level, LevelError, LevelWarn, LevelInfo, LevelDebug)
+	}
+
+	if level == LevelDebug && !klog.V(debugVerbosity).Enabled() {
+		// klog filters verbose messages before handing them over to the
+		// logger, so its verbosity has to be raised as well.
+		fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+		klog.InitFlags(fs)
+		if err := fs.Set("v", strconv.Itoa(debugVerbosity)); err != nil {
+			return errors.Wrap(err, "failed to raise klog verbosity")
+		}
+	}
+	if format == FormatText && severities[level] == severities[LevelInfo] {
+		return nil
+	}
+
+	install(newSink(os.Stderr, format, level))
+	return nil
+}
+
+// installed is the sink klog writes to, if any. It is set once by Setup.
+var installed *sink
+
+func install(s *sink) {
+	installed = s
+	klog.SetLogger(logr.New(s))
+}
+
+// Warningf logs a warning. klog hands over its warnings to the logger as info
+// messages, so warnings of kube-state-metrics are logged through this instead
+// to keep their level.
+func Warningf(format string, args ...interface{}) {
+	if installed == nil {
+		klog.WarningDepth(1, fmt.Sprintf(format, args...))
+		return
+	}
+	if severities[LevelWarn] < severities[installed.level] {
+		return
+	}
+	installed.write(1, LevelWarn, fmt.Sprintf(format, args...), nil, nil)
+}
+
+// Fatalf logs a fatal error and exits with the exit code of klog.Fatalf,
+// which it behaves like without a structured logger.
+func Fatalf(format string, args ...interface{}) {
+	if installed == nil {
+		klog.FatalDepth(1, fmt.Sprintf(format, args...))
+		return
+	}
+	installed.write(1, levelFatal, fmt.Sprintf(format, args...), nil, nil)
+	klog.Flush()
+	os.Exit(255)
+}
+
+// sink is a logr.LogSink writing one line per message in the text or JSON
+// format.
+type sink struct {
+	mu        *sync.Mutex
+	w         io.Writer
+	format    string
+	level     string
+	name      string
+	values    []interface{}
+	callDepth int
+	now       func() time.Time
+}
+
+func newSink(w io.Writer, format, level string) *sink {
+	return &sink{
+		mu:     &sync.Mutex{},
+		w:      w,
+		format: format,
+		level:  level,
+		now:    time.Now,
+	}
+}
+
+// Init implements logr.LogSink.
+func (s *sink) Init(info logr.RuntimeInfo) {
+	s.callDepth += info.CallDepth
+}
+
+// Enabled implements logr.LogSink. Verbose messages are left to the klog
+// verbosity, unless only warnings and errors are logged.
+func (s *sink) Enabled(level int) bool {
+	return level == 0 || severities[s.level] == severities[LevelInfo]
+}
+
+// Info implements logr.LogSink. klog hands over its warnings and fatal errors
+// as info messages as well, see Warningf and Fatalf.
+func (s *sink) Info(level int, msg string, keysAndValues ...interface{}) {
+	if severities[LevelInfo] < severities[s.level] {
+		return
+	}
+	s.write(s.callDepth+1, LevelInfo, msg, nil, keysAndValues)
+}
+
+// Error implements logr.LogSink.
+func (s *sink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.write(s.callDepth+1, LevelError, msg, err, keysAndValues)
+}
+
+// WithValues implements logr.LogSink.
+func (s *sink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	c := *s
+	c.values = append(append([]interface{}{}, s.values...), keysAndValues...)
+	return &c
+}
+
+// WithName implements logr.LogSink.
+func (s *sink) WithName(name string) logr.LogSink {
+	c := *s
+	if c.name != "" {
+		name = c.name + "." + name
+	}
+	c.name = name
+	return &c
+}
+
+// WithCallDepth implements logr.CallDepthLogSink.
+func (s *sink) WithCallDepth(depth int) logr.LogSink {
+	c := *s
+	c.callDepth += depth
+	return &c
+}
+
+// write writes the message, attributed to the caller the given number of
+// frames above the caller of write.
+func (s *sink) write(depth int, level, msg string, err error, keysAndValues []interface{}) {
+	fields := []interface{}{
+		"ts", s.now().UTC().Format(time.RFC3339Nano),
+		"level", level,
+	}
+	if _, file, line, ok := runtime.Caller(depth + 1); ok {
+		fields = append(fields, "caller", fmt.Sprintf("%s:%d", filepath.Base(file), line))
+	}
+	if s.name != "" {
+		fields = append(fields, "logger", s.name)
+	}
+	// klog hands over unstructured messages with a trailing newline.
+	fields = append(fields, "msg", strings.TrimSuffix(msg, "\n"))
+	if err != nil {
+		fields = append(fields, "err", err.Error())
+	}
+	fields = append(fields, s.values...)
+	fields = append(fields, keysAndValues...)
+
+	var line []byte
+	if s.format == FormatJSON {
+		line = encodeJSON(fields)
+	} else {
+		line = encodeText(fields)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(line) //nolint:errcheck
+}
+
+// encodeJSON encodes the key value pairs as a JSON object on a single line.
+func encodeJSON(fields []interface{}) []byte {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(fmt.Sprint(fields[i]))
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(jsonValue(fieldValue(fields, i)))
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+func jsonValue(v interface{}) []byte {
+	switch v := v.(type) {
+	case error:
+		s, _ := json.Marshal(v.Error())
+		return s
+	case fmt.Stringer:
+		s, _ := json.Marshal(v.String())
+		return s
+	}
+	s, err := json.Marshal(v)
+	if err != nil {
+		s, _ = json.Marshal(fmt.Sprintf("%+v", v))
+	}
+	return s
+}
+
+// encodeText encodes the key value pairs in the logfmt style on a single
+// line, quoting values when needed.
+func encodeText(fields []interface{}) []byte {
+	var b strings.Builder
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(fmt.Sprint(fields[i]))
+		b.WriteByte('=')
+		s := fmt.Sprint(fieldValue(fields, i))
+		if s == "" || strings.ContainsAny(s, " \"=\n\t") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+// fieldValue returns the value of the key at index i, which is missing if an
+// odd number of key value pairs was passed.
+func fieldValue(fields []interface{}, i int) interface{} {
+	if i+1 < len(fields) {
+		return fields[i+1]
+	}
+	return "(MISSING)"
+}
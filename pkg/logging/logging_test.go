@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+func TestSetupValidation(t *testing.T) {
+	tests := []struct {
+		format, level string
+		wantErr       bool
+	}{
+		{format: FormatText, level: LevelInfo},
+		{format: "yaml", level: LevelInfo, wantErr: true},
+		{format: FormatJSON, level: "trace", wantErr: true},
+		{format: FormatJSON, level: levelFatal, wantErr: true},
+	}
+
+	for _, test := range tests {
+		if err := Setup(test.format, test.level); (err != nil) != test.wantErr {
+			t.Errorf("unexpected error for format %q and level %q: %v", test.format, test.level, err)
+		}
+	}
+}
+
+func TestSink(t *testing.T) {
+	defer func() {
+		klog.ClearLogger()
+		installed = nil
+	}()
+
+	tests := []struct {
+		format, level string
+		want          []string
+	}{
+		{
+			format: FormatJSON,
+			level:  LevelInfo,
+			want: []string{
+				`{"ts":"2021-10-14T12:00:00Z","level":"info","caller":"logging_test.go:LINE","msg":"watching pods"}`,
+				`{"ts":"2021-10-14T12:00:00Z","level":"warn","caller":"logging_test.go:LINE","msg":"slow list"}`,
+				`{"ts":"2021-10-14T12:00:00Z","level":"error","caller":"logging_test.go:LINE","msg":"List and watch request failed","err":"forbidden","resource":"verticalpodautoscalers","namespace":"kube-system"}`,
+			},
+		},
+		{
+			format: FormatText,
+			level:  LevelWarn,
+			want: []string{
+				`ts=2021-10-14T12:00:00Z level=warn caller=logging_test.go:LINE msg="slow list"`,
+				`ts=2021-10-14T12:00:00Z level=error caller=logging_test.go:LINE msg="List and watch request failed" err=forbidden resource=verticalpodautoscalers namespace=kube-system`,
+			},
+		},
+		{
+			format: FormatJSON,
+			level:  LevelError,
+			want: []string{
+				`{"ts":"2021-10-14T12:00:00Z","level":"error","caller":"logging_test.go:LINE","msg":"List and watch request failed","err":"forbidden","resource":"verticalpodautoscalers","namespace":"kube-system"}`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		s := newSink(&buf, test.format, test.level)
+		s.now = func() time.Time { return time.Date(2021, 10, 14, 12, 0, 0, 0, time.UTC) }
+		install(s)
+
+		klog.Info("watching pods")
+		Warningf("slow %s", "list")
+		klog.ErrorS(errors.New("forbidden"), "List and watch request failed", "resource", "verticalpodautoscalers", "namespace", "kube-system")
+
+		got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if len(got) != len(test.want) {
+			t.Fatalf("expected %d lines with format %q and level %q, got:\n%s", len(test.want), test.format, test.level, buf.String())
+		}
+		for i, line := range got {
+			if test.format == FormatJSON && !json.Valid([]byte(line)) {
+				t.Errorf("invalid JSON line: %s", line)
+			}
+			// The line numbers of the callers are not worth pinning down.
+			if want := test.want[i]; !matchesIgnoringLine(line, want) {
+				t.Errorf("unexpected line with format %q and level %q:\nwant: %s\ngot:  %s", test.format, test.level, want, line)
+			}
+		}
+	}
+}
+
+func matchesIgnoringLine(line, want string) bool {
+	i := strings.Index(want, "LINE")
+	if i < 0 || !strings.HasPrefix(line, want[:i]) {
+		return line == want
+	}
+	rest := strings.TrimLeft(line[i:], "0123456789")
+	return len(rest) < len(line[i:]) && rest == want[i+len("LINE"):]
+}
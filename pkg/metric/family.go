@@ -17,13 +17,26 @@ limitations under the License.
 package metric
 
 import (
+	"bytes"
+	"io"
 	"strings"
+	"sync"
 )
 
+// familyBufPool holds the buffers families are serialized into before being
+// written to writers which cannot be written to byte by byte, so they can be
+// reused instead of growing a new buffer for every family.
+var familyBufPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
 // FamilyInterface interface for a family
 type FamilyInterface interface {
 	Inspect(inspect func(Family))
 	ByteSlice() []byte
+	io.WriterTo
 }
 
 // Family represents a set of metrics with the same name and help text.
@@ -40,11 +53,38 @@ func (f Family) Inspect(inspect func(Family)) {
 
 // ByteSlice returns the given Family in its string representation.
 func (f Family) ByteSlice() []byte {
-	b := strings.Builder{}
-	for _, m := range f.Metrics {
-		b.WriteString(f.Name)
-		m.Write(&b)
+	var b bytes.Buffer
+	f.write(&b)
+	return b.Bytes()
+}
+
+// WriteTo writes the given Family in its string representation to w. A
+// bytes.Buffer or strings.Builder is written to directly, any other writer at
+// once from a pooled buffer.
+func (f Family) WriteTo(w io.Writer) (int64, error) {
+	switch b := w.(type) {
+	case *bytes.Buffer:
+		n := b.Len()
+		f.write(b)
+		return int64(b.Len() - n), nil
+	case *strings.Builder:
+		n := b.Len()
+		f.write(b)
+		return int64(b.Len() - n), nil
 	}
 
-	return []byte(b.String())
+	b := familyBufPool.Get().(*bytes.Buffer)
+	b.Reset()
+	defer familyBufPool.Put(b)
+
+	f.write(b)
+	return b.WriteTo(w)
+}
+
+func (f Family) write(w writer) {
+	for _, m := range f.Metrics {
+		w.WriteString(f.Name)
+		w.WriteString(m.NameSuffix)
+		m.write(w)
+	}
 }
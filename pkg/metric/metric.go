@@ -18,6 +18,7 @@ package metric
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"strconv"
 	"strings"
@@ -37,6 +38,13 @@ var (
 	}
 )
 
+// writer is implemented by both strings.Builder and bytes.Buffer.
+type writer interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
 // Type represents the type of a metric e.g. a counter. See
 // https://prometheus.io/docs/concepts/metric_types/.
 type Type string
@@ -47,15 +55,91 @@ var Gauge Type = "gauge"
 // Counter defines a Prometheus counter.
 var Counter Type = "counter"
 
+// Histogram defines a Prometheus histogram, whose metrics are generated by
+// HistogramMetrics.
+var Histogram Type = "histogram"
+
 // Metric represents a single time series.
 type Metric struct {
 	// The name of a metric is injected by its family to reduce duplication.
 	LabelKeys   []string
 	LabelValues []string
 	Value       float64
+	// NameSuffix is appended to the name of the family, e.g. _bucket for the
+	// buckets of a histogram.
+	NameSuffix string
+}
+
+// StateSet returns one metric per given value with the given label key, set
+// to 1 for the active value and 0 for all others. All values are returned even
+// if none of them is active.
+func StateSet(labelKey string, values []string, active string) []*Metric {
+	ms := make([]*Metric, len(values))
+
+	for i, v := range values {
+		var value float64
+		if v == active {
+			value = 1
+		}
+		ms[i] = &Metric{
+			LabelKeys:   []string{labelKey},
+			LabelValues: []string{v},
+			Value:       value,
+		}
+	}
+
+	return ms
+}
+
+// ActiveStates returns the metrics of a state set whose value is not 0, i.e.
+// only the active value. Consumers can no longer rely on all values being
+// present.
+func ActiveStates(ms []*Metric) []*Metric {
+	active := make([]*Metric, 0, 1)
+	for _, m := range ms {
+		if m.Value != 0 {
+			active = append(active, m)
+		}
+	}
+	return active
+}
+
+// HistogramMetrics returns the bucket, sum and count metrics of a histogram
+// of the observations. The buckets are the sorted upper bounds of the
+// cumulative buckets, the +Inf bucket is added to them.
+func HistogramMetrics(buckets []float64, observations []float64) []*Metric {
+	ms := make([]*Metric, 0, len(buckets)+3)
+	counts := make([]float64, len(buckets))
+	var sum float64
+	for _, o := range observations {
+		sum += o
+		for i, upperBound := range buckets {
+			if o <= upperBound {
+				counts[i]++
+			}
+		}
+	}
+	for i, upperBound := range buckets {
+		ms = append(ms, &Metric{
+			LabelKeys:   []string{"le"},
+			LabelValues: []string{strconv.FormatFloat(upperBound, 'g', -1, 64)},
+			Value:       counts[i],
+			NameSuffix:  "_bucket",
+		})
+	}
+	count := float64(len(observations))
+	return append(ms,
+		&Metric{LabelKeys: []string{"le"}, LabelValues: []string{"+Inf"}, Value: count, NameSuffix: "_bucket"},
+		&Metric{Value: sum, NameSuffix: "_sum"},
+		&Metric{Value: count, NameSuffix: "_count"},
+	)
 }
 
 func (m *Metric) Write(s *strings.Builder) {
+	m.write(s)
+}
+
+func (m *Metric) write(s writer) {
 	if len(m.LabelKeys) != len(m.LabelValues) {
 		panic(fmt.Sprintf(
 			"expected labelKeys %q to be of same length as labelValues %q",
@@ -69,7 +153,7 @@ func (m *Metric) Write(s *strings.Builder) {
 	s.WriteByte('\n')
 }
 
-func labelsToString(m *strings.Builder, keys, values []string) {
+func labelsToString(m writer, keys, values []string) {
 	if len(keys) > 0 {
 		var separator byte = '{'
 
@@ -93,7 +177,7 @@ var (
 // escapeString replaces '\' by '\\', new line character by '\n', and '"' by
 // '\"'.
 // Taken from github.com/prometheus/common/expfmt/text_create.go.
-func escapeString(m *strings.Builder, v string) {
+func escapeString(m writer, v string) {
 	escapeWithDoubleQuote.WriteString(m, v)
 }
 
@@ -101,7 +185,7 @@ func escapeString(m *strings.Builder, v string) {
 // a few common cases for increased efficiency. For non-hardcoded cases, it uses
 // strconv.AppendFloat to avoid allocations, similar to writeInt.
 // Taken from github.com/prometheus/common/expfmt/text_create.go.
-func writeFloat(w *strings.Builder, f float64) {
+func writeFloat(w writer, f float64) {
 	switch {
 	case f == 1:
 		w.WriteByte('1')
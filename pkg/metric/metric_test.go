@@ -17,6 +17,8 @@ limitations under the License.
 package metric
 
 import (
+	"io"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -41,6 +43,43 @@ func TestFamilyString(t *testing.T) {
 	}
 }
 
+func TestStateSet(t *testing.T) {
+	f := Family{
+		Name:    "kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+		Metrics: StateSet("update_mode", []string{"Off", "Initial", "Auto"}, "Initial"),
+	}
+
+	expected := `kube_verticalpodautoscaler_spec_updatepolicy_updatemode{update_mode="Off"} 0
+kube_verticalpodautoscaler_spec_updatepolicy_updatemode{update_mode="Initial"} 1
+kube_verticalpodautoscaler_spec_updatepolicy_updatemode{update_mode="Auto"} 0`
+	got := strings.TrimSpace(string(f.ByteSlice()))
+
+	if got != expected {
+		t.Fatalf("expected %v but got %v", expected, got)
+	}
+
+	ms := StateSet("update_mode", []string{"Off", "Auto"}, "Unknown")
+	if len(ms) != 2 {
+		t.Fatalf("expected all values to be present, got %d metrics", len(ms))
+	}
+	for _, m := range ms {
+		if m.Value != 0 {
+			t.Fatalf("expected %v to be inactive", m.LabelValues)
+		}
+	}
+}
+
+func TestActiveStates(t *testing.T) {
+	ms := ActiveStates(StateSet("update_mode", []string{"Off", "Initial", "Auto"}, "Initial"))
+	if len(ms) != 1 || ms[0].LabelValues[0] != "Initial" {
+		t.Fatalf("expected only the active value to be present, got %v", ms)
+	}
+
+	if ms := ActiveStates(StateSet("update_mode", []string{"Off", "Auto"}, "Unknown")); len(ms) != 0 {
+		t.Fatalf("expected no value to be present, got %d metrics", len(ms))
+	}
+}
+
 func BenchmarkMetricWrite(b *testing.B) {
 	tests := []struct {
 		testName       string
@@ -86,3 +125,88 @@ func BenchmarkMetricWrite(b *testing.B) {
 		})
 	}
 }
+
+func benchmarkFamily() Family {
+	f := Family{
+		Name: "kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target",
+	}
+	for i := 0; i < 20; i++ {
+		f.Metrics = append(f.Metrics, &Metric{
+			LabelKeys:   []string{"namespace", "verticalpodautoscaler", "target_api_version", "target_kind", "target_name", "container", "resource", "unit"},
+			LabelValues: []string{"ns1", "vpa1", "apps/v1", "Deployment", "deployment1", "container" + strconv.Itoa(i), "memory", "byte"},
+			Value:       8.589934592e+09,
+		})
+	}
+	return f
+}
+
+func BenchmarkFamilyByteSlice(b *testing.B) {
+	f := benchmarkFamily()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if len(f.ByteSlice()) == 0 {
+			b.Fatal("expected family to be serialized")
+		}
+	}
+}
+
+func BenchmarkFamilyWriteTo(b *testing.B) {
+	f := benchmarkFamily()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if n, _ := f.WriteTo(io.Discard); n == 0 {
+			b.Fatal("expected family to be serialized")
+		}
+	}
+}
+
+func TestFamilyWriteTo(t *testing.T) {
+	f := benchmarkFamily()
+	want := string(f.ByteSlice())
+
+	var b strings.Builder
+	b.WriteString("# TYPE\n")
+	if n, err := f.WriteTo(&b); err != nil || n != int64(len(want)) {
+		t.Fatalf("expected %d bytes to be written, got %d: %v", len(want), n, err)
+	}
+	if got := b.String(); got != "# TYPE\n"+want {
+		t.Fatalf("expected the family to be appended, got:\n%s", got)
+	}
+
+	var out onlyWriter
+	if n, err := f.WriteTo(&out); err != nil || n != int64(len(want)) || string(out) != want {
+		t.Fatalf("expected %d bytes to be written at once, got %d: %v", len(want), n, err)
+	}
+}
+
+// onlyWriter is an io.Writer which is not written to directly by
+// Family.WriteTo.
+type onlyWriter []byte
+
+func (w *onlyWriter) Write(p []byte) (int, error) {
+	*w = append(*w, p...)
+	return len(p), nil
+}
+
+func TestHistogramMetrics(t *testing.T) {
+	f := Family{
+		Name:    "kube_verticalpodautoscaler_status_recommendation_target_cpu_cores",
+		Metrics: HistogramMetrics([]float64{0.25, 1}, []float64{0.1, 0.25, 0.5, 2}),
+	}
+
+	expected := `kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="0.25"} 2
+kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="1"} 3
+kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="+Inf"} 4
+kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum 2.85
+kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_count 4
+`
+	if got := string(f.ByteSlice()); got != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, got)
+	}
+}
@@ -18,11 +18,17 @@ package generator
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"k8s.io/kube-state-metrics/v2/pkg/metric"
 )
 
+// DefaultMetricPrefix is the prefix all metric family names are defined with.
+const DefaultMetricPrefix = "kube_"
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 // FamilyGenerator provides everything needed to generate a metric family with a
 // Kubernetes object.
 // DeprecatedVersion is defined only if the metric for which this options applies is,
@@ -31,10 +37,18 @@ type FamilyGenerator struct {
 	Name              string
 	Help              string
 	Type              metric.Type
+	Unit              string
 	DeprecatedVersion string
 	GenerateFunc      func(obj interface{}) *metric.Family
 }
 
+// FamilyOverride overrides the help text and unit of a metric family. The
+// unit is only exposed in the OpenMetrics text format.
+type FamilyOverride struct {
+	Help string `json:"help,omitempty"`
+	Unit string `json:"unit,omitempty"`
+}
+
 // NewFamilyGenerator creates new FamilyGenerator instances.
 func NewFamilyGenerator(name string, help string, metricType metric.Type, deprecatedVersion string, generateFunc func(obj interface{}) *metric.Family) *FamilyGenerator {
 	f := &FamilyGenerator{
@@ -44,12 +58,17 @@ func NewFamilyGenerator(name string, help string, metricType metric.Type, deprec
 		DeprecatedVersion: deprecatedVersion,
 		GenerateFunc:      generateFunc,
 	}
-	if deprecatedVersion != "" {
-		f.Help = fmt.Sprintf("(Deprecated since %s) %s", deprecatedVersion, help)
-	}
+	f.setHelp(help)
 	return f
 }
 
+func (g *FamilyGenerator) setHelp(help string) {
+	g.Help = help
+	if g.DeprecatedVersion != "" {
+		g.Help = fmt.Sprintf("(Deprecated since %s) %s", g.DeprecatedVersion, help)
+	}
+}
+
 // Generate calls the FamilyGenerator.GenerateFunc and gives the family its
 // name. The reasoning behind injecting the name at such a late point in time is
 // deduplication in the code, preventing typos made by developers as
@@ -72,6 +91,12 @@ func (g *FamilyGenerator) generateHeader() string {
 	header.WriteString(g.Name)
 	header.WriteByte(' ')
 	header.WriteString(string(g.Type))
+	if g.Unit != "" {
+		header.WriteString("\n# UNIT ")
+		header.WriteString(g.Name)
+		header.WriteByte(' ')
+		header.WriteString(g.Unit)
+	}
 
 	return header.String()
 }
@@ -102,6 +127,221 @@ func ComposeMetricGenFuncs(familyGens []FamilyGenerator) func(obj interface{}) [
 	}
 }
 
+// PrefixMetricFamilies takes a prefix and a slice of metric families and
+// returns a slice with the DefaultMetricPrefix of each family name replaced
+// by the given prefix.
+func PrefixMetricFamilies(prefix string, families []FamilyGenerator) []FamilyGenerator {
+	if prefix == DefaultMetricPrefix {
+		return families
+	}
+
+	prefixed := make([]FamilyGenerator, len(families))
+
+	for i, f := range families {
+		f.Name = prefix + strings.TrimPrefix(f.Name, DefaultMetricPrefix)
+		prefixed[i] = f
+	}
+
+	return prefixed
+}
+
+// AliasMetricFamilies takes a map of former names by metric family name and a
+// slice of metric families and returns a slice in which each renamed family
+// is followed by a copy exposing the same values under its former name.
+func AliasMetricFamilies(aliases map[string]string, families []FamilyGenerator) []FamilyGenerator {
+	if len(aliases) == 0 {
+		return families
+	}
+
+	aliased := make([]FamilyGenerator, 0, len(families))
+
+	for _, f := range families {
+		aliased = append(aliased, f)
+		if alias, ok := aliases[f.Name]; ok {
+			a := f
+			a.Name = alias
+			a.Help = fmt.Sprintf("(Deprecated alias of %s) %s", f.Name, f.Help)
+			aliased = append(aliased, a)
+		}
+	}
+
+	return aliased
+}
+
+// OverrideMetricFamilies takes a map of overrides by metric family name and a
+// slice of metric families and returns a slice with the help text and unit of
+// the matching families overridden.
+func OverrideMetricFamilies(overrides map[string]FamilyOverride, families []FamilyGenerator) []FamilyGenerator {
+	if len(overrides) == 0 {
+		return families
+	}
+
+	overridden := make([]FamilyGenerator, len(families))
+
+	for i, f := range families {
+		if o, ok := overrides[f.Name]; ok {
+			if o.Help != "" {
+				f.setHelp(o.Help)
+			}
+			if o.Unit != "" {
+				f.Unit = o.Unit
+			}
+		}
+		overridden[i] = f
+	}
+
+	return overridden
+}
+
+// ValidateFamilyOverride checks whether the override can be applied to the
+// given metric family. OpenMetrics requires the name of a family with a unit
+// to end with the unit.
+func ValidateFamilyOverride(f FamilyGenerator, o FamilyOverride) error {
+	if o.Unit == "" {
+		return nil
+	}
+	name := f.Name
+	if f.Type == metric.Counter {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	if !strings.HasSuffix(name, "_"+o.Unit) {
+		return fmt.Errorf("unit %q is not a suffix of the metric family name %s", o.Unit, f.Name)
+	}
+	return nil
+}
+
+// RenameLabels takes a map of new label keys by the keys they replace and a
+// slice of metric families and returns a slice with the label keys of their
+// series renamed. Series whose renamed keys would collide, e.g. with a label
+// of the same key which is not renamed, are left untouched. Such collisions
+// are rejected at startup by ValidateRenamedLabelKeys for all keys known
+// upfront, which leaves the ones of labels generated from annotations and
+// labels of the objects.
+func RenameLabels(renames map[string]string, families []FamilyGenerator) []FamilyGenerator {
+	if len(renames) == 0 {
+		return families
+	}
+
+	renamed := make([]FamilyGenerator, len(families))
+
+	for i, f := range families {
+		generate := f.GenerateFunc
+		f.GenerateFunc = func(obj interface{}) *metric.Family {
+			family := generate(obj)
+			for _, m := range family.Metrics {
+				if keys, ok := renameLabelKeys(renames, m.LabelKeys); ok {
+					m.LabelKeys = keys
+				}
+			}
+			return family
+		}
+		renamed[i] = f
+	}
+
+	return renamed
+}
+
+// renameLabelKeys returns a copy of the label keys with the given ones
+// renamed, as the keys may be shared between series. It returns false if no
+// key was renamed or the renamed keys collide.
+func renameLabelKeys(renames map[string]string, keys []string) ([]string, bool) {
+	var renamed []string
+	for i, k := range keys {
+		if n, ok := renames[k]; ok {
+			if renamed == nil {
+				renamed = append([]string(nil), keys...)
+			}
+			renamed[i] = n
+		}
+	}
+	if renamed == nil {
+		return nil, false
+	}
+	seen := make(map[string]struct{}, len(renamed))
+	for _, k := range renamed {
+		if _, ok := seen[k]; ok {
+			return nil, false
+		}
+		seen[k] = struct{}{}
+	}
+	return renamed, true
+}
+
+// ValidateLabelRenames checks whether the label keys can be renamed, i.e.
+// whether all keys are valid label names and no two keys are renamed to the
+// same one.
+func ValidateLabelRenames(renames map[string]string) error {
+	renamedFrom := make(map[string]string, len(renames))
+	for from, to := range renames {
+		for _, name := range []string{from, to} {
+			if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+				return fmt.Errorf("%q is not a valid label name", name)
+			}
+		}
+		if other, ok := renamedFrom[to]; ok {
+			if other > from {
+				other, from = from, other
+			}
+			return fmt.Errorf("labels %s and %s are both renamed to %s", other, from, to)
+		}
+		renamedFrom[to] = from
+	}
+	return nil
+}
+
+// ValidateRenamedLabelKeys checks whether the label keys of a series of the
+// metric family of the given name can be renamed, i.e. whether none is
+// renamed to the key of another one.
+func ValidateRenamedLabelKeys(renames map[string]string, name string, keys []string) error {
+	renamed := make(map[string]string, len(keys))
+	for _, k := range keys {
+		to := k
+		if n, ok := renames[k]; ok {
+			to = n
+		}
+		if from, ok := renamed[to]; ok {
+			if from == to {
+				from, k = k, from
+			}
+			return fmt.Errorf("metric family %s: label %s is renamed to %s, which the family has already", name, from, to)
+		}
+		renamed[to] = k
+	}
+	return nil
+}
+
+// MetricFilter tells whether a series of the metric family with the given
+// name is exposed.
+type MetricFilter func(name string, m *metric.Metric) bool
+
+// FilterMetrics takes a filter and a slice of metric families and returns a
+// slice with the series rejected by the filter dropped from the families.
+func FilterMetrics(filter MetricFilter, families []FamilyGenerator) []FamilyGenerator {
+	if filter == nil {
+		return families
+	}
+
+	filtered := make([]FamilyGenerator, len(families))
+
+	for i, f := range families {
+		name, generate := f.Name, f.GenerateFunc
+		f.GenerateFunc = func(obj interface{}) *metric.Family {
+			family := generate(obj)
+			metrics := make([]*metric.Metric, 0, len(family.Metrics))
+			for _, m := range family.Metrics {
+				if filter(name, m) {
+					metrics = append(metrics, m)
+				}
+			}
+			family.Metrics = metrics
+			return family
+		}
+		filtered[i] = f
+	}
+
+	return filtered
+}
+
 type allowDenyLister interface {
 	IsIncluded(string) bool
 	IsExcluded(string) bool
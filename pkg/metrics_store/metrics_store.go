@@ -17,7 +17,9 @@ limitations under the License.
 package metricsstore
 
 import (
+	"bytes"
 	"io"
+	"strings"
 	"sync"
 
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -26,6 +28,14 @@ import (
 	"k8s.io/kube-state-metrics/v2/pkg/metric"
 )
 
+// generateBufPool holds the buffers the metric families of an object are
+// serialized into, so they can be reused across objects.
+var generateBufPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
 // MetricsStore implements the k8s.io/client-go/tools/cache.Store
 // interface. Instead of storing entire Kubernetes objects, it stores metrics
 // generated based on those objects.
@@ -41,6 +51,19 @@ type MetricsStore struct {
 	// later on zipped with with their corresponding metric families in
 	// MetricStore.WriteAll().
 	headers []string
+	// openMetricsHeaders contains the headers converted to the OpenMetrics
+	// text format, used by MetricsStore.WriteAllOpenMetrics().
+	openMetricsHeaders []string
+	// synced is set once the store was first populated through Replace,
+	// i.e. after the initial list of its reflector.
+	synced bool
+	// budget limits the number of series of the store, if set. series holds
+	// the number of series taken from it by each object.
+	budget *SeriesBudget
+	series map[types.UID]int
+	// generationConcurrency is the number of workers generating the metrics
+	// of the objects passed to Replace.
+	generationConcurrency int
 
 	// generateMetricsFunc generates metrics based on a given Kubernetes object
 	// and returns them grouped by metric family.
@@ -49,36 +72,122 @@ type MetricsStore struct {
 
 // NewMetricsStore returns a new MetricsStore
 func NewMetricsStore(headers []string, generateFunc func(interface{}) []metric.FamilyInterface) *MetricsStore {
+	prometheusHeaders := make([]string, len(headers))
+	openMetricsHeaders := make([]string, len(headers))
+	for i, header := range headers {
+		prometheusHeaders[i] = prometheusHeader(header)
+		openMetricsHeaders[i] = openMetricsHeader(header)
+	}
+
 	return &MetricsStore{
 		generateMetricsFunc: generateFunc,
-		headers:             headers,
+		headers:             prometheusHeaders,
+		openMetricsHeaders:  openMetricsHeaders,
 		metrics:             map[types.UID][][]byte{},
 	}
 }
 
+// generatedObject holds the metric families generated for an object, in
+// their string representation, and the number of series of each family.
+type generatedObject struct {
+	uid      types.UID
+	families [][]byte
+	series   []int
+}
+
+// WithSeriesBudget limits the number of series of the store to the given
+// budget, which may be shared with other stores.
+func (s *MetricsStore) WithSeriesBudget(budget *SeriesBudget) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.budget = budget
+	s.series = map[types.UID]int{}
+}
+
+// WithGenerationConcurrency sets the number of workers generating the metrics
+// of the objects passed to Replace, i.e. of the initial list and relists of
+// the reflector. With more than one worker, the metrics are generated before
+// the store is locked, so scrapes are not blocked by their generation.
+func (s *MetricsStore) WithGenerationConcurrency(workers int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.generationConcurrency = workers
+}
+
 // Implementing k8s.io/client-go/tools/cache.Store interface
 
 // Add inserts adds to the MetricsStore by calling the metrics generator functions and
 // adding the generated metrics to the metrics map that underlies the MetricStore.
 func (s *MetricsStore) Add(obj interface{}) error {
-	o, err := meta.Accessor(obj)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	g, err := s.generate(obj)
 	if err != nil {
 		return err
 	}
+	s.set(g)
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	return nil
+}
+
+// generate generates the metric families of the object. It does not access
+// the metrics of the store, so it may be called without holding its lock.
+func (s *MetricsStore) generate(obj interface{}) (generatedObject, error) {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return generatedObject{}, err
+	}
 
 	families := s.generateMetricsFunc(obj)
-	familyStrings := make([][]byte, len(families))
+	g := generatedObject{
+		uid:      o.GetUID(),
+		families: make([][]byte, len(families)),
+		series:   make([]int, len(families)),
+	}
 
+	// The families are serialized into a single pooled buffer, which is
+	// copied once for all of them.
+	b := generateBufPool.Get().(*bytes.Buffer)
+	b.Reset()
+	defer generateBufPool.Put(b)
+
+	ends := make([]int, len(families))
 	for i, f := range families {
-		familyStrings[i] = f.ByteSlice()
+		f.WriteTo(b)
+		ends[i] = b.Len()
+		g.series[i] = seriesCount(f)
+	}
+	out := make([]byte, b.Len())
+	copy(out, b.Bytes())
+	start := 0
+	for i, end := range ends {
+		g.families[i] = out[start:end:end]
+		start = end
 	}
 
-	s.metrics[o.GetUID()] = familyStrings
+	return g, nil
+}
 
-	return nil
+// set stores the generated metric families of an object, dropping the ones
+// exceeding the series budget. The caller must hold the lock of the store.
+func (s *MetricsStore) set(g generatedObject) {
+	if s.budget != nil {
+		s.budget.release(s.series[g.uid])
+		series := 0
+		for i, n := range g.series {
+			if !s.budget.reserve(n) {
+				g.families[i] = nil
+				continue
+			}
+			series += n
+		}
+		s.series[g.uid] = series
+	}
+
+	s.metrics[g.uid] = g.families
 }
 
 // Update updates the existing entry in the MetricsStore.
@@ -99,6 +208,10 @@ func (s *MetricsStore) Delete(obj interface{}) error {
 	defer s.mutex.Unlock()
 
 	delete(s.metrics, o.GetUID())
+	if s.budget != nil {
+		s.budget.release(s.series[o.GetUID()])
+		delete(s.series, o.GetUID())
+	}
 
 	return nil
 }
@@ -126,8 +239,15 @@ func (s *MetricsStore) GetByKey(key string) (item interface{}, exists bool, err
 // Replace will delete the contents of the store, using instead the
 // given list.
 func (s *MetricsStore) Replace(list []interface{}, _ string) error {
+	s.mutex.RLock()
+	workers := s.generationConcurrency
+	s.mutex.RUnlock()
+	if workers > 1 {
+		return s.replaceConcurrently(list, workers)
+	}
+
 	s.mutex.Lock()
-	s.metrics = map[types.UID][][]byte{}
+	s.reset()
 	s.mutex.Unlock()
 
 	for _, o := range list {
@@ -137,9 +257,77 @@ func (s *MetricsStore) Replace(list []interface{}, _ string) error {
 		}
 	}
 
+	s.mutex.Lock()
+	s.synced = true
+	s.mutex.Unlock()
+
 	return nil
 }
 
+// replaceConcurrently generates the metrics of the objects in list with the
+// given number of workers and replaces the contents of the store with them at
+// once. The objects are stored in the order of list, so the series budget
+// drops the same metric families as it does when replacing serially.
+func (s *MetricsStore) replaceConcurrently(list []interface{}, workers int) error {
+	generated := make([]generatedObject, len(list))
+	errs := make([]error, len(list))
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(list); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				generated[i], errs[i] = s.generate(list[i])
+			}
+		}()
+	}
+	for i := range list {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.reset()
+	for _, g := range generated {
+		s.set(g)
+	}
+	s.synced = true
+
+	return nil
+}
+
+// reset removes all metrics of the store and returns their series to the
+// series budget. The caller must hold the lock of the store.
+func (s *MetricsStore) reset() {
+	s.metrics = map[types.UID][][]byte{}
+	if s.budget != nil {
+		for _, n := range s.series {
+			s.budget.release(n)
+		}
+		s.series = map[types.UID]int{}
+	}
+}
+
+// HasSynced returns true once the store was populated with the initial list
+// of objects.
+func (s *MetricsStore) HasSynced() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.synced
+}
+
 // Resync implements the Resync method of the store interface.
 func (s *MetricsStore) Resync() error {
 	return nil
@@ -148,10 +336,30 @@ func (s *MetricsStore) Resync() error {
 // WriteAll writes all metrics of the store into the given writer, zipped with the
 // help text of each metric family.
 func (s *MetricsStore) WriteAll(w io.Writer) {
+	s.writeAll(w, s.headers)
+}
+
+// WriteAllOpenMetrics writes all metrics of the store into the given writer in
+// the OpenMetrics text format. The caller is responsible for writing the
+// "# EOF" trailer once all metrics are written out.
+func (s *MetricsStore) WriteAllOpenMetrics(w io.Writer) {
+	s.writeAll(w, s.openMetricsHeaders)
+}
+
+// seriesCount returns the number of series of the metric family.
+func seriesCount(f metric.FamilyInterface) int {
+	n := 0
+	f.Inspect(func(f metric.Family) {
+		n = len(f.Metrics)
+	})
+	return n
+}
+
+func (s *MetricsStore) writeAll(w io.Writer, headers []string) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	for i, help := range s.headers {
+	for i, help := range headers {
 		w.Write([]byte(help))
 		w.Write([]byte{'\n'})
 		for _, metricFamilies := range s.metrics {
@@ -159,3 +367,56 @@ func (s *MetricsStore) WriteAll(w io.Writer) {
 		}
 	}
 }
+
+// prometheusHeader removes the UNIT line of a metric family header, which is
+// only part of the OpenMetrics text format.
+func prometheusHeader(header string) string {
+	if !strings.Contains(header, "# UNIT ") {
+		return header
+	}
+	lines := strings.Split(header, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "# UNIT ") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// openMetricsHeader converts a metric family header from the Prometheus text
+// format to the OpenMetrics text format. OpenMetrics requires the name of
+// counter families to omit the "_total" suffix of their samples, so counters
+// whose samples lack it are exposed as unknown type instead. The optional
+// "_created" series of counters are not written, as kube-state-metrics does
+// not know when a counter started counting. Any other header is returned
+// unchanged.
+func openMetricsHeader(header string) string {
+	lines := strings.Split(header, "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "#" || fields[1] != "TYPE" {
+			continue
+		}
+		name := fields[2]
+		if fields[3] != "counter" {
+			return header
+		}
+		if !strings.HasSuffix(name, "_total") {
+			lines[i] = "# TYPE " + name + " unknown"
+			return strings.Join(lines, "\n")
+		}
+
+		familyName := strings.TrimSuffix(name, "_total")
+		for i, l := range lines {
+			for _, keyword := range []string{"HELP", "TYPE", "UNIT"} {
+				prefix := "# " + keyword + " " + name + " "
+				if strings.HasPrefix(l, prefix) {
+					lines[i] = "# " + keyword + " " + familyName + " " + strings.TrimPrefix(l, prefix)
+				}
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+	return header
+}
@@ -18,9 +18,12 @@ package metricsstore
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -90,3 +93,167 @@ func TestObjectsSameNameDifferentNamespaces(t *testing.T) {
 		}
 	}
 }
+
+func TestSeriesBudget(t *testing.T) {
+	genFunc := func(obj interface{}) []metric.FamilyInterface {
+		o, err := meta.Accessor(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		series := func(n int) *metric.Family {
+			f := &metric.Family{Name: "kube_service_info"}
+			for i := 0; i < n; i++ {
+				f.Metrics = append(f.Metrics, &metric.Metric{
+					LabelKeys:   []string{"uid", "i"},
+					LabelValues: []string{string(o.GetUID()), fmt.Sprint(i)},
+					Value:       1,
+				})
+			}
+			return f
+		}
+		return []metric.FamilyInterface{series(2), series(1)}
+	}
+	service := func(uid string) *v1.Service {
+		return &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: uid, UID: types.UID(uid)}}
+	}
+	written := func(stores ...*MetricsStore) int {
+		w := strings.Builder{}
+		NewMultiStoreMetricsWriter(stores).WriteAll(&w)
+		return strings.Count(w.String(), "kube_service_info{")
+	}
+
+	dropped := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_series_dropped_total"})
+	budget := NewSeriesBudget(5, dropped)
+	a := NewMetricsStore([]string{"first", "second"}, genFunc)
+	a.WithSeriesBudget(budget)
+	b := NewMetricsStore([]string{"first", "second"}, genFunc)
+	b.WithSeriesBudget(budget)
+
+	if err := a.Add(service("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(service("b")); err != nil {
+		t.Fatal(err)
+	}
+	if got := written(a, b); got != 5 {
+		t.Errorf("expected the second family of the second object to be dropped, got %d series", got)
+	}
+	if got := testutil.ToFloat64(dropped); got != 1 {
+		t.Errorf("expected 1 dropped series, got %v", got)
+	}
+
+	// Updating an object reuses the series it already took.
+	if err := a.Update(service("a")); err != nil {
+		t.Fatal(err)
+	}
+	if got := written(a, b); got != 5 {
+		t.Errorf("expected the updated object to keep its series, got %d series", got)
+	}
+
+	if err := a.Delete(service("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Replace([]interface{}{service("b"), service("c")}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := written(a, b); got != 5 {
+		t.Errorf("expected the released series to be reused, got %d series", got)
+	}
+	if got := testutil.ToFloat64(dropped); got != 2 {
+		t.Errorf("expected 2 dropped series, got %v", got)
+	}
+}
+
+// replaceGenFunc generates a family of ten series and a family of a single
+// series for a service.
+func replaceGenFunc(obj interface{}) []metric.FamilyInterface {
+	o, _ := meta.Accessor(obj)
+	info := &metric.Family{Name: "kube_service_info"}
+	for i := 0; i < 10; i++ {
+		info.Metrics = append(info.Metrics, &metric.Metric{
+			LabelKeys:   []string{"namespace", "service", "port"},
+			LabelValues: []string{o.GetNamespace(), o.GetName(), fmt.Sprint(i)},
+			Value:       float64(i),
+		})
+	}
+	created := &metric.Family{Name: "kube_service_created", Metrics: []*metric.Metric{{
+		LabelKeys:   []string{"namespace", "service"},
+		LabelValues: []string{o.GetNamespace(), o.GetName()},
+		Value:       1,
+	}}}
+	return []metric.FamilyInterface{info, created}
+}
+
+func replaceList(n int) []interface{} {
+	list := make([]interface{}, n)
+	for i := range list {
+		list[i] = &v1.Service{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      fmt.Sprintf("service-%d", i),
+			UID:       types.UID(fmt.Sprintf("uid-%d", i)),
+		}}
+	}
+	return list
+}
+
+func TestReplaceConcurrently(t *testing.T) {
+	lines := func(s *MetricsStore) []string {
+		w := strings.Builder{}
+		s.WriteAll(&w)
+		l := strings.Split(w.String(), "\n")
+		sort.Strings(l)
+		return l
+	}
+	list := replaceList(100)
+
+	serial := NewMetricsStore([]string{"info", "created"}, replaceGenFunc)
+	if err := serial.Replace(list, ""); err != nil {
+		t.Fatal(err)
+	}
+	concurrent := NewMetricsStore([]string{"info", "created"}, replaceGenFunc)
+	concurrent.WithGenerationConcurrency(4)
+	if err := concurrent.Replace(list, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !concurrent.HasSynced() {
+		t.Error("expected the store to be synced")
+	}
+	if want, got := lines(serial), lines(concurrent); strings.Join(want, "\n") != strings.Join(got, "\n") {
+		t.Errorf("expected the same metrics as generated serially, want %d lines, got %d", len(want), len(got))
+	}
+
+	// The budget drops the same families regardless of the concurrency.
+	serial = NewMetricsStore([]string{"info", "created"}, replaceGenFunc)
+	serial.WithSeriesBudget(NewSeriesBudget(505, prometheus.NewCounter(prometheus.CounterOpts{Name: "serial_dropped_total"})))
+	if err := serial.Replace(list, ""); err != nil {
+		t.Fatal(err)
+	}
+	concurrent.WithSeriesBudget(NewSeriesBudget(505, prometheus.NewCounter(prometheus.CounterOpts{Name: "concurrent_dropped_total"})))
+	if err := concurrent.Replace(list, ""); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := lines(serial), lines(concurrent); strings.Join(want, "\n") != strings.Join(got, "\n") {
+		t.Errorf("expected the budget to drop the same families, want %d lines, got %d", len(want), len(got))
+	}
+
+	if err := concurrent.Replace([]interface{}{"not an object"}, ""); err == nil {
+		t.Error("expected an error for an object without metadata")
+	}
+}
+
+func BenchmarkReplace(b *testing.B) {
+	list := replaceList(10000)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			s := NewMetricsStore([]string{"info", "created"}, replaceGenFunc)
+			s.WithGenerationConcurrency(workers)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := s.Replace(list, ""); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -18,10 +18,24 @@ package metricsstore
 
 import "io"
 
-// MetricsWriter is the interface that wraps the WriteAll method.
-// WriteAll writes out bytes to the underlying writer.
+// MetricsWriter is the interface that wraps the WriteAll,
+// WriteAllOpenMetrics and HasSynced methods.
+// WriteAll writes out bytes in the Prometheus text format to the underlying
+// writer, WriteAllOpenMetrics writes them out in the OpenMetrics text format.
+// HasSynced reports whether the written out metrics cover all objects.
 type MetricsWriter interface {
 	WriteAll(w io.Writer)
+	WriteAllOpenMetrics(w io.Writer)
+	HasSynced() bool
+}
+
+// NativeHistogramWriter is a MetricsWriter exposing some of its histograms as
+// native histograms as well, which only the protobuf exposition format
+// supports. NativeHistograms returns the observations of each of these
+// histograms, keyed by the name of their family, which has a single series.
+type NativeHistogramWriter interface {
+	MetricsWriter
+	NativeHistograms() map[string][]float64
 }
 
 // MultiStoreMetricsWriter is a struct that holds multiple MetricsStore(s) and
@@ -51,6 +65,31 @@ func (m MultiStoreMetricsWriter) WriteAll(w io.Writer) {
 		return
 	}
 
+	m.writeAll(w, m.stores[0].headers)
+}
+
+// WriteAllOpenMetrics writes out metrics from the underlying stores to the
+// given writer in the OpenMetrics text format. The caller is responsible for
+// writing the "# EOF" trailer once all metrics are written out.
+func (m MultiStoreMetricsWriter) WriteAllOpenMetrics(w io.Writer) {
+	if len(m.stores) == 0 {
+		return
+	}
+
+	m.writeAll(w, m.stores[0].openMetricsHeaders)
+}
+
+// HasSynced returns true once all underlying stores have synced.
+func (m MultiStoreMetricsWriter) HasSynced() bool {
+	for _, s := range m.stores {
+		if !s.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (m MultiStoreMetricsWriter) writeAll(w io.Writer, headers []string) {
 	for _, s := range m.stores {
 		s.mutex.RLock()
 		defer func(s *MetricsStore) {
@@ -58,7 +97,7 @@ func (m MultiStoreMetricsWriter) WriteAll(w io.Writer) {
 		}(s)
 	}
 
-	for i, help := range m.stores[0].headers {
+	for i, help := range headers {
 		w.Write([]byte(help))
 		w.Write([]byte{'\n'})
 		for _, s := range m.stores {
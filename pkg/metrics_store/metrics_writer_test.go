@@ -222,3 +222,158 @@ func TestWriteAllWithMultipleStores(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteAllOpenMetrics(t *testing.T) {
+	genFunc := func(obj interface{}) []metric.FamilyInterface {
+		o, err := meta.Accessor(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mf1 := metric.Family{
+			Name: "kube_service_info",
+			Metrics: []*metric.Metric{
+				{
+					LabelKeys:   []string{"namespace", "uid"},
+					LabelValues: []string{o.GetNamespace(), string(o.GetUID())},
+					Value:       float64(1),
+				},
+			},
+		}
+
+		mf2 := metric.Family{
+			Name: "kube_service_restarts_total",
+			Metrics: []*metric.Metric{
+				{
+					LabelKeys:   []string{"namespace", "uid"},
+					LabelValues: []string{o.GetNamespace(), string(o.GetUID())},
+					Value:       float64(3),
+				},
+			},
+		}
+
+		mf3 := metric.Family{
+			Name: "kube_service_reloads",
+			Metrics: []*metric.Metric{
+				{
+					LabelKeys:   []string{"namespace", "uid"},
+					LabelValues: []string{o.GetNamespace(), string(o.GetUID())},
+					Value:       float64(2),
+				},
+			},
+		}
+
+		return []metric.FamilyInterface{&mf1, &mf2, &mf3}
+	}
+	headers := []string{
+		"# HELP kube_service_info Information about service.\n# TYPE kube_service_info gauge",
+		"# HELP kube_service_restarts_total The number of restarts.\n# TYPE kube_service_restarts_total counter",
+		"# HELP kube_service_reloads The number of reloads.\n# TYPE kube_service_reloads counter",
+	}
+	store := metricsstore.NewMetricsStore(headers, genFunc)
+	svc := v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:       "a1",
+			Name:      "service",
+			Namespace: "a",
+		},
+	}
+	if err := store.Add(&svc); err != nil {
+		t.Fatal(err)
+	}
+
+	multiNsWriter := metricsstore.NewMultiStoreMetricsWriter([]*metricsstore.MetricsStore{store})
+	w := strings.Builder{}
+	multiNsWriter.WriteAllOpenMetrics(&w)
+
+	expected := `# HELP kube_service_info Information about service.
+# TYPE kube_service_info gauge
+kube_service_info{namespace="a",uid="a1"} 1
+# HELP kube_service_restarts The number of restarts.
+# TYPE kube_service_restarts counter
+kube_service_restarts_total{namespace="a",uid="a1"} 3
+# HELP kube_service_reloads The number of reloads.
+# TYPE kube_service_reloads unknown
+kube_service_reloads{namespace="a",uid="a1"} 2
+`
+	if result := w.String(); result != expected {
+		t.Fatalf("Invalid OpenMetrics output, got:\n%s\nwant:\n%s", result, expected)
+	}
+
+	w.Reset()
+	multiNsWriter.WriteAll(&w)
+	if result := w.String(); !strings.Contains(result, "# TYPE kube_service_restarts_total counter") {
+		t.Fatalf("Prometheus text output should keep the counter family name, got:\n%s", result)
+	}
+}
+
+func TestWriteAllUnit(t *testing.T) {
+	genFunc := func(obj interface{}) []metric.FamilyInterface {
+		return []metric.FamilyInterface{&metric.Family{
+			Name: "kube_service_uptime_seconds_total",
+			Metrics: []*metric.Metric{
+				{
+					LabelKeys:   []string{"namespace"},
+					LabelValues: []string{"a"},
+					Value:       float64(60),
+				},
+			},
+		}}
+	}
+	headers := []string{
+		"# HELP kube_service_uptime_seconds_total Uptime of the service.\n# TYPE kube_service_uptime_seconds_total counter\n# UNIT kube_service_uptime_seconds_total seconds",
+	}
+	store := metricsstore.NewMetricsStore(headers, genFunc)
+	if err := store.Add(&v1.Service{ObjectMeta: metav1.ObjectMeta{UID: "a1", Name: "service", Namespace: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := strings.Builder{}
+	store.WriteAllOpenMetrics(&w)
+	expected := `# HELP kube_service_uptime_seconds Uptime of the service.
+# TYPE kube_service_uptime_seconds counter
+# UNIT kube_service_uptime_seconds seconds
+kube_service_uptime_seconds_total{namespace="a"} 60
+`
+	if result := w.String(); result != expected {
+		t.Fatalf("Invalid OpenMetrics output, got:\n%s\nwant:\n%s", result, expected)
+	}
+
+	w.Reset()
+	store.WriteAll(&w)
+	expected = `# HELP kube_service_uptime_seconds_total Uptime of the service.
+# TYPE kube_service_uptime_seconds_total counter
+kube_service_uptime_seconds_total{namespace="a"} 60
+`
+	if result := w.String(); result != expected {
+		t.Fatalf("Prometheus text output should omit the unit, got:\n%s\nwant:\n%s", result, expected)
+	}
+}
+
+func TestHasSynced(t *testing.T) {
+	genFunc := func(obj interface{}) []metric.FamilyInterface {
+		return []metric.FamilyInterface{}
+	}
+	s1 := metricsstore.NewMetricsStore([]string{"Info about services"}, genFunc)
+	s2 := metricsstore.NewMetricsStore([]string{"Info about services"}, genFunc)
+	mw := metricsstore.NewMultiStoreMetricsWriter([]*metricsstore.MetricsStore{s1, s2})
+
+	if mw.HasSynced() {
+		t.Fatal("expected writer to not have synced before any store was populated")
+	}
+	if err := s1.Replace([]interface{}{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !s1.HasSynced() {
+		t.Error("expected store to have synced after Replace")
+	}
+	if mw.HasSynced() {
+		t.Error("expected writer to not have synced before all stores were populated")
+	}
+	if err := s2.Replace([]interface{}{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !mw.HasSynced() {
+		t.Error("expected writer to have synced once all stores were populated")
+	}
+}
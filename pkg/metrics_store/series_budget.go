@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsstore
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SeriesBudget limits the number of series held by the MetricsStores sharing
+// it. Once the budget is exhausted, the stores drop the metric families which
+// do not fit anymore instead of growing without bounds, counting their series
+// in dropped.
+type SeriesBudget struct {
+	mutex   sync.Mutex
+	max     int
+	used    int
+	dropped prometheus.Counter
+}
+
+// NewSeriesBudget returns a new SeriesBudget of max series.
+func NewSeriesBudget(max int, dropped prometheus.Counter) *SeriesBudget {
+	return &SeriesBudget{
+		max:     max,
+		dropped: dropped,
+	}
+}
+
+// reserve takes n series from the budget. If they do not fit, nothing is
+// taken, the series are counted as dropped and false is returned.
+func (b *SeriesBudget) reserve(n int) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.used+n > b.max {
+		b.dropped.Add(float64(n))
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// release returns n series to the budget.
+func (b *SeriesBudget) release(n int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.used -= n
+}
@@ -17,15 +17,22 @@ limitations under the License.
 package metricshandler
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -34,6 +41,7 @@ import (
 	"k8s.io/klog/v2"
 
 	ksmtypes "k8s.io/kube-state-metrics/v2/pkg/builder/types"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
 	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
 	"k8s.io/kube-state-metrics/v2/pkg/options"
 )
@@ -53,6 +61,11 @@ type MetricsHandler struct {
 	metricsWriters []metricsstore.MetricsWriter
 	curShard       int32
 	curTotalShards int
+
+	// catalogOnce encodes catalog, the JSON catalog of the metric families,
+	// on its first request, as it does not change after startup.
+	catalogOnce sync.Once
+	catalog     []byte
 }
 
 // New creates and returns a new MetricsHandler with the given options.
@@ -174,6 +187,17 @@ func (m *MetricsHandler) Run(ctx context.Context) error {
 	return ctx.Err()
 }
 
+// ServeReady responds with 200 once the stores of all enabled resources have
+// synced their initial list of objects, and with 503 before.
+func (m *MetricsHandler) ServeReady(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	if !m.hasSynced() {
+		status = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(http.StatusText(status)))
+}
+
 // ServeHTTP implements the http.Handler interface. It writes all generated
 // metrics to the response body.
 func (m *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -182,24 +206,54 @@ func (m *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	resHeader := w.Header()
 	var writer io.Writer = w
 
-	resHeader.Set("Content-Type", `text/plain; version=`+"0.0.4")
+	// Serve the OpenMetrics text format if requested, falling back to the
+	// Prometheus text format otherwise. The protobuf format is only served
+	// if native histograms are exposed, as only it supports them, and it
+	// takes parsing the text format.
+	format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+	var nativeHistograms map[string][]float64
+	if format == expfmt.FmtProtoDelim {
+		nativeHistograms = nativeHistogramsOf(m.metricsWriters)
+	}
+	openMetrics := format == expfmt.FmtOpenMetrics
+	switch {
+	case openMetrics:
+		resHeader.Set("Content-Type", string(expfmt.FmtOpenMetrics))
+	case nativeHistograms != nil:
+		resHeader.Set("Content-Type", string(expfmt.FmtProtoDelim))
+	default:
+		resHeader.Set("Content-Type", `text/plain; version=`+"0.0.4")
+	}
 
 	if m.enableGZIPEncoding {
-		// Gzip response if requested. Taken from
-		// github.com/prometheus/client_golang/prometheus/promhttp.decorateWriter.
-		reqHeader := r.Header.Get("Accept-Encoding")
-		parts := strings.Split(reqHeader, ",")
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			if part == "gzip" || strings.HasPrefix(part, "gzip;") {
-				writer = gzip.NewWriter(writer)
-				resHeader.Set("Content-Encoding", "gzip")
+		// The response differs by the encodings accepted by the client.
+		// Only gzip is supported, as the standard library lacks a zstd
+		// encoder.
+		resHeader.Add("Vary", "Accept-Encoding")
+		if acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+			// Compress the response while it is written, so no
+			// uncompressed copy of it is buffered.
+			writer = gzip.NewWriter(writer)
+			resHeader.Set("Content-Encoding", "gzip")
+		}
+	}
+
+	if nativeHistograms != nil {
+		if err := writeProtobuf(writer, m.metricsWriters, nativeHistograms); err != nil {
+			klog.Errorf("Failed to write metrics in the protobuf format: %v", err)
+		}
+	} else {
+		for _, w := range m.metricsWriters {
+			if openMetrics {
+				w.WriteAllOpenMetrics(writer)
+			} else {
+				w.WriteAll(writer)
 			}
 		}
 	}
 
-	for _, w := range m.metricsWriters {
-		w.WriteAll(writer)
+	if openMetrics {
+		writer.Write([]byte("# EOF\n"))
 	}
 
 	// In case we gzipped the response, we have to close the writer.
@@ -208,6 +262,204 @@ func (m *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// catalogResource is the JSON representation of the metric families of a
+// resource served by MetricsHandler.ServeCatalog.
+type catalogResource struct {
+	Resource string          `json:"resource"`
+	Families []catalogFamily `json:"families"`
+}
+
+type catalogFamily struct {
+	Name string `json:"name"`
+	Help string `json:"help"`
+	Type string `json:"type"`
+}
+
+// ServeCatalog writes the name, help text and type of the metric families
+// exposed for each enabled resource as JSON, sorted by resource. It does not
+// depend on any objects to exist. The catalog is built on the first request
+// and served from then on.
+func (m *MetricsHandler) ServeCatalog(w http.ResponseWriter, r *http.Request) {
+	m.catalogOnce.Do(func() {
+		m.mtx.RLock()
+		catalog := m.storeBuilder.Catalog()
+		m.mtx.RUnlock()
+		m.catalog = encodeCatalog(catalog)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(m.catalog); err != nil {
+		klog.Errorf("Failed to write the metric catalog: %v", err)
+	}
+}
+
+// encodeCatalog returns the JSON encoding of the metric families of the
+// catalog, sorted by resource.
+func encodeCatalog(catalog map[string][]generator.FamilyGenerator) []byte {
+	resources := make([]catalogResource, 0, len(catalog))
+	for resource, families := range catalog {
+		cr := catalogResource{Resource: resource, Families: make([]catalogFamily, 0, len(families))}
+		for _, f := range families {
+			cr.Families = append(cr.Families, catalogFamily{Name: f.Name, Help: f.Help, Type: string(f.Type)})
+		}
+		resources = append(resources, cr)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Resource < resources[j].Resource
+	})
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(resources); err != nil {
+		klog.Errorf("Failed to encode the metric catalog: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// acceptsEncoding reports whether the given Accept-Encoding header value
+// accepts the content encoding, i.e. lists it without a quality value of 0.
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), encoding) {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// jsonFamily is the JSON representation of a metric family served by
+// MetricsHandler.ServeJSON.
+type jsonFamily struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Metrics []jsonMetric `json:"metrics"`
+}
+
+// jsonMetric is the JSON representation of a single time series. The value
+// of a histogram is the sum of its observations.
+type jsonMetric struct {
+	LabelKeys   []string     `json:"labelKeys"`
+	LabelValues []string     `json:"labelValues"`
+	Value       jsonValue    `json:"value"`
+	Count       *uint64      `json:"count,omitempty"`
+	Buckets     []jsonBucket `json:"buckets,omitempty"`
+}
+
+// jsonBucket is the JSON representation of a cumulative bucket of a
+// histogram.
+type jsonBucket struct {
+	UpperBound jsonValue `json:"upperBound"`
+	Count      uint64    `json:"count"`
+}
+
+// jsonValue is a metric value which encodes NaN and infinite values, that are
+// not supported by JSON numbers, the same way as the Prometheus text format.
+type jsonValue float64
+
+// MarshalJSON implements the json.Marshaler interface.
+func (v jsonValue) MarshalJSON() ([]byte, error) {
+	f := float64(v)
+	switch {
+	case math.IsNaN(f):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(f, +1):
+		return []byte(`"+Inf"`), nil
+	case math.IsInf(f, -1):
+		return []byte(`"-Inf"`), nil
+	}
+	return json.Marshal(f)
+}
+
+// ServeJSON serves the same metrics as ServeHTTP as a JSON array of metric
+// families, sorted by name.
+func (m *MetricsHandler) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	metricFamilies, err := m.parseMetricFamilies()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse metrics: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	names := make([]string, 0, len(metricFamilies))
+	for name := range metricFamilies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	families := make([]jsonFamily, 0, len(names))
+	for _, name := range names {
+		mf := metricFamilies[name]
+		family := jsonFamily{
+			Name:    mf.GetName(),
+			Help:    mf.GetHelp(),
+			Type:    strings.ToLower(mf.GetType().String()),
+			Metrics: make([]jsonMetric, 0, len(mf.GetMetric())),
+		}
+		for _, metric := range mf.GetMetric() {
+			jm := jsonMetric{
+				LabelKeys:   make([]string, 0, len(metric.GetLabel())),
+				LabelValues: make([]string, 0, len(metric.GetLabel())),
+			}
+			for _, l := range metric.GetLabel() {
+				jm.LabelKeys = append(jm.LabelKeys, l.GetName())
+				jm.LabelValues = append(jm.LabelValues, l.GetValue())
+			}
+			switch {
+			case metric.Counter != nil:
+				jm.Value = jsonValue(metric.GetCounter().GetValue())
+			case metric.Gauge != nil:
+				jm.Value = jsonValue(metric.GetGauge().GetValue())
+			case metric.Histogram != nil:
+				h := metric.GetHistogram()
+				jm.Value = jsonValue(h.GetSampleSum())
+				jm.Count = h.SampleCount
+				for _, b := range h.GetBucket() {
+					jm.Buckets = append(jm.Buckets, jsonBucket{UpperBound: jsonValue(b.GetUpperBound()), Count: b.GetCumulativeCount()})
+				}
+			default:
+				jm.Value = jsonValue(metric.GetUntyped().GetValue())
+			}
+			family.Metrics = append(family.Metrics, jm)
+		}
+		families = append(families, family)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(families); err != nil {
+		klog.Errorf("Failed to write metrics as JSON: %v", err)
+	}
+}
+
+// parseMetricFamilies parses the metrics served by ServeHTTP in the
+// Prometheus text format into metric families, keyed by name.
+func (m *MetricsHandler) parseMetricFamilies() (map[string]*dto.MetricFamily, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return textToMetricFamilies(m.metricsWriters)
+}
+
+// textToMetricFamilies parses the metrics of the writers in the Prometheus
+// text format into metric families, keyed by name.
+func textToMetricFamilies(writers []metricsstore.MetricsWriter) (map[string]*dto.MetricFamily, error) {
+	buf := &bytes.Buffer{}
+	for _, writer := range writers {
+		writer.WriteAll(buf)
+	}
+
+	var parser expfmt.TextParser
+	return parser.TextToMetricFamilies(buf)
+}
+
 func shardingSettingsFromStatefulSet(ss *appsv1.StatefulSet, podName string) (nominal int32, totalReplicas int, err error) {
 	nominal, err = detectNominalFromPod(ss.Name, podName)
 	if err != nil {
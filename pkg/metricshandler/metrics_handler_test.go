@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricshandler
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	ksmtypes "k8s.io/kube-state-metrics/v2/pkg/builder/types"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: "gzip", want: true},
+		{header: "deflate, GZIP", want: true},
+		{header: "gzip;q=0.5, identity", want: true},
+		{header: "gzip;q=0", want: false},
+		{header: "gzip; q=0.0, *", want: false},
+		{header: "x-gzip, deflate", want: false},
+	}
+
+	for _, test := range tests {
+		if got := acceptsEncoding(test.header, "gzip"); got != test.want {
+			t.Errorf("acceptsEncoding(%q, \"gzip\"): want %v, got %v", test.header, test.want, got)
+		}
+	}
+}
+
+// catalogBuilder counts the catalogs built.
+type catalogBuilder struct {
+	ksmtypes.BuilderInterface
+	catalogs int
+}
+
+func (b *catalogBuilder) Catalog() map[string][]generator.FamilyGenerator {
+	b.catalogs++
+	generate := func(obj interface{}) *metric.Family { return &metric.Family{} }
+	return map[string][]generator.FamilyGenerator{
+		"pods": {*generator.NewFamilyGenerator("kube_pod_info", "Information about pod.", metric.Gauge, "", generate)},
+	}
+}
+
+func TestServeCatalogOnce(t *testing.T) {
+	builder := &catalogBuilder{}
+	m := &MetricsHandler{mtx: &sync.RWMutex{}, storeBuilder: builder}
+
+	want := `[{"resource":"pods","families":[{"name":"kube_pod_info","help":"Information about pod.","type":"gauge"}]}]
+`
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		m.ServeCatalog(rec, httptest.NewRequest("GET", "/catalog", nil))
+		if got := rec.Body.String(); got != want {
+			t.Errorf("request %d: want catalog %q, got %q", i, want, got)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("request %d: want content type application/json, got %q", i, got)
+		}
+	}
+	if builder.catalogs != 1 {
+		t.Errorf("want the catalog to be built once, got %d times", builder.catalogs)
+	}
+}
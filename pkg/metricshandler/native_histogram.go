@@ -0,0 +1,253 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricshandler
+
+import (
+	"io"
+	"math"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+const (
+	// nativeHistogramSchema is the resolution of the native histograms, i.e.
+	// each bucket is 2^(2^-3), about 1.09, times as wide as the previous one.
+	// This is the schema client_golang picks for a bucket factor of 1.1.
+	nativeHistogramSchema = 3
+)
+
+var (
+	// nativeHistogramZeroThreshold is the upper bound of the absolute value
+	// of the observations counted in the zero bucket, which matches the
+	// default of client_golang.
+	nativeHistogramZeroThreshold = math.Ldexp(1, -128)
+
+	// nativeHistogramBounds are the upper bounds of the buckets of the
+	// native histograms between 0.5 and 1, which repeat for each power of 2.
+	nativeHistogramBounds = func() []float64 {
+		n := 1 << nativeHistogramSchema
+		bounds := make([]float64, n)
+		for i := range bounds {
+			bounds[i] = math.Exp2(float64(i)/float64(n)) / 2
+		}
+		return bounds
+	}()
+)
+
+// nativeHistogramsOf returns the observations of the native histograms of
+// the writers, keyed by the name of their family.
+func nativeHistogramsOf(writers []metricsstore.MetricsWriter) map[string][]float64 {
+	var histograms map[string][]float64
+	for _, w := range writers {
+		nw, ok := w.(metricsstore.NativeHistogramWriter)
+		if !ok {
+			continue
+		}
+		for name, observations := range nw.NativeHistograms() {
+			if histograms == nil {
+				histograms = map[string][]float64{}
+			}
+			histograms[name] = observations
+		}
+	}
+	return histograms
+}
+
+// writeProtobuf writes the metrics of the writers in the delimited protobuf
+// exposition format. The histograms of the families in nativeHistograms are
+// written as native histograms of the given observations, along with their
+// classic buckets, which are counted from the same observations so that both
+// are consistent.
+func writeProtobuf(w io.Writer, writers []metricsstore.MetricsWriter, nativeHistograms map[string][]float64) error {
+	metricFamilies, err := textToMetricFamilies(writers)
+	if err != nil {
+		return err
+	}
+
+	enc := expfmt.NewEncoder(w, expfmt.FmtProtoDelim)
+	for _, mf := range sortMetricFamilies(metricFamilies) {
+		observations, ok := nativeHistograms[mf.GetName()]
+		if !ok || mf.GetType() != dto.MetricType_HISTOGRAM {
+			if err := enc.Encode(mf); err != nil {
+				return err
+			}
+			continue
+		}
+		family := marshalNativeHistogramFamily(mf, observations)
+		if _, err := w.Write(protowire.AppendBytes(nil, family)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalNativeHistogramFamily encodes the histogram family as
+// io.prometheus.client.MetricFamily protobuf message, with each series
+// holding the given observations. It is encoded by hand, as the native
+// histogram fields are missing from the client_model version in use.
+func marshalNativeHistogramFamily(mf *dto.MetricFamily, observations []float64) []byte {
+	var family []byte
+	family = protowire.AppendTag(family, 1, protowire.BytesType)
+	family = protowire.AppendString(family, mf.GetName())
+	family = protowire.AppendTag(family, 2, protowire.BytesType)
+	family = protowire.AppendString(family, mf.GetHelp())
+	family = protowire.AppendTag(family, 3, protowire.VarintType)
+	family = protowire.AppendVarint(family, uint64(dto.MetricType_HISTOGRAM))
+
+	for _, metric := range mf.GetMetric() {
+		var mb []byte
+		for _, l := range metric.GetLabel() {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.GetName())
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.GetValue())
+			mb = protowire.AppendTag(mb, 1, protowire.BytesType)
+			mb = protowire.AppendBytes(mb, lb)
+		}
+		var upperBounds []float64
+		for _, b := range metric.GetHistogram().GetBucket() {
+			if !math.IsInf(b.GetUpperBound(), +1) {
+				upperBounds = append(upperBounds, b.GetUpperBound())
+			}
+		}
+		mb = protowire.AppendTag(mb, 7, protowire.BytesType)
+		mb = protowire.AppendBytes(mb, marshalNativeHistogram(upperBounds, observations))
+
+		family = protowire.AppendTag(family, 4, protowire.BytesType)
+		family = protowire.AppendBytes(family, mb)
+	}
+	return family
+}
+
+// marshalNativeHistogram encodes the observations as
+// io.prometheus.client.Histogram protobuf message, observed in both the
+// classic buckets of the given upper bounds and the native buckets.
+func marshalNativeHistogram(upperBounds []float64, observations []float64) []byte {
+	var sum float64
+	var zeroCount uint64
+	positive, negative := map[int]uint64{}, map[int]uint64{}
+	for _, v := range observations {
+		sum += v
+		switch {
+		case math.Abs(v) <= nativeHistogramZeroThreshold:
+			zeroCount++
+		case v > 0:
+			positive[nativeBucketKey(v)]++
+		default:
+			negative[nativeBucketKey(v)]++
+		}
+	}
+
+	var h []byte
+	h = protowire.AppendTag(h, 1, protowire.VarintType)
+	h = protowire.AppendVarint(h, uint64(len(observations)))
+	h = protowire.AppendTag(h, 2, protowire.Fixed64Type)
+	h = protowire.AppendFixed64(h, math.Float64bits(sum))
+	for _, upperBound := range upperBounds {
+		var cumulativeCount uint64
+		for _, v := range observations {
+			if v <= upperBound {
+				cumulativeCount++
+			}
+		}
+		var bb []byte
+		bb = protowire.AppendTag(bb, 1, protowire.VarintType)
+		bb = protowire.AppendVarint(bb, cumulativeCount)
+		bb = protowire.AppendTag(bb, 2, protowire.Fixed64Type)
+		bb = protowire.AppendFixed64(bb, math.Float64bits(upperBound))
+		h = protowire.AppendTag(h, 3, protowire.BytesType)
+		h = protowire.AppendBytes(h, bb)
+	}
+	h = protowire.AppendTag(h, 5, protowire.VarintType)
+	h = protowire.AppendVarint(h, protowire.EncodeZigZag(nativeHistogramSchema))
+	h = protowire.AppendTag(h, 6, protowire.Fixed64Type)
+	h = protowire.AppendFixed64(h, math.Float64bits(nativeHistogramZeroThreshold))
+	h = protowire.AppendTag(h, 7, protowire.VarintType)
+	h = protowire.AppendVarint(h, zeroCount)
+	h = appendNativeBuckets(h, 9, 10, negative)
+	h = appendNativeBuckets(h, 12, 13, positive)
+	return h
+}
+
+// nativeBucketKey returns the index of the native bucket the observation
+// falls into, whose upper bound is 2^(index*2^-schema).
+func nativeBucketKey(v float64) int {
+	frac, exp := math.Frexp(math.Abs(v))
+	return sort.SearchFloat64s(nativeHistogramBounds, frac) + (exp-1)*len(nativeHistogramBounds)
+}
+
+// nativeBucketSpan is a run of consecutive native buckets, offset from the
+// end of the previous run or from bucket 0 for the first one.
+type nativeBucketSpan struct {
+	offset int32
+	length uint32
+}
+
+// nativeBuckets returns the spans of the populated native buckets of the
+// given counts by bucket index, along with the delta of the count of each
+// bucket to the one of the previous bucket.
+func nativeBuckets(counts map[int]uint64) ([]nativeBucketSpan, []int64) {
+	keys := make([]int, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+
+	var spans []nativeBucketSpan
+	var deltas []int64
+	var prevCount int64
+	for i, key := range keys {
+		switch {
+		case i == 0:
+			spans = append(spans, nativeBucketSpan{offset: int32(key), length: 1})
+		case key == keys[i-1]+1:
+			spans[len(spans)-1].length++
+		default:
+			spans = append(spans, nativeBucketSpan{offset: int32(key - keys[i-1] - 1), length: 1})
+		}
+		count := int64(counts[key])
+		deltas = append(deltas, count-prevCount)
+		prevCount = count
+	}
+	return spans, deltas
+}
+
+// appendNativeBuckets appends the spans and deltas of the native buckets of
+// the given counts as the fields of the given numbers.
+func appendNativeBuckets(h []byte, spanField, deltaField protowire.Number, counts map[int]uint64) []byte {
+	spans, deltas := nativeBuckets(counts)
+	for _, span := range spans {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, protowire.EncodeZigZag(int64(span.offset)))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(span.length))
+		h = protowire.AppendTag(h, spanField, protowire.BytesType)
+		h = protowire.AppendBytes(h, sb)
+	}
+	for _, delta := range deltas {
+		h = protowire.AppendTag(h, deltaField, protowire.VarintType)
+		h = protowire.AppendVarint(h, protowire.EncodeZigZag(delta))
+	}
+	return h
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricshandler
+
+import (
+	"io"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+// nativeHistogramWriter writes the given text and exposes the given native
+// histograms.
+type nativeHistogramWriter struct {
+	text       string
+	histograms map[string][]float64
+}
+
+func (w nativeHistogramWriter) WriteAll(out io.Writer)            { io.WriteString(out, w.text) }
+func (w nativeHistogramWriter) WriteAllOpenMetrics(out io.Writer) { w.WriteAll(out) }
+func (w nativeHistogramWriter) HasSynced() bool                   { return true }
+func (w nativeHistogramWriter) NativeHistograms() map[string][]float64 {
+	return w.histograms
+}
+
+func TestNativeBuckets(t *testing.T) {
+	tests := []struct {
+		observations []float64
+		wantSpans    []nativeBucketSpan
+		wantDeltas   []int64
+	}{
+		{},
+		{
+			// 1 and 2 are the upper bounds of buckets 0 and 8.
+			observations: []float64{1, 1, 2},
+			wantSpans:    []nativeBucketSpan{{offset: 0, length: 1}, {offset: 7, length: 1}},
+			wantDeltas:   []int64{2, -1},
+		},
+		{
+			// 1.5 falls into bucket 5, whose bounds are 2^(4/8) and 2^(5/8).
+			observations: []float64{1.45, 1.5, 1.6, 1.6, 1.6, 0.5},
+			wantSpans:    []nativeBucketSpan{{offset: -8, length: 1}, {offset: 12, length: 2}},
+			wantDeltas:   []int64{1, 1, 1},
+		},
+	}
+
+	for i, test := range tests {
+		counts := map[int]uint64{}
+		for _, v := range test.observations {
+			counts[nativeBucketKey(v)]++
+		}
+		spans, deltas := nativeBuckets(counts)
+		if !reflect.DeepEqual(spans, test.wantSpans) || !reflect.DeepEqual(deltas, test.wantDeltas) {
+			t.Errorf("test %d: want spans %v and deltas %v, got %v and %v", i, test.wantSpans, test.wantDeltas, spans, deltas)
+		}
+	}
+}
+
+func TestServeHTTPNativeHistogram(t *testing.T) {
+	m := &MetricsHandler{
+		mtx: &sync.RWMutex{},
+		metricsWriters: []metricsstore.MetricsWriter{
+			nativeHistogramWriter{text: `# HELP kube_vpa_info Information.
+# TYPE kube_vpa_info gauge
+kube_vpa_info{vpa="a"} 1
+`},
+			nativeHistogramWriter{
+				text: `# HELP kube_vpa_cpu_cores Distribution.
+# TYPE kube_vpa_cpu_cores histogram
+kube_vpa_cpu_cores_bucket{le="1"} 1
+kube_vpa_cpu_cores_bucket{le="+Inf"} 1
+kube_vpa_cpu_cores_sum 0.5
+kube_vpa_cpu_cores_count 1
+`,
+				// The observations differ from the text, as they may
+				// have changed since it was written.
+				histograms: map[string][]float64{"kube_vpa_cpu_cores": {0.5, 0, 2}},
+			},
+		},
+	}
+	protobufAccept := `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,text/plain;version=0.0.4;q=0.3`
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", protobufAccept)
+	m.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Type"); got != string(expfmt.FmtProtoDelim) {
+		t.Fatalf("want the protobuf format, got %q", got)
+	}
+
+	dec := expfmt.NewDecoder(rec.Body, expfmt.FmtProtoDelim)
+	var families []*dto.MetricFamily
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		families = append(families, mf)
+	}
+	if len(families) != 2 || families[0].GetName() != "kube_vpa_cpu_cores" || families[1].GetName() != "kube_vpa_info" {
+		t.Fatalf("want the families kube_vpa_cpu_cores and kube_vpa_info, got %v", families)
+	}
+	if got := families[1].GetMetric()[0].GetGauge().GetValue(); got != 1 {
+		t.Errorf("want kube_vpa_info 1, got %v", got)
+	}
+
+	h := families[0].GetMetric()[0].GetHistogram()
+	if h.GetSampleCount() != 3 || h.GetSampleSum() != 2.5 {
+		t.Errorf("want 3 observations summing up to 2.5, got %d summing up to %v", h.GetSampleCount(), h.GetSampleSum())
+	}
+	if len(h.GetBucket()) != 1 || h.GetBucket()[0].GetUpperBound() != 1 || h.GetBucket()[0].GetCumulativeCount() != 2 {
+		t.Errorf("want 2 observations in the classic bucket le=1, got %v", h.GetBucket())
+	}
+
+	// The native histogram fields are unknown to the client_model version
+	// in use, so they are decoded by hand.
+	native := map[protowire.Number][]uint64{}
+	unknown := h.XXX_unrecognized
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		unknown = unknown[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(unknown)
+			native[num] = append(native[num], v)
+			unknown = unknown[n:]
+		case protowire.BytesType:
+			// Only the length of messages is compared.
+			v, n := protowire.ConsumeBytes(unknown)
+			native[num] = append(native[num], uint64(len(v)))
+			unknown = unknown[n:]
+		default:
+			v, n := protowire.ConsumeFixed64(unknown)
+			native[num] = append(native[num], v)
+			unknown = unknown[n:]
+		}
+	}
+	want := map[protowire.Number][]uint64{
+		// The schema, the width of the zero bucket and its count.
+		5: {protowire.EncodeZigZag(nativeHistogramSchema)},
+		6: {math.Float64bits(nativeHistogramZeroThreshold)},
+		7: {1},
+		// Two spans, of buckets -8 and 8, with a count of 1 each.
+		12: {4, 4},
+		13: {protowire.EncodeZigZag(1), protowire.EncodeZigZag(0)},
+	}
+	if !reflect.DeepEqual(native, want) {
+		t.Errorf("want native histogram fields %v, got %v", want, native)
+	}
+
+	// Without native histograms the text format is served.
+	m.metricsWriters = m.metricsWriters[:1]
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("want the text format without native histograms, got %q", got)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricshandler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/klog/v2"
+)
+
+// PushJob is the job label of the metrics pushed to a Pushgateway.
+const PushJob = "kube-state-metrics"
+
+// Gather implements the prometheus.Gatherer interface. It returns the same
+// metrics as ServeHTTP, sorted by name.
+func (m *MetricsHandler) Gather() ([]*dto.MetricFamily, error) {
+	metricFamilies, err := m.parseMetricFamilies()
+	if err != nil {
+		return nil, err
+	}
+	return sortMetricFamilies(metricFamilies), nil
+}
+
+// sortMetricFamilies returns the metric families sorted by name.
+func sortMetricFamilies(metricFamilies map[string]*dto.MetricFamily) []*dto.MetricFamily {
+	families := make([]*dto.MetricFamily, 0, len(metricFamilies))
+	for _, mf := range metricFamilies {
+		families = append(families, mf)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+	return families
+}
+
+// RunPush pushes the metrics to the Pushgateway at url every interval,
+// replacing the metrics pushed before with the same grouping labels, until
+// ctx is canceled. Failed pushes are logged and retried on the next
+// interval. Nothing is pushed until the stores of all enabled resources have
+// synced their initial list of objects.
+func (m *MetricsHandler) RunPush(ctx context.Context, url string, grouping map[string]string, interval time.Duration) error {
+	// A push must not take longer than the interval between pushes.
+	pusher := push.New(url, PushJob).Gatherer(m).Client(&http.Client{Timeout: interval})
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if !m.hasSynced() {
+			klog.V(4).Info("Skipping push to the Pushgateway, the stores have not synced yet")
+			continue
+		}
+		if err := pusher.Push(); err != nil {
+			klog.ErrorS(err, "Failed to push metrics to the Pushgateway", "url", url)
+		}
+	}
+}
+
+// hasSynced reports whether the stores of all enabled resources have synced
+// their initial list of objects.
+func (m *MetricsHandler) hasSynced() bool {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	if m.metricsWriters == nil {
+		return false
+	}
+	for _, w := range m.metricsWriters {
+		if !w.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
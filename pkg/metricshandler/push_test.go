@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricshandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+func TestRunPush(t *testing.T) {
+	store := metricsstore.NewMetricsStore(
+		[]string{"# HELP kube_pod_info Information about pod.\n# TYPE kube_pod_info gauge"},
+		func(obj interface{}) []metric.FamilyInterface {
+			pod := obj.(*v1.Pod)
+			return []metric.FamilyInterface{&metric.Family{
+				Name: "kube_pod_info",
+				Metrics: []*metric.Metric{
+					{
+						LabelKeys:   []string{"namespace", "pod"},
+						LabelValues: []string{pod.Namespace, pod.Name},
+						Value:       1,
+					},
+				},
+			}}
+		},
+	)
+	if err := store.Replace([]interface{}{&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1", UID: "uid1"}}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	m := &MetricsHandler{
+		mtx:            &sync.RWMutex{},
+		metricsWriters: []metricsstore.MetricsWriter{metricsstore.NewMultiStoreMetricsWriter([]*metricsstore.MetricsStore{store})},
+	}
+
+	type request struct {
+		method, path string
+		families     []string
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{method: r.Method, path: r.URL.Path}
+		dec := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			var mf dto.MetricFamily
+			if err := dec.Decode(&mf); err != nil {
+				break
+			}
+			req.families = append(req.families, mf.GetName())
+		}
+		select {
+		case requests <- req:
+		default:
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.RunPush(ctx, server.URL, map[string]string{"cluster": "ci"}, 10*time.Millisecond)
+
+	select {
+	case req := <-requests:
+		if req.method != http.MethodPut {
+			t.Errorf("want method %s, got %s", http.MethodPut, req.method)
+		}
+		if want := "/metrics/job/kube-state-metrics/cluster/ci"; req.path != want {
+			t.Errorf("want path %s, got %s", want, req.path)
+		}
+		if len(req.families) != 1 || req.families[0] != "kube_pod_info" {
+			t.Errorf("want the kube_pod_info family to be pushed, got %v", req.families)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a push")
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricshandler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+	"k8s.io/klog/v2"
+
+	"k8s.io/kube-state-metrics/v2/pkg/version"
+)
+
+const (
+	// remoteWriteMinBackoff and remoteWriteMaxBackoff bound the delay
+	// between the retries of a failed remote write request.
+	remoteWriteMinBackoff = time.Second
+	remoteWriteMaxBackoff = 30 * time.Second
+)
+
+// remoteWriteLabel, remoteWriteSample and remoteWriteSeries mirror
+// prompb.Label, prompb.Sample and prompb.TimeSeries of the Prometheus remote
+// write protocol. They are encoded by hand, as the prompb package would pull
+// in the dependencies of the whole Prometheus module.
+type remoteWriteLabel struct {
+	name, value string
+}
+
+type remoteWriteSample struct {
+	value     float64
+	timestamp int64
+}
+
+type remoteWriteSeries struct {
+	labels  []remoteWriteLabel
+	samples []remoteWriteSample
+}
+
+// marshalWriteRequest encodes the series as prompb.WriteRequest protobuf
+// message.
+func marshalWriteRequest(series []remoteWriteSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+		for _, smpl := range s.samples {
+			var sb []byte
+			sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+			sb = protowire.AppendFixed64(sb, math.Float64bits(smpl.value))
+			sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(smpl.timestamp))
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, sb)
+		}
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
+
+// toRemoteWriteSeries converts the metric families to remote write series
+// with a single sample at the given timestamp in milliseconds. Histograms are
+// split into their _bucket, _sum and _count series. The labels of each
+// series are sorted by name, as required by the protocol.
+func toRemoteWriteSeries(families []*dto.MetricFamily, timestamp int64) []remoteWriteSeries {
+	var series []remoteWriteSeries
+	add := func(name string, labels []*dto.LabelPair, value float64, extra ...remoteWriteLabel) {
+		ls := make([]remoteWriteLabel, 0, len(labels)+len(extra)+1)
+		ls = append(ls, remoteWriteLabel{name: "__name__", value: name})
+		for _, l := range labels {
+			ls = append(ls, remoteWriteLabel{name: l.GetName(), value: l.GetValue()})
+		}
+		ls = append(ls, extra...)
+		sort.Slice(ls, func(i, j int) bool {
+			return ls[i].name < ls[j].name
+		})
+		series = append(series, remoteWriteSeries{
+			labels:  ls,
+			samples: []remoteWriteSample{{value: value, timestamp: timestamp}},
+		})
+	}
+
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			switch {
+			case m.Counter != nil:
+				add(name, m.GetLabel(), m.GetCounter().GetValue())
+			case m.Gauge != nil:
+				add(name, m.GetLabel(), m.GetGauge().GetValue())
+			case m.Histogram != nil:
+				h := m.GetHistogram()
+				infSeen := false
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), +1) {
+						infSeen = true
+					}
+					add(name+"_bucket", m.GetLabel(), float64(b.GetCumulativeCount()), remoteWriteLabel{name: "le", value: formatUpperBound(b.GetUpperBound())})
+				}
+				if !infSeen {
+					add(name+"_bucket", m.GetLabel(), float64(h.GetSampleCount()), remoteWriteLabel{name: "le", value: "+Inf"})
+				}
+				add(name+"_sum", m.GetLabel(), h.GetSampleSum())
+				add(name+"_count", m.GetLabel(), float64(h.GetSampleCount()))
+			default:
+				add(name, m.GetLabel(), m.GetUntyped().GetValue())
+			}
+		}
+	}
+	return series
+}
+
+// remoteWriteBatches splits the series into batches of at most maxSamples
+// samples each, keeping the samples of a series together. A series with more
+// samples than that is sent in a batch of its own.
+func remoteWriteBatches(series []remoteWriteSeries, maxSamples int) [][]remoteWriteSeries {
+	var batches [][]remoteWriteSeries
+	start, samples := 0, 0
+	for i, s := range series {
+		if i > start && samples+len(s.samples) > maxSamples {
+			batches = append(batches, series[start:i])
+			start, samples = i, 0
+		}
+		samples += len(s.samples)
+	}
+	if start < len(series) {
+		batches = append(batches, series[start:])
+	}
+	return batches
+}
+
+func formatUpperBound(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// RunRemoteWrite sends the metrics to the Prometheus remote write endpoint at
+// url every interval until ctx is canceled. All samples carry the time the
+// metrics were gathered at. If bearerTokenFile is set, its content is sent as
+// bearer token, re-read on every request to pick up rotated tokens. The
+// samples are split into requests of at most maxSamplesPerSend samples each,
+// so that no request exceeds the size limits of the endpoint. Failed
+// requests are retried with exponential backoff until the next interval is
+// due, except for client errors, which are logged and dropped. Nothing is
+// sent until the stores of all enabled resources have synced their initial
+// list of objects.
+func (m *MetricsHandler) RunRemoteWrite(ctx context.Context, url, bearerTokenFile string, interval time.Duration, maxSamplesPerSend int) error {
+	client := &http.Client{Timeout: interval}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if !m.hasSynced() {
+			klog.V(4).Info("Skipping remote write, the stores have not synced yet")
+			continue
+		}
+		families, err := m.Gather()
+		if err != nil {
+			klog.ErrorS(err, "Failed to gather metrics for remote write")
+			continue
+		}
+		series := toRemoteWriteSeries(families, time.Now().UnixNano()/int64(time.Millisecond))
+
+		deadline := time.Now().Add(interval)
+		for _, batch := range remoteWriteBatches(series, maxSamplesPerSend) {
+			body := snappy.Encode(nil, marshalWriteRequest(batch))
+			if err := sendRemoteWrite(ctx, client, url, bearerTokenFile, body, deadline); err != nil {
+				klog.ErrorS(err, "Failed to send metrics to the remote write endpoint", "url", url, "series", len(batch))
+			}
+		}
+	}
+}
+
+// sendRemoteWrite sends the snappy compressed write request, retrying
+// recoverable failures with exponential backoff until the deadline.
+func sendRemoteWrite(ctx context.Context, client *http.Client, url, bearerTokenFile string, body []byte, deadline time.Time) error {
+	backoff := remoteWriteMinBackoff
+	for {
+		recoverable, err := postRemoteWrite(ctx, client, url, bearerTokenFile, body)
+		if err == nil || !recoverable {
+			return err
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return errors.Wrap(err, "giving up until the next interval")
+		}
+		klog.V(4).InfoS("Retrying remote write", "url", url, "backoff", backoff, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > remoteWriteMaxBackoff {
+			backoff = remoteWriteMaxBackoff
+		}
+	}
+}
+
+// postRemoteWrite sends a single remote write request. It reports whether a
+// failure is recoverable, i.e. a connection error, a server error or the
+// endpoint asking to slow down.
+func postRemoteWrite(ctx context.Context, client *http.Client, url, bearerTokenFile string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "create remote write request")
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "kube-state-metrics/"+version.Release)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if bearerTokenFile != "" {
+		token, err := os.ReadFile(bearerTokenFile)
+		if err != nil {
+			return false, errors.Wrap(err, "read bearer token file")
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests, err
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricshandler
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestToRemoteWriteSeries(t *testing.T) {
+	var parser expfmt.TextParser
+	metricFamilies, err := parser.TextToMetricFamilies(strings.NewReader(`# HELP kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target Target resources the VerticalPodAutoscaler recommends for the container.
+# TYPE kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target gauge
+kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target{verticalpodautoscaler="vpa1",resource="cpu"} 0.5
+# HELP kube_verticalpodautoscaler_status_recommendation_target_cpu_cores Distribution of the cpu targets.
+# TYPE kube_verticalpodautoscaler_status_recommendation_target_cpu_cores histogram
+kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="1"} 1
+kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket{le="+Inf"} 2
+kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum 2.5
+kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_count 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	families := sortMetricFamilies(metricFamilies)
+
+	got := toRemoteWriteSeries(families, 1000)
+	want := []remoteWriteSeries{
+		{
+			labels: []remoteWriteLabel{
+				{"__name__", "kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target"},
+				{"resource", "cpu"},
+				{"verticalpodautoscaler", "vpa1"},
+			},
+			samples: []remoteWriteSample{{0.5, 1000}},
+		},
+		{
+			labels:  []remoteWriteLabel{{"__name__", "kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket"}, {"le", "1"}},
+			samples: []remoteWriteSample{{1, 1000}},
+		},
+		{
+			labels:  []remoteWriteLabel{{"__name__", "kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_bucket"}, {"le", "+Inf"}},
+			samples: []remoteWriteSample{{2, 1000}},
+		},
+		{
+			labels:  []remoteWriteLabel{{"__name__", "kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum"}},
+			samples: []remoteWriteSample{{2.5, 1000}},
+		},
+		{
+			labels:  []remoteWriteLabel{{"__name__", "kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_count"}},
+			samples: []remoteWriteSample{{2, 1000}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want series\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestSendRemoteWrite(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	series := []remoteWriteSeries{{
+		labels:  []remoteWriteLabel{{"__name__", "kube_verticalpodautoscaler_labels"}},
+		samples: []remoteWriteSample{{1, 1000}},
+	}}
+	body := snappy.Encode(nil, marshalWriteRequest(series))
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("want bearer token, got %q", got)
+		}
+		if got := r.Header.Get("Content-Encoding"); got != "snappy" {
+			t.Errorf("want snappy encoding, got %q", got)
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		req, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("failed to decode the request: %v", err)
+			return
+		}
+		if got := decodeFirstLabel(t, req); got != "kube_verticalpodautoscaler_labels" {
+			t.Errorf("want the metric name as first label, got %q", got)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := sendRemoteWrite(context.Background(), server.Client(), server.URL, tokenFile, body, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("want the failed request to be retried once, got %d requests", requests)
+	}
+
+	// Client errors are not retried.
+	requests = 0
+	badRequest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer badRequest.Close()
+	if err := sendRemoteWrite(context.Background(), badRequest.Client(), badRequest.URL, "", body, time.Now().Add(time.Minute)); err == nil {
+		t.Error("expected an error for a bad request")
+	}
+	if requests != 1 {
+		t.Errorf("want a single request, got %d", requests)
+	}
+}
+
+func TestRemoteWriteBatches(t *testing.T) {
+	series := func(samples ...int) []remoteWriteSeries {
+		ss := make([]remoteWriteSeries, len(samples))
+		for i, n := range samples {
+			ss[i].samples = make([]remoteWriteSample, n)
+		}
+		return ss
+	}
+	samplesOf := func(batches [][]remoteWriteSeries) [][]int {
+		var samples [][]int
+		for _, batch := range batches {
+			var ns []int
+			for _, s := range batch {
+				ns = append(ns, len(s.samples))
+			}
+			samples = append(samples, ns)
+		}
+		return samples
+	}
+
+	tests := []struct {
+		samples    []int
+		maxSamples int
+		want       [][]int
+	}{
+		{maxSamples: 2},
+		{samples: []int{1, 1}, maxSamples: 2, want: [][]int{{1, 1}}},
+		{samples: []int{1, 1, 1, 1, 1}, maxSamples: 2, want: [][]int{{1, 1}, {1, 1}, {1}}},
+		// A series is never split, even if it exceeds the maximum.
+		{samples: []int{1, 3, 1, 1}, maxSamples: 2, want: [][]int{{1}, {3}, {1, 1}}},
+	}
+	for i, test := range tests {
+		if got := samplesOf(remoteWriteBatches(series(test.samples...), test.maxSamples)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: want batches %v, got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestMarshalWriteRequestValue(t *testing.T) {
+	req := marshalWriteRequest([]remoteWriteSeries{{samples: []remoteWriteSample{{math.Inf(1), 5}}}})
+	ts := consumeBytes(t, req)
+	sample := consumeBytes(t, ts)
+	_, _, n := protowire.ConsumeTag(sample)
+	v, _ := protowire.ConsumeFixed64(sample[n:])
+	if !math.IsInf(math.Float64frombits(v), 1) {
+		t.Errorf("want +Inf, got %v", math.Float64frombits(v))
+	}
+}
+
+// decodeFirstLabel returns the value of the first label of the first series
+// of the write request.
+func decodeFirstLabel(t *testing.T, req []byte) string {
+	label := consumeBytes(t, consumeBytes(t, req))
+	_, _, n := protowire.ConsumeTag(label)
+	label = label[n:]
+	_, n = protowire.ConsumeBytes(label)
+	label = label[n:]
+	_, _, n = protowire.ConsumeTag(label)
+	value, _ := protowire.ConsumeBytes(label[n:])
+	return string(value)
+}
+
+// consumeBytes returns the first length-delimited field of the message.
+func consumeBytes(t *testing.T, b []byte) []byte {
+	_, typ, n := protowire.ConsumeTag(b)
+	if n < 0 || typ != protowire.BytesType {
+		t.Fatalf("unexpected field of type %v", typ)
+	}
+	v, m := protowire.ConsumeBytes(b[n:])
+	if m < 0 {
+		t.Fatal(protowire.ParseError(m))
+	}
+	return v
+}
@@ -19,7 +19,9 @@ package options
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"k8s.io/klog/v2"
 
@@ -29,17 +31,25 @@ import (
 // Options are the configurable parameters for kube-state-metrics.
 type Options struct {
 	Apiserver            string
+	APIServerTimeout     time.Duration
 	Kubeconfig           string
+	VPAKubeconfig        string
+	VPAContext           string
+	ClientCAFile         string
 	Help                 bool
 	Port                 int
 	Host                 string
+	MetricsSocket        string
 	TelemetryPort        int
 	TelemetryHost        string
+	TelemetrySocket      string
 	TLSConfig            string
 	Resources            ResourceSet
 	Namespaces           NamespaceList
+	NamespacesDenylist   NamespaceList
 	Shard                int32
 	TotalShards          int
+	ShardBy              string
 	Pod                  string
 	Namespace            string
 	MetricDenylist       MetricSet
@@ -47,11 +57,79 @@ type Options struct {
 	Version              bool
 	AnnotationsAllowList LabelsAllowList
 	LabelsAllowList      LabelsAllowList
+	DefaultLabels        LabelsAllowList
+	MetricPrefix         string
+	VPATargetKinds       KindSet
+	FieldSelectors       FieldSelectors
+	ObjectNames          ObjectNames
+	ObjectLabelSelector  string
+
+	VPAZeroMissingResources  bool
+	VPAPreciseCPU            bool
+	VPAMemoryUnit            string
+	VPARecommenderAnnotation string
+	VPAObservedContainers    string
+	VPARecommenderLabel      string
+	VPARecommendationSums    bool
+	VPAUpdateModeCount       bool
+	VPANamespaceCount        bool
+	VPARecommendationBuckets []float64
+	VPANativeHistogram       bool
+	VPARecommendationBounds  string
+	VPAOwnerReferences       bool
+	VPAContainerDenylist     []string
+	VPATargetReplicas        bool
+	VPAHPAConflict           bool
+	VPAOmitEmptyLabels       bool
+	VPASkipOffModeRecs       bool
 
 	EnableGZIPEncoding bool
+	EnablePprof        bool
+	RoutePrefix        string
+
+	ServerReadTimeout         time.Duration
+	ServerReadHeaderTimeout   time.Duration
+	ServerWriteTimeout        time.Duration
+	ServerIdleTimeout         time.Duration
+	ServerMaxHeaderBytes      int
+	ServerHTTP2               bool
+	ServerShutdownGracePeriod time.Duration
 
 	UseAPIServerCache bool
 
+	EnableUIDLabel             bool
+	EnableResourceVersionLabel bool
+	ClusterName                string
+
+	EnableObjectSeriesCount bool
+
+	ValidateConfig bool
+
+	MetricOverridesConfig string
+
+	SeriesFilter     string
+	LabelRenames     map[string]string
+	DropZeroStateSet bool
+
+	EnableLegacyMetricAliases bool
+	MaxSeries                 int
+	GenerationConcurrency     int
+	StoreQueueDepth           int
+
+	MaxLabelValueLength int
+
+	PushTo       string
+	PushInterval time.Duration
+	PushGrouping map[string]string
+
+	RemoteWriteURL             string
+	RemoteWriteInterval        time.Duration
+	RemoteWriteBearerTokenFile string
+	RemoteWriteMaxSamples      int
+
+	LogFormat string
+	LogLevel  string
+
 	flags *pflag.FlagSet
 }
 
@@ -63,6 +141,10 @@ func NewOptions() *Options {
 		MetricDenylist:       MetricSet{},
 		AnnotationsAllowList: LabelsAllowList{},
 		LabelsAllowList:      LabelsAllowList{},
+		DefaultLabels:        LabelsAllowList{},
+		VPATargetKinds:       KindSet{},
+		FieldSelectors:       FieldSelectors{},
+		ObjectNames:          ObjectNames{},
 	}
 }
 
@@ -82,30 +164,94 @@ func (o *Options) AddFlags() {
 		o.flags.PrintDefaults()
 	}
 
+	o.flags.BoolVar(&o.ValidateConfig, "validate-config", false, "Validate the flags and the metric families they configure without connecting to the apiserver, then exit. It exits non-zero on invalid resources, allowlists, denylists, regular expressions or default labels and on metric families exposed more than once.")
+	o.flags.BoolVar(&o.EnableObjectSeriesCount, "enable-object-series-count", false, "Reports the number of series each object produces in kube_state_metrics_object_series_count on the telemetry port, to help hunting down objects driving the cardinality. This adds a series per object itself.")
+	o.flags.StringVar(&o.ClusterName, "cluster-name", "", "Name of the cluster all metrics carry as cluster label, to tell apart the objects of several clusters scraped into the same Prometheus without relabeling. Metrics already carrying a cluster label are left as is. No label is added if empty.")
+	o.flags.BoolVar(&o.EnableUIDLabel, "enable-uid-label", false, "Adds a uid label holding the UID of the object to all metrics, except the ones already carrying it. This raises the cardinality of objects which are recreated under the same name.")
+	o.flags.BoolVar(&o.EnableResourceVersionLabel, "enable-resource-version-label", false, "DEBUGGING ONLY: Adds a resource_version label holding the resource version of the object to all metrics, except the ones already carrying it, to chase stale metrics. Every update of an object creates a new set of series, so the cardinality grows without bounds in Prometheus. Never enable it for longer than a debugging session.")
 	o.flags.BoolVarP(&o.UseAPIServerCache, "use-apiserver-cache", "", false, "Sets resourceVersion=0 for ListWatch requests, using cached resources from the apiserver instead of an etcd quorum read.")
 	o.flags.StringVar(&o.Apiserver, "apiserver", "", `The URL of the apiserver to use as a master`)
+	o.flags.DurationVar(&o.APIServerTimeout, "apiserver-request-timeout", 0, "Timeout of list requests against the apiserver. Watch requests last until kube-state-metrics shuts down or reconfigures its shards. A timeout of 0 disables it.")
 	o.flags.StringVar(&o.Kubeconfig, "kubeconfig", "", "Absolute path to the kubeconfig file")
-	o.flags.StringVar(&o.TLSConfig, "tls-config", "", "Path to the TLS configuration file")
+	o.flags.StringVar(&o.VPAKubeconfig, "vpa-kubeconfig", "", "Absolute path to the kubeconfig file of the apiserver serving VerticalPodAutoscalers, if it differs from the one of the core resources. Defaults to --kubeconfig.")
+	o.flags.StringVar(&o.VPAContext, "vpa-context", "", "Kubeconfig context of the apiserver serving VerticalPodAutoscalers, if it differs from the one of the core resources. Defaults to the current context.")
+	o.flags.StringVar(&o.ClientCAFile, "client-ca-file", "", "Path to a PEM encoded CA bundle the clients trust when connecting to the apiserver and to the one serving VerticalPodAutoscalers, in addition to the CA of the kubeconfig or the in-cluster service account, e.g. for corporate CAs fronting the apiserver. If neither sets a CA, the bundle is trusted in addition to the system roots.")
+	o.flags.StringVar(&o.TLSConfig, "tls-config", "", "Path to the TLS configuration file. The file and the certificates it references are read again for every new connection, so rotated certificates are picked up without a restart.")
 	o.flags.BoolVarP(&o.Help, "help", "h", false, "Print Help text")
 	o.flags.IntVar(&o.Port, "port", 8080, `Port to expose metrics on.`)
-	o.flags.StringVar(&o.Host, "host", "::", `Host to expose metrics on.`)
+	o.flags.StringVar(&o.Host, "host", "::", `Comma-separated list of hosts to expose metrics on, e.g. '0.0.0.0,::' to listen on IPv4 and IPv6 separately.`)
+	o.flags.StringVar(&o.MetricsSocket, "metrics-socket", "", `Path of a Unix domain socket to expose metrics on, in addition to the TCP listeners. A --port of 0 disables the TCP listeners, to only expose metrics on the socket. A socket left over at the path is replaced.`)
+	o.flags.DurationVar(&o.ServerReadTimeout, "server-read-timeout", 60*time.Second, "Maximum duration for reading an entire request to the metrics server, including the body. A timeout of 0 disables it.")
+	o.flags.DurationVar(&o.ServerReadHeaderTimeout, "server-read-header-timeout", 5*time.Second, "Maximum duration for reading the headers of a request to the metrics server. It protects against clients opening connections without completing their requests. A timeout of 0 falls back to --server-read-timeout.")
+	o.flags.DurationVar(&o.ServerWriteTimeout, "server-write-timeout", 60*time.Second, "Maximum duration of writing a response of the metrics server. It has to cover the scrape of the largest payload. A timeout of 0 disables it.")
+	o.flags.DurationVar(&o.ServerIdleTimeout, "server-idle-timeout", 5*time.Minute, "Maximum duration the metrics server keeps an idle keep-alive connection open for the next request. A timeout of 0 falls back to --server-read-timeout.")
+	o.flags.IntVar(&o.ServerMaxHeaderBytes, "server-max-header-bytes", http.DefaultMaxHeaderBytes, "Maximum size in bytes of the request headers the metrics server reads.")
+	o.flags.DurationVar(&o.ServerShutdownGracePeriod, "server-shutdown-grace-period", 10*time.Second, "Maximum duration the metrics and telemetry servers wait for in-flight requests on SIGINT or SIGTERM, after they stopped accepting new ones. The reflectors are only stopped once the servers are drained.")
+	o.flags.BoolVar(&o.ServerHTTP2, "server-http2", false, "Serve HTTP/2 without TLS on the metrics server, for clients starting connections with the HTTP/2 preface or an h2c upgrade. With --tls-config, HTTP/2 is configured by http_server_config.http2 of the TLS configuration file instead.")
+	o.flags.StringVar(&o.MetricOverridesConfig, "metric-overrides-config", "", "Path to a YAML file mapping metric family names, including the metric prefix, to a help text and unit overriding their defaults, e.g. 'kube_pod_info: {help: \"...\"}'. The unit is only exposed in the OpenMetrics text format and must be a suffix of the name. Unknown families and invalid units are ignored with a warning.")
+	o.flags.StringVar(&o.SeriesFilter, "series-filter", "", "Semicolon-separated list of selectors of the series to drop, each one a regular expression matching the whole metric family name, including the metric prefix, followed by optional predicates on the labels and the value in braces which all have to hold (Example: 'kube_verticalpodautoscaler_spec_updatepolicy_updatemode{value==0};kube_pod_.*{namespace=\"kube-system\"}'). Labels are compared with =, !=, =~ and !~ against a quoted string, the value with ==, !=, <, <=, > and >= against a number.")
+	o.flags.StringToStringVar(&o.LabelRenames, "label-renames", nil, "Comma-separated list of label keys of all metrics to rename, each one mapped to its new key (Example: 'namespace=k8s_namespace,pod=k8s_pod'). Labels are renamed once the series carry all their labels, so --series-filter has to select them by their new keys. Renaming two labels to the same key, or a label to the key of another label of the same metric, is rejected on startup. Series whose renamed labels would collide with a label converted from the labels or annotations of an object are left untouched.")
+	o.flags.BoolVar(&o.DropZeroStateSet, "drop-zero-stateset", false, "Expose only the active series, set to 1, of the metric families exposing a state set, e.g. kube_verticalpodautoscaler_spec_updatepolicy_updatemode, dropping the series of the inactive values set to 0. This breaks the contract that all values of a state set are present, so queries have to treat missing series as 0.")
+	o.flags.IntVar(&o.MaxLabelValueLength, "max-label-value-length", 0, "Maximum length in bytes of the values of the labels converted from Kubernetes labels and annotations, e.g. by --metric-annotations-allowlist. Longer values are truncated and end with '...'. A length of 0 disables it.")
+	o.flags.StringVar(&o.LogFormat, "log-format", "text", "Format of the log messages, one of text or json. The text format at info level keeps the klog output, the other combinations write one structured message per line.")
+	o.flags.StringVar(&o.LogLevel, "log-level", "info", "Minimum level of the log messages, one of debug, info, warn or error. The debug level raises the klog verbosity to at least 4. Except for the text format at info level, warnings of client libraries are logged at the info level.")
 	o.flags.IntVar(&o.TelemetryPort, "telemetry-port", 8081, `Port to expose kube-state-metrics self metrics on.`)
 	o.flags.StringVar(&o.TelemetryHost, "telemetry-host", "::", `Host to expose kube-state-metrics self metrics on.`)
+	o.flags.StringVar(&o.TelemetrySocket, "telemetry-socket", "", `Path of a Unix domain socket to expose kube-state-metrics self metrics on, in addition to the TCP listener. A --telemetry-port of 0 disables the TCP listener, to only expose self metrics on the socket. A socket left over at the path is replaced.`)
 	o.flags.Var(&o.Resources, "resources", fmt.Sprintf("Comma-separated list of Resources to be enabled. Defaults to %q", &DefaultResources))
 	o.flags.Var(&o.Namespaces, "namespaces", fmt.Sprintf("Comma-separated list of namespaces to be enabled. Defaults to %q", &DefaultNamespaces))
-	o.flags.Var(&o.MetricAllowlist, "metric-allowlist", "Comma-separated list of metrics to be exposed. This list comprises of exact metric names and/or regex patterns. The allowlist and denylist are mutually exclusive.")
-	o.flags.Var(&o.MetricDenylist, "metric-denylist", "Comma-separated list of metrics not to be enabled. This list comprises of exact metric names and/or regex patterns. The allowlist and denylist are mutually exclusive.")
-	o.flags.Var(&o.AnnotationsAllowList, "metric-annotations-allowlist", "Comma-separated list of Kubernetes annotations keys that will be used in the resource' labels metric. By default the metric contains only name and namespace labels. To include additional annotations provide a list of resource names in their plural form and Kubernetes annotation keys you would like to allow for them (Example: '=namespaces=[kubernetes.io/team,...],pods=[kubernetes.io/team],...)'. A single '*' can be provided per resource instead to allow any annotations, but that has severe performance implications (Example: '=pods=[*]').")
-	o.flags.Var(&o.LabelsAllowList, "metric-labels-allowlist", "Comma-separated list of additional Kubernetes label keys that will be used in the resource' labels metric. By default the metric contains only name and namespace labels. To include additional labels provide a list of resource names in their plural form and Kubernetes label keys you would like to allow for them (Example: '=namespaces=[k8s-label-1,k8s-label-n,...],pods=[app],...)'. A single '*' can be provided per resource instead to allow any labels, but that has severe performance implications (Example: '=pods=[*]').")
+	o.flags.Var(&o.NamespacesDenylist, "namespaces-denylist", "Comma-separated list of namespaces not to be enabled. The denylist only applies when all namespaces are enabled, objects in those namespaces are excluded from all namespaced resources.")
+	o.flags.Var(&o.FieldSelectors, "field-selectors", "Comma-separated list of namespaced resources in their plural form and the field selectors narrowing their list and watch requests on the server side (Example: '=verticalpodautoscalers=[metadata.namespace!=kube-system,metadata.name!=foo],pods=[spec.nodeName=node1]'). They are combined with the namespaces denylist. Selectors rejected by the apiserver are dropped with a warning and the resource is listed without them.")
+	o.flags.Var(&o.ObjectNames, "object-names", "Comma-separated list of resources in their plural form and the name of the single object they are restricted to, e.g. to debug a specific object in combination with --namespaces (Example: 'verticalpodautoscalers=vpa1').")
+	o.flags.StringVar(&o.ObjectLabelSelector, "object-label-selector", "", "Label selector of the list and watch requests of all resources, so that only the metrics of the objects matching it are exposed (Example: 'monitoring=enabled'). The objects are filtered on the server side, cutting the memory of kube-state-metrics. It applies to all resources including verticalpodautoscalers, namespaces and nodes.")
+	o.flags.Var(&o.MetricAllowlist, "metric-allowlist", "Comma-separated list of metrics to be exposed. This list comprises of exact metric names, regex patterns and/or glob patterns matching whole names (Example: 'kube_verticalpodautoscaler_status_recommendation_*'). The allowlist and denylist are mutually exclusive.")
+	o.flags.Var(&o.MetricDenylist, "metric-denylist", "Comma-separated list of metrics not to be enabled. This list comprises of exact metric names, regex patterns and/or glob patterns matching whole names (Example: 'kube_verticalpodautoscaler_status_recommendation_*'). The allowlist and denylist are mutually exclusive.")
+	o.flags.Var(&o.AnnotationsAllowList, "metric-annotations-allowlist", "Comma-separated list of Kubernetes annotations keys that will be used in the resource' labels metric. By default the metric contains only name and namespace labels. To include additional annotations provide a list of resource names in their plural form and Kubernetes annotation keys you would like to allow for them (Example: '=namespaces=[kubernetes.io/team,...],pods=[kubernetes.io/team],...)'. A single '*' can be provided per resource instead to allow any annotations, but that has severe performance implications (Example: '=pods=[*]'). The kubectl.kubernetes.io/last-applied-configuration annotation is never exposed by the wildcard. Annotation keys wrapped in slashes are interpreted as anchored regular expressions (Example: '=pods=[/team\\..*/]').")
+	o.flags.Var(&o.LabelsAllowList, "metric-labels-allowlist", "Comma-separated list of additional Kubernetes label keys that will be used in the resource' labels metric. By default the metric contains only name and namespace labels. To include additional labels provide a list of resource names in their plural form and Kubernetes label keys you would like to allow for them (Example: '=namespaces=[k8s-label-1,k8s-label-n,...],pods=[app],...)'. A single '*' can be provided per resource instead to allow any labels, but that has severe performance implications (Example: '=pods=[*]'). Label keys wrapped in slashes are interpreted as anchored regular expressions (Example: '=pods=[/team\\..*/]').")
+	o.flags.Var(&o.DefaultLabels, "default-labels", "Comma-separated list of resources in their plural form and the labels all their metrics start with, replacing the default ones (Example: '=verticalpodautoscalers=[namespace,verticalpodautoscaler,uid,target_kind,target_name]'). Only verticalpodautoscalers are supported, with the labels namespace, verticalpodautoscaler, uid, target_api_version, target_kind and target_name.")
+	o.flags.StringVar(&o.MetricPrefix, "metric-prefix", "kube_", "Prefix of the exposed metric names, replacing the default 'kube_' prefix. The metric allowlist and denylist are matched against the prefixed metric names.")
+	o.flags.Var(&o.VPATargetKinds, "vpa-target-kinds", "Comma-separated list of target kinds of the VerticalPodAutoscalers to expose metrics for (Example: 'Deployment,StatefulSet'). VerticalPodAutoscalers targeting other kinds are skipped. By default all VerticalPodAutoscalers are exposed.")
+	o.flags.BoolVar(&o.VPAZeroMissingResources, "vpa-zero-missing-resources", false, "Always expose the cpu and memory container recommendations of VerticalPodAutoscalers, as zero if they are missing from the recommendation. By default only the recommended resources are exposed.")
+	o.flags.BoolVar(&o.VPAPreciseCPU, "vpa-precise-cpu", false, "Expose the cpu resources of VerticalPodAutoscalers as precise fractions of cores. By default they are rounded to millicores.")
+	o.flags.StringVar(&o.VPAMemoryUnit, "vpa-memory-unit", "byte", "Unit of the memory and other resources of VerticalPodAutoscalers measured in bytes, one of byte or mebibyte. The unit label of their metrics is set accordingly.")
+	o.flags.StringVar(&o.VPAObservedContainers, "vpa-observed-containers-annotation", "vpaObservedContainers", "Annotation of VerticalPodAutoscalers listing the containers the recommender has observed, as comma-separated container names. kube_verticalpodautoscaler_status_observed_containers counts them, and is skipped for VerticalPodAutoscalers lacking the annotation. It is not exposed if empty.")
+	o.flags.StringVar(&o.VPARecommenderAnnotation, "vpa-recommender-annotation", "", "Annotation of VerticalPodAutoscalers holding the recommendations of each recommender as a JSON object keyed by recommender name, e.g. '{\"default\":{\"containerRecommendations\":[...]}}'. When set, kube_verticalpodautoscaler_status_recommendation_by_recommender exposes their targets, falling back to the status recommendation as the default recommender if the annotation is missing.")
+	o.flags.StringVar(&o.VPARecommenderLabel, "vpa-recommender-label-annotation", "", "Annotation of VerticalPodAutoscalers naming the recommender handling them, e.g. 'recommender'. When set, all kube_verticalpodautoscaler_* metrics carry its value as recommender label, empty if the annotation is missing, except kube_verticalpodautoscaler_status_recommendation_by_recommender, which labels each series with its recommender already.")
+	o.flags.BoolVar(&o.VPARecommendationSums, "vpa-recommendation-sums", false, "Expose the sums of the cpu and memory targets recommended across all VerticalPodAutoscalers of the shard, as kube_verticalpodautoscaler_status_recommendation_target_cpu_cores_sum and kube_verticalpodautoscaler_status_recommendation_target_memory_bytes_sum.")
+	o.flags.BoolVar(&o.VPAUpdateModeCount, "vpa-update-mode-count", false, "Expose the number of VerticalPodAutoscalers of the shard in each update mode as kube_verticalpodautoscaler_update_mode_count.")
+	o.flags.BoolVar(&o.VPANamespaceCount, "vpa-namespace-count", false, "Expose the number of VerticalPodAutoscalers of the shard in each namespace as kube_verticalpodautoscaler_namespace_count, to avoid counting the series of a VerticalPodAutoscaler family by namespace at query time.")
+	o.flags.Float64SliceVar(&o.VPARecommendationBuckets, "vpa-recommendation-cpu-buckets", nil, "Comma-separated list of upper bounds of the buckets of kube_verticalpodautoscaler_status_recommendation_target_cpu_cores, a histogram of the cpu targets recommended for the containers of all VerticalPodAutoscalers of the shard in cores (Example: '0.1,0.25,0.5,1,2,4'). The histogram is not exposed if empty.")
+	o.flags.BoolVar(&o.VPANativeHistogram, "vpa-recommendation-native-histogram", false, "Expose kube_verticalpodautoscaler_status_recommendation_target_cpu_cores as a native histogram along with its buckets to clients negotiating the protobuf exposition format, e.g. Prometheus with native histograms enabled. Has no effect without --vpa-recommendation-cpu-buckets.")
+	o.flags.StringVar(&o.VPARecommendationBounds, "vpa-recommendation-bounds", "separate", "Families exposing the bounds of the container recommendations of VerticalPodAutoscalers, one of separate, consolidated or both. The separate families expose one bound each, e.g. kube_verticalpodautoscaler_status_recommendation_containerrecommendations_target, while kube_verticalpodautoscaler_status_recommendation exposes all of them with a bound label.")
+	o.flags.BoolVar(&o.VPAOwnerReferences, "vpa-owner-references", false, "Expose the owner references of VerticalPodAutoscalers as kube_verticalpodautoscaler_owner, with one series per owner, to attribute VerticalPodAutoscalers created by operators.")
+	o.flags.StringSliceVar(&o.VPAContainerDenylist, "vpa-container-denylist", nil, "Comma-separated list of names or glob patterns of containers no VerticalPodAutoscaler recommendations are exposed for, e.g. to drop ubiquitous sidecars (Example: 'istio-proxy,linkerd-*').")
+	o.flags.BoolVar(&o.VPATargetReplicas, "vpa-target-replicas", false, "Expose the current number of replicas of the Deployment, StatefulSet, ReplicaSet or ReplicationController targeted by each VerticalPodAutoscaler as kube_verticalpodautoscaler_target_replicas. It requires the resource of the target to be enabled as well, and VerticalPodAutoscalers whose target is not watched are skipped. With sharding, it requires --shard-by=namespace.")
+	o.flags.BoolVar(&o.VPAHPAConflict, "vpa-hpa-conflict", false, "Expose whether a HorizontalPodAutoscaler scales the target of each VerticalPodAutoscaler on the usage of a resource the VerticalPodAutoscaler controls as kube_verticalpodautoscaler_hpa_conflict. It requires the horizontalpodautoscalers resource to be enabled as well, and only HorizontalPodAutoscalers watched by the same instance are considered, so with sharding --shard-by=namespace is required.")
+	o.flags.BoolVar(&o.VPAOmitEmptyLabels, "vpa-omit-empty-default-labels", false, "Omit the default labels of the VerticalPodAutoscaler metrics whose value is empty, e.g. the target_* labels of VerticalPodAutoscalers without targetRef, instead of exposing them blank. The label set of a series changes when the value is set or unset later on.")
+	o.flags.BoolVar(&o.VPASkipOffModeRecs, "vpa-skip-off-mode-recommendations", false, "Do not expose the container recommendations of VerticalPodAutoscalers whose update mode is Off, as they are merely advisory, to save cardinality. Their spec and status metrics are exposed nonetheless.")
 	o.flags.Int32Var(&o.Shard, "shard", int32(0), "The instances shard nominal (zero indexed) within the total number of shards. (default 0)")
 	o.flags.IntVar(&o.TotalShards, "total-shards", 1, "The total number of shards. Sharding is disabled when total shards is set to 1.")
+	o.flags.StringVar(&o.ShardBy, "shard-by", "uid", "Key objects are assigned to shards by, either uid or namespace. With namespace, all objects of a namespace are handled by the same shard, while objects without namespace are still assigned by their uid.")
 
 	autoshardingNotice := "When set, it is expected that --pod and --pod-namespace are both set. Most likely this should be passed via the downward API. This is used for auto-detecting sharding. If set, this has preference over statically configured sharding. This is experimental, it may be removed without notice."
 
 	o.flags.StringVar(&o.Pod, "pod", "", "Name of the pod that contains the kube-state-metrics container. "+autoshardingNotice)
 	o.flags.StringVar(&o.Namespace, "pod-namespace", "", "Name of the namespace of the pod specified by --pod. "+autoshardingNotice)
 	o.flags.BoolVarP(&o.Version, "version", "", false, "kube-state-metrics build version information")
-	o.flags.BoolVar(&o.EnableGZIPEncoding, "enable-gzip-encoding", false, "Gzip responses when requested by clients via 'Accept-Encoding: gzip' header.")
+	o.flags.BoolVar(&o.EnableLegacyMetricAliases, "enable-legacy-metric-aliases", false, "Expose renamed metric families under their former names as well, e.g. kube_hpa_spec_max_replicas next to kube_horizontalpodautoscaler_spec_max_replicas, to migrate dashboards and alerts. The aliases are deprecated and subject to the allowlist and denylist.")
+	o.flags.IntVar(&o.MaxSeries, "max-series", 0, "Maximum number of series kube-state-metrics holds as a last resort safeguard against running out of memory. Once exceeded, further metric families are dropped and counted in kube_state_metrics_series_dropped_total, so the exposed metrics are incomplete. 0 disables the limit.")
+	o.flags.IntVar(&o.GenerationConcurrency, "generation-concurrency", 1, "Number of workers generating the metrics of the objects of each resource when they are listed, e.g. on startup and relists. More than one worker generates the metrics before they replace the previous ones, so scrapes are not blocked meanwhile, at the expense of holding both in memory.")
+	o.flags.IntVar(&o.StoreQueueDepth, "store-queue-depth", 0, "Maximum number of objects of each store whose updates are queued to be applied by a worker of the store, reported as kube_state_metrics_queue_depth. Updates of queued objects are coalesced, and once the queue is full, watching the resource blocks until the worker caught up, which bounds the memory bursts of updates take. 0 applies the updates right away.")
+	o.flags.BoolVar(&o.EnablePprof, "enable-pprof", false, "Serve the pprof endpoints under /debug/pprof/ on the telemetry port, to profile kube-state-metrics itself. They are never served on the metrics port.")
+	o.flags.StringVar(&o.RoutePrefix, "route-prefix", "", "Prefix of the paths of all endpoints of the metrics and telemetry servers, e.g. /ksm to serve /ksm/metrics and /ksm/healthz behind a reverse proxy which does not strip it. The endpoints are served at the root if empty.")
+	o.flags.BoolVar(&o.EnableGZIPEncoding, "enable-gzip-encoding", false, "Gzip responses when requested by clients via 'Accept-Encoding: gzip' header. Other encodings such as zstd are not supported, responses to clients not accepting gzip are not compressed.")
+	o.flags.StringVar(&o.PushTo, "push-to", "", "URL of a Prometheus Pushgateway to periodically push the metrics to, in addition to serving them, e.g. for short-lived clusters which cannot be scraped (Example: 'http://pushgateway:9091'). The metrics are pushed with the job label kube-state-metrics. Pushing is disabled if empty.")
+	o.flags.DurationVar(&o.PushInterval, "push-interval", time.Minute, "Interval of pushing the metrics to the Pushgateway of --push-to.")
+	o.flags.StringToStringVar(&o.PushGrouping, "push-grouping", nil, "Comma-separated list of labels grouping the metrics pushed to the Pushgateway of --push-to, in addition to the job label (Example: 'cluster=ci,shard=0'). Each shard has to be pushed with a distinct grouping.")
+	o.flags.StringVar(&o.RemoteWriteURL, "remote-write-url", "", "URL of a Prometheus remote write endpoint to periodically send the metrics to, in addition to serving them (Example: 'https://prometheus:9090/api/v1/write'). Basic auth credentials can be given as part of the URL. Remote write is disabled if empty.")
+	o.flags.DurationVar(&o.RemoteWriteInterval, "remote-write-interval", time.Minute, "Interval of sending the metrics to the endpoint of --remote-write-url. Failed requests are retried with backoff until the next interval is due.")
+	o.flags.StringVar(&o.RemoteWriteBearerTokenFile, "remote-write-bearer-token-file", "", "File holding the bearer token sent to the endpoint of --remote-write-url. It is read on every request to pick up rotated tokens. Other than basic auth as part of the URL, this is the only authentication supported for remote write.")
+	o.flags.IntVar(&o.RemoteWriteMaxSamples, "remote-write-max-samples-per-send", 2000, "Maximum number of samples sent to the endpoint of --remote-write-url per request. The metrics of each interval are split into as many requests as needed.")
 }
 
 // Parse parses the flag definitions from the argument list.
@@ -18,13 +18,20 @@ package options
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 )
 
-var errLabelsAllowListFormat = errors.New("invalid format, metric=[label1,label2,labeln...],metricN=[]")
+var (
+	errLabelsAllowListFormat = errors.New("invalid format, metric=[label1,label2,labeln...],metricN=[]")
+	errFieldSelectorsFormat  = errors.New("invalid format, resource=[field1=value1,field2!=value2...],resourceN=[]")
+	errObjectNamesFormat     = errors.New("invalid format, resource=name,resourceN=nameN")
+)
 
 // MetricSet represents a collection which has a unique set of metrics.
 type MetricSet map[string]struct{}
@@ -100,6 +107,43 @@ func (r *ResourceSet) Type() string {
 	return "string"
 }
 
+// KindSet represents a collection which has a unique set of object kinds.
+type KindSet map[string]struct{}
+
+func (k *KindSet) String() string {
+	s := *k
+	ss := s.AsSlice()
+	sort.Strings(ss)
+	return strings.Join(ss, ",")
+}
+
+// Set converts a comma-separated string of kinds into a slice and appends it to the KindSet.
+func (k *KindSet) Set(value string) error {
+	s := *k
+	kinds := strings.Split(value, ",")
+	for _, kind := range kinds {
+		kind = strings.TrimSpace(kind)
+		if len(kind) != 0 {
+			s[kind] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// AsSlice returns the KindSet in the form of a plain string slice.
+func (k KindSet) AsSlice() []string {
+	kinds := make([]string, 0, len(k))
+	for kind := range k {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// Type returns a descriptive string about the KindSet type.
+func (k *KindSet) Type() string {
+	return "string"
+}
+
 // NamespaceList represents a list of namespaces to query from.
 type NamespaceList []string
 
@@ -113,6 +157,21 @@ func (n *NamespaceList) IsAllNamespaces() bool {
 	return len(*n) == 1 && (*n)[0] == metav1.NamespaceAll
 }
 
+// GetExcludeNSFieldSelector returns a field selector excluding the given namespaces.
+// If nsDenylist = {case1,case2}, the result will be "metadata.namespace!=case1,metadata.namespace!=case2".
+// The denylist only applies when selecting across all namespaces, otherwise an empty selector is returned.
+func (n *NamespaceList) GetExcludeNSFieldSelector(nsDenylist []string) string {
+	if !n.IsAllNamespaces() {
+		return ""
+	}
+
+	namespaceExcludeSelectors := make([]fields.Selector, len(nsDenylist))
+	for i, ns := range nsDenylist {
+		namespaceExcludeSelectors[i] = fields.OneTermNotEqualSelector("metadata.namespace", ns)
+	}
+	return fields.AndSelectors(namespaceExcludeSelectors...).String()
+}
+
 // Set converts a comma-separated string of namespaces into a slice and appends it to the NamespaceList
 func (n *NamespaceList) Set(value string) error {
 	splitNamespaces := strings.Split(value, ",")
@@ -133,6 +192,25 @@ func (n *NamespaceList) Type() string {
 // LabelWildcard allowlists any label
 const LabelWildcard = "*"
 
+// LabelRegexDelimiter wraps allowlist entries which are interpreted as
+// anchored regular expressions instead of exact label keys, e.g. /team\..*/.
+const LabelRegexDelimiter = "/"
+
+// ParseLabelRegex returns the anchored regular expression of an allowlist
+// entry wrapped in LabelRegexDelimiter. ok is false for exact label keys.
+func ParseLabelRegex(entry string) (re *regexp.Regexp, ok bool, err error) {
+	if len(entry) < 2 || !strings.HasPrefix(entry, LabelRegexDelimiter) || !strings.HasSuffix(entry, LabelRegexDelimiter) {
+		return nil, false, nil
+	}
+
+	pattern := strings.TrimSuffix(strings.TrimPrefix(entry, LabelRegexDelimiter), LabelRegexDelimiter)
+	re, err = regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid regular expression %s: %w", entry, err)
+	}
+	return re, true, nil
+}
+
 // LabelsAllowList represents a list of allowed labels for metrics.
 type LabelsAllowList map[string][]string
 
@@ -140,6 +218,7 @@ type LabelsAllowList map[string][]string
 // Value is in the following format:
 // resource=[k8s-label-name,another-k8s-label],another-resource[k8s-label]
 // Example: pods=[app.kubernetes.io/component,app],resource=[blah]
+// Label names wrapped in slashes are regular expressions, e.g. pods=[/team\..*/]
 func (l *LabelsAllowList) Set(value string) error {
 	// Taken from text/scanner EOF constant.
 	const EOF = -1
@@ -197,6 +276,13 @@ func (l *LabelsAllowList) Set(value string) error {
 			firstWordPos = i + 1
 		}
 	}
+	for _, labels := range m {
+		for _, label := range labels {
+			if _, _, err := ParseLabelRegex(label); err != nil {
+				return err
+			}
+		}
+	}
 	*l = m
 	return nil
 }
@@ -221,3 +307,99 @@ func (l *LabelsAllowList) String() string {
 func (l *LabelsAllowList) Type() string {
 	return "string"
 }
+
+// FieldSelectors represents the field selectors applied to the list and watch
+// requests of individual resources.
+type FieldSelectors map[string]string
+
+// Set converts a comma-separated string of resources and their field selectors and appends to the FieldSelectors.
+// Value is in the following format:
+// resource=[field-selector],another-resource=[field-selector]
+// Example: verticalpodautoscalers=[metadata.namespace!=kube-system,metadata.name!=foo],pods=[spec.nodeName=node1]
+func (f *FieldSelectors) Set(value string) error {
+	m := make(map[string]string, len(*f))
+	for value != "" {
+		i := strings.Index(value, "=[")
+		if i <= 0 {
+			return errFieldSelectorsFormat
+		}
+		name := strings.TrimSpace(value[:i])
+		value = value[i+2:]
+
+		j := strings.Index(value, "]")
+		if j < 0 {
+			return errFieldSelectorsFormat
+		}
+		selector := strings.TrimSpace(value[:j])
+		if _, err := fields.ParseSelector(selector); err != nil {
+			return fmt.Errorf("invalid field selector for %s: %w", name, err)
+		}
+		m[name] = selector
+		value = value[j+1:]
+
+		if value == "" {
+			break
+		}
+		if value[0] != ',' || len(value) == 1 {
+			return errFieldSelectorsFormat
+		}
+		value = value[1:]
+	}
+	*f = m
+	return nil
+}
+
+func (f *FieldSelectors) String() string {
+	s := *f
+	ss := make([]string, 0, len(s))
+	for resource, selector := range s {
+		ss = append(ss, fmt.Sprintf("%s=[%s]", resource, selector))
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, ",")
+}
+
+// Type returns a descriptive string about the FieldSelectors type.
+func (f *FieldSelectors) Type() string {
+	return "string"
+}
+
+// ObjectNames represents the names of the single objects individual resources
+// are restricted to.
+type ObjectNames map[string]string
+
+// Set converts a comma-separated string of resources and object names and appends to the ObjectNames.
+// Value is in the following format:
+// resource=name,another-resource=name
+// Example: verticalpodautoscalers=vpa1,pods=pod1
+func (o *ObjectNames) Set(value string) error {
+	m := make(map[string]string, len(*o))
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return errObjectNamesFormat
+		}
+		m[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	*o = m
+	return nil
+}
+
+func (o *ObjectNames) String() string {
+	s := *o
+	ss := make([]string, 0, len(s))
+	for resource, name := range s {
+		ss = append(ss, fmt.Sprintf("%s=%s", resource, name))
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, ",")
+}
+
+// Type returns a descriptive string about the ObjectNames type.
+func (o *ObjectNames) Type() string {
+	return "string"
+}
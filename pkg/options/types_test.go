@@ -86,6 +86,41 @@ func TestNamespaceListSet(t *testing.T) {
 	}
 }
 
+func TestNamespaceListExcludeNSFieldSelector(t *testing.T) {
+	tests := []struct {
+		Desc       string
+		Namespaces NamespaceList
+		Denylist   NamespaceList
+		Wanted     string
+	}{
+		{
+			Desc:       "empty denylist",
+			Namespaces: DefaultNamespaces,
+			Denylist:   NamespaceList{},
+			Wanted:     "",
+		},
+		{
+			Desc:       "all namespaces with denylist",
+			Namespaces: DefaultNamespaces,
+			Denylist:   NamespaceList{"kube-system", "monitoring"},
+			Wanted:     "metadata.namespace!=kube-system,metadata.namespace!=monitoring",
+		},
+		{
+			Desc:       "namespaces with denylist",
+			Namespaces: NamespaceList{"default"},
+			Denylist:   NamespaceList{"kube-system"},
+			Wanted:     "",
+		},
+	}
+
+	for _, test := range tests {
+		got := test.Namespaces.GetExcludeNSFieldSelector(test.Denylist)
+		if got != test.Wanted {
+			t.Errorf("Test error for Desc: %s. Want: %+v. Got: %+v.", test.Desc, test.Wanted, got)
+		}
+	}
+}
+
 func TestMetricSetSet(t *testing.T) {
 	tests := []struct {
 		Desc   string
@@ -187,6 +222,21 @@ func TestLabelsAllowListSet(t *testing.T) {
 				},
 				"pods": {}}),
 		},
+		{
+			Desc:  "with regex",
+			Value: "pods=[/team\\..*/,app]",
+			Wanted: LabelsAllowList(map[string][]string{
+				"pods": {
+					"/team\\..*/",
+					"app",
+				}}),
+		},
+		{
+			Desc:   "[invalid] regex",
+			Value:  "pods=[/team(/]",
+			Wanted: LabelsAllowList(map[string][]string{}),
+			err:    true,
+		},
 		{
 			Desc:  "with wildcard",
 			Value: "cronjobs=[*],pods=[*,foo],namespaces=[bar,*]",
@@ -212,3 +262,108 @@ func TestLabelsAllowListSet(t *testing.T) {
 		}
 	}
 }
+
+func TestFieldSelectorsSet(t *testing.T) {
+	tests := []struct {
+		Desc   string
+		Value  string
+		Wanted FieldSelectors
+		err    bool
+	}{
+		{
+			Desc:   "empty field selectors",
+			Value:  "",
+			Wanted: FieldSelectors{},
+		},
+		{
+			Desc:  "one resource",
+			Value: "verticalpodautoscalers=[metadata.namespace!=kube-system]",
+			Wanted: FieldSelectors(map[string]string{
+				"verticalpodautoscalers": "metadata.namespace!=kube-system",
+			}),
+		},
+		{
+			Desc:  "two resources with multiple requirements",
+			Value: "verticalpodautoscalers=[metadata.namespace!=kube-system,metadata.name!=foo], pods=[spec.nodeName=node1]",
+			Wanted: FieldSelectors(map[string]string{
+				"verticalpodautoscalers": "metadata.namespace!=kube-system,metadata.name!=foo",
+				"pods":                   "spec.nodeName=node1",
+			}),
+		},
+		{
+			Desc:   "[invalid] missing brackets",
+			Value:  "pods=spec.nodeName=node1",
+			Wanted: FieldSelectors{},
+			err:    true,
+		},
+		{
+			Desc:   "[invalid] no comma between resources",
+			Value:  "pods=[spec.nodeName=node1]services=[metadata.name=foo]",
+			Wanted: FieldSelectors{},
+			err:    true,
+		},
+		{
+			Desc:   "[invalid] trailing comma",
+			Value:  "pods=[spec.nodeName=node1],",
+			Wanted: FieldSelectors{},
+			err:    true,
+		},
+		{
+			Desc:   "[invalid] malformed selector",
+			Value:  "pods=[spec.nodeName]",
+			Wanted: FieldSelectors{},
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		fs := &FieldSelectors{}
+		gotError := fs.Set(test.Value)
+		if (gotError != nil) != test.err || !reflect.DeepEqual(*fs, test.Wanted) {
+			t.Errorf("Test error for Desc: %s\n Want: \n%+v\n Got: \n%#+v\n Got Error: %#v", test.Desc, test.Wanted, *fs, gotError)
+		}
+	}
+}
+
+func TestObjectNamesSet(t *testing.T) {
+	tests := []struct {
+		Desc   string
+		Value  string
+		Wanted ObjectNames
+		err    bool
+	}{
+		{
+			Desc:   "empty object names",
+			Value:  "",
+			Wanted: ObjectNames{},
+		},
+		{
+			Desc:  "two resources",
+			Value: "verticalpodautoscalers=vpa1, pods=pod1",
+			Wanted: ObjectNames(map[string]string{
+				"verticalpodautoscalers": "vpa1",
+				"pods":                   "pod1",
+			}),
+		},
+		{
+			Desc:   "[invalid] missing name",
+			Value:  "verticalpodautoscalers=",
+			Wanted: ObjectNames{},
+			err:    true,
+		},
+		{
+			Desc:   "[invalid] missing resource",
+			Value:  "vpa1",
+			Wanted: ObjectNames{},
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		on := &ObjectNames{}
+		gotError := on.Set(test.Value)
+		if (gotError != nil) != test.err || !reflect.DeepEqual(*on, test.Wanted) {
+			t.Errorf("Test error for Desc: %s\n Want: \n%+v\n Got: \n%#+v\n Got Error: %#v", test.Desc, test.Wanted, *on, gotError)
+		}
+	}
+}
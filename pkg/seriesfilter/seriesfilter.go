@@ -0,0 +1,233 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package seriesfilter parses the selectors of the series to drop from the
+// generated metric families.
+//
+// Selectors are separated by semicolons. Each one consists of a regular
+// expression matching the whole family name and optional predicates in
+// braces, which all have to hold for a series to be dropped:
+//
+//	kube_verticalpodautoscaler_spec_updatepolicy_updatemode{value==0}
+//	kube_pod_.*{namespace="kube-system",container=~"istio-.*"}
+//
+// Labels are compared with =, !=, =~ and !~ against a quoted string, regular
+// expressions matching the whole label value. The value of a series is
+// compared with ==, !=, <, <=, > and >= against a number.
+package seriesfilter
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	generator "k8s.io/kube-state-metrics/v2/pkg/metric_generator"
+)
+
+// selector matches the series of the families whose name matches. The series
+// has to satisfy all predicates.
+type selector struct {
+	name       *regexp.Regexp
+	predicates []func(m *metric.Metric) bool
+}
+
+func (s selector) matches(name string, m *metric.Metric) bool {
+	if !s.name.MatchString(name) {
+		return false
+	}
+	for _, p := range s.predicates {
+		if !p(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse parses the semicolon-separated selectors of the series to drop and
+// returns a filter keeping all other series. It returns nil for an empty
+// expression.
+func Parse(expr string) (generator.MetricFilter, error) {
+	var selectors []selector
+	for _, s := range split(expr, ';') {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		sel, err := parseSelector(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid series selector %q", s)
+		}
+		selectors = append(selectors, sel)
+	}
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+
+	return func(name string, m *metric.Metric) bool {
+		for _, s := range selectors {
+			if s.matches(name, m) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func parseSelector(s string) (selector, error) {
+	name, predicates := s, ""
+	if i := strings.IndexByte(s, '{'); i >= 0 {
+		if !strings.HasSuffix(s, "}") {
+			return selector{}, errors.New("missing closing brace")
+		}
+		name, predicates = s[:i], s[i+1:len(s)-1]
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return selector{}, errors.New("missing metric family name")
+	}
+	re, err := regexp.Compile("^(?:" + name + ")$")
+	if err != nil {
+		return selector{}, err
+	}
+
+	sel := selector{name: re}
+	for _, p := range split(predicates, ',') {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		predicate, err := parsePredicate(p)
+		if err != nil {
+			return selector{}, err
+		}
+		sel.predicates = append(sel.predicates, predicate)
+	}
+	return sel, nil
+}
+
+// labelOperators and valueOperators are ordered so that no operator is
+// preceded by one of its prefixes.
+var (
+	labelOperators = []string{"=~", "!~", "!=", "="}
+	valueOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+)
+
+func parsePredicate(p string) (func(m *metric.Metric) bool, error) {
+	i := strings.IndexAny(p, "=!<>")
+	if i <= 0 {
+		return nil, errors.Errorf("invalid predicate %q, expected a label or value followed by an operator", p)
+	}
+	key, rest := strings.TrimSpace(p[:i]), p[i:]
+
+	// A quoted operand compares a label, which may be called value as well.
+	for _, op := range labelOperators {
+		if !strings.HasPrefix(rest, op) {
+			continue
+		}
+		operand := strings.TrimSpace(rest[len(op):])
+		if !strings.HasPrefix(operand, `"`) {
+			break
+		}
+		value, err := strconv.Unquote(operand)
+		if err != nil {
+			return nil, errors.Errorf("invalid quoted string %s in predicate %q", operand, p)
+		}
+		return labelPredicate(key, op, value)
+	}
+
+	if key != "value" {
+		return nil, errors.Errorf("invalid predicate %q, labels are compared with a quoted string", p)
+	}
+	for _, op := range valueOperators {
+		if !strings.HasPrefix(rest, op) {
+			continue
+		}
+		operand, err := strconv.ParseFloat(strings.TrimSpace(rest[len(op):]), 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid number in predicate %q", p)
+		}
+		return valuePredicate(op, operand), nil
+	}
+	return nil, errors.Errorf("invalid operator in predicate %q", p)
+}
+
+func labelPredicate(label, op, value string) (func(m *metric.Metric) bool, error) {
+	switch op {
+	case "=":
+		return func(m *metric.Metric) bool { return labelValue(m, label) == value }, nil
+	case "!=":
+		return func(m *metric.Metric) bool { return labelValue(m, label) != value }, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + value + ")$")
+	if err != nil {
+		return nil, err
+	}
+	if op == "=~" {
+		return func(m *metric.Metric) bool { return re.MatchString(labelValue(m, label)) }, nil
+	}
+	return func(m *metric.Metric) bool { return !re.MatchString(labelValue(m, label)) }, nil
+}
+
+func valuePredicate(op string, operand float64) func(m *metric.Metric) bool {
+	switch op {
+	case "==":
+		return func(m *metric.Metric) bool { return m.Value == operand }
+	case "!=":
+		return func(m *metric.Metric) bool { return m.Value != operand }
+	case "<":
+		return func(m *metric.Metric) bool { return m.Value < operand }
+	case "<=":
+		return func(m *metric.Metric) bool { return m.Value <= operand }
+	case ">":
+		return func(m *metric.Metric) bool { return m.Value > operand }
+	default:
+		return func(m *metric.Metric) bool { return m.Value >= operand }
+	}
+}
+
+// labelValue returns the value of the label of the metric, which is empty if
+// the metric lacks the label like in PromQL.
+func labelValue(m *metric.Metric, label string) string {
+	for i, key := range m.LabelKeys {
+		if key == label {
+			return m.LabelValues[i]
+		}
+	}
+	return ""
+}
+
+// split splits s at the separator, except within quoted strings.
+func split(s string, sep byte) []string {
+	var (
+		parts  []string
+		start  int
+		quoted bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package seriesfilter
+
+import (
+	"testing"
+
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+func TestParse(t *testing.T) {
+	updateMode := func(mode string, value float64) *metric.Metric {
+		return &metric.Metric{
+			LabelKeys:   []string{"namespace", "verticalpodautoscaler", "update_mode"},
+			LabelValues: []string{"kube-system", "vpa1", mode},
+			Value:       value,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		expr   string
+		family string
+		metric *metric.Metric
+		want   bool
+	}{
+		{
+			name:   "drops series by value",
+			expr:   "kube_verticalpodautoscaler_spec_updatepolicy_updatemode{value==0}",
+			family: "kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+			metric: updateMode("Off", 0),
+			want:   false,
+		},
+		{
+			name:   "keeps series not matching the value",
+			expr:   "kube_verticalpodautoscaler_spec_updatepolicy_updatemode{value==0}",
+			family: "kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+			metric: updateMode("Auto", 1),
+			want:   true,
+		},
+		{
+			name:   "keeps series of other families",
+			expr:   "kube_verticalpodautoscaler_spec_updatepolicy_updatemode{value==0}",
+			family: "kube_verticalpodautoscaler_labels",
+			metric: updateMode("Off", 0),
+			want:   true,
+		},
+		{
+			name:   "matches the whole family name",
+			expr:   "kube_verticalpodautoscaler",
+			family: "kube_verticalpodautoscaler_labels",
+			metric: updateMode("Off", 0),
+			want:   true,
+		},
+		{
+			name:   "drops all series of a family without predicates",
+			expr:   "kube_verticalpodautoscaler_.*",
+			family: "kube_verticalpodautoscaler_labels",
+			metric: updateMode("Off", 1),
+			want:   false,
+		},
+		{
+			name:   "requires all predicates to hold",
+			expr:   `kube_.*{namespace="kube-system",update_mode=~"Auto|Recreate"}`,
+			family: "kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+			metric: updateMode("Off", 1),
+			want:   true,
+		},
+		{
+			name:   "drops series matching all predicates",
+			expr:   `kube_.*{namespace="kube-system",update_mode!~"Auto|Recreate"}`,
+			family: "kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+			metric: updateMode("Off", 1),
+			want:   false,
+		},
+		{
+			name:   "compares missing labels as empty",
+			expr:   `kube_.*{container=""}`,
+			family: "kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+			metric: updateMode("Off", 1),
+			want:   false,
+		},
+		{
+			name:   "drops series matching any selector",
+			expr:   `kube_pod_.* ; kube_verticalpodautoscaler_.*{value<1}`,
+			family: "kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+			metric: updateMode("Off", 0),
+			want:   false,
+		},
+		{
+			name:   "ignores separators in quoted strings",
+			expr:   `kube_.*{verticalpodautoscaler!="a;b,c"}`,
+			family: "kube_verticalpodautoscaler_spec_updatepolicy_updatemode",
+			metric: updateMode("Off", 0),
+			want:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := Parse(test.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := filter(test.family, test.metric); got != test.want {
+				t.Errorf("expected the filter to return %t, got %t", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	filter, err := Parse(" ; ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filter != nil {
+		t.Error("expected no filter for an empty expression")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"{value==0}",
+		"kube_pod_info{value==0",
+		"kube_pod_(",
+		"kube_pod_info{namespace=default}",
+		`kube_pod_info{namespace=~"("}`,
+		"kube_pod_info{value==zero}",
+		"kube_pod_info{value}",
+		"kube_pod_info{value=<1}",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected an error for %q", expr)
+		}
+	}
+}
@@ -18,8 +18,11 @@ package sharding
 
 import (
 	"hash/fnv"
+	"strconv"
+	"sync"
 
 	jump "github.com/dgryski/go-jump"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -27,21 +30,43 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
+// The keys objects are assigned to shards by.
+const (
+	// ByUID spreads the objects across shards by their UID.
+	ByUID = "uid"
+	// ByNamespace assigns all objects of a namespace to the same shard.
+	// Objects without namespace are spread across shards by their UID.
+	ByNamespace = "namespace"
+)
+
 type shardedListWatch struct {
-	sharding *sharding
-	lw       cache.ListerWatcher
+	sharding  *sharding
+	lw        cache.ListerWatcher
+	ownership *ownership
 }
 
 // NewShardedListWatch returns a new shardedListWatch via the cache.ListerWatcher interface.
 // In the case of no sharding needed, it returns the provided cache.ListerWatcher
 func NewShardedListWatch(shard int32, totalShards int, lw cache.ListerWatcher) cache.ListerWatcher {
+	return NewOwnershipShardedListWatch(shard, totalShards, ByUID, lw, nil, "")
+}
+
+// NewOwnershipShardedListWatch returns a new shardedListWatch like
+// NewShardedListWatch, which assigns the objects to shards by the given key,
+// ByUID or ByNamespace, and additionally counts the objects of the resource
+// owned by each shard in the given ownership metric.
+func NewOwnershipShardedListWatch(shard int32, totalShards int, by string, lw cache.ListerWatcher, ownershipVec *prometheus.GaugeVec, resource string) cache.ListerWatcher {
 	// This is an "optimization" as this configuration means no sharding is to
 	// be performed.
 	if shard == 0 && totalShards == 1 {
 		return lw
 	}
 
-	return &shardedListWatch{sharding: &sharding{shard: shard, totalShards: totalShards}, lw: lw}
+	s := &shardedListWatch{sharding: &sharding{shard: shard, totalShards: totalShards, byNamespace: by == ByNamespace}, lw: lw}
+	if ownershipVec != nil {
+		s.ownership = newOwnership(ownershipVec, resource, totalShards)
+	}
+	return s
 }
 
 func (s *shardedListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
@@ -60,16 +85,22 @@ func (s *shardedListWatch) List(options metav1.ListOptions) (runtime.Object, err
 	res := &metav1.List{
 		Items: []runtime.RawExtension{},
 	}
+	owned := make([]float64, s.sharding.totalShards)
 	for _, item := range items {
 		a, err := meta.Accessor(item)
 		if err != nil {
 			return nil, err
 		}
-		if s.sharding.keep(a) {
+		shard := s.sharding.shardOf(a)
+		owned[shard]++
+		if shard == s.sharding.shard {
 			res.Items = append(res.Items, runtime.RawExtension{Object: item})
 		}
 	}
 	res.ListMeta.ResourceVersion = metaObj.GetResourceVersion()
+	if s.ownership != nil {
+		s.ownership.reset(owned)
+	}
 
 	return res, nil
 }
@@ -81,23 +112,84 @@ func (s *shardedListWatch) Watch(options metav1.ListOptions) (watch.Interface, e
 	}
 
 	return watch.Filter(w, func(in watch.Event) (out watch.Event, keep bool) {
+		// Bookmarks do not belong to any shard, but carry the resource
+		// version all shards need to resume their watch from.
+		if in.Type == watch.Bookmark {
+			return in, true
+		}
 		a, err := meta.Accessor(in.Object)
 		if err != nil {
 			// TODO(brancz): needs logging
 			return in, true
 		}
 
-		return in, s.sharding.keep(a)
+		shard := s.sharding.shardOf(a)
+		if s.ownership != nil {
+			switch in.Type {
+			case watch.Added:
+				s.ownership.add(shard, 1)
+			case watch.Deleted:
+				s.ownership.add(shard, -1)
+			}
+		}
+		return in, shard == s.sharding.shard
 	}), nil
 }
 
 type sharding struct {
 	shard       int32
 	totalShards int
+	byNamespace bool
 }
 
 func (s *sharding) keep(o metav1.Object) bool {
+	return s.shardOf(o) == s.shard
+}
+
+// shardOf returns the shard owning the given object.
+func (s *sharding) shardOf(o metav1.Object) int32 {
 	h := fnv.New64a()
-	h.Write([]byte(o.GetUID()))
-	return jump.Hash(h.Sum64(), s.totalShards) == s.shard
+	if s.byNamespace && o.GetNamespace() != "" {
+		h.Write([]byte(o.GetNamespace()))
+	} else {
+		h.Write([]byte(o.GetUID()))
+	}
+	return jump.Hash(h.Sum64(), s.totalShards)
+}
+
+// ownership counts the objects a single list watch has seen per shard. As
+// several list watches may exist for a resource, e.g. one per namespace, the
+// shared gauges are only ever changed by the difference to these counts.
+type ownership struct {
+	mtx    sync.Mutex
+	gauges []prometheus.Gauge
+	owned  []float64
+}
+
+func newOwnership(vec *prometheus.GaugeVec, resource string, totalShards int) *ownership {
+	o := &ownership{
+		gauges: make([]prometheus.Gauge, totalShards),
+		owned:  make([]float64, totalShards),
+	}
+	for i := range o.gauges {
+		o.gauges[i] = vec.WithLabelValues(resource, strconv.Itoa(i), strconv.Itoa(totalShards))
+	}
+	return o
+}
+
+// reset replaces the counts, e.g. after the objects were listed again.
+func (o *ownership) reset(owned []float64) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	for i, n := range owned {
+		o.gauges[i].Add(n - o.owned[i])
+		o.owned[i] = n
+	}
+}
+
+func (o *ownership) add(shard int32, delta float64) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.owned[shard] += delta
+	o.gauges[shard].Add(delta)
 }
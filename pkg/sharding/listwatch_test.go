@@ -17,11 +17,18 @@ limitations under the License.
 package sharding
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestSharding(t *testing.T) {
@@ -54,3 +61,142 @@ func TestSharding(t *testing.T) {
 		t.Fatal("Shard two should not pick up the object.")
 	}
 }
+
+func TestShardingByNamespace(t *testing.T) {
+	configMap := func(namespace, uid string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      uid,
+				Namespace: namespace,
+				UID:       types.UID(uid),
+			},
+		}
+	}
+
+	s := &sharding{
+		totalShards: 8,
+		byNamespace: true,
+	}
+	for _, namespace := range []string{"ns1", "ns2", "ns3"} {
+		want := s.shardOf(configMap(namespace, "uid0"))
+		for i := 1; i < 20; i++ {
+			if got := s.shardOf(configMap(namespace, fmt.Sprintf("uid%d", i))); got != want {
+				t.Fatalf("expected all objects of %s on shard %d, got shard %d", namespace, want, got)
+			}
+		}
+	}
+
+	// Objects without namespace are still spread by their UID.
+	shards := map[int32]struct{}{}
+	for i := 0; i < 20; i++ {
+		shards[s.shardOf(configMap("", fmt.Sprintf("uid%d", i)))] = struct{}{}
+	}
+	if len(shards) < 2 {
+		t.Errorf("expected objects without namespace to be spread across shards, got %v", shards)
+	}
+}
+
+func TestShardOwnership(t *testing.T) {
+	configMap := func(i int) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("configmap%d", i),
+				Namespace: "ns1",
+				UID:       types.UID(fmt.Sprintf("uid%d", i)),
+			},
+		}
+	}
+
+	list := &v1.ConfigMapList{}
+	for i := 0; i < 20; i++ {
+		list.Items = append(list.Items, *configMap(i))
+	}
+	fw := watch.NewFake()
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return list, nil
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return fw, nil
+		},
+	}
+
+	metrics := NewShardingMetrics(prometheus.NewRegistry())
+	slw := NewOwnershipShardedListWatch(1, 3, ByUID, lw, metrics.Ownership, "configmaps")
+
+	owned := func(shard int) float64 {
+		return testutil.ToFloat64(metrics.Ownership.WithLabelValues("configmaps", fmt.Sprint(shard), "3"))
+	}
+	total := func() float64 {
+		return owned(0) + owned(1) + owned(2)
+	}
+
+	res, err := slw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total() != 20 {
+		t.Errorf("expected 20 owned objects across shards, got %v", total())
+	}
+	if n := len(res.(*metav1.List).Items); owned(1) != float64(n) {
+		t.Errorf("expected shard 1 to own %d objects, got %v", n, owned(1))
+	}
+
+	// Listing again must not count the objects twice.
+	if _, err := slw.List(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if total() != 20 {
+		t.Errorf("expected 20 owned objects across shards after relisting, got %v", total())
+	}
+
+	w, err := slw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		fw.Add(configMap(20))
+		fw.Add(configMap(21))
+		fw.Delete(configMap(0))
+		fw.Stop()
+	}()
+	for range w.ResultChan() {
+	}
+	if total() != 21 {
+		t.Errorf("expected 21 owned objects across shards after watch events, got %v", total())
+	}
+}
+
+func TestShardingBookmarks(t *testing.T) {
+	list := &v1.ConfigMapList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}}
+	for shard := int32(0); shard < 3; shard++ {
+		fw := watch.NewFake()
+		lw := &cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				return list, nil
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				if !opts.AllowWatchBookmarks {
+					t.Error("expected the reflector to request bookmarks")
+				}
+				return fw, nil
+			},
+		}
+		slw := NewOwnershipShardedListWatch(shard, 3, ByUID, lw, nil, "configmaps")
+		reflector := cache.NewReflector(slw, &v1.ConfigMap{}, cache.NewStore(cache.MetaNamespaceKeyFunc), 0)
+		stop := make(chan struct{})
+		go reflector.Run(stop)
+
+		// Every shard resumes its watch from the resource version of the
+		// bookmark, although the bookmark object hashes to a single shard.
+		fw.Action(watch.Bookmark, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "5"}})
+		deadline := time.Now().Add(5 * time.Second)
+		for reflector.LastSyncResourceVersion() != "5" {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected shard %d to track the resource version of the bookmark, got %q", shard, reflector.LastSyncResourceVersion())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(stop)
+	}
+}
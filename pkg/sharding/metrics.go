@@ -24,13 +24,18 @@ import (
 const (
 	// LabelOrdinal is name of Prometheus metric label to use in conjunction with kube_state_metrics_shard_ordinal.
 	LabelOrdinal = "shard_ordinal"
+	// LabelTotalShards is name of Prometheus metric label to use in conjunction with kube_state_metrics_shard_ownership.
+	LabelTotalShards = "total_shards"
+	// LabelResource is name of Prometheus metric label to use in conjunction with kube_state_metrics_shard_ownership.
+	LabelResource = "resource"
 )
 
-// Metrics stores the pointers of kube_state_metrics_shard_ordinal
-// and kube_state_metrics_total_shards metrics.
+// Metrics stores the pointers of kube_state_metrics_shard_ordinal,
+// kube_state_metrics_total_shards and kube_state_metrics_shard_ownership metrics.
 type Metrics struct {
-	Ordinal *prometheus.GaugeVec
-	Total   prometheus.Gauge
+	Ordinal   *prometheus.GaugeVec
+	Total     prometheus.Gauge
+	Ownership *prometheus.GaugeVec
 }
 
 // NewShardingMetrics takes in a prometheus registry and initializes
@@ -49,5 +54,11 @@ func NewShardingMetrics(r prometheus.Registerer) *Metrics {
 				Help: "Number of total shards this instance is aware of",
 			},
 		),
+		Ownership: promauto.With(r).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kube_state_metrics_shard_ownership",
+				Help: "Number of objects of a resource owned by each shard, as seen by this instance",
+			}, []string{LabelResource, LabelOrdinal, LabelTotalShards},
+		),
 	}
 }
@@ -17,6 +17,8 @@ limitations under the License.
 package watch
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,15 +27,18 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
-// ListWatchMetrics stores the pointers of kube_state_metrics_[list|watch]_total metrics.
+// ListWatchMetrics stores the pointers of kube_state_metrics_[list|watch]_total
+// and kube_state_metrics_watch_errors_total metrics.
 type ListWatchMetrics struct {
-	WatchTotal *prometheus.CounterVec
-	ListTotal  *prometheus.CounterVec
+	WatchTotal       *prometheus.CounterVec
+	ListTotal        *prometheus.CounterVec
+	WatchErrorsTotal *prometheus.CounterVec
 }
 
 // NewListWatchMetrics takes in a prometheus registry and initializes
-// and registers the kube_state_metrics_list_total and
-// kube_state_metrics_watch_total metrics. It returns those registered metrics.
+// and registers the kube_state_metrics_list_total,
+// kube_state_metrics_watch_total and kube_state_metrics_watch_errors_total
+// metrics. It returns those registered metrics.
 func NewListWatchMetrics(r prometheus.Registerer) *ListWatchMetrics {
 	return &ListWatchMetrics{
 		WatchTotal: promauto.With(r).NewCounterVec(
@@ -50,6 +55,13 @@ func NewListWatchMetrics(r prometheus.Registerer) *ListWatchMetrics {
 			},
 			[]string{"result", "resource"},
 		),
+		WatchErrorsTotal: promauto.With(r).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kube_state_metrics_watch_errors_total",
+				Help: "Number of failed resource watches and error events received on resource watches in kube-state-metrics",
+			},
+			[]string{"resource"},
+		),
 	}
 }
 
@@ -101,9 +113,56 @@ func (i *InstrumentedListerWatcher) Watch(options metav1.ListOptions) (res watch
 	res, err = i.lw.Watch(options)
 	if err != nil {
 		i.metrics.WatchTotal.WithLabelValues("error", i.resource).Inc()
+		i.metrics.WatchErrorsTotal.WithLabelValues(i.resource).Inc()
 		return
 	}
 
 	i.metrics.WatchTotal.WithLabelValues("success", i.resource).Inc()
-	return
+	return newInstrumentedWatch(res, i.metrics.WatchErrorsTotal.WithLabelValues(i.resource)), nil
+}
+
+// instrumentedWatch forwards the events of a watch.Interface and counts the
+// error events, which usually make the reflector relist.
+type instrumentedWatch struct {
+	watch.Interface
+	result   chan watch.Event
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newInstrumentedWatch(w watch.Interface, errors prometheus.Counter) watch.Interface {
+	iw := &instrumentedWatch{
+		Interface: w,
+		result:    make(chan watch.Event),
+		done:      make(chan struct{}),
+	}
+
+	go func() {
+		defer close(iw.result)
+		for event := range w.ResultChan() {
+			if event.Type == watch.Error {
+				errors.Inc()
+			}
+			select {
+			case iw.result <- event:
+			case <-iw.done:
+				return
+			}
+		}
+	}()
+
+	return iw
+}
+
+// ResultChan returns the forwarded events.
+func (w *instrumentedWatch) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+// Stop stops the underlying watch and the forwarding of its events.
+func (w *instrumentedWatch) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		w.Interface.Stop()
+	})
 }
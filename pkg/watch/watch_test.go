@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestInstrumentedListerWatcherWatchErrors(t *testing.T) {
+	metrics := NewListWatchMetrics(prometheus.NewRegistry())
+	fake := watch.NewFake()
+	var failWatch bool
+	lw := NewInstrumentedListerWatcher(&cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return &v1.PodList{}, nil
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			if failWatch {
+				return nil, errors.New("connection refused")
+			}
+			return fake, nil
+		},
+	}, metrics, "*v1.Pod", false)
+	watchErrors := metrics.WatchErrorsTotal.WithLabelValues("*v1.Pod")
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected watch error: %v", err)
+	}
+	go func() {
+		fake.Add(&v1.Pod{})
+		fake.Error(&metav1.Status{Reason: metav1.StatusReasonExpired})
+	}()
+	for _, want := range []watch.EventType{watch.Added, watch.Error} {
+		if event := <-w.ResultChan(); event.Type != want {
+			t.Fatalf("expected %s event, got %s", want, event.Type)
+		}
+	}
+	if v := testutil.ToFloat64(watchErrors); v != 1 {
+		t.Errorf("expected 1 watch error after an error event, got %v", v)
+	}
+
+	// Stopping must not leave the forwarding blocked on an event nobody
+	// receives anymore.
+	fake.Add(&v1.Pod{})
+	w.Stop()
+	w.Stop()
+	for range w.ResultChan() {
+	}
+
+	failWatch = true
+	if _, err := lw.Watch(metav1.ListOptions{}); err == nil {
+		t.Fatal("expected watch to fail")
+	}
+	if v := testutil.ToFloat64(watchErrors); v != 2 {
+		t.Errorf("expected 2 watch errors after a failed watch, got %v", v)
+	}
+}